@@ -0,0 +1,314 @@
+package accounting
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/gpmn/sheep/logger"
+	"github.com/gpmn/sheep/portfolio"
+	"github.com/gpmn/sheep/proto"
+)
+
+// Method 成本计算方法
+type Method int
+
+const (
+	// FIFO 先进先出, 平仓时按开仓顺序消耗持仓批次
+	FIFO Method = iota
+	// Average 平均成本, 所有持仓合并为一个批次
+	Average
+)
+
+const epsilon = 1e-12
+
+// lot : 一个持仓批次, amount 为正表示多头, 为负表示空头
+type lot struct {
+	amount float64
+	price  float64
+}
+
+// position : 某策略某交易对的持仓
+type position struct {
+	base      string
+	quote     string
+	lots      []lot
+	realised  float64            // 计价币
+	feeQuote  float64            // 以计价币或基础币支付的手续费, 折算为计价币
+	otherFees map[string]float64 // 以其他币种支付的手续费
+	fills     int
+}
+
+func (p *position) amount() float64 {
+	var sum float64
+	for _, l := range p.lots {
+		sum += l.amount
+	}
+	return sum
+}
+
+func (p *position) cost() float64 {
+	var sum float64
+	for _, l := range p.lots {
+		sum += l.amount * l.price
+	}
+	return sum
+}
+
+// apply 记录一次成交, signed 为带方向的成交数量
+func (p *position) apply(method Method, signed, price float64) {
+	// 先平掉方向相反的批次
+	for len(p.lots) > 0 && signed != 0 && (p.lots[0].amount > 0) != (signed > 0) {
+		l := &p.lots[0]
+		closed := math.Min(math.Abs(l.amount), math.Abs(signed))
+		if l.amount > 0 {
+			p.realised += (price - l.price) * closed
+			l.amount -= closed
+			signed += closed
+		} else {
+			p.realised += (l.price - price) * closed
+			l.amount += closed
+			signed -= closed
+		}
+		if math.Abs(l.amount) <= epsilon {
+			p.lots = p.lots[1:]
+		}
+		if math.Abs(signed) <= epsilon {
+			signed = 0
+		}
+	}
+	if signed == 0 {
+		return
+	}
+
+	if method == Average && len(p.lots) > 0 {
+		l := &p.lots[0]
+		total := l.amount + signed
+		l.price = (l.amount*l.price + signed*price) / total
+		l.amount = total
+		return
+	}
+	p.lots = append(p.lots, lot{amount: signed, price: price})
+}
+
+// Book : 成本和盈亏账簿, 按策略标签和交易对记录成交, 并发安全.
+// 同一成交不要重复记录, 来自推送和历史订单的成交需由调用方去重
+type Book struct {
+	method    Method
+	reporting string
+	positions map[string]map[string]*position // tag -> symbol -> position
+	mutex     sync.Mutex
+
+	// 日志, 为nil时不输出, 在使用推送监听器之前设置
+	Logger logger.Logger
+}
+
+// NewBook 创建账簿, reporting 为报告货币, 如 usdt
+func NewBook(method Method, reporting string) *Book {
+	return &Book{
+		method:    method,
+		reporting: strings.ToLower(reporting),
+		positions: make(map[string]map[string]*position),
+	}
+}
+
+func (b *Book) log() logger.Logger {
+	return logger.OrNop(b.Logger)
+}
+
+// Record 记录策略 tag 的一次成交
+func (b *Book) Record(tag string, fill proto.Fill) error {
+	symbol := strings.ToLower(fill.Symbol)
+	base, quote, ok := portfolio.SplitSymbol(symbol)
+	if !ok {
+		return portfolio.ErrUnknownSymbol
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	positions, ok := b.positions[tag]
+	if !ok {
+		positions = make(map[string]*position)
+		b.positions[tag] = positions
+	}
+	p, ok := positions[symbol]
+	if !ok {
+		p = &position{base: base, quote: quote, otherFees: make(map[string]float64)}
+		positions[symbol] = p
+	}
+
+	signed := fill.Amount
+	if fill.Side == proto.TradeSideSell {
+		signed = -signed
+	}
+	p.apply(b.method, signed, fill.Price)
+	p.fills++
+
+	switch strings.ToLower(fill.FeeCurrency) {
+	case "":
+	case quote:
+		p.feeQuote += fill.Fee
+	case base:
+		// 基础币手续费从持仓中扣除, 相当于按成交价减少持仓, 手续费本身仍计入 Fees
+		p.apply(b.method, -fill.Fee, fill.Price)
+		p.feeQuote += fill.Fee * fill.Price
+	default:
+		p.otherFees[strings.ToLower(fill.FeeCurrency)] += fill.Fee
+	}
+	return nil
+}
+
+// RecordOrders 把历史订单的成交部分作为成交记录, 成交价为已成交金额除以已成交数量, 不含手续费.
+// 交易所不提供已成交金额时限价单按订单价格记录, 市价单被跳过, 返回记录的订单数
+func (b *Book) RecordOrders(tag string, orders []proto.Order) (int, error) {
+	n := 0
+	for _, order := range orders {
+		if order.FieldAmount <= 0 {
+			continue
+		}
+		price := order.FieldCashAmount / order.FieldAmount
+		if order.FieldCashAmount <= 0 {
+			if strings.HasSuffix(order.Type, "market") {
+				continue
+			}
+			price = order.Price
+		}
+		side := proto.TradeSideBuy
+		if strings.HasPrefix(order.Type, "sell") {
+			side = proto.TradeSideSell
+		}
+		err := b.Record(tag, proto.Fill{
+			OrderID: order.ID,
+			Symbol:  order.Symbol,
+			Side:    side,
+			Price:   price,
+			Amount:  order.FieldAmount,
+			TS:      order.CreatedSec * 1000,
+		})
+		if err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+// PnL : 某策略某交易对的盈亏, 金额单位为计价币, Reporting 结尾的字段为报告货币
+type PnL struct {
+	Tag        string  `json:"tag"`
+	Symbol     string  `json:"symbol"` // 按策略汇总时为空
+	Quote      string  `json:"quote"`
+	Position   float64 `json:"position"`   // 持仓数量, 空头为负
+	AvgCost    float64 `json:"avg_cost"`   // 持仓平均成本
+	CostBasis  float64 `json:"cost_basis"` // 持仓成本
+	Mark       float64 `json:"mark"`       // 标记价格, 没有价格时为0
+	Realised   float64 `json:"realised"`
+	Unrealised float64 `json:"unrealised"`
+	Fees       float64 `json:"fees"` // 以计价币或基础币支付的手续费
+	Fills      int     `json:"fills"`
+
+	Reporting           string  `json:"reporting"`
+	Converted           bool    `json:"converted"` // 是否所有金额都找到了报告货币汇率
+	CostBasisReporting  float64 `json:"cost_basis_reporting"`
+	RealisedReporting   float64 `json:"realised_reporting"`
+	UnrealisedReporting float64 `json:"unrealised_reporting"`
+	FeesReporting       float64 `json:"fees_reporting"` // 包括以其他币种支付的手续费
+	NetReporting        float64 `json:"net_reporting"`  // 已实现 + 未实现 - 手续费
+}
+
+// rate 币种到报告货币的汇率, 查找 currency+reporting 或 reporting+currency 交易对
+func (b *Book) rate(prices map[string]float64, currency string) (float64, bool) {
+	if currency == b.reporting {
+		return 1, true
+	}
+	if p, ok := prices[currency+b.reporting]; ok && p > 0 {
+		return p, true
+	}
+	if p, ok := prices[b.reporting+currency]; ok && p > 0 {
+		return 1 / p, true
+	}
+	return 0, false
+}
+
+// Report 按当前价格计算所有策略和交易对的盈亏, prices 为交易对到最新价的映射,
+// 交易对为小写无分隔符, 如 btcusdt. 报告货币金额按当前汇率折算. 结果按策略和交易对排序
+func (b *Book) Report(prices map[string]float64) []PnL {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	var res []PnL
+	for tag, positions := range b.positions {
+		for symbol, p := range positions {
+			pnl := PnL{
+				Tag:       tag,
+				Symbol:    symbol,
+				Quote:     p.quote,
+				Position:  p.amount(),
+				CostBasis: p.cost(),
+				Realised:  p.realised,
+				Fees:      p.feeQuote,
+				Fills:     p.fills,
+				Reporting: b.reporting,
+				Converted: true,
+			}
+			if math.Abs(pnl.Position) > epsilon {
+				pnl.AvgCost = pnl.CostBasis / pnl.Position
+			}
+			if mark, ok := prices[symbol]; ok {
+				pnl.Mark = mark
+				pnl.Unrealised = mark*pnl.Position - pnl.CostBasis
+			} else if math.Abs(pnl.Position) > epsilon {
+				pnl.Converted = false
+			}
+
+			rate, ok := b.rate(prices, p.quote)
+			if !ok {
+				pnl.Converted = false
+			}
+			pnl.CostBasisReporting = pnl.CostBasis * rate
+			pnl.RealisedReporting = pnl.Realised * rate
+			pnl.UnrealisedReporting = pnl.Unrealised * rate
+			pnl.FeesReporting = pnl.Fees * rate
+			for currency, fee := range p.otherFees {
+				r, ok := b.rate(prices, currency)
+				if !ok {
+					pnl.Converted = false
+				}
+				pnl.FeesReporting += fee * r
+			}
+			pnl.NetReporting = pnl.RealisedReporting + pnl.UnrealisedReporting - pnl.FeesReporting
+			res = append(res, pnl)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Tag != res[j].Tag {
+			return res[i].Tag < res[j].Tag
+		}
+		return res[i].Symbol < res[j].Symbol
+	})
+	return res
+}
+
+// ByTag 按策略汇总报告货币金额, 计价币金额和持仓不汇总
+func ByTag(report []PnL) []PnL {
+	var res []PnL
+	index := make(map[string]int)
+	for _, pnl := range report {
+		i, ok := index[pnl.Tag]
+		if !ok {
+			i = len(res)
+			index[pnl.Tag] = i
+			res = append(res, PnL{Tag: pnl.Tag, Reporting: pnl.Reporting, Converted: true})
+		}
+		sum := &res[i]
+		sum.Fills += pnl.Fills
+		sum.Converted = sum.Converted && pnl.Converted
+		sum.CostBasisReporting += pnl.CostBasisReporting
+		sum.RealisedReporting += pnl.RealisedReporting
+		sum.UnrealisedReporting += pnl.UnrealisedReporting
+		sum.FeesReporting += pnl.FeesReporting
+		sum.NetReporting += pnl.NetReporting
+	}
+	return res
+}
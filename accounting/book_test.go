@@ -0,0 +1,192 @@
+package accounting
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+
+	"github.com/gpmn/sheep/binance"
+	"github.com/gpmn/sheep/huobi"
+	"github.com/gpmn/sheep/mockserver"
+	"github.com/gpmn/sheep/proto"
+)
+
+func near(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func fill(symbol, side string, price, amount float64) proto.Fill {
+	return proto.Fill{Symbol: symbol, Side: side, Price: price, Amount: amount}
+}
+
+func record(t *testing.T, b *Book, tag string, fills ...proto.Fill) {
+	for _, f := range fills {
+		if err := b.Record(tag, f); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestMethods(t *testing.T) {
+	prices := map[string]float64{"btcusdt": 300}
+	for _, c := range []struct {
+		method     Method
+		realised   float64
+		unrealised float64
+	}{
+		{FIFO, 150, 100},
+		{Average, 100, 150},
+	} {
+		b := NewBook(c.method, "usdt")
+		record(t, b, "grid",
+			fill("btcusdt", proto.TradeSideBuy, 100, 1),
+			fill("btcusdt", proto.TradeSideBuy, 200, 1),
+			fill("btcusdt", proto.TradeSideSell, 250, 1),
+		)
+		report := b.Report(prices)
+		if len(report) != 1 {
+			t.Fatalf("report %+v", report)
+		}
+		pnl := report[0]
+		if !near(pnl.Position, 1) || !near(pnl.Realised, c.realised) || !near(pnl.Unrealised, c.unrealised) || !pnl.Converted {
+			t.Errorf("method %d : %+v", c.method, pnl)
+		}
+	}
+}
+
+func TestShortAndFees(t *testing.T) {
+	b := NewBook(FIFO, "USDT")
+	sell := fill("btcusdt", proto.TradeSideSell, 100, 1)
+	sell.Fee, sell.FeeCurrency = 0.1, "usdt"
+	buy := fill("btcusdt", proto.TradeSideBuy, 90, 2)
+	buy.Fee, buy.FeeCurrency = 0.002, "btc"
+	bnb := fill("btcusdt", proto.TradeSideSell, 95, 1)
+	bnb.Fee, bnb.FeeCurrency = 0.1, "bnb"
+	record(t, b, "mm", sell, buy, bnb)
+
+	pnl := b.Report(map[string]float64{"btcusdt": 95, "bnbusdt": 20})[0]
+	// 空头平仓 10, 扣除 0.002 btc 手续费后多头 0.998 平仓 4.99, 剩余空头 0.002
+	if !near(pnl.Position, -0.002) || !near(pnl.Realised, 14.99) || !near(pnl.Fees, 0.1+0.18) {
+		t.Fatalf("pnl %+v", pnl)
+	}
+	if !near(pnl.FeesReporting, 0.28+2) || !near(pnl.NetReporting, 14.99-2.28) || !pnl.Converted {
+		t.Fatalf("pnl %+v", pnl)
+	}
+
+	// 缺少 bnb 汇率
+	if pnl := b.Report(map[string]float64{"btcusdt": 95})[0]; pnl.Converted {
+		t.Fatalf("pnl %+v", pnl)
+	}
+}
+
+func TestBaseFee(t *testing.T) {
+	b := NewBook(FIFO, "usdt")
+	buy := fill("btcusdt", proto.TradeSideBuy, 100, 1)
+	buy.Fee, buy.FeeCurrency = 0.001, "btc"
+	record(t, b, "a", buy)
+
+	// 实际持有 0.999 btc, 支付 100 usdt
+	pnl := b.Report(map[string]float64{"btcusdt": 110})[0]
+	if !near(pnl.Position, 0.999) || !near(pnl.AvgCost, 100) || !near(pnl.NetReporting, 0.999*110-100) {
+		t.Fatalf("pnl %+v", pnl)
+	}
+}
+
+func TestReportingCurrency(t *testing.T) {
+	b := NewBook(Average, "usdt")
+	record(t, b, "a", fill("ethbtc", proto.TradeSideBuy, 0.05, 2))
+	record(t, b, "b", fill("btcusdt", proto.TradeSideBuy, 100, 1))
+	n, err := b.RecordOrders("b", []proto.Order{
+		{ID: "1", Symbol: "btcusdt", Type: proto.OrderPlaceTypeSellLimit, Price: 105, FieldAmount: 0.5, FieldCashAmount: 55},
+		{ID: "2", Symbol: "btcusdt", Type: proto.OrderPlaceTypeBuyMarket, FieldAmount: 100},
+		{ID: "3", Symbol: "btcusdt", Type: proto.OrderPlaceTypeBuyMarket, Amount: 120, FieldAmount: 0.4, FieldCashAmount: 48},
+		{ID: "4", Symbol: "btcusdt", Type: proto.OrderPlaceTypeSellLimit, Price: 120, FieldAmount: 0.4},
+	})
+	if err != nil || n != 3 {
+		t.Fatalf("recorded %d, err %v", n, err)
+	}
+
+	report := b.Report(map[string]float64{"ethbtc": 0.06, "btcusdt": 300})
+	if len(report) != 2 || report[0].Tag != "a" {
+		t.Fatalf("report %+v", report)
+	}
+	if !near(report[0].Unrealised, 0.02) || !near(report[0].UnrealisedReporting, 6) || !near(report[0].CostBasisReporting, 30) {
+		t.Fatalf("ethbtc %+v", report[0])
+	}
+	tags := ByTag(report)
+	if len(tags) != 2 || !near(tags[1].NetReporting, 5+100) {
+		t.Fatalf("tags %+v", tags)
+	}
+}
+
+// 交易所文档中的订单和推送样例, 改为已成交的市价单
+const (
+	huobiOrder     = `{"status":"ok","data":{"id":59378,"symbol":"ethusdt","account-id":100009,"amount":"10.1000000000","price":"0.0","created-at":1494901162595,"type":"sell-market","field-amount":"10.1000000000","field-cash-amount":"1011.0100000000","field-fees":"2.0220200000","finished-at":1494901400468,"user-id":1000,"source":"api","state":"filled","canceled-at":0,"exchange":"huobi","batch":""}}`
+	binanceOrder   = `{"symbol":"LTCBTC","orderId":1,"clientOrderId":"myOrder1","price":"0.0","origQty":"2.0","executedQty":"2.0","cummulativeQuoteQty":"0.19","status":"FILLED","timeInForce":"GTC","type":"MARKET","side":"BUY","stopPrice":"0.0","icebergQty":"0.0","time":1499827319559}`
+	huobiOrderPush = `{"op":"notify","topic":"orders.htusdt","ts":1522856623232,"data":{"seq-id":94984,"order-id":2039498445,"symbol":"htusdt","account-id":100077,"order-amount":"5.000000000000000000","order-price":"1.662100000000000000","created-at":1522858623622,"order-type":"buy-limit","order-source":"api","order-state":"filled","role":"taker","price":"1.662100000000000000","filled-amount":"5.000000000000000000","unfilled-amount":"0.000000000000000000","filled-cash-amount":"8.310500000000000000","filled-fees":"0.010000000000000000"}}`
+	binanceReport  = `{"e":"executionReport","E":1499405658700,"s":"LTCBTC","c":"mUvoqJxFIILMdfAW5iGSOW","S":"SELL","o":"LIMIT","f":"GTC","q":"1.00000000","p":"0.10000000","P":"0.00000000","F":"0.00000000","g":-1,"C":"","x":"TRADE","X":"FILLED","r":"NONE","i":2,"l":"1.00000000","z":"1.00000000","L":"0.10000000","n":"0.00010000","N":"BTC","T":1499405658699,"t":1234,"I":8641990,"w":false,"m":true,"M":true,"O":1499405658657,"Z":"0.10000000","Y":"0.10000000","Q":"0.00000000"}`
+)
+
+func TestExchangeFills(t *testing.T) {
+	s := mockserver.NewHuobi("ak", "sk")
+	defer s.Close()
+	defer func(host string) { huobi.Host = host }(huobi.Host)
+	huobi.Host = s.URL
+	s.Handle("GET", "/v1/order/orders/59378", json.RawMessage(huobiOrder))
+
+	h, err := huobi.NewHuobi("ak", "sk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	order, err := h.GetOrderInfo(&proto.OrderInfoParams{OrderID: "59378"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var status binance.OrderStatus
+	if err := json.Unmarshal([]byte(binanceOrder), &status); err != nil {
+		t.Fatal(err)
+	}
+
+	b := NewBook(Average, "usdt")
+	n, err := b.RecordOrders("a", []proto.Order{*order, binance.TransOrderStatus(&status)})
+	if err != nil || n != 2 {
+		t.Fatalf("recorded %d, err %v", n, err)
+	}
+
+	var od huobi.OrderUpdate
+	if err := json.Unmarshal([]byte(huobiOrderPush), &od); err != nil {
+		t.Fatal(err)
+	}
+	b.HuobiOrderListener("a")("htusdt", &od)
+	var report binance.WsExecutionReport
+	if err := json.Unmarshal([]byte(binanceReport), &report); err != nil {
+		t.Fatal(err)
+	}
+	b.BinanceExecutionListener("a")(&report)
+
+	pnls := b.Report(nil)
+	if len(pnls) != 3 {
+		t.Fatalf("report %+v", pnls)
+	}
+	for _, c := range []struct {
+		symbol   string
+		position float64
+		avgCost  float64
+		realised float64
+		fills    int
+	}{
+		{"ethusdt", -10.1, 100.1, 0, 1},
+		{"htusdt", 4.99, 1.6621, 0, 1},
+		{"ltcbtc", 1, 0.095, 0.005, 2},
+	} {
+		var pnl PnL
+		for _, p := range pnls {
+			if p.Symbol == c.symbol {
+				pnl = p
+			}
+		}
+		if !near(pnl.Position, c.position) || !near(pnl.AvgCost, c.avgCost) || !near(pnl.Realised, c.realised) || pnl.Fills != c.fills {
+			t.Fatalf("%s : pnl %+v", c.symbol, pnl)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package accounting
+
+import (
+	"github.com/gpmn/sheep/binance"
+	"github.com/gpmn/sheep/huobi"
+	"github.com/gpmn/sheep/logger"
+	"github.com/gpmn/sheep/portfolio"
+)
+
+// HuobiOrderListener 火币订单推送监听器, 推送中的成交记入策略 tag, 用于 huobi.Huobi.SetOrderListener
+func (b *Book) HuobiOrderListener(tag string) huobi.OrderListener {
+	return func(symbol string, od *huobi.OrderUpdate) {
+		base, quote, ok := portfolio.SplitSymbol(od.Order.Symbol)
+		if !ok {
+			b.log().Warn("Book.HuobiOrderListener - unknown symbol", logger.F("symbol", od.Order.Symbol))
+			return
+		}
+		fill, ok := huobi.TransOrderFill(od, base, quote)
+		if !ok {
+			return
+		}
+		if err := b.Record(tag, fill); err != nil {
+			b.log().Warn("Book.HuobiOrderListener - Record failed", logger.Err(err))
+		}
+	}
+}
+
+// BinanceExecutionListener 币安执行报告监听器, 成交记入策略 tag, 用于 binance.Binance.SetExecutionReportListener
+func (b *Book) BinanceExecutionListener(tag string) binance.ExecutionReportListener {
+	return func(report *binance.WsExecutionReport) {
+		fill, ok := binance.TransExecutionFill(report)
+		if !ok {
+			return
+		}
+		if err := b.Record(tag, fill); err != nil {
+			b.log().Warn("Book.BinanceExecutionListener - Record failed", logger.Err(err))
+		}
+	}
+}
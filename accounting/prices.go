@@ -0,0 +1,48 @@
+package accounting
+
+import (
+	"github.com/gpmn/sheep/binance"
+	"github.com/gpmn/sheep/huobi"
+)
+
+// PriceSource 查询交易对最新价, 交易对为小写无分隔符
+type PriceSource func() (map[string]float64, error)
+
+// HuobiPrices 火币所有交易对的最新价
+func HuobiPrices(h *huobi.Huobi) PriceSource {
+	return func() (map[string]float64, error) {
+		tickers, err := h.GetTickers()
+		if err != nil {
+			return nil, err
+		}
+		return huobi.TransTickers(tickers), nil
+	}
+}
+
+// BinancePrices 币安所有交易对的最新价
+func BinancePrices(b *binance.Binance) PriceSource {
+	return func() (map[string]float64, error) {
+		prices, err := b.GetAllPrices()
+		if err != nil {
+			return nil, err
+		}
+		return binance.TransPrices(prices), nil
+	}
+}
+
+// MergePrices 依次查询多个价格源并合并, 同一交易对以先出现的为准; 任一价格源失败时返回错误
+func MergePrices(sources ...PriceSource) (map[string]float64, error) {
+	res := make(map[string]float64)
+	for _, source := range sources {
+		prices, err := source()
+		if err != nil {
+			return nil, err
+		}
+		for symbol, price := range prices {
+			if _, ok := res[symbol]; !ok {
+				res[symbol] = price
+			}
+		}
+	}
+	return res, nil
+}
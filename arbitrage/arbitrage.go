@@ -0,0 +1,261 @@
+package arbitrage
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gpmn/sheep/logger"
+	"github.com/gpmn/sheep/proto"
+)
+
+// Venue : 参与扫描的交易所, 同一个 Scanner 的各交易所交易同一个币对
+type Venue struct {
+	Exchange string  // 交易所标识, 和行情推送的 exchange 一致
+	Symbol   string  // 交易所自己格式的交易对, 如 btcusdt, btc_usdt
+	TakerFee float64 // 吃单手续费率, 0.002 表示 0.2%
+	// 从该交易所提币的手续费, 分别以基础币和计价币计.
+	// 在这里买入后要把基础币提到卖出的交易所, 在这里卖出后要把计价币提回买入的交易所
+	WithdrawBase  float64
+	WithdrawQuote float64
+	MaxAge        time.Duration // 超过该时间未更新视为过期, 不参与扫描; 0 表示不检查
+	Tickers       TickerFunc    // 非nil时 Start 轮询最优买卖价, 否则使用 OnDepth 推送的深度
+}
+
+// Config : 扫描参数
+type Config struct {
+	Pair      string // 统一的币对名称, 如 btc/usdt, 只用于标识推送
+	Venues    []Venue
+	MaxSize   float64 // 单次机会的最大数量(基础币), 如受余额限制; 0 表示不限
+	MinProfit float64 // 扣除手续费和提币成本后的最小利润(计价币), 低于该值不推送
+	MinSpread float64 // 最小利润率, Profit/买入金额
+}
+
+// Opportunity : 在 Buy 买入, 在 Sell 卖出 Size 数量的套利机会, 价格都含手续费
+type Opportunity struct {
+	Pair      string    `json:"pair"`
+	Buy       string    `json:"buy"`
+	Sell      string    `json:"sell"`
+	Size      float64   `json:"size"`       // 可执行数量, 两边深度都能成交
+	BuyPrice  float64   `json:"buy_price"`  // 含手续费的买入均价
+	SellPrice float64   `json:"sell_price"` // 含手续费的卖出均价
+	BuyLimit  float64   `json:"buy_limit"`  // 买入吃到的最高挂单价, 可作为限价
+	SellLimit float64   `json:"sell_limit"` // 卖出吃到的最低挂单价, 可作为限价
+	Cost      float64   `json:"cost"`       // 提币成本(计价币)
+	Profit    float64   `json:"profit"`     // 净利润(计价币)
+	Spread    float64   `json:"spread"`     // 利润率, Profit/(BuyPrice*Size)
+	Time      time.Time `json:"time"`
+}
+
+// Listener 套利机会监听器
+type Listener func(o *Opportunity)
+
+type venue struct {
+	Venue
+	depth   *proto.Depth
+	updated time.Time
+}
+
+// Scanner : 监控多个交易所同一币对的深度, 发现套利机会时通知监听器, 自己不下单.
+// 每次深度更新后重新计算包含该交易所的买卖组合
+type Scanner struct {
+	config   Config
+	venues   []*venue
+	listener Listener
+	stop     chan struct{}
+	mutex    sync.RWMutex
+
+	// 日志, 为nil时不输出, 在 Start 之前设置
+	Logger logger.Logger
+}
+
+// New 创建扫描器
+func New(config Config) *Scanner {
+	s := &Scanner{config: config}
+	for _, v := range config.Venues {
+		v.Symbol = strings.ToLower(v.Symbol)
+		s.venues = append(s.venues, &venue{Venue: v})
+	}
+	return s
+}
+
+func (s *Scanner) log() logger.Logger {
+	return logger.OrNop(s.Logger)
+}
+
+// SetListener 设置套利机会监听器
+func (s *Scanner) SetListener(listener Listener) {
+	s.mutex.Lock()
+	s.listener = listener
+	s.mutex.Unlock()
+}
+
+// OnDepth 深度推送, 可以作为 feed.Feed 或 config.Runtime.OpenFeeds 的深度监听器, 不是本扫描器的交易对时忽略
+func (s *Scanner) OnDepth(exchange, symbol string, depth *proto.Depth) {
+	symbol = strings.ToLower(symbol)
+	s.mutex.Lock()
+	var updated *venue
+	for _, v := range s.venues {
+		if v.Exchange == exchange && v.Symbol == symbol {
+			v.depth, v.updated = depth, time.Now()
+			updated = v
+			break
+		}
+	}
+	listener := s.listener
+	s.mutex.Unlock()
+	if updated == nil || listener == nil {
+		return
+	}
+	for _, o := range s.scan(updated) {
+		o := o
+		listener(&o)
+	}
+}
+
+// Scan 计算当前所有买卖组合, 按利润从高到低返回满足条件的机会
+func (s *Scanner) Scan() []Opportunity {
+	return s.scan(nil)
+}
+
+// scan only 非nil时只计算包含该交易所的组合
+func (s *Scanner) scan(only *venue) []Opportunity {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	now := time.Now()
+	var res []Opportunity
+	for _, buy := range s.venues {
+		for _, sell := range s.venues {
+			if buy == sell || (only != nil && buy != only && sell != only) {
+				continue
+			}
+			if buy.stale(now) || sell.stale(now) {
+				continue
+			}
+			if o, ok := s.evaluate(buy, sell); ok {
+				o.Time = now
+				res = append(res, o)
+			}
+		}
+	}
+	sort.SliceStable(res, func(i, j int) bool { return res[i].Profit > res[j].Profit })
+	return res
+}
+
+func (v *venue) stale(now time.Time) bool {
+	return v.depth == nil || (v.MaxAge > 0 && now.Sub(v.updated) > v.MaxAge)
+}
+
+// evaluate 同时吃 buy 的卖盘和 sell 的买盘, 直到含手续费的卖价不高于买价或达到 MaxSize.
+// 提币成本和数量无关, 所以这样得到的数量利润最大
+func (s *Scanner) evaluate(buy, sell *venue) (Opportunity, bool) {
+	o := Opportunity{Pair: s.config.Pair, Buy: buy.Exchange, Sell: sell.Exchange}
+	asks, bids := buy.depth.Asks, sell.depth.Bids
+	var i, j int
+	var askLeft, bidLeft float64
+	if len(asks) > 0 {
+		askLeft = asks[0].Amount
+	}
+	if len(bids) > 0 {
+		bidLeft = bids[0].Amount
+	}
+	var cost, income float64
+	for i < len(asks) && j < len(bids) {
+		if s.config.MaxSize > 0 && o.Size >= s.config.MaxSize {
+			break
+		}
+		ask, bid := asks[i].Price*(1+buy.TakerFee), bids[j].Price*(1-sell.TakerFee)
+		if bid <= ask {
+			break
+		}
+		amount := askLeft
+		if bidLeft < amount {
+			amount = bidLeft
+		}
+		if s.config.MaxSize > 0 && o.Size+amount > s.config.MaxSize {
+			amount = s.config.MaxSize - o.Size
+		}
+		if amount > 0 {
+			o.Size += amount
+			cost += amount * ask
+			income += amount * bid
+			o.BuyLimit, o.SellLimit = asks[i].Price, bids[j].Price
+		}
+		if askLeft -= amount; askLeft <= 0 {
+			if i++; i < len(asks) {
+				askLeft = asks[i].Amount
+			}
+		}
+		if bidLeft -= amount; bidLeft <= 0 {
+			if j++; j < len(bids) {
+				bidLeft = bids[j].Amount
+			}
+		}
+	}
+	if o.Size <= 0 {
+		return o, false
+	}
+	o.BuyPrice, o.SellPrice = cost/o.Size, income/o.Size
+	o.Cost = buy.WithdrawBase*o.SellPrice + sell.WithdrawQuote
+	o.Profit = income - cost - o.Cost
+	o.Spread = o.Profit / cost
+	if o.Profit <= 0 || o.Profit < s.config.MinProfit || o.Spread < s.config.MinSpread {
+		return o, false
+	}
+	return o, true
+}
+
+// Poll 轮询一次设置了 Tickers 的交易所, 结果按 OnDepth 处理
+func (s *Scanner) Poll() error {
+	var lastErr error
+	for _, v := range s.venues {
+		if v.Tickers == nil {
+			continue
+		}
+		depth, err := v.Tickers(v.Symbol)
+		if err != nil {
+			s.log().Warn("Scanner.Poll - tickers failed", logger.F("exchange", v.Exchange), logger.F("symbol", v.Symbol), logger.Err(err))
+			lastErr = err
+			continue
+		}
+		s.OnDepth(v.Exchange, v.Symbol, depth)
+	}
+	return lastErr
+}
+
+// Start 每隔 interval 轮询一次, 直到 Stop
+func (s *Scanner) Start(interval time.Duration) {
+	s.mutex.Lock()
+	if s.stop != nil {
+		s.mutex.Unlock()
+		return
+	}
+	stop := make(chan struct{})
+	s.stop = stop
+	s.mutex.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		s.Poll()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				s.Poll()
+			}
+		}
+	}()
+}
+
+// Stop 停止轮询
+func (s *Scanner) Stop() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.stop != nil {
+		close(s.stop)
+		s.stop = nil
+	}
+}
@@ -0,0 +1,90 @@
+package arbitrage
+
+import (
+	"math"
+	"testing"
+
+	"github.com/gpmn/sheep/proto"
+)
+
+func levels(pairs ...float64) []proto.DepthLevel {
+	var res []proto.DepthLevel
+	for i := 0; i+1 < len(pairs); i += 2 {
+		res = append(res, proto.DepthLevel{Price: pairs[i], Amount: pairs[i+1]})
+	}
+	return res
+}
+
+func near(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestScanner(t *testing.T) {
+	s := New(Config{
+		Pair: "btc/usdt",
+		Venues: []Venue{
+			{Exchange: "huobi", Symbol: "btcusdt", TakerFee: 0.001, WithdrawBase: 0.01},
+			{Exchange: "okex", Symbol: "BTC_USDT", WithdrawQuote: 1},
+		},
+	})
+	var got []*Opportunity
+	s.SetListener(func(o *Opportunity) { got = append(got, o) })
+
+	s.OnDepth("huobi", "btcusdt", &proto.Depth{Bids: levels(99, 5), Asks: levels(100, 1, 101, 2, 110, 5)})
+	if len(got) != 0 {
+		t.Fatalf("single venue %+v", got)
+	}
+	s.OnDepth("okex", "other", &proto.Depth{Bids: levels(200, 1)})
+	if len(got) != 0 {
+		t.Fatalf("other symbol %+v", got)
+	}
+	// 含手续费的买价 100.1, 101.101, 110.11; 卖盘 105 可以吃到第二档, 103 不够 110.11
+	s.OnDepth("okex", "btc_usdt", &proto.Depth{Bids: levels(105, 2, 103, 3), Asks: levels(106, 1)})
+	if len(got) != 1 {
+		t.Fatalf("got %d opportunities", len(got))
+	}
+	o := got[0]
+	if o.Buy != "huobi" || o.Sell != "okex" || o.Pair != "btc/usdt" || !near(o.Size, 3) {
+		t.Fatalf("%+v", o)
+	}
+	cost := 100.1 + 2*101.101
+	income := 2*105 + 103.0
+	if !near(o.BuyPrice, cost/3) || !near(o.SellPrice, income/3) || o.BuyLimit != 101 || o.SellLimit != 103 {
+		t.Fatalf("%+v", o)
+	}
+	if !near(o.Cost, 0.01*income/3+1) || !near(o.Profit, income-cost-o.Cost) || !near(o.Spread, o.Profit/cost) {
+		t.Fatalf("%+v", o)
+	}
+
+	// 数量上限, 最小利润
+	s.config.MaxSize = 0.5
+	if list := s.Scan(); len(list) != 1 || !near(list[0].Size, 0.5) {
+		t.Fatalf("%+v", list)
+	}
+	s.config.MinProfit = 10
+	if list := s.Scan(); len(list) != 0 {
+		t.Fatalf("%+v", list)
+	}
+}
+
+func TestPoll(t *testing.T) {
+	tickers := func(bid, ask float64) TickerFunc {
+		return func(symbol string) (*proto.Depth, error) {
+			return bookDepth(symbol, bid, 1, ask, 1, 0), nil
+		}
+	}
+	s := New(Config{Venues: []Venue{
+		{Exchange: "huobi", Symbol: Symbol("huobi", "BTC", "USDT"), Tickers: tickers(99, 100)},
+		{Exchange: "binance", Symbol: Symbol("binance", "btc", "usdt"), Tickers: tickers(102, 103)},
+	}})
+	if err := s.Poll(); err != nil {
+		t.Fatal(err)
+	}
+	list := s.Scan()
+	if len(list) != 1 || list[0].Buy != "huobi" || list[0].Sell != "binance" || !near(list[0].Profit, 2) {
+		t.Fatalf("%+v", list)
+	}
+	if Symbol("okex", "BTC", "usdt") != "btc_usdt" {
+		t.Fatal(Symbol("okex", "BTC", "usdt"))
+	}
+}
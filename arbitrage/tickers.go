@@ -0,0 +1,67 @@
+package arbitrage
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gpmn/sheep/binance"
+	"github.com/gpmn/sheep/consts"
+	"github.com/gpmn/sheep/huobi"
+	"github.com/gpmn/sheep/proto"
+)
+
+// Symbol 币对在交易所的交易对格式, 小写, 如 okex 为 btc_usdt, 其他为 btcusdt
+func Symbol(exchange, base, quote string) string {
+	base, quote = strings.ToLower(base), strings.ToLower(quote)
+	switch exchange {
+	case consts.ExchangeTypeOKEX:
+		return base + "_" + quote
+	}
+	return base + quote
+}
+
+// TickerFunc 查询交易对的最优买卖价, 返回只有一档的深度.
+// 没有深度推送的交易所用它轮询, 可执行数量受一档挂单量限制
+type TickerFunc func(symbol string) (*proto.Depth, error)
+
+func bookDepth(symbol string, bid, bidSize, ask, askSize float64, ts int64) *proto.Depth {
+	d := &proto.Depth{Symbol: symbol, TS: ts}
+	if bid > 0 && bidSize > 0 {
+		d.Bids = []proto.DepthLevel{{Price: bid, Amount: bidSize}}
+	}
+	if ask > 0 && askSize > 0 {
+		d.Asks = []proto.DepthLevel{{Price: ask, Amount: askSize}}
+	}
+	return d
+}
+
+// HuobiTickers 使用 Huobi.GetBookTickers
+func HuobiTickers(h *huobi.Huobi) TickerFunc {
+	return func(symbol string) (*proto.Depth, error) {
+		tickers, err := h.GetBookTickers()
+		if err != nil {
+			return nil, err
+		}
+		t, ok := tickers[symbol]
+		if !ok {
+			return nil, fmt.Errorf("%s : no ticker", symbol)
+		}
+		return bookDepth(symbol, t.Bid, t.BidSize, t.Ask, t.AskSize, t.TS), nil
+	}
+}
+
+// BinanceTickers 使用 Binance.GetBookTickers
+func BinanceTickers(b *binance.Binance) TickerFunc {
+	return func(symbol string) (*proto.Depth, error) {
+		tickers, err := b.GetBookTickers()
+		if err != nil {
+			return nil, err
+		}
+		for _, t := range tickers {
+			if strings.EqualFold(t.Symbol, symbol) {
+				return bookDepth(symbol, t.BidPrice, t.BidQuantity, t.AskPrice, t.AskQuantity, 0), nil
+			}
+		}
+		return nil, fmt.Errorf("%s : no ticker", symbol)
+	}
+}
@@ -0,0 +1,163 @@
+package backtest
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/gpmn/sheep/proto"
+	"github.com/gpmn/sheep/sim"
+)
+
+// Config : 回测参数
+type Config struct {
+	sim.Config
+	Balances       map[string]float64 // 初始余额
+	Quote          string             // 权益计价币种, 如 usdt
+	EquityInterval time.Duration      // 权益曲线采样间隔, 0 表示每个事件采样
+}
+
+// EquityPoint : 权益曲线上的一点
+type EquityPoint struct {
+	TS     int64   `json:"ts"` // 毫秒
+	Equity float64 `json:"equity"`
+}
+
+// Report : 回测结果
+type Report struct {
+	Start       int64              `json:"start"` // 第一个事件时间, 毫秒
+	End         int64              `json:"end"`   // 最后一个事件时间, 毫秒
+	Events      int                `json:"events"`
+	StartEquity float64            `json:"start_equity"`
+	EndEquity   float64            `json:"end_equity"`
+	Return      float64            `json:"return"`       // 收益率
+	MaxDrawdown float64            `json:"max_drawdown"` // 最大回撤比例
+	Fills       []proto.Fill       `json:"fills"`
+	Fees        map[string]float64 `json:"fees"` // 各币种手续费合计
+	Equity      []EquityPoint      `json:"equity"`
+	Balances    map[string]float64 `json:"balances"` // 结束时的余额
+}
+
+func (r *Report) String() string {
+	return fmt.Sprintf("%s - %s events %d fills %d equity %.8g -> %.8g return %.4f%% max drawdown %.4f%%",
+		time.Unix(0, r.Start*int64(time.Millisecond)).UTC().Format(time.RFC3339),
+		time.Unix(0, r.End*int64(time.Millisecond)).UTC().Format(time.RFC3339),
+		r.Events, len(r.Fills), r.StartEquity, r.EndEquity, r.Return*100, r.MaxDrawdown*100)
+}
+
+// Backtest : 回测驱动, 策略通过 Exchange() 取得 sheep.ExchageI / sheep.MarketDataI
+// 设置监听器并订阅, 然后调用 Run 按时间输入行情, 时钟为事件时间
+type Backtest struct {
+	config       Config
+	exchange     *sim.Exchange
+	now          time.Time
+	fills        []proto.Fill
+	fillListener sim.FillListener
+}
+
+// New 创建回测
+func New(config Config) *Backtest {
+	config.Quote = strings.ToLower(config.Quote)
+	b := &Backtest{config: config}
+	b.exchange = sim.NewExchange(config.Config, config.Balances)
+	b.exchange.SetClock(func() time.Time { return b.now })
+	b.exchange.SetFillListener(func(f *proto.Fill) {
+		b.fills = append(b.fills, *f)
+		if b.fillListener != nil {
+			b.fillListener(f)
+		}
+	})
+	return b
+}
+
+// Exchange 模拟交易所, 实现 sheep.ExchageI 和 sheep.MarketDataI
+func (b *Backtest) Exchange() *sim.Exchange {
+	return b.exchange
+}
+
+// SetFillListener 设置订单成交监听器
+func (b *Backtest) SetFillListener(listener sim.FillListener) {
+	b.fillListener = listener
+}
+
+// equity 按最新价格计算总权益, 没有价格的币种不计入
+func (b *Backtest) equity() float64 {
+	var total float64
+	for currency, amount := range b.exchange.Balances() {
+		if currency == b.config.Quote {
+			total += amount
+			continue
+		}
+		if price, ok := b.exchange.LastPrice(currency + b.config.Quote); ok {
+			total += amount * price
+		}
+	}
+	return total
+}
+
+// Run 输入feed中的所有事件, 结束后生成报告
+func (b *Backtest) Run(feed Feed) (*Report, error) {
+	r := &Report{Fees: make(map[string]float64)}
+	var next int64
+	var peak float64
+	sample := func(ts int64) {
+		equity := b.equity()
+		r.Equity = append(r.Equity, EquityPoint{TS: ts, Equity: equity})
+		if equity > peak {
+			peak = equity
+		}
+		if peak > 0 && (peak-equity)/peak > r.MaxDrawdown {
+			r.MaxDrawdown = (peak - equity) / peak
+		}
+	}
+
+	for {
+		e, err := feed.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		b.now = time.Unix(0, e.TS*int64(time.Millisecond))
+		if e.Depth != nil {
+			b.exchange.OnDepth(e.Symbol, e.Depth)
+		}
+		if e.Trade != nil {
+			b.exchange.OnTrade(e.Symbol, e.Trade)
+		}
+
+		if r.Events == 0 {
+			r.Start = e.TS
+			r.StartEquity = b.equity()
+		}
+		r.Events++
+		r.End = e.TS
+		if e.TS >= next {
+			sample(e.TS)
+			next = e.TS + int64(b.config.EquityInterval/time.Millisecond)
+		}
+	}
+
+	if n := len(r.Equity); n > 0 && r.Equity[n-1].TS != r.End {
+		sample(r.End)
+	}
+	r.EndEquity = b.equity()
+	if r.StartEquity > 0 {
+		r.Return = r.EndEquity/r.StartEquity - 1
+	}
+	r.Fills = b.fills
+	for _, f := range r.Fills {
+		r.Fees[f.FeeCurrency] += f.Fee
+	}
+	r.Balances = b.exchange.Balances()
+	return r, nil
+}
+
+// Orders 回测中的所有订单, 按下单顺序
+func (b *Backtest) Orders() []proto.Order {
+	orders, _ := b.exchange.GetOrders(&proto.OrdersParams{})
+	return orders
+}
@@ -0,0 +1,45 @@
+package backtest
+
+import (
+	"testing"
+
+	"github.com/gpmn/sheep"
+	"github.com/gpmn/sheep/proto"
+)
+
+var (
+	_ sheep.ExchageI    = New(Config{}).Exchange()
+	_ sheep.MarketDataI = New(Config{}).Exchange()
+)
+
+func TestBuyAndHold(t *testing.T) {
+	b := New(Config{Balances: map[string]float64{"usdt": 1000}, Quote: "usdt"})
+	ex := b.Exchange()
+
+	var ordered bool
+	ex.SetTradeListener(func(symbol string, trade *proto.Trade) {
+		if ordered {
+			return
+		}
+		ordered = true
+		if _, err := ex.OrderPlace(&proto.OrderPlaceParams{Amount: 1000, BaseCurrencyID: "btc", QuoteCurrencyID: "usdt", Type: proto.OrderPlaceTypeBuyMarket}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	ex.SubscribeTrade("btcusdt")
+
+	var events []Event
+	for i, price := range []float64{100, 120, 90, 110} {
+		events = append(events, Event{TS: int64(i) * 1000, Symbol: "btcusdt", Trade: &proto.Trade{Price: price, Amount: 1}})
+	}
+	r, err := b.Run(NewSliceFeed(events))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Fills) != 1 || r.EndEquity != 1100 || r.StartEquity != 1000 {
+		t.Fatalf("report %+v", r)
+	}
+	if dd := (1200.0 - 900) / 1200; r.MaxDrawdown != dd {
+		t.Fatalf("max drawdown %v, want %v", r.MaxDrawdown, dd)
+	}
+}
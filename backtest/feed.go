@@ -0,0 +1,108 @@
+package backtest
+
+import (
+	"io"
+	"sort"
+	"time"
+
+	"github.com/gpmn/sheep/consts"
+	"github.com/gpmn/sheep/history"
+	"github.com/gpmn/sheep/huobi"
+	"github.com/gpmn/sheep/proto"
+	"github.com/gpmn/sheep/recorder"
+)
+
+// Event : 回测行情事件, Depth 和 Trade 只有一个不为空
+type Event struct {
+	TS     int64 // 毫秒
+	Symbol string
+	Depth  *proto.Depth
+	Trade  *proto.Trade
+}
+
+// Feed : 按时间顺序输出事件, 结束时返回 io.EOF
+type Feed interface {
+	Next() (*Event, error)
+}
+
+type sliceFeed struct {
+	events []Event
+	idx    int
+}
+
+// NewSliceFeed 由事件列表创建 Feed, 事件按时间排序
+func NewSliceFeed(events []Event) Feed {
+	sort.SliceStable(events, func(i, j int) bool { return events[i].TS < events[j].TS })
+	return &sliceFeed{events: events}
+}
+
+func (f *sliceFeed) Next() (*Event, error) {
+	if f.idx >= len(f.events) {
+		return nil, io.EOF
+	}
+	f.idx++
+	return &f.events[f.idx-1], nil
+}
+
+// StoreTrades 从 history.Store 读取[start, end)内的成交
+func StoreTrades(store *history.Store, exchange string, symbols []string, start, end time.Time) (Feed, error) {
+	from := start.UnixNano() / int64(time.Millisecond)
+	to := end.UnixNano() / int64(time.Millisecond)
+
+	var events []Event
+	for _, symbol := range symbols {
+		trades, err := store.ReadTrades(exchange, symbol, from, to)
+		if err != nil {
+			return nil, err
+		}
+		for i := range trades {
+			events = append(events, Event{TS: trades[i].TS, Symbol: symbol, Trade: &trades[i]})
+		}
+	}
+	return NewSliceFeed(events), nil
+}
+
+// HuobiFrames 从 recorder 记录的火币消息中读取symbols的深度和成交, 时间为记录时的本地时间
+// 消息经过和实时行情相同的 huobi.Market 解析流程
+func HuobiFrames(files []string, symbols ...string) (Feed, error) {
+	h := new(huobi.Huobi)
+	market := h.OpenReplay()
+
+	var events []Event
+	var ts int64
+	h.SetDepthlListener(func(symbol string, depth *huobi.MarketDepth) {
+		d := huobi.TransDepth(symbol, depth)
+		events = append(events, Event{TS: ts, Symbol: symbol, Depth: &d})
+	})
+	h.SetDetailListener(func(symbol string, detail *huobi.MarketTradeDetail) {
+		for _, t := range detail.Tick.Data {
+			trade := huobi.TransTrade(symbol, t)
+			events = append(events, Event{TS: ts, Symbol: symbol, Trade: &trade})
+		}
+	})
+	if err := h.SubscribeDepth(symbols...); err != nil {
+		return nil, err
+	}
+	if err := h.SubscribeDetail(symbols...); err != nil {
+		return nil, err
+	}
+
+	for _, name := range files {
+		frames, err := recorder.ReadFrames(name)
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range frames {
+			if f.Source != consts.ExchangeTypeHuobi {
+				continue
+			}
+			ts = f.TS
+			if len(f.Raw) > 0 {
+				market.Replay(f.Raw)
+			} else {
+				market.Replay([]byte(f.Msg))
+			}
+		}
+	}
+	return NewSliceFeed(events), nil
+}
@@ -2,18 +2,29 @@ package bibox
 
 import (
 	"errors"
-	"log"
 
 	"encoding/json"
 
+	"github.com/gpmn/sheep/logger"
 	"github.com/gpmn/sheep/util"
 )
 
-const BiboxHost = "https://api.bibox.com"
+// BiboxHost REST API入口
+var BiboxHost = "https://api.bibox.com"
 
 type Bibox struct {
 	accessKey string
 	secretKey string
+	logger    logger.Logger
+}
+
+// SetLogger 设置日志, 为nil时不输出
+func (b *Bibox) SetLogger(l logger.Logger) {
+	b.logger = l
+}
+
+func (b *Bibox) log() logger.Logger {
+	return logger.OrNop(b.logger)
 }
 
 func (b *Bibox) GetAccountBalabce() (*GetAccountBalanceRsp, error) {
@@ -34,15 +45,18 @@ func (b *Bibox) GetAccountBalabce() (*GetAccountBalanceRsp, error) {
 		"sign":   CreateSign(b.secretKey, string(mcmds)),
 	}
 
-	ret := util.HttpPostRequest(BiboxHost+path, req, nil)
+	ret, err := util.HttpPostRequest(BiboxHost+path, req, nil)
+	if err != nil {
+		return nil, err
+	}
 	var rsp GetAccountBalanceRsp
 
-	err := json.Unmarshal([]byte(ret), &rsp)
+	err = json.Unmarshal([]byte(ret), &rsp)
 	if err != nil {
 		return nil, errors.New(ret)
 	}
 
-	log.Println(rsp)
+	b.log().Debug("Bibox.GetAccountBalabce", logger.F("response", rsp))
 
 	return &rsp, nil
 
@@ -71,17 +85,18 @@ func (b *Bibox) OrderPlace(pair, account_type, order_type, order_side, price, am
 		"sign":   CreateSign(b.secretKey, string(mcmds)),
 	}
 
-	ret := util.HttpPostRequest(BiboxHost+path, req, nil)
-	log.Println(ret)
+	ret, err := util.HttpPostRequest(BiboxHost+path, req, nil)
+	if err != nil {
+		return nil, err
+	}
+	b.log().Debug("Bibox.OrderPlace", logger.F("response", ret))
 	var rsp OrderPlaceRsp
 
-	err := json.Unmarshal([]byte(ret), &rsp)
+	err = json.Unmarshal([]byte(ret), &rsp)
 	if err != nil {
 		return nil, errors.New(ret)
 	}
 
-	log.Println(rsp)
-
 	return &rsp, nil
 }
 
@@ -103,17 +118,18 @@ func (b *Bibox) OrderCancel(orders_id string) error {
 		"sign":   CreateSign(b.secretKey, string(mcmds)),
 	}
 
-	ret := util.HttpPostRequest(BiboxHost+path, req, nil)
-	log.Println(ret)
+	ret, err := util.HttpPostRequest(BiboxHost+path, req, nil)
+	if err != nil {
+		return err
+	}
+	b.log().Debug("Bibox.OrderCancel", logger.F("response", ret))
 	var rsp OrderCancelRsp
 
-	err := json.Unmarshal([]byte(ret), &rsp)
+	err = json.Unmarshal([]byte(ret), &rsp)
 	if err != nil {
 		return errors.New(ret)
 	}
 
-	log.Println(rsp)
-
 	return nil
 }
 
@@ -141,17 +157,18 @@ func (b *Bibox) GetOrderPendingList(pair, account_type, page, size, coin_symbol,
 		"sign":   CreateSign(b.secretKey, string(mcmds)),
 	}
 
-	ret := util.HttpPostRequest(BiboxHost+path, req, nil)
-	log.Println(ret)
+	ret, err := util.HttpPostRequest(BiboxHost+path, req, nil)
+	if err != nil {
+		return nil, err
+	}
+	b.log().Debug("Bibox.GetOrderPendingList", logger.F("response", ret))
 	var rsp OrderPendingListRsp
 
-	err := json.Unmarshal([]byte(ret), &rsp)
+	err = json.Unmarshal([]byte(ret), &rsp)
 	if err != nil {
 		return nil, errors.New(ret)
 	}
 
-	log.Println(rsp)
-
 	return &rsp, nil
 }
 
@@ -173,17 +190,18 @@ func (b *Bibox) GetOrderInfo(id string) (*OrderInfoRsp, error) {
 		"sign":   CreateSign(b.secretKey, string(mcmds)),
 	}
 
-	ret := util.HttpPostRequest(BiboxHost+path, req, nil)
-	log.Println(ret)
+	ret, err := util.HttpPostRequest(BiboxHost+path, req, nil)
+	if err != nil {
+		return nil, err
+	}
+	b.log().Debug("Bibox.GetOrderInfo", logger.F("response", ret))
 	var rsp OrderInfoRsp
 
-	err := json.Unmarshal([]byte(ret), &rsp)
+	err = json.Unmarshal([]byte(ret), &rsp)
 	if err != nil {
 		return nil, errors.New(ret)
 	}
 
-	log.Println(rsp)
-
 	return &rsp, nil
 }
 
@@ -211,17 +229,18 @@ func (b *Bibox) GetOrderHistoryList(pair, account_type, page, size, coin_symbol,
 		"sign":   CreateSign(b.secretKey, string(mcmds)),
 	}
 
-	ret := util.HttpPostRequest(BiboxHost+path, req, nil)
-	log.Println(ret)
+	ret, err := util.HttpPostRequest(BiboxHost+path, req, nil)
+	if err != nil {
+		return nil, err
+	}
+	b.log().Debug("Bibox.GetOrderHistoryList", logger.F("response", ret))
 	var rsp GetOrderHistoryListRsp
 
-	err := json.Unmarshal([]byte(ret), &rsp)
+	err = json.Unmarshal([]byte(ret), &rsp)
 	if err != nil {
 		return nil, errors.New(ret)
 	}
 
-	log.Println(rsp)
-
 	return &rsp, nil
 }
 
@@ -237,16 +256,15 @@ func NewBibox(accessKey, secretKey string) (*Bibox, error) {
 	return f, nil
 }
 
-func Ping() {
+// Ping 检查服务器是否可以访问
+func Ping() error {
 	path := "/v1/public"
 	var req = map[string]string{
 		"cmd": "ping",
 	}
 
-	ret := util.HttpGetRequest(BiboxHost+path+"?"+util.Map2UrlQuery(req), nil)
-
-	log.Println(ret)
-
+	_, err := util.HttpGetRequest(BiboxHost+path+"?"+util.Map2UrlQuery(req), nil)
+	return err
 }
 
 func GetMarketDepth(pair string) (*GetMarketDepthRsp, error) {
@@ -257,11 +275,14 @@ func GetMarketDepth(pair string) (*GetMarketDepthRsp, error) {
 		"size": "10",
 	}
 
-	ret := util.HttpGetRequest(BiboxHost+path+"?"+util.Map2UrlQuery(req), nil)
+	ret, err := util.HttpGetRequest(BiboxHost+path+"?"+util.Map2UrlQuery(req), nil)
+	if err != nil {
+		return nil, err
+	}
 
 	var rsp GetMarketDepthRsp
 
-	err := json.Unmarshal([]byte(ret), &rsp)
+	err = json.Unmarshal([]byte(ret), &rsp)
 	if err != nil {
 		return nil, err
 	}
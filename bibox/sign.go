@@ -1,9 +1,16 @@
 package bibox
 
 import (
+	"crypto/hmac"
+
 	"github.com/gpmn/sheep/util"
 )
 
 func CreateSign(secret string, cmds string) string {
 	return util.ComputeHmacMd5(string(cmds), secret)
 }
+
+// VerifySign 校验cmds的签名
+func VerifySign(secret, cmds, sign string) bool {
+	return hmac.Equal([]byte(CreateSign(secret, cmds)), []byte(sign))
+}
@@ -0,0 +1,17 @@
+package bibox
+
+import "testing"
+
+func TestCreateSign(t *testing.T) {
+	cmds := `[{"cmd":"transfer/assets","body":{"select":"1"}}]`
+	want := "617692257b604f48d83dc996d8a15b21"
+	if sign := CreateSign("sk", cmds); sign != want {
+		t.Fatalf("sign %s, want %s", sign, want)
+	}
+	if !VerifySign("sk", cmds, want) {
+		t.Fatal("VerifySign failed")
+	}
+	if VerifySign("sk", `[{"cmd":"transfer/assets","body":{"select":"0"}}]`, want) {
+		t.Fatal("VerifySign accepted modified cmds")
+	}
+}
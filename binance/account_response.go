@@ -42,19 +42,20 @@ type CanceledOrder struct {
 
 // Result from: GET /api/v3/order
 type OrderStatus struct {
-	Symbol        string  `json:"symbol"`
-	OrderId       int64   `json:"orderId"`
-	ClientOrderId string  `json:"clientOrderId"`
-	Price         float64 `json:"price,string"`
-	OrigQty       float64 `json:"origQty,string"`
-	ExecutedQty   float64 `json:"executedQty,string"`
-	Status        string  `json:"status"`
-	TimeInForce   string  `json:"timeInForce"`
-	Type          string  `json:"type"`
-	Side          string  `json:"side"`
-	StopPrice     float64 `json:"stopPrice,string"`
-	IcebergQty    float64 `json:"icebergQty,string"`
-	Time          int64   `json:"time"`
+	Symbol              string  `json:"symbol"`
+	OrderId             int64   `json:"orderId"`
+	ClientOrderId       string  `json:"clientOrderId"`
+	Price               float64 `json:"price,string"`
+	OrigQty             float64 `json:"origQty,string"`
+	ExecutedQty         float64 `json:"executedQty,string"`
+	CummulativeQuoteQty float64 `json:"cummulativeQuoteQty,string"`
+	Status              string  `json:"status"`
+	TimeInForce         string  `json:"timeInForce"`
+	Type                string  `json:"type"`
+	Side                string  `json:"side"`
+	StopPrice           float64 `json:"stopPrice,string"`
+	IcebergQty          float64 `json:"icebergQty,string"`
+	Time                int64   `json:"time"`
 }
 
 // Result from: GET /api/v3/myTrades
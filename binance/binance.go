@@ -11,14 +11,27 @@
 */
 package binance
 
+import (
+	"github.com/gpmn/sheep/logger"
+)
+
 //"errors"
 
-const (
-	BaseUrl = "https://api.binance.com"
-)
+// REST API entry
+var BaseUrl = "https://api.binance.com"
 
 type Binance struct {
 	client *Client
+
+	market            *Market
+	tradeListener     TradeListener
+	aggTradeListener  AggTradeListener
+	depthListener     DepthListener
+	klineListener     KlineListener
+	userStream        *UserStream
+	accountListener   AccountUpdateListener
+	executionListener ExecutionReportListener
+	logger            logger.Logger
 }
 
 /*
@@ -33,5 +46,17 @@ func handleErr(r jsonResponse) error {
 */
 func New(key, secret string) *Binance {
 	client := NewClient(key, secret)
-	return &Binance{client}
+	return &Binance{client: client}
+}
+
+// Sets the logger of the client and its websockets, nil disables logging
+func (b *Binance) SetLogger(l logger.Logger) {
+	b.logger = l
+	if b.market != nil {
+		b.market.Logger = l
+	}
+}
+
+func (b *Binance) log() logger.Logger {
+	return logger.OrNop(b.logger)
 }
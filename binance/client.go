@@ -13,8 +13,12 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"net/url"
 	"strings"
 	"time"
+
+	"github.com/gpmn/sheep/consts"
+	"github.com/gpmn/sheep/metrics"
 )
 
 type Client struct {
@@ -40,6 +44,24 @@ func handleError(resp *http.Response) error {
 	return nil
 }
 
+// HMAC-SHA256 of the payload with the API secret, hex encoded
+func signPayload(secret, payload string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(payload))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Recomputes the signature of a captured request query, every parameter except "signature" is signed
+func VerifySignature(query url.Values, secret string) bool {
+	params := url.Values{}
+	for key, value := range query {
+		if key != "signature" {
+			params[key] = value
+		}
+	}
+	return hmac.Equal([]byte(signPayload(secret, params.Encode())), []byte(query.Get("signature")))
+}
+
 // Creates a new Binance HTTP Client
 func NewClient(key, secret string) (c *Client) {
 	client := &Client{
@@ -51,6 +73,15 @@ func NewClient(key, secret string) (c *Client) {
 }
 
 func (c *Client) do(method, resource, payload string, auth bool, result interface{}) (resp *http.Response, err error) {
+	return c.request(method, resource, payload, auth, auth, result)
+}
+
+// Same as do, but only sends the API Key header without signing the request (USER_STREAM endpoints)
+func (c *Client) doWithKey(method, resource, payload string, result interface{}) (resp *http.Response, err error) {
+	return c.request(method, resource, payload, true, false, result)
+}
+
+func (c *Client) request(method, resource, payload string, withKey, sign bool, result interface{}) (resp *http.Response, err error) {
 
 	fullUrl := fmt.Sprintf("%s/%s", BaseUrl, resource)
 
@@ -61,7 +92,7 @@ func (c *Client) do(method, resource, payload string, auth bool, result interfac
 
 	req.Header.Add("Accept", "application/json")
 
-	if auth {
+	if withKey {
 
 		if len(c.key) == 0 || len(c.secret) == 0 {
 			err = errors.New("Private endpoints requre you to set an API Key and API Secret")
@@ -69,23 +100,26 @@ func (c *Client) do(method, resource, payload string, auth bool, result interfac
 		}
 
 		req.Header.Add("X-MBX-APIKEY", c.key)
+	}
+
+	if sign {
 
 		q := req.URL.Query()
 
 		timestamp := time.Now().Unix() * 1000
 		q.Set("timestamp", fmt.Sprintf("%d", timestamp))
 
-		mac := hmac.New(sha256.New, []byte(c.secret))
-		_, err := mac.Write([]byte(q.Encode()))
-		if err != nil {
-			return nil, err
-		}
-
-		signature := hex.EncodeToString(mac.Sum(nil))
+		signature := signPayload(c.secret, q.Encode())
 		req.URL.RawQuery = q.Encode() + "&signature=" + signature
 	}
 
+	start := time.Now()
 	resp, err = c.httpClient.Do(req)
+	status := 0
+	if resp != nil {
+		status = resp.StatusCode
+	}
+	metrics.ObserveHTTP(consts.ExchangeTypeBinance, req.URL, status, err, time.Since(start))
 	if err != nil {
 		return
 	}
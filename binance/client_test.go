@@ -0,0 +1,38 @@
+package binance
+
+import (
+	"net/url"
+	"testing"
+)
+
+const testSecret = "NhqPtmdSJYdKjVHjA7PZj4Mge3R5YNiP1e3UZjInClVN65XAbvqqM6A7H5fATj0j"
+
+// Example from the official API documentation
+func TestSignPayload(t *testing.T) {
+	payload := "symbol=LTCBTC&side=BUY&type=LIMIT&timeInForce=GTC&quantity=1&price=0.1&recvWindow=5000&timestamp=1499827319559"
+	want := "c8db56825ae71d6d79447849e617115f4a920fa2acdcab2b053c4b2838bd6b71"
+	if signature := signPayload(testSecret, payload); signature != want {
+		t.Fatalf("signature %s, want %s", signature, want)
+	}
+}
+
+// Client.request signs the sorted query, VerifySignature must recompute the same payload
+func TestVerifySignature(t *testing.T) {
+	query, err := url.ParseQuery("symbol=LTCBTC&side=BUY&type=LIMIT&timeInForce=GTC&quantity=1&price=0.1&recvWindow=5000&timestamp=1499827319559")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "70fd30433bc3a2e3b5ff17d075e50538dde3734841da6dc28d79113dd37fa9c7"
+	if signature := signPayload(testSecret, query.Encode()); signature != want {
+		t.Fatalf("signature %s, want %s", signature, want)
+	}
+
+	query.Set("signature", want)
+	if !VerifySignature(query, testSecret) {
+		t.Fatal("VerifySignature failed")
+	}
+	query.Set("quantity", "2")
+	if VerifySignature(query, testSecret) {
+		t.Fatal("VerifySignature accepted modified query")
+	}
+}
@@ -0,0 +1,50 @@
+/*
+    depth/main.go
+        Subscribes the Binance depth diff stream and maintains
+        local depth cache.
+
+*/
+
+package main
+
+import (
+	"log"
+
+	"github.com/gpmn/sheep/binance"
+	"github.com/gpmn/sheep/orderbook"
+)
+
+const (
+	MaxDepth = 100 // Size of the REST snapshot
+)
+
+func main() {
+
+	symbol := "ethbtc"
+
+	client := binance.New("", "")
+
+	// Set up Order Book, seeded from the rest api and resynced on gaps
+	book := orderbook.NewBook(symbol)
+	syncer := orderbook.NewSyncer(book, orderbook.BinanceSnapshot(client, symbol, MaxDepth))
+
+	book.SetChangeListener(func(b *orderbook.Book) {
+		bid, _ := b.BestBid()
+		ask, _ := b.BestAsk()
+		log.Printf("%s bid %v x %v, ask %v x %v", b.Symbol(), bid.Price, bid.Amount, ask.Price, ask.Amount)
+	})
+
+	// Connect to websocket, diffs are buffered until the snapshot is loaded
+	if err := client.OpenWebsocket(); err != nil {
+		panic(err)
+	}
+	defer client.CloseWebsocket()
+
+	client.SetDepthListener(orderbook.BinanceListener(map[string]*orderbook.Syncer{symbol: syncer}))
+	if err := client.SubscribeDepth(symbol); err != nil {
+		panic(err)
+	}
+	syncer.Start()
+
+	select {}
+}
@@ -1,6 +1,6 @@
 /*
 
-    get_positions.go
+    get_positions/main.go
         Example script showing how to call route: /api/v3/account
 
 */
@@ -10,7 +10,7 @@ package main
 import (
     "os"
     "fmt"
-    "go-binance/binance"
+    "github.com/gpmn/sheep/binance"
 )
 
 func main() {
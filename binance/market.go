@@ -38,6 +38,29 @@ func (b *Binance) GetAggTrades(q SymbolQuery) (trades []AggTrade, err error) {
 	return
 }
 
+// Aggregate trades from an id or within a time range, oldest first.
+func (b *Binance) GetAggTradesRange(q AggTradesQuery) (trades []AggTrade, err error) {
+
+	err = q.ValidateAggTradesQuery()
+	if err != nil {
+		return
+	}
+
+	reqUrl := fmt.Sprintf("api/v1/aggTrades?symbol=%s&limit=%d", q.Symbol, q.Limit)
+	if q.FromId != 0 {
+		reqUrl += fmt.Sprintf("&fromId=%d", q.FromId)
+	}
+	if q.StartTime != 0 {
+		reqUrl += fmt.Sprintf("&startTime=%d", q.StartTime)
+	}
+	if q.EndTime != 0 {
+		reqUrl += fmt.Sprintf("&endTime=%d", q.EndTime)
+	}
+
+	_, err = b.client.do("GET", reqUrl, "", false, &trades)
+	return
+}
+
 // Kline/candlestick bars for a symbol. Klines are uniquely identified by their open time.
 func (b *Binance) GetKlines(q KlineQuery) (klines []Kline, err error) {
 
@@ -47,6 +70,12 @@ func (b *Binance) GetKlines(q KlineQuery) (klines []Kline, err error) {
 	}
 
 	reqUrl := fmt.Sprintf("api/v1/klines?symbol=%s&interval=%s&limit=%d", q.Symbol, q.Interval, q.Limit)
+	if q.StartTime != 0 {
+		reqUrl += fmt.Sprintf("&startTime=%d", q.StartTime)
+	}
+	if q.EndTime != 0 {
+		reqUrl += fmt.Sprintf("&endTime=%d", q.EndTime)
+	}
 
 	_, err = b.client.do("GET", reqUrl, "", false, &klines)
 	if err != nil {
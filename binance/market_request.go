@@ -42,10 +42,13 @@ func (q *SymbolQuery) ValidateSymbolQuery() error {
 }
 
 // Input for: Get /api/v1/klines
+// StartTime and EndTime are optional, in milliseconds.
 type KlineQuery struct {
-	Symbol   string
-	Interval string
-	Limit    int64
+	Symbol    string
+	Interval  string
+	Limit     int64
+	StartTime int64
+	EndTime   int64
 }
 
 func (q *KlineQuery) ValidateKlineQuery() error {
@@ -61,3 +64,27 @@ func (q *KlineQuery) ValidateKlineQuery() error {
 		return nil
 	}
 }
+
+// Input for: GET /api/v1/aggTrades with a range
+// Either FromId or StartTime/EndTime (at most one hour apart, in milliseconds) may be set.
+type AggTradesQuery struct {
+	Symbol    string
+	FromId    int64
+	StartTime int64
+	EndTime   int64
+	Limit     int64
+}
+
+func (q *AggTradesQuery) ValidateAggTradesQuery() error {
+	switch {
+	case len(q.Symbol) == 0:
+		return errors.New("AggTradesQuery requires a symbol")
+	case q.EndTime != 0 && q.EndTime-q.StartTime > 3600*1000:
+		return errors.New("AggTradesQuery range must be within one hour")
+	case q.Limit == 0:
+		q.Limit = 500
+		return nil
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,154 @@
+package binance
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gpmn/sheep/proto"
+)
+
+// takerSide returns the taker side of a trade, a buyer maker means the taker sold
+func takerSide(maker bool) string {
+	if maker {
+		return proto.TradeSideSell
+	}
+	return proto.TradeSideBuy
+}
+
+// TransTrade converts a trade stream event to proto.Trade
+func TransTrade(e *WsTradeEvent) proto.Trade {
+	return proto.Trade{
+		ID:     strconv.FormatInt(e.TradeId, 10),
+		Symbol: strings.ToLower(e.Symbol),
+		Price:  e.Price,
+		Amount: e.Quantity,
+		Side:   takerSide(e.Maker),
+		TS:     e.TradeTime,
+	}
+}
+
+// TransAggTrade converts an aggregate trade stream event to proto.Trade
+func TransAggTrade(e *WsAggTradeEvent) proto.Trade {
+	return proto.Trade{
+		ID:     strconv.FormatInt(e.AggTradeId, 10),
+		Symbol: strings.ToLower(e.Symbol),
+		Price:  e.Price,
+		Amount: e.Quantity,
+		Side:   takerSide(e.Maker),
+		TS:     e.TradeTime,
+	}
+}
+
+// TransKline converts a rest kline to proto.Candle
+func TransKline(symbol string, k Kline) proto.Candle {
+	return proto.Candle{
+		Symbol:      strings.ToLower(symbol),
+		Start:       k.OpenTime,
+		End:         k.CloseTime + 1,
+		Open:        k.Open,
+		High:        k.High,
+		Low:         k.Low,
+		Close:       k.Close,
+		Volume:      k.Volume,
+		QuoteVolume: k.QuoteVolume,
+		Count:       k.NumTrades,
+		Closed:      k.CloseTime < time.Now().UnixNano()/int64(time.Millisecond),
+	}
+}
+
+// TransRestAggTrade converts an aggregate trade from the rest api to proto.Trade
+func TransRestAggTrade(symbol string, t AggTrade) proto.Trade {
+	return proto.Trade{
+		ID:     strconv.FormatInt(t.TradeId, 10),
+		Symbol: strings.ToLower(symbol),
+		Price:  t.Price,
+		Amount: t.Quantity,
+		Side:   takerSide(t.Maker),
+		TS:     t.Timestamp,
+	}
+}
+
+// transOrderState converts an order status to the proto state,
+// REJECTED and EXPIRED orders are reported as canceled
+func transOrderState(status string) string {
+	switch status {
+	case "PARTIALLY_FILLED":
+		return proto.OrderStatePartialFilled
+	case "FILLED":
+		return proto.OrderStateFilled
+	case "CANCELED", "REJECTED", "EXPIRED":
+		return proto.OrderStateCanceled
+	}
+	return proto.OrderStateSubmitted
+}
+
+// transOrderType converts order side and type to the proto type, all non market orders are limit orders
+func transOrderType(side, typ string) string {
+	if typ == "MARKET" {
+		return strings.ToLower(side) + "-market"
+	}
+	return strings.ToLower(side) + "-limit"
+}
+
+// TransExecutionReport converts an execution report to the proto.Order snapshot after the execution.
+func TransExecutionReport(r *WsExecutionReport) proto.Order {
+	return proto.Order{
+		ID:              strconv.FormatInt(r.OrderId, 10),
+		Symbol:          strings.ToLower(r.Symbol),
+		State:           transOrderState(r.Status),
+		Amount:          r.Quantity,
+		FieldAmount:     r.CumulativeFilledQty,
+		Price:           r.Price,
+		Type:            transOrderType(r.Side, r.Type),
+		CreatedSec:      r.CreateTime / 1000,
+		FieldCashAmount: r.CumulativeQuoteQty,
+	}
+}
+
+// TransOrderStatus converts an order queried from the rest api to proto.Order
+func TransOrderStatus(o *OrderStatus) proto.Order {
+	return proto.Order{
+		ID:              strconv.FormatInt(o.OrderId, 10),
+		Symbol:          strings.ToLower(o.Symbol),
+		State:           transOrderState(o.Status),
+		Amount:          o.OrigQty,
+		FieldAmount:     o.ExecutedQty,
+		Price:           o.Price,
+		Type:            transOrderType(o.Side, o.Type),
+		CreatedSec:      o.Time / 1000,
+		FieldCashAmount: o.CummulativeQuoteQty,
+	}
+}
+
+// TransExecutionFill converts the trade carried by an execution report to proto.Fill,
+// it returns false when the report is not a TRADE execution
+func TransExecutionFill(r *WsExecutionReport) (proto.Fill, bool) {
+	if r.ExecutionType != "TRADE" || r.LastExecutedQty <= 0 {
+		return proto.Fill{}, false
+	}
+	side := proto.TradeSideSell
+	if r.Side == "BUY" {
+		side = proto.TradeSideBuy
+	}
+	return proto.Fill{
+		OrderID:     strconv.FormatInt(r.OrderId, 10),
+		Symbol:      strings.ToLower(r.Symbol),
+		Side:        side,
+		Price:       r.LastExecutedPrice,
+		Amount:      r.LastExecutedQty,
+		Fee:         r.Commission,
+		FeeCurrency: strings.ToLower(r.CommissionAsset),
+		Maker:       r.IsMaker,
+		TS:          r.TransactTime,
+	}, true
+}
+
+// TransPrices converts GetAllPrices to a map from lowercase symbol to latest price
+func TransPrices(prices []TickerPrice) map[string]float64 {
+	res := make(map[string]float64, len(prices))
+	for _, p := range prices {
+		res[strings.ToLower(p.Symbol)] = p.Price
+	}
+	return res
+}
@@ -0,0 +1,195 @@
+/*
+
+   userstream.go
+       User Data Stream (listenKey) for the Binance Exchange API
+
+*/
+
+package binance
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gpmn/sheep/logger"
+	"github.com/gpmn/sheep/util"
+)
+
+// A listenKey expires 60 minutes after creation unless it is kept alive
+var UserStreamKeepAliveInterval = 30 * time.Minute
+
+// Result from: POST /api/v3/userDataStream
+type ListenKey struct {
+	ListenKey string `json:"listenKey"`
+}
+
+// Start a new user data stream, returns the listenKey
+func (b *Binance) CreateListenKey() (listenKey string, err error) {
+	var res ListenKey
+	_, err = b.client.doWithKey("POST", "api/v3/userDataStream", "", &res)
+	if err != nil {
+		return
+	}
+
+	return res.ListenKey, nil
+}
+
+// Keepalive a user data stream to prevent a time out
+func (b *Binance) KeepAliveListenKey(listenKey string) (err error) {
+	reqUrl := fmt.Sprintf("api/v3/userDataStream?listenKey=%s", listenKey)
+	_, err = b.client.doWithKey("PUT", reqUrl, "", &struct{}{})
+
+	return
+}
+
+// Close out a user data stream
+func (b *Binance) CloseListenKey(listenKey string) (err error) {
+	reqUrl := fmt.Sprintf("api/v3/userDataStream?listenKey=%s", listenKey)
+	_, err = b.client.doWithKey("DELETE", reqUrl, "", &struct{}{})
+
+	return
+}
+
+type AccountUpdateListener func(update *WsAccountUpdate)
+type ExecutionReportListener func(report *WsExecutionReport)
+
+type userStreamEvent struct {
+	EventType string `json:"e"`
+}
+
+// UserStream holds the listenKey websocket and keeps the key alive
+type UserStream struct {
+	b         *Binance
+	ws        *util.SafeWebSocket
+	listenKey string
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+func (u *UserStream) connect() error {
+	ws, err := util.NewSafeWebSocket(StreamEndpoint + "/ws/" + u.listenKey)
+	if err != nil {
+		return err
+	}
+	u.ws = ws
+	u.ws.Listen(u.handleMessage)
+	return nil
+}
+
+func (u *UserStream) handleMessage(buf []byte) {
+	var ev userStreamEvent
+	if err := json.Unmarshal(buf, &ev); err != nil {
+		u.b.log().Error("UserStream.handleMessage - json.Unmarshal failed", logger.Err(err))
+		return
+	}
+
+	switch ev.EventType {
+	case "outboundAccountInfo", "outboundAccountPosition":
+		var update WsAccountUpdate
+		if err := json.Unmarshal(buf, &update); err != nil {
+			u.b.log().Error("UserStream.handleMessage - json.Unmarshal failed", logger.F("event", ev.EventType), logger.Err(err))
+			return
+		}
+		if u.b.accountListener != nil {
+			u.b.accountListener(&update)
+		}
+	case "executionReport":
+		var report WsExecutionReport
+		if err := json.Unmarshal(buf, &report); err != nil {
+			u.b.log().Error("UserStream.handleMessage - json.Unmarshal failed", logger.F("event", ev.EventType), logger.Err(err))
+			return
+		}
+		if u.b.executionListener != nil {
+			u.b.executionListener(&report)
+		}
+	}
+}
+
+func (u *UserStream) keepAlive() {
+	ticker := time.NewTicker(UserStreamKeepAliveInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-u.closed:
+			return
+		case <-ticker.C:
+			if err := u.b.KeepAliveListenKey(u.listenKey); err != nil {
+				u.b.log().Error("UserStream.keepAlive - KeepAliveListenKey failed", logger.Err(err))
+			}
+		}
+	}
+}
+
+// Blocks until Close(), reconnects with the same listenKey when the connection drops
+func (u *UserStream) loop() {
+	for {
+		err := u.ws.Loop()
+		select {
+		case <-u.closed:
+			return
+		default:
+		}
+		u.b.log().Warn("UserStream.loop - connection lost", logger.Err(err))
+		time.Sleep(time.Second)
+		if err := u.connect(); err != nil {
+			u.b.log().Error("UserStream.loop - connect failed", logger.Err(err))
+		}
+	}
+}
+
+func (u *UserStream) close() (err error) {
+	u.closeOnce.Do(func() {
+		close(u.closed)
+		err = u.ws.Destroy()
+		if e := u.b.CloseListenKey(u.listenKey); e != nil && err == nil {
+			err = e
+		}
+	})
+	return err
+}
+
+// Creates a listenKey and connects the user data stream.
+// Account updates and execution reports go to the listeners set by
+// SetAccountUpdateListener and SetExecutionReportListener.
+func (b *Binance) OpenUserStream() error {
+	if b.userStream != nil {
+		return errors.New("user stream already opened")
+	}
+
+	listenKey, err := b.CreateListenKey()
+	if err != nil {
+		return err
+	}
+
+	u := &UserStream{b: b, listenKey: listenKey, closed: make(chan struct{})}
+	if err = u.connect(); err != nil {
+		b.CloseListenKey(listenKey)
+		return err
+	}
+	b.userStream = u
+
+	go u.keepAlive()
+	go u.loop()
+	return nil
+}
+
+// Closes the user data stream and its listenKey
+func (b *Binance) CloseUserStream() error {
+	if b.userStream == nil {
+		return nil
+	}
+	err := b.userStream.close()
+	b.userStream = nil
+	return err
+}
+
+func (b *Binance) SetAccountUpdateListener(listener AccountUpdateListener) {
+	b.accountListener = listener
+}
+
+func (b *Binance) SetExecutionReportListener(listener ExecutionReportListener) {
+	b.executionListener = listener
+}
@@ -0,0 +1,388 @@
+/*
+
+   websocket.go
+       Websocket Market Streams for the Binance Exchange API
+
+*/
+
+package binance
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gpmn/sheep/consts"
+	"github.com/gpmn/sheep/logger"
+	"github.com/gpmn/sheep/metrics"
+	"github.com/gpmn/sheep/util"
+)
+
+// Websocket entry of the market & user data streams
+var StreamEndpoint = "wss://stream.binance.com:9443"
+
+// Returned when a subscribe request is not answered in time
+var ErrSubscribeTimeout = errors.New("binance stream subscribe timeout")
+
+// Raw stream listener, data is the payload of the combined stream message
+type StreamListener func(stream string, data json.RawMessage)
+
+type streamRequest struct {
+	Method string   `json:"method"`
+	Params []string `json:"params"`
+	Id     int64    `json:"id"`
+}
+
+// Any message received on the combined stream endpoint
+type streamMessage struct {
+	Stream string          `json:"stream"`
+	Data   json.RawMessage `json:"data"`
+	Id     *int64          `json:"id"`
+	Result json.RawMessage `json:"result"`
+	Code   int64           `json:"code"`
+	Msg    string          `json:"msg"`
+	Error  *struct {
+		Code int64  `json:"code"`
+		Msg  string `json:"msg"`
+	} `json:"error"`
+}
+
+func (s *streamMessage) err() error {
+	switch {
+	case s.Error != nil:
+		return fmt.Errorf("binance stream error %d : %s", s.Error.Code, s.Error.Msg)
+	case s.Code != 0:
+		return fmt.Errorf("binance stream error %d : %s", s.Code, s.Msg)
+	default:
+		return nil
+	}
+}
+
+// Market is a combined stream connection, every subscribed stream shares the same websocket.
+type Market struct {
+	ws *util.SafeWebSocket
+
+	listeners         map[string]StreamListener
+	subscribeResultCb map[int64]chan *streamMessage
+	nextId            int64
+
+	// Reconnect after the connection drops, disabled by Close(), guarded by mutex
+	autoReconnect bool
+
+	// Max wait for a subscribe reply, default 10s
+	ReceiveTimeout time.Duration
+
+	// Logger, nil disables logging
+	Logger logger.Logger
+
+	// Guards ws, autoReconnect, nextId and the maps
+	mutex *sync.RWMutex
+}
+
+// Creates a Market and connects to the combined stream endpoint
+func NewMarket() (m *Market, err error) {
+	return dialMarket(nil)
+}
+
+// Sets the logger before connecting so the read loop never races with it
+func dialMarket(l logger.Logger) (m *Market, err error) {
+	m = &Market{
+		Logger:            l,
+		ReceiveTimeout:    10 * time.Second,
+		autoReconnect:     true,
+		listeners:         make(map[string]StreamListener),
+		subscribeResultCb: make(map[int64]chan *streamMessage),
+		mutex:             &sync.RWMutex{},
+	}
+
+	if err := m.connect(); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+func (m *Market) log() logger.Logger {
+	return logger.OrNop(m.Logger)
+}
+
+func (m *Market) connect() error {
+	ws, err := util.NewSafeWebSocket(StreamEndpoint + "/stream")
+	if err != nil {
+		return err
+	}
+	m.mutex.Lock()
+	m.ws = ws
+	m.mutex.Unlock()
+	m.handleMessageLoop(ws)
+
+	return nil
+}
+
+func (m *Market) reconnect() error {
+	m.log().Info("Market.reconnect - reconnecting after 1s")
+	metrics.IncReconnect(consts.ExchangeTypeBinance)
+	time.Sleep(time.Second)
+
+	if err := m.connect(); err != nil {
+		m.log().Error("Market.reconnect - connect failed", logger.Err(err))
+		return err
+	}
+
+	m.mutex.RLock()
+	var streams []string
+	for stream := range m.listeners {
+		streams = append(streams, stream)
+	}
+	m.mutex.RUnlock()
+
+	if len(streams) == 0 {
+		return nil
+	}
+	if err := m.request("SUBSCRIBE", streams); err != nil {
+		m.log().Error("Market.reconnect - resubscribe failed", logger.Err(err))
+		m.conn().Destroy()
+		return err
+	}
+	return nil
+}
+
+// The current connection, replaced on reconnect
+func (m *Market) conn() *util.SafeWebSocket {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	return m.ws
+}
+
+func (m *Market) reconnectEnabled() bool {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	return m.autoReconnect
+}
+
+func (m *Market) handleMessageLoop(ws *util.SafeWebSocket) {
+	ws.Listen(func(buf []byte) {
+		var msg streamMessage
+		if err := json.Unmarshal(buf, &msg); err != nil {
+			m.log().Error("Market.handleMessageLoop - json.Unmarshal failed", logger.Err(err))
+			return
+		}
+
+		if msg.Stream != "" {
+			metrics.IncMessage(consts.ExchangeTypeBinance, msg.Stream)
+			m.mutex.RLock()
+			listener, ok := m.listeners[msg.Stream]
+			m.mutex.RUnlock()
+			if ok {
+				listener(msg.Stream, msg.Data)
+			}
+			return
+		}
+
+		if msg.Id != nil {
+			m.mutex.RLock()
+			c, ok := m.subscribeResultCb[*msg.Id]
+			m.mutex.RUnlock()
+			if ok {
+				c <- &msg
+			}
+		}
+	})
+}
+
+// Sends a SUBSCRIBE / UNSUBSCRIBE request and waits for its reply
+func (m *Market) request(method string, streams []string) error {
+	c := make(chan *streamMessage, 1)
+
+	m.mutex.Lock()
+	m.nextId++
+	id := m.nextId
+	m.subscribeResultCb[id] = c
+	m.mutex.Unlock()
+
+	defer func() {
+		m.mutex.Lock()
+		delete(m.subscribeResultCb, id)
+		m.mutex.Unlock()
+	}()
+
+	b, err := json.Marshal(streamRequest{Method: method, Params: streams, Id: id})
+	if err != nil {
+		return err
+	}
+	m.conn().Send(b)
+
+	select {
+	case msg := <-c:
+		return msg.err()
+	case <-time.After(m.ReceiveTimeout):
+		return ErrSubscribeTimeout
+	}
+}
+
+// Subscribes a stream, e.g. "bnbbtc@trade"
+func (m *Market) Subscribe(stream string, listener StreamListener) error {
+	m.mutex.Lock()
+	_, subscribed := m.listeners[stream]
+	m.listeners[stream] = listener
+	m.mutex.Unlock()
+
+	if subscribed {
+		return nil
+	}
+
+	if err := m.request("SUBSCRIBE", []string{stream}); err != nil {
+		metrics.IncSubscribeFailure(consts.ExchangeTypeBinance, stream)
+		m.mutex.Lock()
+		delete(m.listeners, stream)
+		m.mutex.Unlock()
+		return err
+	}
+	return nil
+}
+
+// Unsubscribes a stream and drops its listener
+func (m *Market) Unsubscribe(stream string) error {
+	m.mutex.Lock()
+	delete(m.listeners, stream)
+	m.mutex.Unlock()
+
+	return m.request("UNSUBSCRIBE", []string{stream})
+}
+
+// Blocks until the connection is closed by Close()
+func (m *Market) Loop() {
+	for {
+		err := m.conn().Loop()
+		if err == util.SafeWebSocketDestroyError || !m.reconnectEnabled() {
+			break
+		}
+		m.log().Warn("Market.Loop - connection lost", logger.Err(err))
+		m.reconnect()
+	}
+}
+
+// Closes the connection without reconnecting
+func (m *Market) Close() error {
+	m.mutex.Lock()
+	m.autoReconnect = false
+	ws := m.ws
+	m.mutex.Unlock()
+	return ws.Destroy()
+}
+
+type TradeListener func(symbol string, trade *WsTradeEvent)
+type AggTradeListener func(symbol string, trade *WsAggTradeEvent)
+type DepthListener func(symbol string, depth *WsDepthEvent)
+type KlineListener func(symbol string, kline *WsKlineEvent)
+
+// Opens the combined market stream connection
+func (b *Binance) OpenWebsocket() error {
+	var err error
+	b.market, err = dialMarket(b.logger)
+	if err != nil {
+		return err
+	}
+
+	go b.market.Loop()
+	return nil
+}
+
+func (b *Binance) CloseWebsocket() error {
+	return b.market.Close()
+}
+
+func (b *Binance) SetTradeListener(listener TradeListener) {
+	b.tradeListener = listener
+}
+
+func (b *Binance) SetAggTradeListener(listener AggTradeListener) {
+	b.aggTradeListener = listener
+}
+
+func (b *Binance) SetDepthListener(listener DepthListener) {
+	b.depthListener = listener
+}
+
+func (b *Binance) SetKlineListener(listener KlineListener) {
+	b.klineListener = listener
+}
+
+// Subscribes <symbol>@trade for every symbol
+func (b *Binance) SubscribeTrade(symbols ...string) error {
+	return b.subscribe("@trade", symbols, func(symbol string, data json.RawMessage) {
+		var ev WsTradeEvent
+		if err := json.Unmarshal(data, &ev); err != nil {
+			b.log().Error("Binance.SubscribeTrade - callback failed", logger.Err(err))
+			return
+		}
+		if b.tradeListener != nil {
+			b.tradeListener(symbol, &ev)
+		}
+	})
+}
+
+// Subscribes <symbol>@aggTrade for every symbol
+func (b *Binance) SubscribeAggTrade(symbols ...string) error {
+	return b.subscribe("@aggTrade", symbols, func(symbol string, data json.RawMessage) {
+		var ev WsAggTradeEvent
+		if err := json.Unmarshal(data, &ev); err != nil {
+			b.log().Error("Binance.SubscribeAggTrade - callback failed", logger.Err(err))
+			return
+		}
+		if b.aggTradeListener != nil {
+			b.aggTradeListener(symbol, &ev)
+		}
+	})
+}
+
+// Subscribes the <symbol>@depth diff stream for every symbol
+func (b *Binance) SubscribeDepth(symbols ...string) error {
+	return b.subscribe("@depth", symbols, func(symbol string, data json.RawMessage) {
+		var ev WsDepthEvent
+		if err := json.Unmarshal(data, &ev); err != nil {
+			b.log().Error("Binance.SubscribeDepth - callback failed", logger.Err(err))
+			return
+		}
+		if b.depthListener != nil {
+			b.depthListener(symbol, &ev)
+		}
+	})
+}
+
+// Subscribes <symbol>@kline_<interval> for every symbol
+func (b *Binance) SubscribeKline(interval string, symbols ...string) error {
+	if !IntervalEnum[interval] {
+		return errors.New("Invalid Kline Interval")
+	}
+	return b.subscribe("@kline_"+interval, symbols, func(symbol string, data json.RawMessage) {
+		var ev WsKlineEvent
+		if err := json.Unmarshal(data, &ev); err != nil {
+			b.log().Error("Binance.SubscribeKline - callback failed", logger.Err(err))
+			return
+		}
+		if b.klineListener != nil {
+			b.klineListener(symbol, &ev)
+		}
+	})
+}
+
+func (b *Binance) subscribe(suffix string, symbols []string, handler func(symbol string, data json.RawMessage)) error {
+	if b.market == nil {
+		return errors.New("websocket not opened")
+	}
+	for _, symbol := range symbols {
+		symbol = strings.ToLower(symbol)
+		err := b.market.Subscribe(symbol+suffix, func(stream string, data json.RawMessage) {
+			handler(symbol, data)
+		})
+		if err != nil {
+			b.log().Error("Binance.subscribe - Subscribe failed", logger.F("stream", symbol+suffix), logger.Err(err))
+			return err
+		}
+	}
+	return nil
+}
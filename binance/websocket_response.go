@@ -0,0 +1,137 @@
+/*
+
+   websocket_response.go
+       Stores event structs for the Binance websocket streams
+
+*/
+
+package binance
+
+// Event from: <symbol>@trade
+type WsTradeEvent struct {
+	EventType     string  `json:"e"`
+	EventTime     int64   `json:"E"`
+	Symbol        string  `json:"s"`
+	TradeId       int64   `json:"t"`
+	Price         float64 `json:"p,string"`
+	Quantity      float64 `json:"q,string"`
+	BuyerOrderId  int64   `json:"b"`
+	SellerOrderId int64   `json:"a"`
+	TradeTime     int64   `json:"T"`
+	Maker         bool    `json:"m"`
+	BestMatch     bool    `json:"M"`
+}
+
+// Event from: <symbol>@aggTrade
+type WsAggTradeEvent struct {
+	EventType    string  `json:"e"`
+	EventTime    int64   `json:"E"`
+	Symbol       string  `json:"s"`
+	AggTradeId   int64   `json:"a"`
+	Price        float64 `json:"p,string"`
+	Quantity     float64 `json:"q,string"`
+	FirstTradeId int64   `json:"f"`
+	LastTradeId  int64   `json:"l"`
+	TradeTime    int64   `json:"T"`
+	Maker        bool    `json:"m"`
+	BestMatch    bool    `json:"M"`
+}
+
+// Event from: <symbol>@depth
+// Bids and asks are absolute quantities, a quantity of 0 removes the price level.
+type WsDepthEvent struct {
+	EventType     string  `json:"e"`
+	EventTime     int64   `json:"E"`
+	Symbol        string  `json:"s"`
+	FirstUpdateId int64   `json:"U"`
+	FinalUpdateId int64   `json:"u"`
+	Bids          []Order `json:"b"`
+	Asks          []Order `json:"a"`
+}
+
+// Event from: <symbol>@kline_<interval>
+type WsKlineEvent struct {
+	EventType string  `json:"e"`
+	EventTime int64   `json:"E"`
+	Symbol    string  `json:"s"`
+	Kline     WsKline `json:"k"`
+}
+
+type WsKline struct {
+	StartTime        int64   `json:"t"`
+	CloseTime        int64   `json:"T"`
+	Symbol           string  `json:"s"`
+	Interval         string  `json:"i"`
+	FirstTradeId     int64   `json:"f"`
+	LastTradeId      int64   `json:"L"`
+	Open             float64 `json:"o,string"`
+	Close            float64 `json:"c,string"`
+	High             float64 `json:"h,string"`
+	Low              float64 `json:"l,string"`
+	Volume           float64 `json:"v,string"`
+	NumTrades        int64   `json:"n"`
+	Final            bool    `json:"x"`
+	QuoteVolume      float64 `json:"q,string"`
+	TakerBaseVolume  float64 `json:"V,string"`
+	TakerQuoteVolume float64 `json:"Q,string"`
+	Ignore           string  `json:"B"`
+}
+
+// Event from the user data stream: outboundAccountInfo & outboundAccountPosition
+type WsAccountUpdate struct {
+	EventType        string             `json:"e"`
+	EventTime        int64              `json:"E"`
+	MakerCommission  int64              `json:"m"`
+	TakerCommission  int64              `json:"t"`
+	BuyerCommission  int64              `json:"b"`
+	SellerCommission int64              `json:"s"`
+	CanTrade         bool               `json:"T"`
+	CanWithdraw      bool               `json:"W"`
+	CanDeposit       bool               `json:"D"`
+	LastUpdateTime   int64              `json:"u"`
+	Balances         []WsAccountBalance `json:"B"`
+}
+
+type WsAccountBalance struct {
+	Asset  string  `json:"a"`
+	Free   float64 `json:"f,string"`
+	Locked float64 `json:"l,string"`
+}
+
+// Event from the user data stream: executionReport
+// Every single-letter key of the payload is declared, encoding/json matches
+// keys case-insensitively and would otherwise mix up pairs like "x" and "X".
+type WsExecutionReport struct {
+	EventType           string  `json:"e"`
+	EventTime           int64   `json:"E"`
+	Symbol              string  `json:"s"`
+	ClientOrderId       string  `json:"c"`
+	Side                string  `json:"S"`
+	Type                string  `json:"o"`
+	TimeInForce         string  `json:"f"`
+	Quantity            float64 `json:"q,string"`
+	Price               float64 `json:"p,string"`
+	StopPrice           float64 `json:"P,string"`
+	IcebergQty          float64 `json:"F,string"`
+	OrderListId         int64   `json:"g"`
+	OrigClientOrderId   string  `json:"C"`
+	ExecutionType       string  `json:"x"`
+	Status              string  `json:"X"`
+	RejectReason        string  `json:"r"`
+	OrderId             int64   `json:"i"`
+	LastExecutedQty     float64 `json:"l,string"`
+	CumulativeFilledQty float64 `json:"z,string"`
+	LastExecutedPrice   float64 `json:"L,string"`
+	Commission          float64 `json:"n,string"`
+	CommissionAsset     string  `json:"N"`
+	TransactTime        int64   `json:"T"`
+	TradeId             int64   `json:"t"`
+	Ignore              int64   `json:"I"`
+	IsWorking           bool    `json:"w"`
+	IsMaker             bool    `json:"m"`
+	IgnoreM             bool    `json:"M"`
+	CreateTime          int64   `json:"O"`
+	CumulativeQuoteQty  float64 `json:"Z,string"`
+	LastQuoteQty        float64 `json:"Y,string"`
+	QuoteOrderQty       float64 `json:"Q,string"`
+}
@@ -0,0 +1,327 @@
+package candles
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gpmn/sheep/proto"
+)
+
+// ErrBackfill 只有时间K线支持用历史K线回填
+var ErrBackfill = errors.New("backfill only supported by time bars")
+
+// DefaultMaxBars 默认保留的已收盘K线数量
+const DefaultMaxBars = 1000
+
+const (
+	BarTypeTime   = "time"   // 固定时间周期
+	BarTypeTick   = "tick"   // 固定成交笔数
+	BarTypeVolume = "volume" // 固定成交量
+)
+
+// Listener K线监听器, candle.Closed 为 false 时是未收盘K线的最新状态
+type Listener func(candle *proto.Candle)
+
+// Aggregator : 由成交生成K线, 并发安全
+type Aggregator struct {
+	MaxBars int // 保留的已收盘K线数量, 0 使用 DefaultMaxBars
+
+	symbol   string
+	barType  string
+	interval int64   // 时间K线周期, 毫秒
+	ticks    int64   // 成交笔数K线每根的笔数
+	volume   float64 // 成交量K线每根的成交量
+
+	current  *proto.Candle
+	bars     []proto.Candle
+	cutoff   int64 // 回填后忽略该时间(毫秒)及之前的成交
+	listener Listener
+	mutex    sync.Mutex
+}
+
+// NewTimeBars 创建时间K线, interval 不小于1毫秒
+func NewTimeBars(symbol string, interval time.Duration) *Aggregator {
+	ms := int64(interval / time.Millisecond)
+	if ms < 1 {
+		ms = 1
+	}
+	return &Aggregator{symbol: symbol, barType: BarTypeTime, interval: ms}
+}
+
+// NewTickBars 创建成交笔数K线, 每n笔成交一根
+func NewTickBars(symbol string, n int) *Aggregator {
+	if n < 1 {
+		n = 1
+	}
+	return &Aggregator{symbol: symbol, barType: BarTypeTick, ticks: int64(n)}
+}
+
+// NewVolumeBars 创建成交量K线, 每根成交量为volume, 跨越两根的成交被拆分
+func NewVolumeBars(symbol string, volume float64) *Aggregator {
+	return &Aggregator{symbol: symbol, barType: BarTypeVolume, volume: volume}
+}
+
+// Symbol 交易对
+func (a *Aggregator) Symbol() string {
+	return a.symbol
+}
+
+// BarType K线类型
+func (a *Aggregator) BarType() string {
+	return a.barType
+}
+
+// Interval 时间K线周期, 其他类型返回0
+func (a *Aggregator) Interval() time.Duration {
+	if a.barType != BarTypeTime {
+		return 0
+	}
+	return time.Duration(a.interval) * time.Millisecond
+}
+
+// SetListener 设置K线监听器
+func (a *Aggregator) SetListener(listener Listener) {
+	a.mutex.Lock()
+	a.listener = listener
+	a.mutex.Unlock()
+}
+
+// Bars 已收盘K线的拷贝, 时间从早到晚
+func (a *Aggregator) Bars() []proto.Candle {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	res := make([]proto.Candle, len(a.bars))
+	copy(res, a.bars)
+	return res
+}
+
+// Current 未收盘K线
+func (a *Aggregator) Current() (proto.Candle, bool) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if a.current == nil {
+		return proto.Candle{}, false
+	}
+	return *a.current, true
+}
+
+// AddTrade 加入一笔成交, 时间K线中早于未收盘K线的成交被忽略
+func (a *Aggregator) AddTrade(t proto.Trade) {
+	a.mutex.Lock()
+	events := a.addTrade(t)
+	listener := a.listener
+	a.mutex.Unlock()
+
+	a.emit(listener, events)
+}
+
+// Tick 时间K线在没有成交时也按时收盘, 需要定时调用
+func (a *Aggregator) Tick(now time.Time) {
+	if a.barType != BarTypeTime {
+		return
+	}
+	ms := now.UnixNano() / int64(time.Millisecond)
+
+	a.mutex.Lock()
+	var events []proto.Candle
+	if a.current != nil && a.current.End <= ms {
+		events = append(events, a.close())
+	}
+	listener := a.listener
+	a.mutex.Unlock()
+
+	a.emit(listener, events)
+}
+
+// Backfill 用历史K线回填, candles 的周期需整除时间K线周期.
+// 最后一根可能未收盘, 因此回填后忽略此刻之前的成交.
+func (a *Aggregator) Backfill(candles []proto.Candle) error {
+	if a.barType != BarTypeTime {
+		return ErrBackfill
+	}
+	bars := Resample(candles, a.Interval())
+	if len(bars) == 0 {
+		return nil
+	}
+
+	a.mutex.Lock()
+	last := bars[len(bars)-1]
+	if a.current != nil && a.current.Start > last.Start {
+		a.mutex.Unlock()
+		return nil
+	}
+	var events []proto.Candle
+	for i := range bars[:len(bars)-1] {
+		bars[i].Closed = true
+		a.push(bars[i])
+		events = append(events, bars[i])
+	}
+	last.Closed = false
+	a.current = &last
+	a.cutoff = time.Now().UnixNano() / int64(time.Millisecond)
+	events = append(events, last)
+	listener := a.listener
+	a.mutex.Unlock()
+
+	a.emit(listener, events)
+	return nil
+}
+
+func (a *Aggregator) emit(listener Listener, events []proto.Candle) {
+	if listener == nil {
+		return
+	}
+	for i := range events {
+		listener(&events[i])
+	}
+}
+
+func (a *Aggregator) addTrade(t proto.Trade) []proto.Candle {
+	if t.Amount <= 0 || (a.cutoff > 0 && t.TS <= a.cutoff) {
+		return nil
+	}
+
+	var events []proto.Candle
+	switch a.barType {
+	case BarTypeTime:
+		start := t.TS - t.TS%a.interval
+		if a.current != nil && start < a.current.Start {
+			return nil
+		}
+		if a.current != nil && start > a.current.Start {
+			events = append(events, a.close())
+		}
+		if a.current == nil {
+			a.open(t, start, start+a.interval)
+		}
+		a.merge(t)
+		events = append(events, *a.current)
+	case BarTypeTick:
+		if a.current == nil {
+			a.open(t, t.TS, t.TS)
+		}
+		a.merge(t)
+		if a.current.Count >= a.ticks {
+			events = append(events, a.close())
+		} else {
+			events = append(events, *a.current)
+		}
+	case BarTypeVolume:
+		for t.Amount > 0 {
+			if a.current == nil {
+				a.open(t, t.TS, t.TS)
+			}
+			part := t
+			if rest := a.volume - a.current.Volume; a.volume > 0 && part.Amount > rest {
+				part.Amount = rest
+			}
+			a.merge(part)
+			t.Amount -= part.Amount
+			if a.volume > 0 && a.current.Volume >= a.volume {
+				events = append(events, a.close())
+			} else {
+				events = append(events, *a.current)
+			}
+		}
+	}
+	return events
+}
+
+func (a *Aggregator) open(t proto.Trade, start, end int64) {
+	a.current = &proto.Candle{
+		Symbol: a.symbol,
+		Start:  start,
+		End:    end,
+		Open:   t.Price,
+		High:   t.Price,
+		Low:    t.Price,
+		Close:  t.Price,
+	}
+}
+
+func (a *Aggregator) merge(t proto.Trade) {
+	c := a.current
+	if t.Price > c.High {
+		c.High = t.Price
+	}
+	if t.Price < c.Low {
+		c.Low = t.Price
+	}
+	c.Close = t.Price
+	c.Volume += t.Amount
+	c.QuoteVolume += t.Amount * t.Price
+	c.Count++
+	if a.barType != BarTypeTime {
+		c.End = t.TS
+	}
+}
+
+// close 收盘当前K线并返回
+func (a *Aggregator) close() proto.Candle {
+	c := *a.current
+	c.Closed = true
+	a.current = nil
+	a.push(c)
+	return c
+}
+
+func (a *Aggregator) push(c proto.Candle) {
+	max := a.MaxBars
+	if max <= 0 {
+		max = DefaultMaxBars
+	}
+	a.bars = append(a.bars, c)
+	if len(a.bars) > max {
+		a.bars = append(a.bars[:0], a.bars[len(a.bars)-max:]...)
+	}
+}
+
+// Resample 将K线合并为interval周期的K线, 按开始时间对齐, 结果时间从早到晚.
+// 合并后的K线只有全部组成K线都已收盘并覆盖到周期结束时才标记为收盘.
+func Resample(candles []proto.Candle, interval time.Duration) []proto.Candle {
+	ms := int64(interval / time.Millisecond)
+	if ms < 1 || len(candles) == 0 {
+		return nil
+	}
+	sorted := make([]proto.Candle, len(candles))
+	copy(sorted, candles)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Start < sorted[j].Start })
+
+	var res []proto.Candle
+	for _, c := range sorted {
+		start := c.Start - c.Start%ms
+		n := len(res)
+		if n == 0 || res[n-1].Start != start {
+			res = append(res, proto.Candle{
+				Symbol: c.Symbol,
+				Start:  start,
+				End:    start + ms,
+				Open:   c.Open,
+				High:   c.High,
+				Low:    c.Low,
+				Closed: true,
+			})
+			n++
+		}
+		r := &res[n-1]
+		if c.High > r.High {
+			r.High = c.High
+		}
+		if c.Low < r.Low {
+			r.Low = c.Low
+		}
+		r.Close = c.Close
+		r.Volume += c.Volume
+		r.QuoteVolume += c.QuoteVolume
+		r.Count += c.Count
+		if !c.Closed {
+			r.Closed = false
+		}
+	}
+	if n := len(res); n > 0 && sorted[len(sorted)-1].End < res[n-1].End {
+		res[n-1].Closed = false
+	}
+	return res
+}
@@ -0,0 +1,85 @@
+package candles
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gpmn/sheep/proto"
+)
+
+func trade(ts int64, price, amount float64) proto.Trade {
+	return proto.Trade{Symbol: "btcusdt", Price: price, Amount: amount, TS: ts}
+}
+
+func TestTimeBars(t *testing.T) {
+	a := NewTimeBars("btcusdt", time.Minute)
+	var closed []proto.Candle
+	a.SetListener(func(c *proto.Candle) {
+		if c.Closed {
+			closed = append(closed, *c)
+		}
+	})
+
+	a.AddTrade(trade(60000, 10, 1))
+	a.AddTrade(trade(61000, 12, 1))
+	a.AddTrade(trade(119999, 9, 2))
+	a.AddTrade(trade(120000, 11, 1))
+	a.AddTrade(trade(100000, 1, 1)) // 迟到的成交被忽略
+
+	if len(closed) != 1 {
+		t.Fatalf("closed %+v", closed)
+	}
+	c := closed[0]
+	if c.Start != 60000 || c.End != 120000 || c.Open != 10 || c.High != 12 || c.Low != 9 || c.Close != 9 || c.Volume != 4 || c.Count != 3 {
+		t.Fatalf("bar %+v", c)
+	}
+	if cur, ok := a.Current(); !ok || cur.Start != 120000 || cur.Closed {
+		t.Fatalf("current %+v", cur)
+	}
+
+	a.Tick(time.Unix(180, 0))
+	if len(a.Bars()) != 2 {
+		t.Fatalf("bar not closed by tick")
+	}
+}
+
+func TestVolumeBars(t *testing.T) {
+	a := NewVolumeBars("btcusdt", 2)
+	a.AddTrade(trade(1, 10, 1.5))
+	a.AddTrade(trade(2, 11, 3))
+	bars := a.Bars()
+	if len(bars) != 2 || bars[0].Volume != 2 || bars[0].Close != 11 || bars[1].Open != 11 || bars[1].Volume != 2 {
+		t.Fatalf("bars %+v", bars)
+	}
+	if cur, _ := a.Current(); cur.Volume != 0.5 {
+		t.Fatalf("current %+v", cur)
+	}
+}
+
+func TestResample(t *testing.T) {
+	var src []proto.Candle
+	for i := int64(0); i < 5; i++ {
+		src = append(src, proto.Candle{Start: i * 60000, End: (i + 1) * 60000, Open: float64(i), High: float64(i) + 1, Low: float64(i), Close: float64(i), Volume: 1, Closed: true})
+	}
+	bars := Resample(src, 2*time.Minute)
+	if len(bars) != 3 {
+		t.Fatalf("bars %+v", bars)
+	}
+	if bars[0].Open != 0 || bars[0].Close != 1 || bars[0].High != 2 || bars[0].Volume != 2 || !bars[0].Closed {
+		t.Fatalf("bar %+v", bars[0])
+	}
+	if bars[2].Closed {
+		t.Fatalf("partial bar marked closed")
+	}
+
+	a := NewTimeBars("btcusdt", 2*time.Minute)
+	if err := a.Backfill(src); err != nil {
+		t.Fatal(err)
+	}
+	if len(a.Bars()) != 2 {
+		t.Fatalf("backfilled %+v", a.Bars())
+	}
+	if err := NewTickBars("btcusdt", 10).Backfill(src); err != ErrBackfill {
+		t.Fatalf("got %v, want ErrBackfill", err)
+	}
+}
@@ -0,0 +1,228 @@
+package candles
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gpmn/sheep/binance"
+	"github.com/gpmn/sheep/fcoin"
+	"github.com/gpmn/sheep/huobi"
+	"github.com/gpmn/sheep/okex"
+	"github.com/gpmn/sheep/proto"
+)
+
+// Router : 按交易对把成交分发给多个 Aggregator
+type Router struct {
+	aggregators map[string][]*Aggregator
+	mutex       sync.RWMutex
+}
+
+// NewRouter 创建 Router
+func NewRouter(aggregators ...*Aggregator) *Router {
+	r := &Router{aggregators: make(map[string][]*Aggregator)}
+	for _, a := range aggregators {
+		r.Add(a)
+	}
+	return r
+}
+
+// Add 添加 Aggregator
+func (r *Router) Add(a *Aggregator) {
+	r.mutex.Lock()
+	r.aggregators[a.Symbol()] = append(r.aggregators[a.Symbol()], a)
+	r.mutex.Unlock()
+}
+
+// AddTrade 分发一笔成交
+func (r *Router) AddTrade(t proto.Trade) {
+	r.mutex.RLock()
+	aggregators := r.aggregators[t.Symbol]
+	r.mutex.RUnlock()
+	for _, a := range aggregators {
+		a.AddTrade(t)
+	}
+}
+
+// Tick 所有时间K线按时收盘
+func (r *Router) Tick(now time.Time) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	for _, aggregators := range r.aggregators {
+		for _, a := range aggregators {
+			a.Tick(now)
+		}
+	}
+}
+
+// Backfill 用fn回填所有时间K线, 每个回填bars根
+func (r *Router) Backfill(fn BackfillFunc, bars int) error {
+	r.mutex.RLock()
+	var list []*Aggregator
+	for _, aggregators := range r.aggregators {
+		list = append(list, aggregators...)
+	}
+	r.mutex.RUnlock()
+
+	for _, a := range list {
+		if a.BarType() != BarTypeTime {
+			continue
+		}
+		candles, err := fn(a.Symbol(), a.Interval(), bars)
+		if err != nil {
+			return err
+		}
+		if err = a.Backfill(candles); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// HuobiListener 火币成交推送
+func HuobiListener(r *Router) huobi.DetailListener {
+	return func(symbol string, detail *huobi.MarketTradeDetail) {
+		for _, t := range detail.Tick.Data {
+			r.AddTrade(huobi.TransTrade(symbol, t))
+		}
+	}
+}
+
+// BinanceTradeListener 币安逐笔成交推送
+func BinanceTradeListener(r *Router) binance.TradeListener {
+	return func(symbol string, trade *binance.WsTradeEvent) {
+		r.AddTrade(binance.TransTrade(trade))
+	}
+}
+
+// BinanceAggTradeListener 币安归集成交推送
+func BinanceAggTradeListener(r *Router) binance.AggTradeListener {
+	return func(symbol string, trade *binance.WsAggTradeEvent) {
+		r.AddTrade(binance.TransAggTrade(trade))
+	}
+}
+
+// OKEXListener OKEX成交推送
+func OKEXListener(r *Router) okex.DealsListener {
+	return func(symbol string, deals []okex.Deal) {
+		for _, d := range deals {
+			r.AddTrade(okex.TransDeal(symbol, d))
+		}
+	}
+}
+
+// FCoinListener FCoin成交推送
+func FCoinListener(r *Router) fcoin.TradeListener {
+	return func(symbol string, trade *fcoin.TradeUpdate) {
+		r.AddTrade(fcoin.TransTrade(symbol, trade))
+	}
+}
+
+// BackfillFunc 获取symbol交易对覆盖bars根interval周期的历史K线
+type BackfillFunc func(symbol string, interval time.Duration, bars int) ([]proto.Candle, error)
+
+type period struct {
+	name     string
+	duration time.Duration
+}
+
+var huobiPeriods = []period{
+	{"1min", time.Minute}, {"5min", 5 * time.Minute}, {"15min", 15 * time.Minute},
+	{"30min", 30 * time.Minute}, {"60min", time.Hour}, {"1day", 24 * time.Hour},
+}
+
+var binancePeriods = []period{
+	{"1m", time.Minute}, {"3m", 3 * time.Minute}, {"5m", 5 * time.Minute},
+	{"15m", 15 * time.Minute}, {"30m", 30 * time.Minute}, {"1h", time.Hour},
+	{"2h", 2 * time.Hour}, {"4h", 4 * time.Hour}, {"6h", 6 * time.Hour},
+	{"8h", 8 * time.Hour}, {"12h", 12 * time.Hour}, {"1d", 24 * time.Hour},
+}
+
+var fcoinPeriods = []period{
+	{fcoin.CandleResolutionM1, time.Minute}, {fcoin.CandleResolutionM3, 3 * time.Minute},
+	{fcoin.CandleResolutionM5, 5 * time.Minute}, {fcoin.CandleResolutionM15, 15 * time.Minute},
+	{fcoin.CandleResolutionM30, 30 * time.Minute}, {fcoin.CandleResolutionH1, time.Hour},
+	{fcoin.CandleResolutionH4, 4 * time.Hour}, {fcoin.CandleResolutionH6, 6 * time.Hour},
+	{fcoin.CandleResolutionD1, 24 * time.Hour},
+}
+
+// choosePeriod 选择能整除interval的最大周期, 返回周期和所需根数
+func choosePeriod(periods []period, interval time.Duration, bars, max int) (period, int, error) {
+	for i := len(periods) - 1; i >= 0; i-- {
+		p := periods[i]
+		if interval%p.duration != 0 {
+			continue
+		}
+		n := bars * int(interval/p.duration)
+		if n > max {
+			n = max
+		}
+		return p, n, nil
+	}
+	return period{}, 0, fmt.Errorf("no kline period divides %v", interval)
+}
+
+// HuobiBackfill 火币历史K线
+func HuobiBackfill(h *huobi.Huobi) BackfillFunc {
+	return func(symbol string, interval time.Duration, bars int) ([]proto.Candle, error) {
+		p, n, err := choosePeriod(huobiPeriods, interval, bars, 2000)
+		if err != nil {
+			return nil, err
+		}
+		kl, err := h.GetKLines(symbol, p.name, n)
+		if err != nil {
+			return nil, err
+		}
+		if kl.Status != "ok" {
+			return nil, fmt.Errorf("huobi GetKLines status %s", kl.Status)
+		}
+		var res []proto.Candle
+		for _, k := range kl.KLines {
+			res = append(res, huobi.TransKLine(symbol, p.duration, k))
+		}
+		return res, nil
+	}
+}
+
+// BinanceBackfill 币安历史K线
+func BinanceBackfill(b *binance.Binance) BackfillFunc {
+	return func(symbol string, interval time.Duration, bars int) ([]proto.Candle, error) {
+		p, n, err := choosePeriod(binancePeriods, interval, bars, 1000)
+		if err != nil {
+			return nil, err
+		}
+		klines, err := b.GetKlines(binance.KlineQuery{
+			Symbol:   strings.ToUpper(symbol),
+			Interval: p.name,
+			Limit:    int64(n),
+		})
+		if err != nil {
+			return nil, err
+		}
+		var res []proto.Candle
+		for _, k := range klines {
+			res = append(res, binance.TransKline(symbol, k))
+		}
+		return res, nil
+	}
+}
+
+// FCoinBackfill FCoin历史K线, 需要先 OpenWebsocket
+func FCoinBackfill(f *fcoin.FCoin) BackfillFunc {
+	return func(symbol string, interval time.Duration, bars int) ([]proto.Candle, error) {
+		p, n, err := choosePeriod(fcoinPeriods, interval, bars, 1000)
+		if err != nil {
+			return nil, err
+		}
+		candles, err := f.GetCandles(p.name, symbol, n, 0)
+		if err != nil {
+			return nil, err
+		}
+		var res []proto.Candle
+		for i := range candles {
+			res = append(res, fcoin.TransCandle(symbol, p.duration, &candles[i]))
+		}
+		return res, nil
+	}
+}
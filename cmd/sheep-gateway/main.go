@@ -0,0 +1,103 @@
+// sheep-gateway : 把配置中的交易所和行情以 HTTP/JSON, websocket 和 SSE 提供给其他语言的服务, 接口见 gateway.Server.
+// 指定 -grpc 时同时提供 gRPC 服务, 接口见 rpc/sheep.proto
+//
+//	sheep-gateway -config sheep.yaml -clients clients.yaml -listen :8080 -grpc :9090
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/gpmn/sheep"
+	"github.com/gpmn/sheep/config"
+	"github.com/gpmn/sheep/gateway"
+	"github.com/gpmn/sheep/rpc"
+	"github.com/gpmn/sheep/stream"
+	"google.golang.org/grpc"
+)
+
+func main() {
+	configPath := flag.String("config", "", "YAML配置文件, 见 config.Config")
+	clientsPath := flag.String("clients", "", "调用方令牌文件, 见 gateway.LoadClients")
+	listen := flag.String("listen", ":8080", "监听地址")
+	grpcListen := flag.String("grpc", "", "gRPC监听地址, 为空时不提供gRPC服务")
+	poll := flag.Duration("poll", 5*time.Second, "轮询未完成订单的间隔, 为0时不轮询")
+	buffer := flag.Int("buffer", stream.DefaultBuffer, "每个推送订阅者的缓冲条数")
+	flag.Parse()
+	if *configPath == "" || *clientsPath == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	c, err := config.Load(*configPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	clients, err := gateway.LoadClients(*clientsPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	r, err := config.Build(c)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer r.Close()
+
+	hub := stream.NewHub(*buffer)
+	defer hub.Close()
+	s := gateway.New(r.Exchanges, clients, hub)
+	if *poll > 0 {
+		for _, name := range r.Names() {
+			g := s.Guard(name)
+			g.Start(*poll)
+			defer g.Stop()
+		}
+	}
+	if err := r.OpenFeeds(s.OnDepth, s.OnTrade); err != nil {
+		log.Fatal(err)
+	}
+
+	// gRPC 和 HTTP 共用风控实例, 限额和订单状态一致
+	var grpcSrv *grpc.Server
+	if *grpcListen != "" {
+		guards := make(map[string]sheep.ExchageI)
+		for _, name := range r.Names() {
+			guards[name] = s.Guard(name)
+		}
+		lis, err := net.Listen("tcp", *grpcListen)
+		if err != nil {
+			log.Fatal(err)
+		}
+		grpcSrv = rpc.NewGRPCServer(rpc.New(guards, clients, hub))
+		go func() {
+			log.Printf("sheep-gateway grpc listening on %s", *grpcListen)
+			if err := grpcSrv.Serve(lis); err != nil {
+				log.Fatal(err)
+			}
+		}()
+	}
+
+	srv := &http.Server{Addr: *listen, Handler: s}
+	go func() {
+		sig := make(chan os.Signal, 1)
+		signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+		<-sig
+		if grpcSrv != nil {
+			grpcSrv.Stop()
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(ctx)
+	}()
+	log.Printf("sheep-gateway listening on %s", *listen)
+	if err := srv.ListenAndServe(); err != http.ErrServerClosed {
+		log.Fatal(err)
+	}
+}
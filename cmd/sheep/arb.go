@@ -0,0 +1,97 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/gpmn/sheep/arbitrage"
+	"github.com/gpmn/sheep/binance"
+	"github.com/gpmn/sheep/consts"
+	"github.com/gpmn/sheep/feed"
+	"github.com/gpmn/sheep/huobi"
+	"github.com/gpmn/sheep/proto"
+)
+
+func runArb(e *env, args []string) error {
+	fs := flag.NewFlagSet("arb", flag.ExitOnError)
+	base := fs.String("base", "", "基础币, 如 btc")
+	quote := fs.String("quote", "", "计价币, 如 usdt")
+	exchanges := fs.String("exchanges", "huobi,binance", "交易所类型, 逗号分隔")
+	fee := fs.Float64("fee", 0.002, "吃单手续费率")
+	withdrawBase := fs.Float64("withdraw-base", 0, "基础币提币手续费")
+	withdrawQuote := fs.Float64("withdraw-quote", 0, "计价币提币手续费")
+	maxSize := fs.Float64("max-size", 0, "最大数量, 0 表示不限")
+	minProfit := fs.Float64("min-profit", 0, "最小净利润(计价币)")
+	poll := fs.Duration("poll", 0, "大于0时轮询最优买卖价(只支持 huobi, binance), 否则订阅深度")
+	fs.Parse(args)
+	if *base == "" || *quote == "" {
+		return errors.New("需要 -base 和 -quote")
+	}
+
+	c := arbitrage.Config{Pair: strings.ToLower(*base + "/" + *quote), MaxSize: *maxSize, MinProfit: *minProfit}
+	for _, typ := range strings.Split(*exchanges, ",") {
+		v := arbitrage.Venue{
+			Exchange:      typ,
+			Symbol:        arbitrage.Symbol(typ, *base, *quote),
+			TakerFee:      *fee,
+			WithdrawBase:  *withdrawBase,
+			WithdrawQuote: *withdrawQuote,
+			MaxAge:        time.Minute,
+		}
+		if *poll > 0 {
+			switch typ {
+			case consts.ExchangeTypeHuobi:
+				v.Tickers = arbitrage.HuobiTickers(new(huobi.Huobi))
+			case consts.ExchangeTypeBinance:
+				v.Tickers = arbitrage.BinanceTickers(binance.New("", ""))
+			default:
+				return errors.New(typ + " 不支持轮询")
+			}
+			v.MaxAge = 3 * *poll
+		}
+		c.Venues = append(c.Venues, v)
+	}
+	s := arbitrage.New(c)
+
+	opportunities := make(chan *arbitrage.Opportunity, 1024)
+	s.SetListener(func(o *arbitrage.Opportunity) {
+		select {
+		case opportunities <- o:
+		default:
+		}
+	})
+	if *poll > 0 {
+		s.Start(*poll)
+		defer s.Stop()
+	} else {
+		for _, v := range c.Venues {
+			f, err := feed.New(v.Exchange)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			exchange := v.Exchange
+			f.SetDepthListener(func(symbol string, d *proto.Depth) { s.OnDepth(exchange, symbol, d) })
+			if err := f.SubscribeDepth(v.Symbol); err != nil {
+				return err
+			}
+		}
+	}
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	for {
+		select {
+		case o := <-opportunities:
+			if err := e.print(o); err != nil {
+				return err
+			}
+		case <-interrupt:
+			return nil
+		}
+	}
+}
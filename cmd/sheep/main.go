@@ -0,0 +1,157 @@
+// sheep : 手动交易和查询的命令行工具
+//
+//	sheep [全局参数] <命令> [命令参数]
+//
+// 交易类命令使用 -config 配置中 -exchange 对应的实例, 或环境变量中 -account 对应的账户(见 credentials.Env);
+// 行情类命令只需要 -exchange 指定交易所类型或配置中的名称
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/gpmn/sheep"
+	"github.com/gpmn/sheep/config"
+	"github.com/gpmn/sheep/credentials"
+	"github.com/gpmn/sheep/paper"
+)
+
+type command struct {
+	usage string
+	run   func(e *env, args []string) error
+}
+
+var commands = map[string]command{
+	"balances": {"查询余额", runBalances},
+	"place":    {"下单: -side buy|sell -type limit|market -base btc -quote usdt -price 100 -amount 1", runPlace},
+	"cancel":   {"撤单: -id <订单号> -base btc -quote usdt", runCancel},
+	"order":    {"查询订单: -id <订单号> -base btc -quote usdt", runOrder},
+	"orders":   {"未完成订单, -history 为历史订单: -base btc -quote usdt", runOrders},
+	"depth":    {"深度快照: -symbol btcusdt -levels 10", runDepth},
+	"ticker":   {"行情, 不指定 -symbol 时为全部交易对", runTicker},
+	"klines":   {"K线: -symbol btcusdt -interval 1h -n 24", runKlines},
+	"stream":   {"实时推送, 直到中断: -depth btcusdt,ethusdt -trade btcusdt", runStream},
+	"arb":      {"跨交易所套利机会, 直到中断, 不下单: -base btc -quote usdt -exchanges huobi,okex,binance", runArb},
+}
+
+// env 全局参数
+type env struct {
+	configPath string
+	exchange   string
+	account    string
+	envPrefix  string
+	format     string
+	out        io.Writer
+
+	config *config.Config
+}
+
+func main() {
+	e := &env{out: os.Stdout}
+	fs := flag.NewFlagSet("sheep", flag.ExitOnError)
+	fs.StringVar(&e.configPath, "config", "", "YAML配置文件")
+	fs.StringVar(&e.exchange, "exchange", "", "配置中的交易所名称, 或交易所类型")
+	fs.StringVar(&e.account, "account", "", "不使用配置时, 环境变量中的账户名")
+	fs.StringVar(&e.envPrefix, "env", "SHEEP", "密钥环境变量前缀")
+	fs.StringVar(&e.format, "o", formatTable, "输出格式, table 或 json")
+	fs.Usage = func() { usage(fs) }
+	fs.Parse(os.Args[1:])
+
+	if fs.NArg() == 0 {
+		usage(fs)
+		os.Exit(2)
+	}
+	cmd, ok := commands[fs.Arg(0)]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "未知命令 %s\n", fs.Arg(0))
+		usage(fs)
+		os.Exit(2)
+	}
+	if err := e.init(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if err := cmd.run(e, fs.Args()[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func usage(fs *flag.FlagSet) {
+	fmt.Fprintf(os.Stderr, "用法: sheep [全局参数] <命令> [命令参数]\n\n命令:\n")
+	var names []string
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-9s %s\n", name, commands[name].usage)
+	}
+	fmt.Fprintf(os.Stderr, "\n全局参数:\n")
+	fs.PrintDefaults()
+}
+
+func (e *env) init() error {
+	if e.format != formatTable && e.format != formatJSON {
+		return fmt.Errorf("unknown output format %s", e.format)
+	}
+	if e.configPath == "" {
+		return nil
+	}
+	c, err := config.Load(e.configPath)
+	if err != nil {
+		return err
+	}
+	if err := c.ApplyEndpoints(); err != nil {
+		return err
+	}
+	e.config = c
+	return nil
+}
+
+// trader 交易用的实例, 配置中只创建选中的交易所
+func (e *env) trader() (sheep.ExchageI, error) {
+	if e.config != nil {
+		ex, ok := e.config.Exchange(e.exchange)
+		if !ok {
+			return nil, fmt.Errorf("exchange %q not in config", e.exchange)
+		}
+		c := *e.config
+		c.Exchanges = []config.Exchange{ex}
+		c.Subscriptions = nil
+		c.Credentials.Reload = 0
+		r, err := config.Build(&c)
+		if err != nil {
+			return nil, err
+		}
+		return r.Exchanges[ex.Name], nil
+	}
+
+	if e.account == "" {
+		return nil, errors.New("需要 -config 和 -exchange, 或 -account")
+	}
+	store, err := credentials.NewStore(credentials.Env(e.envPrefix))
+	if err != nil {
+		return nil, err
+	}
+	return store.Exchange(e.account)
+}
+
+// exchangeType 行情用的交易所类型, 模拟盘使用所模拟交易所的行情
+func (e *env) exchangeType() (string, error) {
+	typ := e.exchange
+	if e.config != nil {
+		if ex, ok := e.config.Exchange(e.exchange); ok {
+			typ = ex.Type
+		}
+	}
+	if typ == "" {
+		return "", errors.New("需要 -exchange")
+	}
+	return strings.TrimPrefix(typ, paper.Prefix), nil
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gpmn/sheep/binance"
+	"github.com/gpmn/sheep/candles"
+	"github.com/gpmn/sheep/consts"
+	"github.com/gpmn/sheep/fcoin"
+	"github.com/gpmn/sheep/feed"
+	"github.com/gpmn/sheep/huobi"
+	"github.com/gpmn/sheep/proto"
+)
+
+func runDepth(e *env, args []string) error {
+	fs := flag.NewFlagSet("depth", flag.ExitOnError)
+	symbol := fs.String("symbol", "", "交易对, 交易所自己的格式")
+	levels := fs.Int("levels", 10, "档数")
+	timeout := fs.Duration("timeout", 10*time.Second, "等待推送的时间")
+	fs.Parse(args)
+	if *symbol == "" {
+		return errors.New("需要 -symbol")
+	}
+
+	typ, err := e.exchangeType()
+	if err != nil {
+		return err
+	}
+	f, err := feed.New(typ)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	f.Levels = *levels
+
+	depths := make(chan *proto.Depth, 1)
+	f.SetDepthListener(func(symbol string, depth *proto.Depth) {
+		select {
+		case depths <- depth:
+		default:
+		}
+	})
+	if err := f.SubscribeDepth(*symbol); err != nil {
+		return err
+	}
+	select {
+	case depth := <-depths:
+		if e.format == formatJSON {
+			return e.print(depth)
+		}
+		return e.print(depthRows(depth))
+	case <-time.After(*timeout):
+		return errors.New("depth timeout")
+	}
+}
+
+// depthRow 表格输出时买卖盘并排
+type depthRow struct {
+	BidAmount string `json:"bid_amount"`
+	BidPrice  string `json:"bid_price"`
+	AskPrice  string `json:"ask_price"`
+	AskAmount string `json:"ask_amount"`
+}
+
+func depthRows(d *proto.Depth) []depthRow {
+	n := len(d.Bids)
+	if len(d.Asks) > n {
+		n = len(d.Asks)
+	}
+	rows := make([]depthRow, n)
+	for i := range rows {
+		if i < len(d.Bids) {
+			rows[i].BidAmount, rows[i].BidPrice = formatFloat(d.Bids[i].Amount), formatFloat(d.Bids[i].Price)
+		}
+		if i < len(d.Asks) {
+			rows[i].AskPrice, rows[i].AskAmount = formatFloat(d.Asks[i].Price), formatFloat(d.Asks[i].Amount)
+		}
+	}
+	return rows
+}
+
+// ticker 各交易所没有的字段为0
+type ticker struct {
+	Symbol string  `json:"symbol"`
+	Last   float64 `json:"last"`
+	Bid    float64 `json:"bid"`
+	Ask    float64 `json:"ask"`
+	Volume float64 `json:"volume"` // 24小时成交量
+}
+
+func runTicker(e *env, args []string) error {
+	fs := flag.NewFlagSet("ticker", flag.ExitOnError)
+	symbol := fs.String("symbol", "", "交易对, 为空时为全部")
+	fs.Parse(args)
+
+	typ, err := e.exchangeType()
+	if err != nil {
+		return err
+	}
+	tickers, err := getTickers(typ, strings.ToLower(*symbol))
+	if err != nil {
+		return err
+	}
+	sort.Slice(tickers, func(i, j int) bool { return tickers[i].Symbol < tickers[j].Symbol })
+	return e.print(tickers)
+}
+
+func getTickers(typ, symbol string) ([]ticker, error) {
+	res := []ticker{}
+	switch typ {
+	case consts.ExchangeTypeHuobi:
+		m, err := new(huobi.Huobi).GetTickers()
+		if err != nil {
+			return nil, err
+		}
+		for s, t := range m {
+			if symbol == "" || s == symbol {
+				res = append(res, ticker{Symbol: s, Last: t.Price, Volume: t.Amount})
+			}
+		}
+		return res, nil
+	case consts.ExchangeTypeBinance:
+		b := binance.New("", "")
+		if symbol != "" {
+			s, err := b.Get24Hr(binance.SymbolQuery{Symbol: strings.ToUpper(symbol)})
+			if err != nil {
+				return nil, err
+			}
+			return append(res, ticker{Symbol: symbol, Last: s.LastPrice, Bid: s.BidPrice, Ask: s.AskPrice, Volume: s.Volume}), nil
+		}
+		books, err := b.GetBookTickers()
+		if err != nil {
+			return nil, err
+		}
+		for _, t := range books {
+			res = append(res, ticker{Symbol: strings.ToLower(t.Symbol), Bid: t.BidPrice, Ask: t.AskPrice})
+		}
+		return res, nil
+	}
+	return nil, errors.New("该交易所不支持 ticker")
+}
+
+func runKlines(e *env, args []string) error {
+	fs := flag.NewFlagSet("klines", flag.ExitOnError)
+	symbol := fs.String("symbol", "", "交易对")
+	interval := fs.Duration("interval", time.Hour, "周期, 如 1m, 1h, 24h")
+	n := fs.Int("n", 24, "根数")
+	fs.Parse(args)
+	if *symbol == "" {
+		return errors.New("需要 -symbol")
+	}
+
+	typ, err := e.exchangeType()
+	if err != nil {
+		return err
+	}
+	var backfill candles.BackfillFunc
+	switch typ {
+	case consts.ExchangeTypeHuobi:
+		backfill = candles.HuobiBackfill(new(huobi.Huobi))
+	case consts.ExchangeTypeBinance:
+		backfill = candles.BinanceBackfill(binance.New("", ""))
+	case consts.ExchangeTypeFCoin:
+		f := new(fcoin.FCoin)
+		if err := f.OpenWebsocket(); err != nil {
+			return err
+		}
+		defer f.CloseWebsocket()
+		backfill = candles.FCoinBackfill(f)
+	default:
+		return errors.New("该交易所不支持 klines")
+	}
+
+	// 交易所周期小于 interval 时合并
+	bars, err := backfill(strings.ToLower(*symbol), *interval, *n)
+	if err != nil {
+		return err
+	}
+	bars = candles.Resample(bars, *interval)
+	if len(bars) > *n {
+		bars = bars[len(bars)-*n:]
+	}
+	if bars == nil {
+		bars = []proto.Candle{}
+	}
+	return e.print(bars)
+}
+
+// event stream 输出的一条推送
+type event struct {
+	Exchange string       `json:"exchange"`
+	Symbol   string       `json:"symbol"`
+	Depth    *proto.Depth `json:"depth,omitempty"`
+	Trade    *proto.Trade `json:"trade,omitempty"`
+}
+
+func runStream(e *env, args []string) error {
+	fs := flag.NewFlagSet("stream", flag.ExitOnError)
+	depth := fs.String("depth", "", "订阅深度的交易对, 逗号分隔")
+	trade := fs.String("trade", "", "订阅成交的交易对, 逗号分隔")
+	levels := fs.Int("levels", 5, "深度档数")
+	fs.Parse(args)
+	if *depth == "" && *trade == "" {
+		return errors.New("需要 -depth 或 -trade")
+	}
+
+	typ, err := e.exchangeType()
+	if err != nil {
+		return err
+	}
+	f, err := feed.New(typ)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	f.Levels = *levels
+
+	events := make(chan event, 1024)
+	f.SetDepthListener(func(symbol string, d *proto.Depth) {
+		events <- event{Exchange: typ, Symbol: symbol, Depth: d}
+	})
+	f.SetTradeListener(func(symbol string, t *proto.Trade) {
+		events <- event{Exchange: typ, Symbol: symbol, Trade: t}
+	})
+	if *depth != "" {
+		if err := f.SubscribeDepth(strings.Split(*depth, ",")...); err != nil {
+			return err
+		}
+	}
+	if *trade != "" {
+		if err := f.SubscribeTrade(strings.Split(*trade, ",")...); err != nil {
+			return err
+		}
+	}
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	for {
+		select {
+		case ev := <-events:
+			if err := e.printEvent(ev); err != nil {
+				return err
+			}
+		case <-interrupt:
+			return nil
+		}
+	}
+}
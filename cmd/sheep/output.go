@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+const (
+	formatTable = "table"
+	formatJSON  = "json"
+)
+
+// print 输出结构体或结构体切片, 表格的列名为 json tag
+func (e *env) print(v interface{}) error {
+	if e.format == formatJSON {
+		enc := json.NewEncoder(e.out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	}
+
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	var rows []reflect.Value
+	if rv.Kind() == reflect.Slice {
+		for i := 0; i < rv.Len(); i++ {
+			rows = append(rows, reflect.Indirect(rv.Index(i)))
+		}
+	} else {
+		rows = append(rows, rv)
+	}
+	typ := rv.Type()
+	if rv.Kind() == reflect.Slice {
+		typ = typ.Elem()
+		if typ.Kind() == reflect.Ptr {
+			typ = typ.Elem()
+		}
+	}
+	if typ.Kind() != reflect.Struct {
+		_, err := fmt.Fprintln(e.out, v)
+		return err
+	}
+
+	w := tabwriter.NewWriter(e.out, 0, 0, 2, ' ', 0)
+	var columns []int
+	var header []string
+	for i := 0; i < typ.NumField(); i++ {
+		name := strings.Split(typ.Field(i).Tag.Get("json"), ",")[0]
+		if name == "-" || typ.Field(i).PkgPath != "" {
+			continue
+		}
+		if name == "" {
+			name = typ.Field(i).Name
+		}
+		columns = append(columns, i)
+		header = append(header, strings.ToUpper(name))
+	}
+	fmt.Fprintln(w, strings.Join(header, "\t"))
+	for _, row := range rows {
+		cells := make([]string, 0, len(columns))
+		for _, i := range columns {
+			cells = append(cells, formatValue(row.Field(i)))
+		}
+		fmt.Fprintln(w, strings.Join(cells, "\t"))
+	}
+	return w.Flush()
+}
+
+func formatValue(v reflect.Value) string {
+	switch v.Kind() {
+	case reflect.Float32, reflect.Float64:
+		return formatFloat(v.Float())
+	case reflect.Ptr:
+		if v.IsNil() {
+			return ""
+		}
+		return formatValue(v.Elem())
+	}
+	return fmt.Sprint(v.Interface())
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// printEvent 每条推送一行, json 格式为一行一个对象
+func (e *env) printEvent(ev event) error {
+	if e.format == formatJSON {
+		return json.NewEncoder(e.out).Encode(ev)
+	}
+
+	now := time.Now().Format("15:04:05.000")
+	if ev.Trade != nil {
+		t := ev.Trade
+		_, err := fmt.Fprintf(e.out, "%s %s %s trade %s %s %s\n", now, ev.Exchange, ev.Symbol, t.Side, formatFloat(t.Price), formatFloat(t.Amount))
+		return err
+	}
+	line := []string{now, ev.Exchange, ev.Symbol, "depth"}
+	for i, bid := range ev.Depth.Bids {
+		line = append(line, fmt.Sprintf("bid%d %s@%s", i+1, formatFloat(bid.Amount), formatFloat(bid.Price)))
+	}
+	for i, ask := range ev.Depth.Asks {
+		line = append(line, fmt.Sprintf("ask%d %s@%s", i+1, formatFloat(ask.Amount), formatFloat(ask.Price)))
+	}
+	_, err := fmt.Fprintln(e.out, strings.Join(line, " "))
+	return err
+}
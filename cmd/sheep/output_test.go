@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/gpmn/sheep/consts"
+	"github.com/gpmn/sheep/proto"
+	"github.com/gpmn/sheep/sim"
+)
+
+func TestPrint(t *testing.T) {
+	var buf bytes.Buffer
+	e := &env{out: &buf, format: formatTable}
+	err := e.print([]proto.AccountBalance{
+		{Currency: "btc", Balance: 0.00000001, Type: proto.AccountBalanceTypeTrade},
+		{Currency: "usdt", Balance: 1500, Type: proto.AccountBalanceTypeFrozen},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 || strings.Fields(lines[0])[0] != "CURRENCY" {
+		t.Fatalf("table\n%s", buf.String())
+	}
+	if f := strings.Fields(lines[1]); f[1] != "0.00000001" || f[2] != "trade" {
+		t.Fatalf("row %q", lines[1])
+	}
+
+	buf.Reset()
+	e.format = formatJSON
+	if err := e.print(&proto.OrderPlaceReturn{OrderID: "42"}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), `"order_id": "42"`) {
+		t.Fatalf("json %s", buf.String())
+	}
+}
+
+func TestOrdersParams(t *testing.T) {
+	p := pair{base: "BTC", quote: "usdt"}
+	if params := ordersParams(consts.ExchangeTypeHuobi, p, false); params.Symbol != "btcusdt" || params.States != "submitted,partial-filled" {
+		t.Fatalf("huobi %+v", params)
+	}
+	if params := ordersParams(consts.ExchangeTypeOKEX, p, true); params.Status != "1" || params.States != "" {
+		t.Fatalf("okex %+v", params)
+	}
+}
+
+func TestPrecision(t *testing.T) {
+	if decimals(0.01) != 2 || decimals(6543) != 0 || decimals(1.5e-7) != 8 {
+		t.Fatal(decimals(0.01), decimals(6543), decimals(1.5e-7))
+	}
+	params := &proto.OrderPlaceParams{Price: 6543.21, Amount: 0.01, PricePrecision: -1, AmountPrecision: 4}
+	if err := precision(sim.NewExchange(sim.Config{}, nil), &pair{base: "btc", quote: "usdt"}, params); err != nil || params.PricePrecision != 2 || params.AmountPrecision != 4 {
+		t.Fatalf("%+v %v", params, err)
+	}
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"strconv"
+	"strings"
+
+	"github.com/gpmn/sheep"
+	"github.com/gpmn/sheep/consts"
+	"github.com/gpmn/sheep/huobi"
+	"github.com/gpmn/sheep/paper"
+	"github.com/gpmn/sheep/proto"
+)
+
+// pair 交易对参数
+type pair struct {
+	base, quote string
+}
+
+func (p *pair) register(fs *flag.FlagSet) {
+	fs.StringVar(&p.base, "base", "", "基础币, 如 btc")
+	fs.StringVar(&p.quote, "quote", "", "计价币, 如 usdt")
+}
+
+func (p *pair) check() error {
+	if p.base == "" || p.quote == "" {
+		return errors.New("需要 -base 和 -quote")
+	}
+	return nil
+}
+
+func (p *pair) symbol() string {
+	return strings.ToLower(p.base + p.quote)
+}
+
+func runBalances(e *env, args []string) error {
+	fs := flag.NewFlagSet("balances", flag.ExitOnError)
+	all := fs.Bool("all", false, "包括余额为0的币种")
+	fs.Parse(args)
+
+	ex, err := e.trader()
+	if err != nil {
+		return err
+	}
+	balances, err := ex.GetAccountBalance()
+	if err != nil {
+		return err
+	}
+	res := []proto.AccountBalance{}
+	for _, b := range balances {
+		if *all || b.Balance != 0 {
+			res = append(res, b)
+		}
+	}
+	return e.print(res)
+}
+
+func runPlace(e *env, args []string) error {
+	fs := flag.NewFlagSet("place", flag.ExitOnError)
+	var p pair
+	p.register(fs)
+	side := fs.String("side", "", "buy 或 sell")
+	typ := fs.String("type", "limit", "limit 或 market")
+	price := fs.Float64("price", 0, "限价单价格")
+	amount := fs.Float64("amount", 0, "数量, 市价买单为花费的计价币数量")
+	pricePrecision := fs.Int("price-precision", -1, "价格小数位数, 小于0时 huobi 查询交易对精度, 其他交易所使用输入的小数位数")
+	amountPrecision := fs.Int("amount-precision", -1, "数量小数位数, 同 -price-precision")
+	fs.Parse(args)
+
+	if err := p.check(); err != nil {
+		return err
+	}
+	if *side != "buy" && *side != "sell" {
+		return errors.New("-side 必须为 buy 或 sell")
+	}
+	if *typ != "limit" && *typ != "market" {
+		return errors.New("-type 必须为 limit 或 market")
+	}
+	if *amount <= 0 || (*typ == "limit" && *price <= 0) {
+		return errors.New("需要 -amount, 限价单需要 -price")
+	}
+
+	ex, err := e.trader()
+	if err != nil {
+		return err
+	}
+	params := &proto.OrderPlaceParams{
+		Price:           *price,
+		Amount:          *amount,
+		BaseCurrencyID:  p.base,
+		QuoteCurrencyID: p.quote,
+		Type:            *side + "-" + *typ,
+		PricePrecision:  *pricePrecision,
+		AmountPrecision: *amountPrecision,
+	}
+	if err := precision(ex, &p, params); err != nil {
+		return err
+	}
+	ret, err := ex.OrderPlace(params)
+	if err != nil {
+		return err
+	}
+	return e.print(ret)
+}
+
+// decimals 输入值的小数位数
+func decimals(f float64) int {
+	s := strconv.FormatFloat(f, 'f', -1, 64)
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		return len(s) - i - 1
+	}
+	return 0
+}
+
+// precision 补全小于0的下单精度. huobi 按精度格式化价格和数量, 查询交易对精度;
+// 其他交易所或查不到时使用输入值的小数位数. 市价买单的数量是计价币, 不使用交易对的数量精度
+func precision(ex sheep.ExchageI, p *pair, params *proto.OrderPlaceParams) error {
+	if params.PricePrecision >= 0 && params.AmountPrecision >= 0 {
+		return nil
+	}
+	if strings.TrimPrefix(ex.GetExchangeType(), paper.Prefix) == consts.ExchangeTypeHuobi {
+		symbols, err := new(huobi.Huobi).GetSymbols()
+		if err != nil {
+			return err
+		}
+		for _, desc := range symbols {
+			if desc.Symbol == p.symbol() {
+				if params.PricePrecision < 0 {
+					params.PricePrecision = desc.PricePrecision
+				}
+				if params.AmountPrecision < 0 && params.Type != proto.OrderPlaceTypeBuyMarket {
+					params.AmountPrecision = desc.AmountPrecision
+				}
+			}
+		}
+	}
+	if params.PricePrecision < 0 {
+		params.PricePrecision = decimals(params.Price)
+	}
+	if params.AmountPrecision < 0 {
+		params.AmountPrecision = decimals(params.Amount)
+	}
+	return nil
+}
+
+func runCancel(e *env, args []string) error {
+	fs := flag.NewFlagSet("cancel", flag.ExitOnError)
+	var p pair
+	p.register(fs)
+	id := fs.String("id", "", "订单号")
+	fs.Parse(args)
+	if *id == "" {
+		return errors.New("需要 -id")
+	}
+
+	ex, err := e.trader()
+	if err != nil {
+		return err
+	}
+	err = ex.OrderCancel(&proto.OrderCancelParams{OrderID: *id, BaseCurrencyID: p.base, QuoteCurrencyID: p.quote})
+	if err != nil {
+		return err
+	}
+	return e.print(proto.OrderPlaceReturn{OrderID: *id})
+}
+
+func runOrder(e *env, args []string) error {
+	fs := flag.NewFlagSet("order", flag.ExitOnError)
+	var p pair
+	p.register(fs)
+	id := fs.String("id", "", "订单号")
+	fs.Parse(args)
+	if *id == "" {
+		return errors.New("需要 -id")
+	}
+
+	ex, err := e.trader()
+	if err != nil {
+		return err
+	}
+	order, err := ex.GetOrderInfo(&proto.OrderInfoParams{OrderID: *id, BaseCurrencyID: p.base, QuoteCurrencyID: p.quote})
+	if err != nil {
+		return err
+	}
+	return e.print(order)
+}
+
+func runOrders(e *env, args []string) error {
+	fs := flag.NewFlagSet("orders", flag.ExitOnError)
+	var p pair
+	p.register(fs)
+	history := fs.Bool("history", false, "查询已完成的订单")
+	fs.Parse(args)
+	if err := p.check(); err != nil {
+		return err
+	}
+
+	ex, err := e.trader()
+	if err != nil {
+		return err
+	}
+	orders, err := ex.GetOrders(ordersParams(ex.GetExchangeType(), p, *history))
+	if err != nil {
+		return err
+	}
+	if orders == nil {
+		orders = []proto.Order{}
+	}
+	return e.print(orders)
+}
+
+// ordersParams 各交易所查询订单列表的参数不同, 见 proto.OrdersParams
+func ordersParams(exchangeType string, p pair, history bool) *proto.OrdersParams {
+	params := &proto.OrdersParams{
+		Symbol:          p.symbol(),
+		BaseCurrencyID:  p.base,
+		QuoteCurrencyID: p.quote,
+	}
+	if exchangeType == consts.ExchangeTypeOKEX {
+		params.Status = "0"
+		if history {
+			params.Status = "1"
+		}
+		params.CurrentPage, params.PageLength = "1", "200"
+		return params
+	}
+	params.States = proto.OrderStateSubmitted + "," + proto.OrderStatePartialFilled
+	if history {
+		params.States = proto.OrderStateFilled + "," + proto.OrderStateCanceled
+	}
+	return params
+}
@@ -3,8 +3,6 @@ package coinpark
 import (
 	"errors"
 
-	"log"
-
 	"github.com/gpmn/sheep/util"
 )
 
@@ -33,9 +31,10 @@ func Ping() bool {
 		"cmd": "ping",
 	}
 
-	ret := util.HttpGetRequest(CoinParkHost+path+"?"+util.Map2UrlQuery(req), req)
-
-	log.Println(ret)
+	_, err := util.HttpGetRequest(CoinParkHost+path+"?"+util.Map2UrlQuery(req), req)
+	if err != nil {
+		return false
+	}
 
 	return true
 
@@ -1,7 +1,16 @@
 package coinpark
 
-import "github.com/gpmn/sheep/util"
+import (
+	"crypto/hmac"
+
+	"github.com/gpmn/sheep/util"
+)
 
 func CreateSign(secret, cmds string) string {
 	return util.ComputeHmacMd5(cmds, secret)
 }
+
+// VerifySign 校验cmds的签名
+func VerifySign(secret, cmds, sign string) bool {
+	return hmac.Equal([]byte(CreateSign(secret, cmds)), []byte(sign))
+}
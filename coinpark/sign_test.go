@@ -0,0 +1,17 @@
+package coinpark
+
+import "testing"
+
+func TestCreateSign(t *testing.T) {
+	cmds := `[{"cmd":"user/userInfo","body":{}}]`
+	want := "db1a4d267ce703289c18e9caebf8a653"
+	if sign := CreateSign("sk", cmds); sign != want {
+		t.Fatalf("sign %s, want %s", sign, want)
+	}
+	if !VerifySign("sk", cmds, want) {
+		t.Fatal("VerifySign failed")
+	}
+	if VerifySign("wrong", cmds, want) {
+		t.Fatal("VerifySign accepted wrong secret")
+	}
+}
@@ -0,0 +1,222 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gpmn/sheep"
+	"github.com/gpmn/sheep/bibox"
+	"github.com/gpmn/sheep/binance"
+	"github.com/gpmn/sheep/consts"
+	"github.com/gpmn/sheep/credentials"
+	"github.com/gpmn/sheep/fcoin"
+	"github.com/gpmn/sheep/huobi"
+	"github.com/gpmn/sheep/okex"
+	"github.com/gpmn/sheep/risk"
+	"gopkg.in/yaml.v2"
+)
+
+// Config : 声明式配置, 描述交易所, 密钥, 接口地址, 行情订阅和风控参数.
+// 文件中的 ${VAR} 在解析前用环境变量替换, 密钥可以不写入文件
+type Config struct {
+	Credentials   Credentials         `yaml:"credentials"`
+	Endpoints     map[string]Endpoint `yaml:"endpoints"` // 按交易所类型覆盖接口地址, 用于测试网或镜像
+	Exchanges     []Exchange          `yaml:"exchanges"`
+	Subscriptions []Subscription      `yaml:"subscriptions"`
+}
+
+// Credentials 密钥来源, 多个来源中同名的账户以 file, env, accounts 的顺序后者为准
+type Credentials struct {
+	File     string                   `yaml:"file"`     // credentials.File 的JSON文件
+	Env      string                   `yaml:"env"`      // credentials.Env 的环境变量前缀
+	Accounts []credentials.Credential `yaml:"accounts"` // 直接写在配置中的账户
+	Reload   time.Duration            `yaml:"reload"`   // 自动重新加载的间隔, 为0时不自动加载
+}
+
+// Endpoint 接口地址, 为空的项使用默认值.
+// 各交易所的地址是包级变量, 同一类型的所有实例共用
+type Endpoint struct {
+	REST      string `yaml:"rest"`
+	Websocket string `yaml:"websocket"`
+}
+
+// Exchange 一个交易所实例
+type Exchange struct {
+	Name         string        `yaml:"name"`
+	Type         string        `yaml:"type"`          // consts.ExchangeTypeXXX, 或 paper:<交易所>
+	Credential   string        `yaml:"credential"`    // 账户名, 为空时不使用密钥
+	RateLimit    time.Duration `yaml:"rate_limit"`    // 两次调用的最小间隔, 见 sheep.RateLimitInterceptor
+	Retry        int           `yaml:"retry"`         // 网络错误的最多尝试次数, 见 sheep.RetryInterceptor
+	RetryBackoff time.Duration `yaml:"retry_backoff"` // 重试间隔
+	Risk         *Risk         `yaml:"risk"`          // 下单前风控, 为空时不检查
+}
+
+// Risk 风控参数, 见 risk.Limits
+type Risk struct {
+	MaxOrderNotional float64            `yaml:"max_order_notional"`
+	MaxPosition      map[string]float64 `yaml:"max_position"`
+	MaxOpenOrders    int                `yaml:"max_open_orders"`
+	PriceBand        float64            `yaml:"price_band"`
+	FatFinger        float64            `yaml:"fat_finger"`
+	MaxDailyLoss     float64            `yaml:"max_daily_loss"`
+}
+
+// Limits 转换为 risk.Limits
+func (r Risk) Limits() risk.Limits {
+	return risk.Limits{
+		MaxOrderNotional: r.MaxOrderNotional,
+		MaxPosition:      r.MaxPosition,
+		MaxOpenOrders:    r.MaxOpenOrders,
+		PriceBand:        r.PriceBand,
+		FatFinger:        r.FatFinger,
+		MaxDailyLoss:     r.MaxDailyLoss,
+	}
+}
+
+// Subscription 一个交易所的行情订阅, 见 feed.Feed
+type Subscription struct {
+	Exchange string   `yaml:"exchange"` // consts.ExchangeTypeXXX
+	Depth    []string `yaml:"depth"`    // 订阅深度的交易对
+	Trade    []string `yaml:"trade"`    // 订阅成交的交易对
+	Levels   int      `yaml:"levels"`   // 深度推送的档数, 为0时使用 feed.DefaultLevels
+}
+
+// Load 读取并解析配置文件
+func Load(path string) (*Config, error) {
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	c, err := Parse(buf)
+	if err != nil {
+		return nil, fmt.Errorf("%s : %v", path, err)
+	}
+	return c, nil
+}
+
+// Parse 解析YAML配置并检查
+func Parse(buf []byte) (*Config, error) {
+	var c Config
+	if err := yaml.UnmarshalStrict([]byte(os.ExpandEnv(string(buf))), &c); err != nil {
+		return nil, err
+	}
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// Validate 检查名称, 类型和引用
+func (c *Config) Validate() error {
+	for typ := range c.Endpoints {
+		if _, ok := endpointSetters[typ]; !ok {
+			return fmt.Errorf("endpoints : unknown exchange type %s", typ)
+		}
+	}
+
+	names := make(map[string]bool)
+	creds := make(map[string]string)
+	for i, ex := range c.Exchanges {
+		if ex.Name == "" {
+			return fmt.Errorf("exchanges[%d] : name empty", i)
+		}
+		if names[ex.Name] {
+			return fmt.Errorf("exchange %s : duplicate name", ex.Name)
+		}
+		names[ex.Name] = true
+		if ex.Type == "" {
+			return fmt.Errorf("exchange %s : type empty", ex.Name)
+		}
+		if !sheep.Supported(ex.Type) {
+			return fmt.Errorf("exchange %s : unsupported type %s", ex.Name, ex.Type)
+		}
+		if ex.Credential != "" {
+			// Store 每个账户只创建一个实例, 两个配置共用时后者的拦截器不会生效
+			if other, ok := creds[ex.Credential]; ok {
+				return fmt.Errorf("exchange %s : credential %s already used by %s", ex.Name, ex.Credential, other)
+			}
+			creds[ex.Credential] = ex.Name
+		}
+		if ex.RateLimit < 0 || ex.Retry < 0 || ex.RetryBackoff < 0 {
+			return fmt.Errorf("exchange %s : negative rate limit or retry", ex.Name)
+		}
+	}
+
+	subs := make(map[string]bool)
+	for i, s := range c.Subscriptions {
+		if s.Exchange == "" {
+			return fmt.Errorf("subscriptions[%d] : exchange empty", i)
+		}
+		if subs[s.Exchange] {
+			return fmt.Errorf("subscription %s : duplicate exchange", s.Exchange)
+		}
+		subs[s.Exchange] = true
+		if s.Levels < 0 {
+			return fmt.Errorf("subscription %s : negative levels", s.Exchange)
+		}
+	}
+	return nil
+}
+
+// Exchange 按名称查找交易所配置
+func (c *Config) Exchange(name string) (Exchange, bool) {
+	for _, ex := range c.Exchanges {
+		if ex.Name == name {
+			return ex, true
+		}
+	}
+	return Exchange{}, false
+}
+
+var endpointSetters = map[string]func(e Endpoint){
+	consts.ExchangeTypeHuobi: func(e Endpoint) {
+		setString(&huobi.Host, e.REST)
+		setString(&huobi.Endpoint, e.Websocket)
+	},
+	consts.ExchangeTypeOKEX: func(e Endpoint) {
+		setString(&okex.APIURL, withSlash(e.REST))
+		setString(&okex.Endpoint, e.Websocket)
+	},
+	consts.ExchangeTypeFCoin: func(e Endpoint) {
+		setString(&fcoin.FCoinHost, withSlash(e.REST))
+		setString(&fcoin.Endpoint, e.Websocket)
+	},
+	consts.ExchangeTypeBinance: func(e Endpoint) {
+		setString(&binance.BaseUrl, e.REST)
+		setString(&binance.StreamEndpoint, e.Websocket)
+	},
+	consts.ExchangeTypeBibox: func(e Endpoint) {
+		setString(&bibox.BiboxHost, e.REST)
+	},
+}
+
+func setString(dst *string, value string) {
+	if value != "" {
+		*dst = value
+	}
+}
+
+// withSlash okex, fcoin 的地址以 / 结尾, 直接拼接路径
+func withSlash(url string) string {
+	if url != "" && !strings.HasSuffix(url, "/") {
+		return url + "/"
+	}
+	return url
+}
+
+// ApplyEndpoints 把 Endpoints 写入各交易所包的地址变量, 需要在创建实例和连接 websocket 之前调用.
+// 模拟盘(paper:<交易所>)使用该交易所的地址
+func (c *Config) ApplyEndpoints() error {
+	for typ, e := range c.Endpoints {
+		set, ok := endpointSetters[typ]
+		if !ok {
+			return errors.New("不支持该交易所: " + typ)
+		}
+		set(e)
+	}
+	return nil
+}
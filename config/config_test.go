@@ -0,0 +1,129 @@
+package config
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gpmn/sheep/huobi"
+	"github.com/gpmn/sheep/mockserver"
+	"github.com/gpmn/sheep/okex"
+	"github.com/gpmn/sheep/proto"
+	"github.com/gpmn/sheep/risk"
+)
+
+func TestParse(t *testing.T) {
+	os.Setenv("SHEEP_TEST_SECRET", "sk")
+	defer os.Unsetenv("SHEEP_TEST_SECRET")
+	c, err := Parse([]byte(`
+credentials:
+  accounts:
+    - {name: main, exchange: huobi, access_key: ak, secret_key: "${SHEEP_TEST_SECRET}"}
+exchanges:
+  - name: spot
+    type: huobi
+    credential: main
+    rate_limit: 100ms
+    retry: 3
+    risk:
+      max_order_notional: 1000
+      max_position: {btc: 2}
+subscriptions:
+  - exchange: binance
+    depth: [BTCUSDT]
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a := c.Credentials.Accounts; len(a) != 1 || a[0].SecretKey != "sk" {
+		t.Fatalf("accounts %+v", a)
+	}
+	e, ok := c.Exchange("spot")
+	if !ok || e.RateLimit != 100*time.Millisecond || e.Retry != 3 {
+		t.Fatalf("exchange %+v", e)
+	}
+	if l := e.Risk.Limits(); l.MaxOrderNotional != 1000 || l.MaxPosition["btc"] != 2 {
+		t.Fatalf("limits %+v", l)
+	}
+
+	for _, bad := range []string{
+		"exchanges: [{name: a, type: huobi}, {name: a, type: okex}]",
+		"exchanges: [{name: a}]",
+		"exchanges: [{name: a, type: binance}]",
+		"exchanges: [{name: a, type: \"paper:okex\"}]",
+		"exchanges: [{name: a, type: huobi, credential: x}, {name: b, type: huobi, credential: x}]",
+		"endpoints: {unknown: {rest: http://localhost}}",
+		"subscriptions: [{depth: [btcusdt]}]",
+		"exchanges: [{name: a, type: huobi, ratelimit: 1s}]",
+	} {
+		if _, err := Parse([]byte(bad)); err == nil {
+			t.Fatalf("accepted %q", bad)
+		}
+	}
+}
+
+func TestBuild(t *testing.T) {
+	s := mockserver.NewHuobi("ak", "sk")
+	defer s.Close()
+	defer func(host, endpoint, api string) { huobi.Host, huobi.Endpoint, okex.APIURL = host, endpoint, api }(huobi.Host, huobi.Endpoint, okex.APIURL)
+
+	c, err := Parse([]byte(`
+endpoints:
+  huobi: {rest: "` + s.URL + `", websocket: "` + s.WSURL("/ws") + `"}
+  okex: {rest: "http://localhost:1/api"}
+credentials:
+  accounts:
+    - {name: main, exchange: huobi, access_key: ak, secret_key: sk}
+exchanges:
+  - name: spot
+    type: huobi
+    credential: main
+    risk: {max_order_notional: 1000}
+subscriptions:
+  - exchange: huobi
+    trade: [BTCUSDT]
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := Build(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if okex.APIURL != "http://localhost:1/api/" {
+		t.Fatalf("okex url %s", okex.APIURL)
+	}
+	if names := r.Names(); len(names) != 1 || names[0] != "spot" || r.Guards["spot"] == nil {
+		t.Fatalf("names %v", names)
+	}
+
+	_, err = r.Exchanges["spot"].OrderPlace(&proto.OrderPlaceParams{
+		Price: 100, Amount: 20, BaseCurrencyID: "btc", QuoteCurrencyID: "usdt", Type: "buy-limit",
+	})
+	if e, ok := err.(*risk.RejectError); !ok || e.Rule != risk.RuleOrderNotional {
+		t.Fatalf("got %v", err)
+	}
+
+	trades := make(chan *proto.Trade, 1)
+	err = r.OpenFeeds(nil, func(exchange, symbol string, trade *proto.Trade) {
+		if exchange == "huobi" && strings.EqualFold(symbol, "btcusdt") {
+			trades <- trade
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Publish("market.btcusdt.trade.detail", map[string]interface{}{
+		"data": []map[string]interface{}{{"amount": 1, "direction": "sell", "price": 100, "ts": 1}},
+	})
+	select {
+	case tr := <-trades:
+		if tr.Price != 100 {
+			t.Fatalf("trade %+v", tr)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("trade not received")
+	}
+}
@@ -0,0 +1,153 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/gpmn/sheep"
+	"github.com/gpmn/sheep/credentials"
+	"github.com/gpmn/sheep/feed"
+	"github.com/gpmn/sheep/proto"
+	"github.com/gpmn/sheep/risk"
+)
+
+// Runtime 由配置创建的对象
+type Runtime struct {
+	Config      *Config
+	Credentials *credentials.Store
+	// Exchanges 按名称的交易所实例, 配置了风控时为 *risk.Guard
+	Exchanges map[string]sheep.ExchageI
+	Guards    map[string]*risk.Guard
+	// Feeds 按交易所类型的行情, OpenFeeds 之后可用
+	Feeds map[string]*feed.Feed
+}
+
+// Build 设置接口地址, 加载密钥并创建所有交易所实例, 行情在 OpenFeeds 时连接
+func Build(c *Config) (*Runtime, error) {
+	if err := c.ApplyEndpoints(); err != nil {
+		return nil, err
+	}
+
+	loaders := []credentials.Loader{}
+	if c.Credentials.File != "" {
+		loaders = append(loaders, credentials.File(c.Credentials.File))
+	}
+	if c.Credentials.Env != "" {
+		loaders = append(loaders, credentials.Env(c.Credentials.Env))
+	}
+	if len(c.Credentials.Accounts) > 0 {
+		accounts := c.Credentials.Accounts
+		loaders = append(loaders, func() ([]credentials.Credential, error) { return accounts, nil })
+	}
+	store, err := credentials.NewStore(loaders...)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Runtime{
+		Config:      c,
+		Credentials: store,
+		Exchanges:   make(map[string]sheep.ExchageI),
+		Guards:      make(map[string]*risk.Guard),
+		Feeds:       make(map[string]*feed.Feed),
+	}
+	for _, e := range c.Exchanges {
+		ex, err := r.newExchange(e)
+		if err != nil {
+			return nil, fmt.Errorf("exchange %s : %v", e.Name, err)
+		}
+		if e.Risk != nil {
+			g := risk.New(ex, e.Risk.Limits())
+			r.Guards[e.Name] = g
+			ex = g
+		}
+		r.Exchanges[e.Name] = ex
+	}
+	if c.Credentials.Reload > 0 {
+		store.Start(c.Credentials.Reload)
+	}
+	return r, nil
+}
+
+func (r *Runtime) newExchange(e Exchange) (sheep.ExchageI, error) {
+	var interceptors []sheep.Interceptor
+	if e.Retry > 1 {
+		// Retry 是总尝试次数, RetryInterceptor 的参数是重试次数
+		interceptors = append(interceptors, sheep.RetryInterceptor(e.Retry-1, e.RetryBackoff))
+	}
+	if e.RateLimit > 0 {
+		interceptors = append(interceptors, sheep.RateLimitInterceptor(e.RateLimit))
+	}
+	opts := []sheep.Option{sheep.WithInterceptors(interceptors...)}
+
+	if e.Credential == "" {
+		return sheep.NewExchange(e.Type, "", "", opts...)
+	}
+	cred, err := r.Credentials.Get(e.Credential)
+	if err != nil {
+		return nil, fmt.Errorf("credential %s : %v", e.Credential, err)
+	}
+	if cred.Exchange != e.Type {
+		return nil, fmt.Errorf("credential %s is for %s", e.Credential, cred.Exchange)
+	}
+	return r.Credentials.Exchange(e.Credential, opts...)
+}
+
+// OpenFeeds 连接 Subscriptions 中的交易所行情并订阅, 推送交给 depth, trade (可以为nil), exchange 为交易所类型
+func (r *Runtime) OpenFeeds(depth func(exchange, symbol string, depth *proto.Depth), trade func(exchange, symbol string, trade *proto.Trade)) error {
+	for _, s := range r.Config.Subscriptions {
+		if _, ok := r.Feeds[s.Exchange]; ok {
+			continue
+		}
+		f, err := feed.New(s.Exchange)
+		if err != nil {
+			return fmt.Errorf("subscription %s : %v", s.Exchange, err)
+		}
+		r.Feeds[s.Exchange] = f
+		if s.Levels > 0 {
+			f.Levels = s.Levels
+		}
+
+		exchange := s.Exchange
+		if depth != nil {
+			f.SetDepthListener(func(symbol string, d *proto.Depth) { depth(exchange, symbol, d) })
+		}
+		if trade != nil {
+			f.SetTradeListener(func(symbol string, t *proto.Trade) { trade(exchange, symbol, t) })
+		}
+		if len(s.Depth) > 0 {
+			if err := f.SubscribeDepth(s.Depth...); err != nil {
+				return fmt.Errorf("subscription %s : %v", s.Exchange, err)
+			}
+		}
+		if len(s.Trade) > 0 {
+			if err := f.SubscribeTrade(s.Trade...); err != nil {
+				return fmt.Errorf("subscription %s : %v", s.Exchange, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Names 交易所实例的名称, 已排序
+func (r *Runtime) Names() []string {
+	var names []string
+	for name := range r.Exchanges {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Close 关闭行情并停止自动加载密钥
+func (r *Runtime) Close() error {
+	r.Credentials.Stop()
+	var first error
+	for typ, f := range r.Feeds {
+		if err := f.Close(); err != nil && first == nil {
+			first = err
+		}
+		delete(r.Feeds, typ)
+	}
+	return first
+}
@@ -1,6 +1,10 @@
 package consts
 
 const (
-	ExchangeTypeHuobi = "huobi"
-	ExchangeTypeOKEX  = "okex"
+	ExchangeTypeHuobi    = "huobi"
+	ExchangeTypeOKEX     = "okex"
+	ExchangeTypeFCoin    = "fcoin"
+	ExchangeTypeBinance  = "binance"
+	ExchangeTypeBibox    = "bibox"
+	ExchangeTypeCoinPark = "coinpark"
 )
@@ -0,0 +1,286 @@
+package credentials
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gpmn/sheep"
+	"github.com/gpmn/sheep/logger"
+)
+
+// ErrUnknownAccount 没有该名称的账户
+var ErrUnknownAccount = errors.New("unknown account")
+
+// Credential 一个账户的密钥, 一个交易所可以有多个账户(子账户), 以 Name 区分
+type Credential struct {
+	Name       string `json:"name" yaml:"name"`
+	Exchange   string `json:"exchange" yaml:"exchange"`
+	AccessKey  string `json:"access_key" yaml:"access_key"`
+	SecretKey  string `json:"secret_key" yaml:"secret_key"`
+	Passphrase string `json:"passphrase,omitempty" yaml:"passphrase,omitempty"` // 可选, 用于需要 passphrase 的交易所API
+	// AccountType, AccountID 可选, 指定下单和查询余额使用的账户, 见 sheep.WithAccount
+	AccountType string `json:"account_type,omitempty" yaml:"account_type,omitempty"`
+	AccountID   int64  `json:"account_id,omitempty" yaml:"account_id,omitempty"`
+}
+
+// String 不输出密钥
+func (c Credential) String() string {
+	return fmt.Sprintf("%s(%s %s %d)", c.Name, c.Exchange, c.AccountType, c.AccountID)
+}
+
+// Validate 名称和交易所必须有, 密钥要么都有要么都没有(只用行情或模拟盘)
+func (c Credential) Validate() error {
+	if c.Name == "" {
+		return errors.New("credential name empty")
+	}
+	if c.Exchange == "" {
+		return fmt.Errorf("credential %s : exchange empty", c.Name)
+	}
+	if (c.AccessKey == "") != (c.SecretKey == "") {
+		return fmt.Errorf("credential %s : access key and secret key must be set together", c.Name)
+	}
+	return nil
+}
+
+// Loader 加载密钥, 每次 Reload 都会重新调用
+type Loader func() ([]Credential, error)
+
+// File 从JSON文件加载, 格式为 {"accounts": [Credential...]}
+func File(path string) Loader {
+	return func() ([]Credential, error) {
+		buf, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		var file struct {
+			Accounts []Credential `json:"accounts"`
+		}
+		if err := json.Unmarshal(buf, &file); err != nil {
+			return nil, fmt.Errorf("%s : %v", path, err)
+		}
+		return file.Accounts, nil
+	}
+}
+
+// Env 从环境变量加载, 变量名为 <prefix>_<NAME>_<FIELD>, FIELD 为
+// EXCHANGE, ACCESS_KEY, SECRET_KEY, PASSPHRASE, ACCOUNT_TYPE, ACCOUNT_ID,
+// 账户名为 NAME 的小写, 如 SHEEP_MAIN_EXCHANGE=huobi 对应账户 main
+func Env(prefix string) Loader {
+	return func() ([]Credential, error) {
+		return parseEnv(prefix, os.Environ())
+	}
+}
+
+var envFields = []string{"EXCHANGE", "ACCESS_KEY", "SECRET_KEY", "PASSPHRASE", "ACCOUNT_TYPE", "ACCOUNT_ID"}
+
+func parseEnv(prefix string, environ []string) ([]Credential, error) {
+	creds := make(map[string]*Credential)
+	for _, kv := range environ {
+		i := strings.Index(kv, "=")
+		if i < 0 || !strings.HasPrefix(kv[:i], prefix+"_") {
+			continue
+		}
+		key, value := kv[len(prefix)+1:i], kv[i+1:]
+		for _, field := range envFields {
+			if !strings.HasSuffix(key, "_"+field) || len(key) == len(field)+1 {
+				continue
+			}
+			name := strings.ToLower(strings.TrimSuffix(key, "_"+field))
+			c, ok := creds[name]
+			if !ok {
+				c = &Credential{Name: name}
+				creds[name] = c
+			}
+			switch field {
+			case "EXCHANGE":
+				c.Exchange = value
+			case "ACCESS_KEY":
+				c.AccessKey = value
+			case "SECRET_KEY":
+				c.SecretKey = value
+			case "PASSPHRASE":
+				c.Passphrase = value
+			case "ACCOUNT_TYPE":
+				c.AccountType = value
+			case "ACCOUNT_ID":
+				id, err := strconv.ParseInt(value, 10, 64)
+				if err != nil {
+					return nil, fmt.Errorf("%s_%s : %v", prefix, key, err)
+				}
+				c.AccountID = id
+			}
+			break
+		}
+	}
+	var res []Credential
+	for _, c := range creds {
+		res = append(res, *c)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Name < res[j].Name })
+	return res, nil
+}
+
+// Store 按名称管理多个账户的密钥和交易所实例.
+// Reload 重新加载密钥: 只有密钥变化的账户在已创建的实例上更换密钥, 不需要重启;
+// 交易所或账户变化, 以及不支持更换密钥的实例, 下次 Exchange 时重新创建
+type Store struct {
+	loaders []Loader
+	creds   map[string]Credential
+	clients map[string]sheep.ExchageI
+	stop    chan struct{}
+	mutex   sync.Mutex
+
+	// 日志, 为nil时不输出, 在 Start 之前设置
+	Logger logger.Logger
+}
+
+// NewStore 创建 Store 并加载密钥, 多个 Loader 中同名的账户以后面的为准
+func NewStore(loaders ...Loader) (*Store, error) {
+	s := &Store{loaders: loaders, clients: make(map[string]sheep.ExchageI)}
+	if err := s.Reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *Store) log() logger.Logger {
+	return logger.OrNop(s.Logger)
+}
+
+func (s *Store) load() (map[string]Credential, error) {
+	creds := make(map[string]Credential)
+	for _, loader := range s.loaders {
+		list, err := loader()
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range list {
+			if err := c.Validate(); err != nil {
+				return nil, err
+			}
+			creds[c.Name] = c
+		}
+	}
+	return creds, nil
+}
+
+// Reload 重新加载密钥, 加载失败时保留原来的密钥
+func (s *Store) Reload() error {
+	creds, err := s.load()
+	if err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for name, ex := range s.clients {
+		c, ok := creds[name]
+		old := s.creds[name]
+		if !ok || c.Exchange != old.Exchange || c.AccountType != old.AccountType || c.AccountID != old.AccountID ||
+			c.Passphrase != old.Passphrase {
+			delete(s.clients, name)
+			continue
+		}
+		if c.AccessKey == old.AccessKey && c.SecretKey == old.SecretKey {
+			continue
+		}
+		if err := sheep.SetKeys(ex, c.AccessKey, c.SecretKey); err != nil {
+			delete(s.clients, name)
+		}
+	}
+	s.creds = creds
+	return nil
+}
+
+// Names 所有账户名, 已排序
+func (s *Store) Names() []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	var names []string
+	for name := range s.creds {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Get 按名称查询密钥
+func (s *Store) Get(name string) (Credential, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	c, ok := s.creds[name]
+	if !ok {
+		return Credential{}, ErrUnknownAccount
+	}
+	return c, nil
+}
+
+// Exchange 返回账户对应的交易所实例, 第一次调用时用 sheep.NewExchange 创建, 之后返回同一个实例, opts 只在创建时使用
+func (s *Store) Exchange(name string, opts ...sheep.Option) (sheep.ExchageI, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if ex, ok := s.clients[name]; ok {
+		return ex, nil
+	}
+	c, ok := s.creds[name]
+	if !ok {
+		return nil, ErrUnknownAccount
+	}
+	if c.AccountType != "" || c.AccountID != 0 {
+		opts = append([]sheep.Option{sheep.WithAccount(c.AccountType, c.AccountID)}, opts...)
+	}
+	if c.Passphrase != "" {
+		opts = append([]sheep.Option{sheep.WithPassphrase(c.Passphrase)}, opts...)
+	}
+	ex, err := sheep.NewExchange(c.Exchange, c.AccessKey, c.SecretKey, opts...)
+	if err != nil {
+		return nil, err
+	}
+	s.clients[name] = ex
+	return ex, nil
+}
+
+// Start 每隔 interval 调用一次 Reload, 用于密钥文件或环境更新后自动轮换
+func (s *Store) Start(interval time.Duration) {
+	s.mutex.Lock()
+	if s.stop != nil {
+		s.mutex.Unlock()
+		return
+	}
+	stop := make(chan struct{})
+	s.stop = stop
+	s.mutex.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				if err := s.Reload(); err != nil {
+					s.log().Warn("Store.Reload failed", logger.Err(err))
+				}
+			}
+		}
+	}()
+}
+
+// Stop 停止自动 Reload
+func (s *Store) Stop() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.stop != nil {
+		close(s.stop)
+		s.stop = nil
+	}
+}
@@ -0,0 +1,143 @@
+package credentials
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gpmn/sheep"
+	"github.com/gpmn/sheep/huobi"
+	"github.com/gpmn/sheep/mockserver"
+)
+
+func TestParseEnv(t *testing.T) {
+	creds, err := parseEnv("SHEEP", []string{
+		"SHEEP_MAIN_EXCHANGE=huobi",
+		"SHEEP_MAIN_ACCESS_KEY=ak",
+		"SHEEP_MAIN_SECRET_KEY=sk",
+		"SHEEP_MAIN_PASSPHRASE=pp",
+		"SHEEP_SUB_1_EXCHANGE=huobi",
+		"SHEEP_SUB_1_ACCOUNT_TYPE=margin",
+		"SHEEP_SUB_1_ACCOUNT_ID=7",
+		"SHEEP_EXCHANGE=ignored",
+		"OTHER_MAIN_EXCHANGE=okex",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(creds) != 2 {
+		t.Fatalf("got %+v", creds)
+	}
+	main, sub := creds[0], creds[1]
+	if main.Name != "main" || main.Exchange != "huobi" || main.AccessKey != "ak" || main.SecretKey != "sk" || main.Passphrase != "pp" {
+		t.Fatalf("main %+v", main)
+	}
+	if sub.Name != "sub_1" || sub.AccountType != "margin" || sub.AccountID != 7 {
+		t.Fatalf("sub %+v", sub)
+	}
+	if s := main.String(); s != "main(huobi  0)" {
+		t.Fatalf("string %s", s)
+	}
+
+	if _, err := parseEnv("SHEEP", []string{"SHEEP_MAIN_ACCOUNT_ID=x"}); err == nil {
+		t.Fatal("invalid account id accepted")
+	}
+}
+
+func TestStore(t *testing.T) {
+	s := mockserver.NewHuobi("ak1", "sk1")
+	defer s.Close()
+	defer func(host string) { huobi.Host = host }(huobi.Host)
+	huobi.Host = s.URL
+	s.Handle("GET", "/v1/account/accounts", map[string]interface{}{
+		"status": "ok",
+		"data": []map[string]interface{}{
+			{"id": 1, "type": "spot", "state": "working", "user-id": 1},
+			{"id": 2, "type": "margin", "state": "working", "user-id": 1},
+		},
+	})
+	s.Handle("GET", "/v1/account/accounts/2/balance", map[string]interface{}{
+		"status": "ok",
+		"data":   map[string]interface{}{"id": 2, "type": "margin", "list": []interface{}{}},
+	})
+
+	dir, err := ioutil.TempDir("", "credentials")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "accounts.json")
+	write := func(content string) {
+		if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(`{"accounts": [
+		{"name": "spot", "exchange": "huobi", "access_key": "ak1", "secret_key": "sk1"},
+		{"name": "margin", "exchange": "huobi", "access_key": "ak1", "secret_key": "sk1", "account_type": "margin"}
+	]}`)
+
+	store, err := NewStore(File(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := store.Names(); len(names) != 2 || names[0] != "margin" || names[1] != "spot" {
+		t.Fatalf("names %v", names)
+	}
+	if _, err := store.Exchange("unknown"); err != ErrUnknownAccount {
+		t.Fatalf("got %v", err)
+	}
+
+	ex, err := store.Exchange("margin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if account := ex.(*huobi.Huobi).Account(); account.ID != 2 {
+		t.Fatalf("account %+v", account)
+	}
+	if again, _ := store.Exchange("margin"); again != ex {
+		t.Fatal("client not reused")
+	}
+
+	// 轮换密钥: 服务器和文件同时更新, 已创建的实例继续可用
+	s.AccessKey, s.SecretKey = "ak2", "sk2"
+	if _, err := ex.GetAccountBalance(); err == nil {
+		t.Fatal("old key accepted")
+	}
+	write(`{"accounts": [
+		{"name": "margin", "exchange": "huobi", "access_key": "ak2", "secret_key": "sk2", "account_type": "margin"}
+	]}`)
+	if err := store.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := store.Exchange("margin"); again != ex {
+		t.Fatal("client recreated after key rotation")
+	}
+	if _, err := ex.GetAccountBalance(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Get("spot"); err != ErrUnknownAccount {
+		t.Fatalf("got %v", err)
+	}
+
+	write(`{"accounts": [{"name": "margin", "exchange": "huobi", "access_key": "ak2"}]}`)
+	if err := store.Reload(); err == nil {
+		t.Fatal("invalid credential accepted")
+	}
+	if c, _ := store.Get("margin"); c.SecretKey != "sk2" {
+		t.Fatalf("credential %+v", c)
+	}
+}
+
+func TestPassphraseUnsupported(t *testing.T) {
+	store, err := NewStore(func() ([]Credential, error) {
+		return []Credential{{Name: "ok", Exchange: "okex", AccessKey: "ak", SecretKey: "sk", Passphrase: "pp"}}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Exchange("ok"); err != sheep.ErrPassphraseUnsupported {
+		t.Fatalf("got %v", err)
+	}
+}
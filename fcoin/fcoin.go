@@ -3,25 +3,34 @@ package fcoin
 import (
 	"encoding/json"
 	"fmt"
-	"log"
 	"strconv"
 	"strings"
 
+	simplejson "github.com/bitly/go-simplejson"
+	"github.com/gpmn/sheep/logger"
 	"github.com/gpmn/sheep/proto"
+	"github.com/gpmn/sheep/util"
 	"github.com/pkg/errors"
 )
 
-const FCoinHost = "https://api.fcoin.com/v2/"
+// FCoinHost REST API入口
+var FCoinHost = "https://api.fcoin.com/v2/"
 
 type FCoin struct {
-	accessKey string
-	secretKey string
-	Market    *Market
+	accessKey      string
+	secretKey      string
+	Market         *Market
+	depthListener  DepthListener
+	tradeListener  TradeListener
+	tickerListener TickerListener
+	candleListener CandleListener
+	recorder       util.Recorder
+	logger         logger.Logger
 }
 
 func (f *FCoin) OpenWebsocket() error {
 	var err error
-	f.Market, err = NewMarket()
+	f.Market, err = dialMarket(f.recorder, f.logger)
 	if err != nil {
 		return err
 	}
@@ -30,10 +39,36 @@ func (f *FCoin) OpenWebsocket() error {
 	return nil
 }
 
+// SetRecorder 设置行情消息记录器, 在 OpenWebsocket 之前调用
+func (f *FCoin) SetRecorder(recorder util.Recorder) {
+	f.recorder = recorder
+}
+
+// SetLogger 设置日志, 为nil时不输出, 同时用于 websocket
+func (f *FCoin) SetLogger(l logger.Logger) {
+	f.logger = l
+	if f.Market != nil {
+		f.Market.Logger = l
+	}
+}
+
+func (f *FCoin) log() logger.Logger {
+	return logger.OrNop(f.logger)
+}
+
+// OpenReplay 使用离线Market代替websocket连接, 订阅和监听器照常使用, 消息通过返回的 Market.Replay 输入
+func (f *FCoin) OpenReplay() *Market {
+	f.Market = NewOfflineMarket()
+	return f.Market
+}
+
 func (f *FCoin) GetAccountBalance() ([]proto.AccountBalance, error) {
 	balanceReturn := BalanceReturn{}
 	strRequest := "accounts/balance"
-	jsonBanlanceReturn := apiKeyGet(make(map[string]string), strRequest, f.accessKey, f.secretKey)
+	jsonBanlanceReturn, err := apiKeyGet(make(map[string]string), strRequest, f.accessKey, f.secretKey)
+	if err != nil {
+		return nil, err
+	}
 	json.Unmarshal([]byte(jsonBanlanceReturn), &balanceReturn)
 	if balanceReturn.Status != 0 {
 		return nil, errors.New(strconv.Itoa(balanceReturn.Status))
@@ -43,14 +78,14 @@ func (f *FCoin) GetAccountBalance() ([]proto.AccountBalance, error) {
 	for _, blance := range balanceReturn.Data {
 		var item proto.AccountBalance
 		item.Currency = blance.Currency
-		item.Balance = blance.Balance
+		item.Balance, _ = strconv.ParseFloat(blance.Balance, 64)
 		item.Type = "trade"
 
 		res = append(res, item)
 
 		var item2 proto.AccountBalance
 		item2.Currency = blance.Currency
-		item2.Balance = blance.Frozen
+		item2.Balance, _ = strconv.ParseFloat(blance.Frozen, 64)
 		item2.Type = "frozen"
 
 		res = append(res, item2)
@@ -80,8 +115,11 @@ func (f *FCoin) OrderPlace(params *proto.OrderPlaceParams) (*proto.OrderPlaceRet
 	mapParams["side"] = placeRequestParams.Side
 
 	strRequest := "orders"
-	jsonPlaceReturn := apiKeyPost(mapParams, strRequest, f.accessKey, f.secretKey)
-	log.Println(jsonPlaceReturn)
+	jsonPlaceReturn, err := apiKeyPost(mapParams, strRequest, f.accessKey, f.secretKey)
+	if err != nil {
+		return nil, err
+	}
+	f.log().Debug("FCoin.OrderPlace", logger.F("response", jsonPlaceReturn))
 	json.Unmarshal([]byte(jsonPlaceReturn), &placeReturn)
 
 	if placeReturn.Status != 0 {
@@ -102,7 +140,10 @@ func (f *FCoin) OrderCancel(params *proto.OrderCancelParams) error {
 	placeReturn := PlaceReturn{}
 
 	strRequest := fmt.Sprintf("orders/%s/submit-cancel", params.OrderID)
-	jsonPlaceReturn := apiKeyPost(make(map[string]string), strRequest, f.accessKey, f.secretKey)
+	jsonPlaceReturn, err := apiKeyPost(make(map[string]string), strRequest, f.accessKey, f.secretKey)
+	if err != nil {
+		return err
+	}
 	json.Unmarshal([]byte(jsonPlaceReturn), &placeReturn)
 
 	return nil
@@ -115,7 +156,10 @@ func (f *FCoin) GetOrderInfo(params *proto.OrderInfoParams) (*proto.Order, error
 	orderReturn := OrderReturn{}
 
 	strRequest := fmt.Sprintf("orders/%s", params.OrderID)
-	jsonPlaceReturn := apiKeyGet(make(map[string]string), strRequest, f.accessKey, f.secretKey)
+	jsonPlaceReturn, err := apiKeyGet(make(map[string]string), strRequest, f.accessKey, f.secretKey)
+	if err != nil {
+		return nil, err
+	}
 	json.Unmarshal([]byte(jsonPlaceReturn), &orderReturn)
 
 	var ret proto.Order
@@ -126,6 +170,7 @@ func (f *FCoin) GetOrderInfo(params *proto.OrderInfoParams) (*proto.Order, error
 	ret.Type = TransOrderTypeToProto(orderReturn.Data.Type, orderReturn.Data.Side)
 	ret.Amount, _ = strconv.ParseFloat(orderReturn.Data.Amount, 64)
 	ret.FieldAmount, _ = strconv.ParseFloat(orderReturn.Data.FilledAmount, 64)
+	ret.FieldCashAmount, _ = strconv.ParseFloat(orderReturn.Data.ExecutedValue, 64)
 
 	return &ret, nil
 
@@ -142,7 +187,10 @@ func (f *FCoin) GetOrders(params *proto.OrdersParams) ([]proto.Order, error) {
 	paramMap["limit"] = "10"
 
 	strRequest := "orders"
-	jsonRet := apiKeyGet(paramMap, strRequest, f.accessKey, f.secretKey)
+	jsonRet, err := apiKeyGet(paramMap, strRequest, f.accessKey, f.secretKey)
+	if err != nil {
+		return nil, err
+	}
 	json.Unmarshal([]byte(jsonRet), &ordersReturn)
 
 	var ret []proto.Order
@@ -153,6 +201,7 @@ func (f *FCoin) GetOrders(params *proto.OrdersParams) ([]proto.Order, error) {
 		item.Symbol = cell.Symbol
 		item.State = cell.State
 		item.FieldAmount, _ = strconv.ParseFloat(cell.FilledAmount, 64)
+		item.FieldCashAmount, _ = strconv.ParseFloat(cell.ExecutedValue, 64)
 		item.Type = TransOrderTypeToProto(cell.Type, cell.Side)
 		item.Amount, _ = strconv.ParseFloat(cell.Amount, 64)
 
@@ -167,7 +216,10 @@ func GetMarketDepth(params *proto.MarketDepthParams) (*MarketDepthReturn, error)
 	marketDepth := MarketDepthReturn{}
 
 	strRequest := "market/depth/" + params.Level + "/" + params.Symbol
-	jsonRet := apiKeyGet(make(map[string]string), strRequest, "", "")
+	jsonRet, err := apiKeyGet(make(map[string]string), strRequest, "", "")
+	if err != nil {
+		return nil, err
+	}
 
 	json.Unmarshal([]byte(jsonRet), &marketDepth)
 
@@ -178,6 +230,139 @@ func (f *FCoin) CloseWebsocket() error {
 	return f.Market.Close()
 }
 
+func (f *FCoin) SetDepthListener(listener DepthListener) {
+	f.depthListener = listener
+}
+
+func (f *FCoin) SetTradeListener(listener TradeListener) {
+	f.tradeListener = listener
+}
+
+func (f *FCoin) SetTickerListener(listener TickerListener) {
+	f.tickerListener = listener
+}
+
+func (f *FCoin) SetCandleListener(listener CandleListener) {
+	f.candleListener = listener
+}
+
+// DepthListener 深度监听器
+type DepthListener func(symbol string, depth *DepthUpdate)
+
+// SubscribeDepth 订阅 depth.<level>.<symbol>, level 取值见 DepthLevel*
+func (f *FCoin) SubscribeDepth(level string, symbols ...string) error {
+	return f.subscribe("depth."+level+".", symbols, func(symbol string, buf []byte) {
+		var du DepthUpdate
+		if err := json.Unmarshal(buf, &du); err != nil {
+			f.log().Error("FCoin.SubscribeDepth - callback failed", logger.Err(err))
+			return
+		}
+		if f.depthListener != nil {
+			f.depthListener(symbol, &du)
+		}
+	})
+}
+
+// TradeListener 成交监听器
+type TradeListener func(symbol string, trade *TradeUpdate)
+
+// SubscribeTrade 订阅 trade.<symbol>
+func (f *FCoin) SubscribeTrade(symbols ...string) error {
+	return f.subscribe("trade.", symbols, func(symbol string, buf []byte) {
+		var tu TradeUpdate
+		if err := json.Unmarshal(buf, &tu); err != nil {
+			f.log().Error("FCoin.SubscribeTrade - callback failed", logger.Err(err))
+			return
+		}
+		if f.tradeListener != nil {
+			f.tradeListener(symbol, &tu)
+		}
+	})
+}
+
+// TickerListener 行情监听器
+type TickerListener func(symbol string, ticker *Ticker)
+
+// SubscribeTicker 订阅 ticker.<symbol>
+func (f *FCoin) SubscribeTicker(symbols ...string) error {
+	return f.subscribe("ticker.", symbols, func(symbol string, buf []byte) {
+		var ticker Ticker
+		if err := json.Unmarshal(buf, &ticker); err != nil {
+			f.log().Error("FCoin.SubscribeTicker - callback failed", logger.Err(err))
+			return
+		}
+		if f.tickerListener != nil {
+			f.tickerListener(symbol, &ticker)
+		}
+	})
+}
+
+// CandleListener K线监听器
+type CandleListener func(symbol, resolution string, candle *Candle)
+
+// SubscribeCandle 订阅 candle.<resolution>.<symbol>, resolution 取值见 CandleResolution*
+func (f *FCoin) SubscribeCandle(resolution string, symbols ...string) error {
+	return f.subscribe("candle."+resolution+".", symbols, func(symbol string, buf []byte) {
+		var candle Candle
+		if err := json.Unmarshal(buf, &candle); err != nil {
+			f.log().Error("FCoin.SubscribeCandle - callback failed", logger.Err(err))
+			return
+		}
+		if f.candleListener != nil {
+			f.candleListener(symbol, resolution, &candle)
+		}
+	})
+}
+
+// GetCandles 通过websocket请求历史K线, before 为0时取最新的limit根, 否则取该时间(秒)之前的
+func (f *FCoin) GetCandles(resolution, symbol string, limit int, before int64) ([]Candle, error) {
+	if f.Market == nil {
+		return nil, errors.New("websocket not opened")
+	}
+	args := []interface{}{limit}
+	if before > 0 {
+		args = append(args, before)
+	}
+	j, err := f.Market.Request("candle."+resolution+"."+strings.ToLower(symbol), args...)
+	if err != nil {
+		f.log().Error("FCoin.GetCandles - Request failed", logger.Err(err))
+		return nil, err
+	}
+	buf, err := j.Get("data").MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+	var candles []Candle
+	if err = json.Unmarshal(buf, &candles); err != nil {
+		f.log().Error("FCoin.GetCandles - json.Unmarshal failed", logger.Err(err))
+		return nil, err
+	}
+	return candles, nil
+}
+
+// subscribe 订阅 <prefix><symbol>, 回调收到完整的推送消息
+func (f *FCoin) subscribe(prefix string, symbols []string, handler func(symbol string, buf []byte)) error {
+	if f.Market == nil {
+		return errors.New("websocket not opened")
+	}
+	for _, symbol := range symbols {
+		symbol := strings.ToLower(symbol)
+		err := f.Market.Subscribe(prefix+symbol, func(topic string, j *simplejson.Json) {
+			buf, err := j.MarshalJSON()
+			if err != nil {
+				f.log().Error("FCoin.subscribe - MarshalJSON failed", logger.F("topic", topic), logger.Err(err))
+				return
+			}
+			handler(symbol, buf)
+		})
+		if err != nil {
+			f.log().Error("FCoin.subscribe - Subscribe failed", logger.Err(err))
+			return err
+		}
+	}
+	return nil
+}
+
 func NewFCoin(accessKey, secretKey string) (*FCoin, error) {
 	if accessKey == "" || secretKey == "" {
 		return nil, errors.New("access key or secret key error")
@@ -1,5 +1,10 @@
 package fcoin
 
+import (
+	"encoding/json"
+	"fmt"
+)
+
 const (
 	OrderTypeLimit = "limit" //限价
 )
@@ -78,3 +83,103 @@ type MarketDepthReturn struct {
 	Status int             `json:"status"`
 	Data   MarketDepthData `json:"data"`
 }
+
+// 深度级别
+const (
+	DepthLevelL20  = "L20"
+	DepthLevelL100 = "L100"
+	DepthLevelFull = "full"
+)
+
+// K线周期
+const (
+	CandleResolutionM1  = "M1"
+	CandleResolutionM3  = "M3"
+	CandleResolutionM5  = "M5"
+	CandleResolutionM15 = "M15"
+	CandleResolutionM30 = "M30"
+	CandleResolutionH1  = "H1"
+	CandleResolutionH4  = "H4"
+	CandleResolutionH6  = "H6"
+	CandleResolutionD1  = "D1"
+	CandleResolutionW1  = "W1"
+	CandleResolutionMN  = "MN"
+)
+
+// DepthUpdate : depth.<level>.<symbol> 推送, bids/asks 为 [price, amount, price, amount ...]
+type DepthUpdate struct {
+	Type string    `json:"type"`
+	TS   int64     `json:"ts"`
+	Seq  int64     `json:"seq"`
+	Bids []float64 `json:"bids"`
+	Asks []float64 `json:"asks"`
+}
+
+// TradeUpdate : trade.<symbol> 推送
+type TradeUpdate struct {
+	Type   string  `json:"type"`
+	ID     int64   `json:"id"`
+	TS     int64   `json:"ts"`
+	Side   string  `json:"side"`
+	Price  float64 `json:"price"`
+	Amount float64 `json:"amount"`
+}
+
+// Ticker : ticker.<symbol> 推送
+type Ticker struct {
+	Type           string
+	Seq            int64
+	LastPrice      float64 // 最新成交价
+	LastVolume     float64 // 最近一笔成交量
+	BestBid        float64 // 最大买一价
+	BestBidVolume  float64 // 最大买一量
+	BestAsk        float64 // 最小卖一价
+	BestAskVolume  float64 // 最小卖一量
+	Open24h        float64 // 24小时前成交价
+	High24h        float64 // 24小时内最高价
+	Low24h         float64 // 24小时内最低价
+	BaseVolume24h  float64 // 24小时内基准货币成交量
+	QuoteVolume24h float64 // 24小时内计价货币成交量
+}
+
+func (t *Ticker) UnmarshalJSON(b []byte) error {
+	var raw struct {
+		Type   string    `json:"type"`
+		Seq    int64     `json:"seq"`
+		Ticker []float64 `json:"ticker"`
+	}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+	if len(raw.Ticker) < 11 {
+		return fmt.Errorf("invalid ticker %s", b)
+	}
+	t.Type = raw.Type
+	t.Seq = raw.Seq
+	t.LastPrice = raw.Ticker[0]
+	t.LastVolume = raw.Ticker[1]
+	t.BestBid = raw.Ticker[2]
+	t.BestBidVolume = raw.Ticker[3]
+	t.BestAsk = raw.Ticker[4]
+	t.BestAskVolume = raw.Ticker[5]
+	t.Open24h = raw.Ticker[6]
+	t.High24h = raw.Ticker[7]
+	t.Low24h = raw.Ticker[8]
+	t.BaseVolume24h = raw.Ticker[9]
+	t.QuoteVolume24h = raw.Ticker[10]
+	return nil
+}
+
+// Candle : candle.<resolution>.<symbol> 推送及历史K线请求结果
+type Candle struct {
+	Type     string  `json:"type"`
+	ID       int64   `json:"id"` // 开盘时间, 秒
+	Seq      int64   `json:"seq"`
+	Open     float64 `json:"open"`
+	Close    float64 `json:"close"`
+	High     float64 `json:"high"`
+	Low      float64 `json:"low"`
+	Count    int64   `json:"count"`
+	BaseVol  float64 `json:"base_vol"`
+	QuoteVol float64 `json:"quote_vol"`
+}
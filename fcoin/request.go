@@ -11,7 +11,7 @@ import (
 // mapParams: map类型的请求参数, key:value
 // strRequest: API路由路径
 // return: 请求结果
-func apiKeyGet(mapParams map[string]string, strRequestPath string, accessKey, secretKey string) string {
+func apiKeyGet(mapParams map[string]string, strRequestPath string, accessKey, secretKey string) (string, error) {
 	strMethod := "GET"
 	now := time.Now()
 	timestamp := now.UnixNano() / 1000 / 1000
@@ -25,14 +25,14 @@ func apiKeyGet(mapParams map[string]string, strRequestPath string, accessKey, se
 	if len(mapParams) > 0 {
 		strUrl = strUrl + "?" + util.Map2UrlQuery(mapParams)
 	}
-	return util.HttpGetRequest(strUrl, resParams)
+	return util.HttpGetRequestWithHeader(strUrl, nil, resParams)
 }
 
 // 进行签名后的HTTP POST请求, 参考官方Python Demo写的
 // mapParams: map类型的请求参数, key:value
 // strRequest: API路由路径
 // return: 请求结果
-func apiKeyPost(mapParams map[string]string, strRequestPath string, accessKey, secretKey string) string {
+func apiKeyPost(mapParams map[string]string, strRequestPath string, accessKey, secretKey string) (string, error) {
 	strMethod := "POST"
 	now := time.Now()
 	timestamp := now.UnixNano() / 1000 / 1000
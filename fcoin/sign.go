@@ -1,6 +1,7 @@
 package fcoin
 
 import (
+	"crypto/hmac"
 	"encoding/base64"
 	"strconv"
 
@@ -34,3 +35,9 @@ func CreateSign(strMethod, strRequestPath, secretKey string, urlParams, postPara
 	return util.ComputeHmacSha1(strPayloadBased, secretKey)
 
 }
+
+// VerifySign 校验请求签名, 参数同 CreateSign, signature 为收到的 FC-ACCESS-SIGNATURE
+func VerifySign(strMethod, strRequestPath, secretKey string, urlParams, postParams map[string]string, unix int64, signature string) bool {
+	strSign := CreateSign(strMethod, strRequestPath, secretKey, urlParams, postParams, unix)
+	return hmac.Equal([]byte(strSign), []byte(signature))
+}
@@ -3,5 +3,46 @@ package fcoin
 import "testing"
 
 func TestCreateSign(t *testing.T) {
+	defer func(host string) { FCoinHost = host }(FCoinHost)
+	FCoinHost = "https://api.fcoin.com/v2/"
 
+	cases := []struct {
+		method, path, secret  string
+		urlParams, postParams map[string]string
+		ts                    int64
+		sign                  string
+	}{
+		{
+			method: "GET", path: "accounts/balance", secret: "3600d0a74aa3410fb3b1996cca2419c8",
+			ts:   1523069544359,
+			sign: "sU1/BOVwzgur+UaI6nr/FXzp+GI=",
+		},
+		{
+			// url参数按名称排序后加在路径后
+			method: "GET", path: "orders", secret: "sk",
+			urlParams: map[string]string{"symbol": "btcusdt", "states": "submitted", "limit": "20"},
+			ts:        1523069544359,
+			sign:      "S6xcq2iUU1Q8g4H96Qs+HoFOzrk=",
+		},
+		{
+			// post参数按名称排序后加在时间戳后
+			method: "POST", path: "orders", secret: "3600d0a74aa3410fb3b1996cca2419c8",
+			postParams: map[string]string{"type": "limit", "side": "buy", "amount": "100.0", "price": "100.0", "symbol": "btcusdt"},
+			ts:         1523069544359,
+			sign:       "DeP6oftldIrys06uq3B7Lkh3a0U=",
+		},
+	}
+
+	for _, c := range cases {
+		sign := CreateSign(c.method, c.path, c.secret, c.urlParams, c.postParams, c.ts)
+		if sign != c.sign {
+			t.Errorf("%s %s : sign %s, want %s", c.method, c.path, sign, c.sign)
+		}
+		if !VerifySign(c.method, c.path, c.secret, c.urlParams, c.postParams, c.ts, c.sign) {
+			t.Errorf("%s %s : VerifySign failed", c.method, c.path)
+		}
+		if VerifySign(c.method, c.path, c.secret, c.urlParams, c.postParams, c.ts+1, c.sign) {
+			t.Errorf("%s %s : VerifySign accepted modified timestamp", c.method, c.path)
+		}
+	}
 }
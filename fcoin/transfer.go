@@ -1,6 +1,11 @@
 package fcoin
 
-import "github.com/gpmn/sheep/proto"
+import (
+	"strconv"
+	"time"
+
+	"github.com/gpmn/sheep/proto"
+)
 
 func TransOrderTypeFromProto(t string) (string, string) {
 	switch t {
@@ -41,3 +46,38 @@ func TransOrderStateFromStatus(s string) string {
 
 	}
 }
+
+// TransTrade 成交推送转换为 proto.Trade
+func TransTrade(symbol string, t *TradeUpdate) proto.Trade {
+	side := proto.TradeSideSell
+	if t.Side == OrderSideBuy {
+		side = proto.TradeSideBuy
+	}
+	return proto.Trade{
+		ID:     strconv.FormatInt(t.ID, 10),
+		Symbol: symbol,
+		Price:  t.Price,
+		Amount: t.Amount,
+		Side:   side,
+		TS:     t.TS,
+	}
+}
+
+// TransCandle K线转换为 proto.Candle, interval 为K线周期
+func TransCandle(symbol string, interval time.Duration, c *Candle) proto.Candle {
+	start := c.ID * 1000
+	end := start + int64(interval/time.Millisecond)
+	return proto.Candle{
+		Symbol:      symbol,
+		Start:       start,
+		End:         end,
+		Open:        c.Open,
+		High:        c.High,
+		Low:         c.Low,
+		Close:       c.Close,
+		Volume:      c.BaseVol,
+		QuoteVolume: c.QuoteVol,
+		Count:       c.Count,
+		Closed:      end <= time.Now().UnixNano()/int64(time.Millisecond),
+	}
+}
@@ -2,40 +2,42 @@ package fcoin
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
 	"math"
+	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/bitly/go-simplejson"
+	"github.com/gpmn/sheep/consts"
+	"github.com/gpmn/sheep/logger"
+	"github.com/gpmn/sheep/metrics"
 	"github.com/gpmn/sheep/util"
 )
 
+// Endpoint 行情的Websocket入口
+var Endpoint = "wss://api.fcoin.com/v2/ws"
+
 type jsonChan = chan *simplejson.Json
 
 // Listener 订阅事件监听器
 type Listener = func(topic string, json *simplejson.Json)
 
-type pongData struct {
-	Pong int64 `json:"pong"`
-}
-
-type pingData struct {
-	Ping int64 `json:"ping"`
-}
-
-type subData struct {
-	Sub string `json:"sub"`
-	ID  string `json:"id"`
-}
-
-type reqData struct {
-	Req string `json:"req"`
-	ID  string `json:"id"`
+// cmdData 客户端指令, cmd 取值 sub, req, ping
+type cmdData struct {
+	Cmd  string        `json:"cmd"`
+	Args []interface{} `json:"args"`
+	ID   string        `json:"id"`
 }
 
 type Market struct {
+	// 上次接收到的pong时间戳, 原子访问, 放在开头保证64位对齐
+	lastPing int64
+	// 上次发送ping的时间戳, 用于计算心跳往返时间, 原子访问
+	lastPingSent int64
+
 	ws *util.SafeWebSocket
 
 	listeners         map[string]Listener
@@ -43,23 +45,40 @@ type Market struct {
 	subscribeResultCb map[string]jsonChan
 	requestResultCb   map[string]jsonChan
 
-	// 掉线后是否自动重连，如果用户主动执行Close()则不自动重连
-	autoReconnect bool
+	// 请求id序号
+	requestSeq int64
 
-	// 上次接收到的ping时间戳
-	lastPing int64
+	// 掉线后是否自动重连，如果用户主动执行Close()则不自动重连, 由 mutex 保护
+	autoReconnect bool
 
 	// 主动发送心跳的时间间隔，默认5秒
 	HeartbeatInterval time.Duration
 	// 接收消息超时时间，默认10秒
 	ReceiveTimeout time.Duration
 
+	// 消息记录器, 为nil时不记录
+	Recorder util.Recorder
+
+	// 日志, 为nil时不输出
+	Logger logger.Logger
+
+	// 离线模式, 不连接服务器, 消息由 Replay 输入
+	offline bool
+
+	// 保护 ws, autoReconnect, requestSeq 和各个 map
 	mutex *sync.RWMutex
 }
 
 // NewMarket 创建Market实例
 func NewMarket() (m *Market, err error) {
+	return dialMarket(nil, nil)
+}
+
+// dialMarket 创建Market实例, 记录器和日志在连接之前设置, 避免和消息处理并发读写
+func dialMarket(recorder util.Recorder, l logger.Logger) (m *Market, err error) {
 	m = &Market{
+		Recorder:          recorder,
+		Logger:            l,
 		HeartbeatInterval: 5 * time.Second,
 		ReceiveTimeout:    10 * time.Second,
 		ws:                nil,
@@ -78,158 +97,200 @@ func NewMarket() (m *Market, err error) {
 	return m, nil
 }
 
+// NewOfflineMarket 创建不连接服务器的Market实例, 用于回放记录的消息
+func NewOfflineMarket() *Market {
+	return &Market{
+		HeartbeatInterval: 5 * time.Second,
+		ReceiveTimeout:    10 * time.Second,
+		listeners:         make(map[string]Listener),
+		subscribeResultCb: make(map[string]jsonChan),
+		requestResultCb:   make(map[string]jsonChan),
+		subscribedTopic:   make(map[string]bool),
+		offline:           true,
+		mutex:             &sync.RWMutex{},
+	}
+}
+
+func (m *Market) log() logger.Logger {
+	return logger.OrNop(m.Logger)
+}
+
 // connect 连接
 func (m *Market) connect() error {
-	fmt.Println("connecting")
-	ws, err := util.NewSafeWebSocket("wss://api.fcoin.com/v2/ws")
+	m.log().Debug("Market.connect - connecting", logger.F("endpoint", Endpoint))
+	ws, err := util.NewSafeWebSocket(Endpoint)
 	if err != nil {
 		return err
 	}
+	atomic.StoreInt64(&m.lastPing, getUinxMillisecond())
+	m.mutex.Lock()
 	m.ws = ws
-	fmt.Println("connected")
+	m.mutex.Unlock()
+	m.log().Debug("Market.connect - connected")
 
-	m.handleMessageLoop()
-	//m.keepAlive()
+	m.handleMessageLoop(ws)
+	m.keepAlive(ws)
 
 	return nil
 }
 
 // reconnect 重新连接
 func (m *Market) reconnect() error {
-	fmt.Println("reconnecting after 1s")
+	m.log().Info("Market.reconnect - reconnecting after 1s")
+	metrics.IncReconnect(consts.ExchangeTypeFCoin)
 	time.Sleep(time.Second)
 
 	if err := m.connect(); err != nil {
-		fmt.Println(err)
+		m.log().Error("Market.reconnect - m.connect failed", logger.Err(err))
 		return err
 	}
 
 	// 重新订阅
 	var listeners = make(map[string]Listener)
+	m.mutex.Lock()
 	for k, v := range m.listeners {
 		listeners[k] = v
+		delete(m.subscribedTopic, k)
 	}
+	m.mutex.Unlock()
 	for topic, listener := range listeners {
-		delete(m.subscribedTopic, topic)
-		m.Subscribe(topic, listener)
+		if err := m.Subscribe(topic, listener); err != nil {
+			m.log().Error("Market.reconnect - m.Subscribe failed", logger.F("topic", topic), logger.Err(err))
+		}
 	}
 	return nil
 }
 
-// handlePing 处理Ping
-func (m *Market) handlePing(ping pingData) (err error) {
-	fmt.Println("handlePing", ping)
-	m.lastPing = ping.Ping
-	var pong = pongData{Pong: ping.Ping}
-	err = m.sendMessage(pong)
-	if err != nil {
-		return err
-	}
-	return nil
+// conn 当前连接, 重连时会更换
+func (m *Market) conn() *util.SafeWebSocket {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	return m.ws
+}
+
+// reconnectEnabled 掉线后是否自动重连
+func (m *Market) reconnectEnabled() bool {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	return m.autoReconnect
+}
+
+func (m *Market) setAutoReconnect(v bool) {
+	m.mutex.Lock()
+	m.autoReconnect = v
+	m.mutex.Unlock()
 }
 
 // sendMessage 发送消息
 func (m *Market) sendMessage(data interface{}) error {
+	ws := m.conn()
+	if ws == nil {
+		return errors.New("websocket not connected")
+	}
 	b, err := json.Marshal(data)
 	if err != nil {
-		return nil
+		return err
 	}
-	fmt.Println("sendMessage", string(b))
-	m.ws.Send(b)
+	ws.Send(b)
 	return nil
 }
 
-func (m *Market) XXxxx() {
-	m.ws.Send([]byte("ticker.ftusdt"))
+// nextID 生成请求id
+func (m *Market) nextID() string {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.requestSeq++
+	return strconv.FormatInt(m.requestSeq, 10)
 }
 
 // handleMessageLoop 处理消息循环
-func (m *Market) handleMessageLoop() {
-	m.ws.Listen(func(buf []byte) {
-		msg := buf
-		//msg, err := unGzipData(buf)
-		////fmt.Println("readMessage", string(msg))
-		//if err != nil {
-		//	fmt.Println(err)
-		//	return
-		//}
-		json, err := simplejson.NewJson(msg)
-		if err != nil {
-			fmt.Println(err)
-			return
-		}
+func (m *Market) handleMessageLoop(ws *util.SafeWebSocket) {
+	ws.Listen(m.handleMessage)
+}
 
-		log.Println(json)
+// Replay 输入一条记录的消息, 与收到的消息经过相同的处理
+func (m *Market) Replay(buf []byte) {
+	m.handleMessage(buf)
+}
 
-		// 处理ping消息
-		if ping := json.Get("ping").MustInt64(); ping > 0 {
-			m.handlePing(pingData{Ping: ping})
-			return
-		}
+// handleMessage 处理一条消息
+func (m *Market) handleMessage(buf []byte) {
+	if m.Recorder != nil {
+		m.Recorder.Record(consts.ExchangeTypeFCoin, buf, buf)
+	}
+	json, err := simplejson.NewJson(buf)
+	if err != nil {
+		m.log().Error("Market.handleMessage - simplejson.NewJson failed", logger.Err(err), logger.F("response", string(buf)))
+		return
+	}
 
-		// 处理pong消息
-		if pong := json.Get("pong").MustInt64(); pong > 0 {
-			m.lastPing = pong
-			return
+	typ := json.Get("type").MustString()
+	id := json.Get("id").MustString()
+
+	switch typ {
+	case "hello":
+		return
+	case "ping":
+		// 心跳回复
+		now := getUinxMillisecond()
+		atomic.StoreInt64(&m.lastPing, now)
+		if sent := atomic.LoadInt64(&m.lastPingSent); sent > 0 {
+			metrics.ObservePing(consts.ExchangeTypeFCoin, time.Duration(now-sent)*time.Millisecond)
 		}
-
-		// 处理订阅消息
-		if ch := json.Get("ch").MustString(); ch != "" {
-			m.mutex.RLock()
-			listener, ok := m.listeners[ch]
-			if ok {
-				//fmt.Println("handleSubscribe", json)
-				listener(ch, json)
-			}
-			m.mutex.RUnlock()
-			return
+		return
+	case "topics":
+		// 订阅成功通知
+		m.mutex.RLock()
+		c, ok := m.subscribeResultCb[id]
+		m.mutex.RUnlock()
+		if ok {
+			c <- json
 		}
+		return
+	}
 
-		// 处理订阅成功通知
-		if subbed := json.Get("subbed").MustString(); subbed != "" {
-			c, ok := m.subscribeResultCb[subbed]
-			if ok {
-				c <- json
-			}
-			return
+	// 请求结果或错误消息, 按id分发
+	if id != "" {
+		m.mutex.RLock()
+		c, ok := m.requestResultCb[id]
+		if !ok {
+			c, ok = m.subscribeResultCb[id]
 		}
-
-		// 请求行情结果
-		if rep, id := json.Get("rep").MustString(), json.Get("id").MustString(); rep != "" && id != "" {
-			c, ok := m.requestResultCb[id]
-			if ok {
-				c <- json
-			}
-			return
+		m.mutex.RUnlock()
+		if ok {
+			c <- json
 		}
+		return
+	}
 
-		// 处理错误消息
-		if status := json.Get("status").MustString(); status == "error" {
-			// 判断是否为订阅失败
-			id := json.Get("id").MustString()
-			c, ok := m.subscribeResultCb[id]
-			if ok {
-				c <- json
-			}
-			return
+	// 处理订阅消息, type 即为 topic
+	if typ != "" {
+		metrics.IncMessage(consts.ExchangeTypeFCoin, typ)
+		m.mutex.RLock()
+		listener, ok := m.listeners[typ]
+		m.mutex.RUnlock()
+		if ok {
+			listener(typ, json)
 		}
-	})
+	}
 }
 
 // keepAlive 保持活跃
-func (m *Market) keepAlive() {
-	m.ws.KeepAlive(m.HeartbeatInterval, func() {
+func (m *Market) keepAlive(ws *util.SafeWebSocket) {
+	ws.KeepAlive(m.HeartbeatInterval, func() {
 		var t = getUinxMillisecond()
-		m.sendMessage(pingData{Ping: t})
+		atomic.StoreInt64(&m.lastPingSent, t)
+		m.sendMessage(cmdData{Cmd: "ping", Args: []interface{}{t}, ID: "ping"})
 
-		// 检查上次ping时间，如果超过20秒无响应，重新连接
-		tr := time.Duration(math.Abs(float64(t - m.lastPing)))
+		// 检查上次pong时间，如果超过两个心跳周期无响应，重新连接
+		lastPing := atomic.LoadInt64(&m.lastPing)
+		tr := time.Duration(math.Abs(float64(t-lastPing))) * time.Millisecond
 		if tr >= m.HeartbeatInterval*2 {
-			fmt.Println("no ping max delay", tr, m.HeartbeatInterval*2, t, m.lastPing)
-			if m.autoReconnect {
+			m.log().Warn("Market.keepAlive - no ping max delay", logger.F("delay", tr), logger.F("max", m.HeartbeatInterval*2), logger.F("lastPing", lastPing))
+			if m.reconnectEnabled() {
 				err := m.reconnect()
 				if err != nil {
-					fmt.Println(err)
+					m.log().Error("Market.keepAlive - reconnect failed", logger.Err(err))
 				}
 			}
 		}
@@ -238,56 +299,111 @@ func (m *Market) keepAlive() {
 
 // Subscribe 订阅
 func (m *Market) Subscribe(topic string, listener Listener) error {
-	var isNew = false
+	m.mutex.Lock()
+	_, subscribed := m.subscribedTopic[topic]
+	m.listeners[topic] = listener
+	if m.offline {
+		m.subscribedTopic[topic] = true
+		m.mutex.Unlock()
+		return nil
+	}
+	if subscribed {
+		m.mutex.Unlock()
+		m.log().Debug("Market.Subscribe - send subscribe before, reset listener only", logger.F("topic", topic))
+		return nil
+	}
+	id := topic
+	c := make(jsonChan, 1)
+	m.subscribeResultCb[id] = c
+	m.mutex.Unlock()
+
+	// 如果未曾发送过订阅指令，则发送，并等待订阅操作结果
+	m.sendMessage(cmdData{Cmd: "sub", Args: []interface{}{topic}, ID: id})
+	json, err := m.wait(c)
 
-	// 如果未曾发送过订阅指令，则发送，并等待订阅操作结果，否则直接返回
-	if _, ok := m.subscribedTopic[topic]; !ok {
-		m.subscribeResultCb[topic] = make(jsonChan)
-		m.sendMessage(subData{ID: topic, Sub: topic})
-		isNew = true
+	m.mutex.Lock()
+	delete(m.subscribeResultCb, id)
+	if err == nil {
+		m.subscribedTopic[topic] = true
 	} else {
-		fmt.Println("send subscribe before, reset listener only")
+		delete(m.listeners, topic)
+	}
+	m.mutex.Unlock()
+
+	if err != nil {
+		metrics.IncSubscribeFailure(consts.ExchangeTypeFCoin, topic)
+		return fmt.Errorf("subscribe %s failed : %v", topic, err)
+	}
+	for _, t := range json.Get("topics").MustArray() {
+		if t == topic {
+			return nil
+		}
 	}
+	metrics.IncSubscribeFailure(consts.ExchangeTypeFCoin, topic)
+	return fmt.Errorf("subscribe %s failed : topic not acknowledged", topic)
+}
 
+// Request 请求行情信息, args 为 topic 之后的参数, 如 candle.M1.btcusdt 的 limit 和 before
+func (m *Market) Request(topic string, args ...interface{}) (*simplejson.Json, error) {
+	id := m.nextID()
+	c := make(jsonChan, 1)
 	m.mutex.Lock()
-	m.listeners[topic] = listener
-	m.subscribedTopic[topic] = true
+	m.requestResultCb[id] = c
 	m.mutex.Unlock()
 
-	if isNew {
-		var json = <-m.subscribeResultCb[topic]
-		// 判断订阅结果，如果出错则返回出错信息
-		if msg, err := json.Get("err-msg").String(); err == nil {
-			return fmt.Errorf(msg)
+	defer func() {
+		m.mutex.Lock()
+		delete(m.requestResultCb, id)
+		m.mutex.Unlock()
+	}()
+
+	if err := m.sendMessage(cmdData{Cmd: "req", Args: append([]interface{}{topic}, args...), ID: id}); err != nil {
+		return nil, err
+	}
+	return m.wait(c)
+}
+
+// wait 等待结果, 超时或返回错误状态时返回错误
+func (m *Market) wait(c jsonChan) (*simplejson.Json, error) {
+	select {
+	case json := <-c:
+		if status := json.Get("status").MustInt(); status != 0 {
+			return json, fmt.Errorf("status %d : %s", status, json.Get("msg").MustString())
 		}
+		return json, nil
+	case <-time.After(m.ReceiveTimeout):
+		return nil, fmt.Errorf("timeout")
 	}
-	return nil
 }
 
 // Loop 进入循环
 func (m *Market) Loop() {
-	fmt.Println("startLoop")
+	m.log().Debug("Market.Loop - start")
 	for {
-		err := m.ws.Loop()
+		err := m.conn().Loop()
 		if err != nil {
-			fmt.Println(err)
+			m.log().Warn("Market.Loop - connection lost", logger.Err(err))
 			if err == util.SafeWebSocketDestroyError {
 				break
-			} else if m.autoReconnect {
+			} else if m.reconnectEnabled() {
 				m.reconnect()
 			} else {
 				break
 			}
 		}
 	}
-	fmt.Println("endLoop")
+	m.log().Debug("Market.Loop - end")
 }
 
 // Close 关闭连接
 func (m *Market) Close() error {
-	fmt.Println("close")
-	m.autoReconnect = false
-	if err := m.ws.Destroy(); err != nil {
+	m.log().Debug("Market.Close")
+	m.setAutoReconnect(false)
+	ws := m.conn()
+	if ws == nil {
+		return nil
+	}
+	if err := ws.Destroy(); err != nil {
 		return err
 	}
 	return nil
@@ -0,0 +1,170 @@
+package feed
+
+import (
+	"errors"
+	"strings"
+	"sync"
+
+	"github.com/gpmn/sheep/orderbook"
+	"github.com/gpmn/sheep/proto"
+)
+
+// ErrUnsupportedExchange 不支持的交易所
+var ErrUnsupportedExchange = errors.New("unsupported feed exchange")
+
+// DefaultLevels 深度推送的默认档数
+const DefaultLevels = 20
+
+// source 各交易所的 websocket 行情, 推送经 Feed.update / Feed.trade 送入
+type source interface {
+	subscribeDepth(symbols []string) error
+	subscribeTrade(symbols []string) error
+	// snapshot 需要REST全量数据的增量推送返回非nil
+	snapshot(symbol string) orderbook.SnapshotFunc
+	close() error
+}
+
+// Feed : 统一的实时行情, 实现 sheep.MarketDataI.
+// 深度推送先应用到本地订单簿(orderbook.Syncer), 再把前 Levels 档的快照交给监听器,
+// 所以各交易所的全量, 增量推送对使用者都是快照. 交易对使用交易所自己的格式, 统一为小写
+type Feed struct {
+	exchange string
+	source   source
+
+	// 深度推送的档数, 默认 DefaultLevels, 在订阅之前设置
+	Levels int
+
+	depthListener func(symbol string, depth *proto.Depth)
+	tradeListener func(symbol string, trade *proto.Trade)
+	syncers       map[string]*orderbook.Syncer
+	mutex         sync.RWMutex
+}
+
+// New 连接交易所的 websocket 并创建 Feed, exchange 为 consts.ExchangeTypeXXX
+func New(exchange string) (*Feed, error) {
+	f := &Feed{
+		exchange: exchange,
+		Levels:   DefaultLevels,
+		syncers:  make(map[string]*orderbook.Syncer),
+	}
+	open, ok := sources[exchange]
+	if !ok {
+		return nil, ErrUnsupportedExchange
+	}
+	source, err := open(f)
+	if err != nil {
+		return nil, err
+	}
+	f.source = source
+	return f, nil
+}
+
+// Exchange 交易所名称
+func (f *Feed) Exchange() string {
+	return f.exchange
+}
+
+// SetDepthListener 设置深度监听器
+func (f *Feed) SetDepthListener(listener func(symbol string, depth *proto.Depth)) {
+	f.mutex.Lock()
+	f.depthListener = listener
+	f.mutex.Unlock()
+}
+
+// SetTradeListener 设置成交监听器
+func (f *Feed) SetTradeListener(listener func(symbol string, trade *proto.Trade)) {
+	f.mutex.Lock()
+	f.tradeListener = listener
+	f.mutex.Unlock()
+}
+
+// SubscribeDepth 订阅深度, 已订阅的交易对忽略
+func (f *Feed) SubscribeDepth(symbols ...string) error {
+	var added []string
+	var syncers []*orderbook.Syncer
+	f.mutex.Lock()
+	for _, symbol := range symbols {
+		symbol = strings.ToLower(symbol)
+		if _, ok := f.syncers[symbol]; ok {
+			continue
+		}
+		book := orderbook.NewBook(symbol)
+		book.SetChangeListener(f.changed)
+		s := orderbook.NewSyncer(book, f.source.snapshot(symbol))
+		f.syncers[symbol] = s
+		added = append(added, symbol)
+		syncers = append(syncers, s)
+	}
+	f.mutex.Unlock()
+	if len(added) == 0 {
+		return nil
+	}
+
+	if err := f.source.subscribeDepth(added); err != nil {
+		return err
+	}
+	for _, s := range syncers {
+		s.Start()
+	}
+	return nil
+}
+
+// SubscribeTrade 订阅成交
+func (f *Feed) SubscribeTrade(symbols ...string) error {
+	lower := make([]string, 0, len(symbols))
+	for _, symbol := range symbols {
+		lower = append(lower, strings.ToLower(symbol))
+	}
+	return f.source.subscribeTrade(lower)
+}
+
+// Book 交易对的本地订单簿, 没有订阅深度时返回nil
+func (f *Feed) Book(symbol string) *orderbook.Book {
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+	if s, ok := f.syncers[strings.ToLower(symbol)]; ok {
+		return s.Book()
+	}
+	return nil
+}
+
+// Close 关闭 websocket, 并结束各订单簿的重新加载
+func (f *Feed) Close() error {
+	f.mutex.RLock()
+	for _, s := range f.syncers {
+		s.Stop()
+	}
+	f.mutex.RUnlock()
+	return f.source.close()
+}
+
+// update 送入一条深度推送
+func (f *Feed) update(symbol string, u *orderbook.Update) {
+	f.mutex.RLock()
+	s, ok := f.syncers[strings.ToLower(symbol)]
+	f.mutex.RUnlock()
+	if ok {
+		s.Feed(u)
+	}
+}
+
+// changed 订单簿变化后推送快照
+func (f *Feed) changed(book *orderbook.Book) {
+	f.mutex.RLock()
+	listener := f.depthListener
+	f.mutex.RUnlock()
+	if listener != nil && book.Synced() {
+		listener(book.Symbol(), book.Depth(f.Levels))
+	}
+}
+
+// trade 送入一笔成交
+func (f *Feed) trade(symbol string, trade proto.Trade) {
+	f.mutex.RLock()
+	listener := f.tradeListener
+	f.mutex.RUnlock()
+	if listener != nil {
+		trade.Symbol = strings.ToLower(symbol)
+		listener(trade.Symbol, &trade)
+	}
+}
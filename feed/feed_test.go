@@ -0,0 +1,68 @@
+package feed
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gpmn/sheep/consts"
+	"github.com/gpmn/sheep/huobi"
+	"github.com/gpmn/sheep/mockserver"
+	"github.com/gpmn/sheep/proto"
+)
+
+func TestHuobiFeed(t *testing.T) {
+	s := mockserver.NewHuobi("", "")
+	defer s.Close()
+	defer func(endpoint string) { huobi.Endpoint = endpoint }(huobi.Endpoint)
+	huobi.Endpoint = s.WSURL("/ws")
+
+	if _, err := New("unknown"); err != ErrUnsupportedExchange {
+		t.Fatalf("got %v", err)
+	}
+	f, err := New(consts.ExchangeTypeHuobi)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	f.Levels = 1
+
+	depths := make(chan *proto.Depth, 1)
+	trades := make(chan *proto.Trade, 1)
+	f.SetDepthListener(func(symbol string, depth *proto.Depth) { depths <- depth })
+	f.SetTradeListener(func(symbol string, trade *proto.Trade) { trades <- trade })
+	if err := f.SubscribeDepth("BTCUSDT"); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.SubscribeTrade("btcusdt"); err != nil {
+		t.Fatal(err)
+	}
+
+	s.Publish("market.btcusdt.depth.step0", map[string]interface{}{
+		"bids": [][]float64{{99, 1}, {98, 2}},
+		"asks": [][]float64{{101, 1}, {102, 2}},
+		"ts":   1,
+	})
+	s.Publish("market.btcusdt.trade.detail", map[string]interface{}{
+		"data": []map[string]interface{}{{"amount": 1, "direction": "buy", "price": 100, "ts": 1}},
+	})
+
+	select {
+	case d := <-depths:
+		if d.Symbol != "btcusdt" || len(d.Bids) != 1 || d.Bids[0].Price != 99 || d.Asks[0].Price != 101 {
+			t.Fatalf("depth %+v", d)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("depth not received")
+	}
+	select {
+	case tr := <-trades:
+		if tr.Symbol != "btcusdt" || tr.Price != 100 || tr.Side != proto.TradeSideBuy {
+			t.Fatalf("trade %+v", tr)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("trade not received")
+	}
+	if b := f.Book("btcusdt"); b == nil || !b.Synced() {
+		t.Fatal("book not synced")
+	}
+}
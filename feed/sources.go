@@ -0,0 +1,133 @@
+package feed
+
+import (
+	"github.com/gpmn/sheep/binance"
+	"github.com/gpmn/sheep/consts"
+	"github.com/gpmn/sheep/fcoin"
+	"github.com/gpmn/sheep/huobi"
+	"github.com/gpmn/sheep/okex"
+	"github.com/gpmn/sheep/orderbook"
+)
+
+// BinanceSnapshotLimit 币安增量深度同步时REST全量数据的档数
+var BinanceSnapshotLimit int64 = 1000
+
+var sources = map[string]func(f *Feed) (source, error){
+	consts.ExchangeTypeHuobi:   openHuobi,
+	consts.ExchangeTypeOKEX:    openOKEX,
+	consts.ExchangeTypeFCoin:   openFCoin,
+	consts.ExchangeTypeBinance: openBinance,
+}
+
+// huobiSource : market.<symbol>.depth.step0 全量深度, market.<symbol>.trade.detail 成交
+type huobiSource struct {
+	h *huobi.Huobi
+}
+
+func openHuobi(f *Feed) (source, error) {
+	h := new(huobi.Huobi)
+	if err := h.OpenWebsocket(); err != nil {
+		return nil, err
+	}
+	h.SetDepthlListener(func(symbol string, depth *huobi.MarketDepth) {
+		f.update(symbol, orderbook.HuobiUpdate(depth))
+	})
+	h.SetDetailListener(func(symbol string, detail *huobi.MarketTradeDetail) {
+		for _, t := range detail.Tick.Data {
+			f.trade(symbol, huobi.TransTrade(symbol, t))
+		}
+	})
+	return &huobiSource{h: h}, nil
+}
+
+func (s *huobiSource) subscribeDepth(symbols []string) error {
+	return s.h.SubscribeDepth(symbols...)
+}
+
+func (s *huobiSource) subscribeTrade(symbols []string) error {
+	return s.h.SubscribeDetail(symbols...)
+}
+
+func (s *huobiSource) snapshot(symbol string) orderbook.SnapshotFunc { return nil }
+func (s *huobiSource) close() error                                  { return s.h.CloseWebsocket() }
+
+// okexSource : ok_sub_spot_X_depth 增量深度(首条为全量), ok_sub_spot_X_deals 成交
+type okexSource struct {
+	o *okex.OKEX
+}
+
+func openOKEX(f *Feed) (source, error) {
+	o, _ := okex.NewOKEX("", "")
+	if err := o.OpenWebsocket(); err != nil {
+		return nil, err
+	}
+	o.SetDepthListener(func(symbol string, depth *okex.MarketDepth) {
+		f.update(symbol, orderbook.OKEXUpdate(depth))
+	})
+	o.SetDealsListener(func(symbol string, deals []okex.Deal) {
+		for _, d := range deals {
+			f.trade(symbol, okex.TransDeal(symbol, d))
+		}
+	})
+	return &okexSource{o: o}, nil
+}
+
+func (s *okexSource) subscribeDepth(symbols []string) error         { return s.o.SubscribeDepth(symbols...) }
+func (s *okexSource) subscribeTrade(symbols []string) error         { return s.o.SubscribeDeals(symbols...) }
+func (s *okexSource) snapshot(symbol string) orderbook.SnapshotFunc { return nil }
+func (s *okexSource) close() error                                  { return s.o.CloseWebsocket() }
+
+// fcoinSource : depth.L20.<symbol> 全量深度, trade.<symbol> 成交
+type fcoinSource struct {
+	f *fcoin.FCoin
+}
+
+func openFCoin(f *Feed) (source, error) {
+	fc := new(fcoin.FCoin)
+	if err := fc.OpenWebsocket(); err != nil {
+		return nil, err
+	}
+	fc.SetDepthListener(func(symbol string, depth *fcoin.DepthUpdate) {
+		f.update(symbol, orderbook.FCoinUpdate(depth))
+	})
+	fc.SetTradeListener(func(symbol string, trade *fcoin.TradeUpdate) {
+		f.trade(symbol, fcoin.TransTrade(symbol, trade))
+	})
+	return &fcoinSource{f: fc}, nil
+}
+
+func (s *fcoinSource) subscribeDepth(symbols []string) error {
+	return s.f.SubscribeDepth(fcoin.DepthLevelL20, symbols...)
+}
+
+func (s *fcoinSource) subscribeTrade(symbols []string) error         { return s.f.SubscribeTrade(symbols...) }
+func (s *fcoinSource) snapshot(symbol string) orderbook.SnapshotFunc { return nil }
+func (s *fcoinSource) close() error                                  { return s.f.CloseWebsocket() }
+
+// binanceSource : <symbol>@depth 增量深度, 用REST全量数据同步; <symbol>@trade 成交
+type binanceSource struct {
+	b *binance.Binance
+}
+
+func openBinance(f *Feed) (source, error) {
+	b := binance.New("", "")
+	if err := b.OpenWebsocket(); err != nil {
+		return nil, err
+	}
+	b.SetDepthListener(func(symbol string, depth *binance.WsDepthEvent) {
+		f.update(symbol, orderbook.BinanceUpdate(depth))
+	})
+	b.SetTradeListener(func(symbol string, trade *binance.WsTradeEvent) {
+		f.trade(symbol, binance.TransTrade(trade))
+	})
+	return &binanceSource{b: b}, nil
+}
+
+func (s *binanceSource) subscribeDepth(symbols []string) error { return s.b.SubscribeDepth(symbols...) }
+func (s *binanceSource) subscribeTrade(symbols []string) error { return s.b.SubscribeTrade(symbols...) }
+
+func (s *binanceSource) snapshot(symbol string) orderbook.SnapshotFunc {
+	return orderbook.BinanceSnapshot(s.b, symbol, BinanceSnapshotLimit)
+}
+
+func (s *binanceSource) close() error { return s.b.CloseWebsocket() }
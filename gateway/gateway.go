@@ -0,0 +1,432 @@
+package gateway
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gpmn/sheep"
+	"github.com/gpmn/sheep/proto"
+	"github.com/gpmn/sheep/risk"
+	"github.com/gpmn/sheep/stream"
+	"gopkg.in/yaml.v2"
+)
+
+// Client : 一个调用方的令牌和权限
+type Client struct {
+	Name      string   `yaml:"name"`
+	Token     string   `yaml:"token"`
+	Exchanges []string `yaml:"exchanges"` // 可以访问的交易所实例名称, 为空时为全部
+	Trade     bool     `yaml:"trade"`     // 是否允许下单, 撤单和风控开关
+}
+
+// Allowed 是否可以访问交易所实例
+func (c *Client) Allowed(exchange string) bool {
+	if len(c.Exchanges) == 0 {
+		return true
+	}
+	for _, name := range c.Exchanges {
+		if name == exchange {
+			return true
+		}
+	}
+	return false
+}
+
+// LoadClients 从YAML文件加载调用方, 格式为 {clients: [Client...]}
+func LoadClients(path string) ([]Client, error) {
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file struct {
+		Clients []Client `yaml:"clients"`
+	}
+	if err := yaml.UnmarshalStrict(buf, &file); err != nil {
+		return nil, fmt.Errorf("%s : %v", path, err)
+	}
+	for i, c := range file.Clients {
+		if c.Name == "" || c.Token == "" {
+			return nil, fmt.Errorf("%s : clients[%d] name or token empty", path, i)
+		}
+	}
+	return file.Clients, nil
+}
+
+// FindClient 按令牌查找调用方, 没有时返回nil
+func FindClient(clients []Client, token string) *Client {
+	if token == "" {
+		return nil
+	}
+	for i := range clients {
+		if subtle.ConstantTimeCompare([]byte(clients[i].Token), []byte(token)) == 1 {
+			return &clients[i]
+		}
+	}
+	return nil
+}
+
+// Server : HTTP/JSON 网关.
+// 每个交易所实例都经过 risk.Guard, 没有配置风控的使用空参数, 只提供订单跟踪和总开关.
+// 调用方用 Authorization: Bearer <token> 认证, 浏览器的 websocket 和 EventSource 可以用 ?token=
+//
+//	GET    /v1/exchanges                          交易所实例列表
+//	GET    /v1/exchanges/{name}/balances          余额
+//	GET    /v1/exchanges/{name}/orders            订单列表, 参数同 proto.OrdersParams 的 json 名称
+//	POST   /v1/exchanges/{name}/orders            下单, body 为 proto.OrderPlaceParams, 另可指定 price_precision, amount_precision, 不指定时为价格和数量的小数位数
+//	GET    /v1/exchanges/{name}/orders/{id}       订单详情, ?base_currency_id=&quote_currency_id=
+//	DELETE /v1/exchanges/{name}/orders/{id}       撤单, 参数同上
+//	POST   /v1/exchanges/{name}/kill              打开风控总开关并撤销未完成订单
+//	POST   /v1/exchanges/{name}/resume            关闭风控总开关
+//	GET    /v1/market/{exchange}/depth/{symbol}   最新深度快照, exchange 为交易所类型
+//	GET    /v1/market/{exchange}/trade/{symbol}   最新成交
+//	GET    /v1/stream                             websocket 或 SSE 推送, ?kinds=&exchanges=&symbols= 逗号分隔
+type Server struct {
+	exchanges map[string]sheep.ExchageI
+	guards    map[string]*risk.Guard
+	clients   []Client
+	hub       *stream.Hub
+}
+
+// New 创建网关, exchanges 按实例名称, 已经是 *risk.Guard 的直接使用.
+// 订单变化推送到 hub, 行情需要另外送入 hub (如 config.Runtime.OpenFeeds(hub.OnDepth, hub.OnTrade))
+func New(exchanges map[string]sheep.ExchageI, clients []Client, hub *stream.Hub) *Server {
+	s := &Server{
+		exchanges: make(map[string]sheep.ExchageI),
+		guards:    make(map[string]*risk.Guard),
+		clients:   clients,
+		hub:       hub,
+	}
+	for name, ex := range exchanges {
+		g, ok := ex.(*risk.Guard)
+		if !ok {
+			g = risk.New(ex, risk.Limits{})
+		}
+		g.SetListener(hub.OrderListener(name))
+		s.exchanges[name] = g
+		s.guards[name] = g
+	}
+	return s
+}
+
+// Guard 交易所实例的风控
+func (s *Server) Guard(name string) *risk.Guard {
+	return s.guards[name]
+}
+
+// OnDepth 深度同时作为风控的参考价, 再送入 hub
+func (s *Server) OnDepth(exchange, symbol string, depth *proto.Depth) {
+	for _, g := range s.guardsOf(exchange) {
+		g.OnDepth(symbol, depth)
+	}
+	s.hub.OnDepth(exchange, symbol, depth)
+}
+
+// OnTrade 成交同时作为风控的参考价, 再送入 hub
+func (s *Server) OnTrade(exchange, symbol string, trade *proto.Trade) {
+	for _, g := range s.guardsOf(exchange) {
+		g.OnTrade(symbol, trade)
+	}
+	s.hub.OnTrade(exchange, symbol, trade)
+}
+
+// guardsOf 交易所类型的所有风控, 模拟盘使用所模拟交易所的行情
+func (s *Server) guardsOf(exchangeType string) []*risk.Guard {
+	var res []*risk.Guard
+	for _, g := range s.guards {
+		typ := g.GetExchangeType()
+		if typ == exchangeType || strings.HasSuffix(typ, ":"+exchangeType) {
+			res = append(res, g)
+		}
+	}
+	return res
+}
+
+var (
+	errUnauthorized = errors.New("unauthorized")
+	errForbidden    = errors.New("forbidden")
+	errNotFound     = errors.New("not found")
+	errMethod       = errors.New("method not allowed")
+)
+
+// httpError : 带状态码的错误
+type httpError struct {
+	status int
+	err    error
+}
+
+func (e *httpError) Error() string { return e.err.Error() }
+
+func statusError(status int, err error) error {
+	return &httpError{status: status, err: err}
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+	Rule  string `json:"rule,omitempty"` // 风控拒绝的规则, 见 risk.RuleXXX
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError 风控拒绝为 422, 交易所返回的错误为 502
+func writeError(w http.ResponseWriter, err error) {
+	status := http.StatusBadGateway
+	resp := errorResponse{Error: err.Error()}
+	if e, ok := err.(*httpError); ok {
+		status = e.status
+	}
+	if e, ok := err.(*risk.RejectError); ok {
+		status = http.StatusUnprocessableEntity
+		resp.Rule = e.Rule
+	}
+	writeJSON(w, status, resp)
+}
+
+// authenticate 按令牌查找调用方
+func (s *Server) authenticate(r *http.Request) (*Client, error) {
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if token == "" {
+		token = r.URL.Query().Get("token")
+	}
+	if c := FindClient(s.clients, token); c != nil {
+		return c, nil
+	}
+	return nil, statusError(http.StatusUnauthorized, errUnauthorized)
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	client, err := s.authenticate(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(parts) < 2 || parts[0] != "v1" {
+		writeError(w, statusError(http.StatusNotFound, errNotFound))
+		return
+	}
+
+	switch parts[1] {
+	case "exchanges":
+		if len(parts) == 2 {
+			s.listExchanges(w, r, client)
+			return
+		}
+		err = s.serveExchange(w, r, client, parts[2], parts[3:])
+	case "market":
+		err = s.serveMarket(w, r, parts[2:])
+	case "stream":
+		err = s.serveStream(w, r, client)
+	default:
+		err = statusError(http.StatusNotFound, errNotFound)
+	}
+	if err != nil {
+		writeError(w, err)
+	}
+}
+
+type exchangeInfo struct {
+	Name   string `json:"name"`
+	Type   string `json:"type"`
+	Killed bool   `json:"killed"`
+}
+
+func (s *Server) listExchanges(w http.ResponseWriter, r *http.Request, client *Client) {
+	res := []exchangeInfo{}
+	for name, g := range s.guards {
+		if client.Allowed(name) {
+			res = append(res, exchangeInfo{Name: name, Type: g.GetExchangeType(), Killed: g.Killed()})
+		}
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Name < res[j].Name })
+	writeJSON(w, http.StatusOK, res)
+}
+
+var exchangeRoutes = map[string]bool{
+	"balances":    true,
+	"orders":      true,
+	"orders/{id}": true,
+	"kill":        true,
+	"resume":      true,
+}
+
+func (s *Server) serveExchange(w http.ResponseWriter, r *http.Request, client *Client, name string, parts []string) error {
+	ex, ok := s.exchanges[name]
+	if !ok {
+		return statusError(http.StatusNotFound, errNotFound)
+	}
+	if !client.Allowed(name) {
+		return statusError(http.StatusForbidden, errForbidden)
+	}
+	path := strings.Join(parts, "/")
+	if len(parts) == 2 && parts[0] == "orders" {
+		path = "orders/{id}"
+	}
+	if !exchangeRoutes[path] {
+		return statusError(http.StatusNotFound, errNotFound)
+	}
+	if r.Method != http.MethodGet && !client.Trade {
+		return statusError(http.StatusForbidden, errForbidden)
+	}
+
+	query := r.URL.Query()
+	switch r.Method + " " + path {
+	case "GET balances":
+		balances, err := ex.GetAccountBalance()
+		if err != nil {
+			return err
+		}
+		writeJSON(w, http.StatusOK, balances)
+	case "GET orders":
+		orders, err := ex.GetOrders(&proto.OrdersParams{
+			Symbol:          query.Get("symbol"),
+			States:          query.Get("states"),
+			BaseCurrencyID:  query.Get("base_currency_id"),
+			QuoteCurrencyID: query.Get("quote_currency_id"),
+			Status:          query.Get("status"),
+			CurrentPage:     query.Get("current_page"),
+			PageLength:      query.Get("page_length"),
+		})
+		if err != nil {
+			return err
+		}
+		if orders == nil {
+			orders = []proto.Order{}
+		}
+		writeJSON(w, http.StatusOK, orders)
+	case "POST orders":
+		var req placeRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			return statusError(http.StatusBadRequest, err)
+		}
+		params := req.params()
+		ret, err := ex.OrderPlace(params)
+		if err != nil {
+			return err
+		}
+		writeJSON(w, http.StatusOK, ret)
+	case "GET orders/{id}":
+		order, err := ex.GetOrderInfo(&proto.OrderInfoParams{
+			OrderID:         parts[1],
+			BaseCurrencyID:  query.Get("base_currency_id"),
+			QuoteCurrencyID: query.Get("quote_currency_id"),
+		})
+		if err != nil {
+			return err
+		}
+		writeJSON(w, http.StatusOK, order)
+	case "DELETE orders/{id}":
+		err := ex.OrderCancel(&proto.OrderCancelParams{
+			OrderID:         parts[1],
+			BaseCurrencyID:  query.Get("base_currency_id"),
+			QuoteCurrencyID: query.Get("quote_currency_id"),
+		})
+		if err != nil {
+			return err
+		}
+		writeJSON(w, http.StatusOK, proto.OrderPlaceReturn{OrderID: parts[1]})
+	case "POST kill":
+		g := s.guards[name]
+		if err := g.Kill(); err != nil {
+			return err
+		}
+		writeJSON(w, http.StatusOK, exchangeInfo{Name: name, Type: g.GetExchangeType(), Killed: g.Killed()})
+	case "POST resume":
+		g := s.guards[name]
+		g.Resume()
+		writeJSON(w, http.StatusOK, exchangeInfo{Name: name, Type: g.GetExchangeType(), Killed: g.Killed()})
+	default:
+		return statusError(http.StatusMethodNotAllowed, errMethod)
+	}
+	return nil
+}
+
+// placeRequest : 下单请求, proto.OrderPlaceParams 的精度字段不参与 JSON, 在这里单独传
+type placeRequest struct {
+	proto.OrderPlaceParams
+	PricePrecision  *int `json:"price_precision"`
+	AmountPrecision *int `json:"amount_precision"`
+}
+
+// params 没有指定精度时使用价格和数量的小数位数
+func (r *placeRequest) params() *proto.OrderPlaceParams {
+	params := r.OrderPlaceParams
+	FillPrecision(&params)
+	if r.PricePrecision != nil {
+		params.PricePrecision = *r.PricePrecision
+	}
+	if r.AmountPrecision != nil {
+		params.AmountPrecision = *r.AmountPrecision
+	}
+	return &params
+}
+
+// decimals 小数位数
+func decimals(f float64) int {
+	s := strconv.FormatFloat(f, 'f', -1, 64)
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		return len(s) - i - 1
+	}
+	return 0
+}
+
+// FillPrecision 精度为0时使用价格和数量的小数位数, 避免按精度格式化的交易所(如 huobi)把小数舍掉
+func FillPrecision(params *proto.OrderPlaceParams) {
+	if params.PricePrecision == 0 {
+		params.PricePrecision = decimals(params.Price)
+	}
+	if params.AmountPrecision == 0 {
+		params.AmountPrecision = decimals(params.Amount)
+	}
+}
+
+func (s *Server) serveMarket(w http.ResponseWriter, r *http.Request, parts []string) error {
+	if r.Method != http.MethodGet {
+		return statusError(http.StatusMethodNotAllowed, errMethod)
+	}
+	if len(parts) != 3 {
+		return statusError(http.StatusNotFound, errNotFound)
+	}
+	exchange, kind, symbol := parts[0], parts[1], parts[2]
+	switch kind {
+	case stream.KindDepth:
+		d, ok := s.hub.Depth(exchange, symbol)
+		if !ok {
+			return statusError(http.StatusNotFound, fmt.Errorf("%s %s depth not subscribed", exchange, symbol))
+		}
+		if n, err := strconv.Atoi(r.URL.Query().Get("levels")); err == nil && n > 0 {
+			d = truncate(d, n)
+		}
+		writeJSON(w, http.StatusOK, d)
+	case stream.KindTrade:
+		t, ok := s.hub.Trade(exchange, symbol)
+		if !ok {
+			return statusError(http.StatusNotFound, fmt.Errorf("%s %s trade not subscribed", exchange, symbol))
+		}
+		writeJSON(w, http.StatusOK, t)
+	default:
+		return statusError(http.StatusNotFound, errNotFound)
+	}
+	return nil
+}
+
+func truncate(d *proto.Depth, n int) *proto.Depth {
+	res := *d
+	if len(res.Bids) > n {
+		res.Bids = res.Bids[:n]
+	}
+	if len(res.Asks) > n {
+		res.Asks = res.Asks[:n]
+	}
+	return &res
+}
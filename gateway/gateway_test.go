@@ -0,0 +1,165 @@
+package gateway
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/gpmn/sheep"
+	"github.com/gpmn/sheep/proto"
+	"github.com/gpmn/sheep/risk"
+	"github.com/gpmn/sheep/stream"
+)
+
+type fakeExchange struct {
+	placed []proto.OrderPlaceParams
+}
+
+func (f *fakeExchange) GetExchangeType() string { return "fake" }
+
+func (f *fakeExchange) GetAccountBalance() ([]proto.AccountBalance, error) {
+	return []proto.AccountBalance{{Currency: "usdt", Balance: 1000, Type: proto.AccountBalanceTypeTrade}}, nil
+}
+
+func (f *fakeExchange) OrderPlace(params *proto.OrderPlaceParams) (*proto.OrderPlaceReturn, error) {
+	f.placed = append(f.placed, *params)
+	return &proto.OrderPlaceReturn{OrderID: "1"}, nil
+}
+
+func (f *fakeExchange) OrderCancel(params *proto.OrderCancelParams) error { return nil }
+
+func (f *fakeExchange) GetOrderInfo(params *proto.OrderInfoParams) (*proto.Order, error) {
+	return &proto.Order{ID: params.OrderID, State: proto.OrderStateCanceled}, nil
+}
+
+func (f *fakeExchange) GetOrders(params *proto.OrdersParams) ([]proto.Order, error) { return nil, nil }
+
+func do(t *testing.T, method, url, token, body string) (int, map[string]interface{}) {
+	req, _ := http.NewRequest(method, url, strings.NewReader(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var res map[string]interface{}
+	json.NewDecoder(resp.Body).Decode(&res)
+	return resp.StatusCode, res
+}
+
+func TestServer(t *testing.T) {
+	main, other := &fakeExchange{}, &fakeExchange{}
+	hub := stream.NewHub(0)
+	s := New(map[string]sheep.ExchageI{
+		"main":  risk.New(main, risk.Limits{MaxOrderNotional: 100}),
+		"other": other,
+	}, []Client{
+		{Name: "trader", Token: "t1", Exchanges: []string{"main"}, Trade: true},
+		{Name: "viewer", Token: "t2"},
+	}, hub)
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	order := `{"price": 10.5, "amount": 0.01, "base_currency_id": "btc", "quote_currency_id": "usdt", "type": "buy-limit", "price_precision": 2}`
+	cases := []struct {
+		method, path, token, body string
+		status                    int
+	}{
+		{"GET", "/v1/exchanges", "", "", http.StatusUnauthorized},
+		{"GET", "/v1/exchanges", "bad", "", http.StatusUnauthorized},
+		{"GET", "/v1/exchanges/main/balances", "t2", "", http.StatusOK},
+		{"GET", "/v1/exchanges/other/balances", "t1", "", http.StatusForbidden},
+		{"POST", "/v1/exchanges/main/orders", "t2", order, http.StatusForbidden},
+		{"GET", "/v1/exchanges/main/unknown", "t1", "", http.StatusNotFound},
+		{"PUT", "/v1/exchanges/main/orders", "t1", "", http.StatusMethodNotAllowed},
+		{"POST", "/v1/exchanges/main/orders", "t1", order, http.StatusOK},
+		{"DELETE", "/v1/exchanges/main/orders/1", "t1", "", http.StatusOK},
+		{"GET", "/v1/market/fake/depth/btcusdt", "t2", "", http.StatusNotFound},
+	}
+	for _, c := range cases {
+		if status, res := do(t, c.method, ts.URL+c.path, c.token, c.body); status != c.status {
+			t.Fatalf("%s %s : status %d %v", c.method, c.path, status, res)
+		}
+	}
+	// 没有指定数量精度时为输入的小数位数
+	if len(main.placed) != 1 || main.placed[0].PricePrecision != 2 || main.placed[0].AmountPrecision != 2 {
+		t.Fatalf("placed %+v", main.placed)
+	}
+
+	big := `{"price": 1000, "amount": 1, "base_currency_id": "btc", "quote_currency_id": "usdt", "type": "buy-limit"}`
+	if status, res := do(t, "POST", ts.URL+"/v1/exchanges/main/orders", "t1", big); status != http.StatusUnprocessableEntity || res["rule"] != risk.RuleOrderNotional {
+		t.Fatalf("status %d %v", status, res)
+	}
+	if status, _ := do(t, "POST", ts.URL+"/v1/exchanges/main/kill", "t1", ""); status != http.StatusOK || !s.Guard("main").Killed() {
+		t.Fatal("kill failed")
+	}
+	if status, res := do(t, "POST", ts.URL+"/v1/exchanges/main/orders", "t1", order); status != http.StatusUnprocessableEntity || res["rule"] != risk.RuleKillSwitch {
+		t.Fatalf("status %d %v", status, res)
+	}
+
+	s.OnDepth("fake", "btcusdt", &proto.Depth{Symbol: "btcusdt", Bids: []proto.DepthLevel{{Price: 9, Amount: 1}, {Price: 8, Amount: 1}}})
+	status, res := do(t, "GET", ts.URL+"/v1/market/fake/depth/btcusdt?levels=1", "t2", "")
+	if bids, _ := res["bids"].([]interface{}); status != http.StatusOK || len(bids) != 1 {
+		t.Fatalf("depth %d %v", status, res)
+	}
+}
+
+func TestStream(t *testing.T) {
+	hub := stream.NewHub(0)
+	s := New(map[string]sheep.ExchageI{"main": &fakeExchange{}}, []Client{{Name: "viewer", Token: "t"}}, hub)
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	// SSE
+	req, _ := http.NewRequest("GET", ts.URL+"/v1/stream?kinds=trade&token=t", nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("content type %s", ct)
+	}
+
+	// websocket
+	wsURL := "ws" + strings.TrimPrefix(ts.URL, "http") + "/v1/stream?kinds=depth,trade&symbols=BTCUSDT"
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, http.Header{"Authorization": {"Bearer t"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// 订阅在返回响应头之前建立, 之后的推送不会丢失
+	s.OnTrade("huobi", "ethusdt", &proto.Trade{Symbol: "ethusdt", Price: 1})
+	s.OnTrade("huobi", "btcusdt", &proto.Trade{Symbol: "btcusdt", Price: 100})
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	var ev stream.Event
+	if err := conn.ReadJSON(&ev); err != nil {
+		t.Fatal(err)
+	}
+	if ev.Symbol != "btcusdt" || ev.Trade == nil || ev.Trade.Price != 100 {
+		t.Fatalf("event %+v", ev)
+	}
+
+	reader := bufio.NewReader(resp.Body)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.HasPrefix(line, "data: ") {
+			var ev stream.Event
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &ev); err != nil || ev.Kind != stream.KindTrade {
+				t.Fatalf("sse %s %v", line, err)
+			}
+			break
+		}
+	}
+}
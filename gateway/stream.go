@@ -0,0 +1,134 @@
+package gateway
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/gpmn/sheep/stream"
+)
+
+// PingInterval websocket ping 和 SSE 注释行的间隔, 防止代理断开空闲连接
+var PingInterval = 30 * time.Second
+
+var upgrader = websocket.Upgrader{
+	// 令牌认证, 不检查 Origin
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+func splitList(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, ",")
+}
+
+// serveStream 请求带 Upgrade 头时使用 websocket, 否则使用 SSE, 每条推送为一个 stream.Event.
+// 订单推送只发送调用方可以访问的交易所实例
+func (s *Server) serveStream(w http.ResponseWriter, r *http.Request, client *Client) error {
+	if r.Method != http.MethodGet {
+		return statusError(http.StatusMethodNotAllowed, errMethod)
+	}
+	query := r.URL.Query()
+	sub := s.hub.Subscribe(stream.Filter{
+		Kinds:     splitList(query.Get("kinds")),
+		Exchanges: splitList(query.Get("exchanges")),
+		Symbols:   splitList(query.Get("symbols")),
+	})
+	defer s.hub.Unsubscribe(sub)
+
+	visible := func(ev *stream.Event) bool {
+		return ev.Kind != stream.KindOrder || client.Allowed(ev.Exchange)
+	}
+	if websocket.IsWebSocketUpgrade(r) {
+		return serveWebsocket(w, r, sub, visible)
+	}
+	return serveSSE(w, r, sub, visible)
+}
+
+func serveWebsocket(w http.ResponseWriter, r *http.Request, sub *stream.Subscription, visible func(*stream.Event) bool) error {
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrade 已经返回了错误响应
+		return nil
+	}
+	defer conn.Close()
+
+	// 只读取控制消息, 对方关闭时结束
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	ping := time.NewTicker(PingInterval)
+	defer ping.Stop()
+	for {
+		select {
+		case ev, ok := <-sub.C:
+			if !ok {
+				return nil
+			}
+			if !visible(&ev) {
+				continue
+			}
+			if err := conn.WriteJSON(ev); err != nil {
+				return nil
+			}
+		case <-ping.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(PingInterval)); err != nil {
+				return nil
+			}
+		case <-closed:
+			return nil
+		}
+	}
+}
+
+func serveSSE(w http.ResponseWriter, r *http.Request, sub *stream.Subscription, visible func(*stream.Event) bool) error {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return statusError(http.StatusInternalServerError, errors.New("streaming unsupported"))
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	ping := time.NewTicker(PingInterval)
+	defer ping.Stop()
+	for {
+		select {
+		case ev, ok := <-sub.C:
+			if !ok {
+				return nil
+			}
+			if !visible(&ev) {
+				continue
+			}
+			buf, err := json.Marshal(ev)
+			if err != nil {
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", ev.Kind, buf); err != nil {
+				return nil
+			}
+			flusher.Flush()
+		case <-ping.C:
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return nil
+			}
+			flusher.Flush()
+		case <-r.Context().Done():
+			return nil
+		}
+	}
+}
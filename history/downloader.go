@@ -0,0 +1,211 @@
+package history
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/gpmn/sheep/logger"
+	"github.com/gpmn/sheep/proto"
+)
+
+// ErrNotSupported 数据源不支持该类数据
+var ErrNotSupported = errors.New("history not supported by source")
+
+// Source : 历史数据源, 每次调用请求一页数据
+// 返回[start, end)内按时间从早到晚的已收盘数据, 以及下一页的开始时间, next >= end 表示已到末尾.
+// 时间均为毫秒.
+type Source interface {
+	Candles(symbol string, interval time.Duration, start, end int64) (candles []proto.Candle, next int64, err error)
+	Trades(symbol string, start, end int64) (trades []proto.Trade, next int64, err error)
+}
+
+// Downloader : 分页下载历史数据写入 Store, 从已存储的最后时间继续
+type Downloader struct {
+	RateLimit time.Duration // 两次请求的最小间隔
+	Retries   int           // 请求失败的重试次数
+	Backoff   time.Duration // 第一次重试的等待时间, 之后每次翻倍
+	Logger    logger.Logger // 日志, 为nil时不输出
+
+	store    *Store
+	exchange string
+	source   Source
+	last     time.Time
+}
+
+// NewDownloader 创建下载器, exchange 为存储目录中的交易所名
+func NewDownloader(store *Store, exchange string, source Source) *Downloader {
+	return &Downloader{
+		RateLimit: 200 * time.Millisecond,
+		Retries:   3,
+		Backoff:   time.Second,
+		store:     store,
+		exchange:  exchange,
+		source:    source,
+	}
+}
+
+func millis(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
+}
+
+func (d *Downloader) log() logger.Logger {
+	return logger.OrNop(d.Logger)
+}
+
+// wait 按 RateLimit 限速
+func (d *Downloader) wait() {
+	if d.RateLimit <= 0 {
+		return
+	}
+	if wait := d.RateLimit - time.Since(d.last); wait > 0 {
+		time.Sleep(wait)
+	}
+	d.last = time.Now()
+}
+
+// retry 限速执行fn, 失败时重试
+func (d *Downloader) retry(name string, fn func() error) error {
+	backoff := d.Backoff
+	for i := 0; ; i++ {
+		d.wait()
+		err := fn()
+		if err == nil || err == ErrNotSupported || i >= d.Retries {
+			return err
+		}
+		d.log().Warn("Downloader."+name+" - failed, retry", logger.F("backoff", backoff), logger.Err(err))
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// DownloadCandles 下载[start, end)内的K线, 已存储的部分跳过, 返回新写入的数量.
+// 已存储范围内缺失的K线也会重新下载, 交易所本身没有的K线每次都会再请求一次
+func (d *Downloader) DownloadCandles(symbol string, interval time.Duration, start, end time.Time) (int, error) {
+	from, to := millis(start), millis(end)
+	stored, err := d.store.ReadCandles(d.exchange, symbol, interval, from, to)
+	if err != nil {
+		return 0, err
+	}
+	sort.Slice(stored, func(i, j int) bool { return stored[i].Start < stored[j].Start })
+
+	total := 0
+	step := int64(interval / time.Millisecond)
+	for _, c := range stored {
+		if c.Start > from {
+			n, err := d.downloadCandles(symbol, interval, from, c.Start)
+			total += n
+			if err != nil {
+				return total, err
+			}
+		}
+		if next := c.Start + step; next > from {
+			from = next
+		}
+	}
+	n, err := d.downloadCandles(symbol, interval, from, to)
+	return total + n, err
+}
+
+// downloadCandles 下载[from, to)内的K线, 区间内没有已存储的数据
+func (d *Downloader) downloadCandles(symbol string, interval time.Duration, from, to int64) (int, error) {
+	total := 0
+	for from < to {
+		var candles []proto.Candle
+		var next int64
+		err := d.retry("DownloadCandles", func() (err error) {
+			candles, next, err = d.source.Candles(symbol, interval, from, to)
+			return err
+		})
+		if err != nil {
+			return total, err
+		}
+
+		var page []proto.Candle
+		for _, c := range candles {
+			if c.Start >= from && c.Start < to && c.Closed {
+				page = append(page, c)
+			}
+		}
+		if err = d.store.WriteCandles(d.exchange, symbol, interval, page); err != nil {
+			return total, err
+		}
+		total += len(page)
+		if len(page) > 0 {
+			from = page[len(page)-1].Start + int64(interval/time.Millisecond)
+		}
+		if next > from {
+			from = next
+		} else if len(page) == 0 {
+			break
+		}
+	}
+	return total, nil
+}
+
+// DownloadTrades 下载[start, end)内的成交, 已存储的部分跳过, 返回新写入的数量
+func (d *Downloader) DownloadTrades(symbol string, start, end time.Time) (int, error) {
+	from, to := millis(start), millis(end)
+
+	// 同一毫秒的成交可能跨页, 记录已写入的ID去重
+	seen := make(map[string]bool)
+	last, err := d.store.LastTrade(d.exchange, symbol)
+	if err != nil {
+		return 0, err
+	}
+	if last != nil && last.TS >= from {
+		from = last.TS
+		trades, err := d.store.ReadTrades(d.exchange, symbol, last.TS, last.TS+1)
+		if err != nil {
+			return 0, err
+		}
+		for _, t := range trades {
+			seen[t.ID] = true
+		}
+	}
+
+	total := 0
+	for from < to {
+		var trades []proto.Trade
+		var next int64
+		err = d.retry("DownloadTrades", func() (err error) {
+			trades, next, err = d.source.Trades(symbol, from, to)
+			return err
+		})
+		if err != nil {
+			return total, err
+		}
+
+		var page []proto.Trade
+		for _, t := range trades {
+			if t.TS < from || t.TS >= to || (seen[t.ID] && t.TS == from) {
+				continue
+			}
+			page = append(page, t)
+		}
+		if err = d.store.WriteTrades(d.exchange, symbol, page); err != nil {
+			return total, err
+		}
+		total += len(page)
+
+		if len(page) > 0 {
+			lastTS := page[len(page)-1].TS
+			if lastTS != from {
+				seen = make(map[string]bool)
+			}
+			for _, t := range page {
+				if t.TS == lastTS {
+					seen[t.ID] = true
+				}
+			}
+			from = lastTS
+		}
+		if next > from {
+			from = next
+			seen = make(map[string]bool)
+		} else if len(page) == 0 {
+			break
+		}
+	}
+	return total, nil
+}
@@ -0,0 +1,175 @@
+package history
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gpmn/sheep/proto"
+)
+
+// fakeSource 每页最多3条, 每分钟一根K线, 每30秒两笔成交
+type fakeSource struct {
+	calls int
+}
+
+func (s *fakeSource) Candles(symbol string, interval time.Duration, start, end int64) ([]proto.Candle, int64, error) {
+	s.calls++
+	ms := int64(interval / time.Millisecond)
+	var res []proto.Candle
+	for ts := start - start%ms; ts < end && len(res) < 3; ts += ms {
+		if ts >= start {
+			res = append(res, proto.Candle{Symbol: symbol, Start: ts, End: ts + ms, Open: 1, High: 2, Low: 0.5, Close: 1.5, Volume: 10, Count: 3, Closed: true})
+		}
+	}
+	next := end
+	if len(res) == 3 {
+		next = res[2].End
+	}
+	return res, next, nil
+}
+
+func (s *fakeSource) Trades(symbol string, start, end int64) ([]proto.Trade, int64, error) {
+	s.calls++
+	var res []proto.Trade
+	for ts := start - start%30000; ts < end && len(res) < 3; ts += 30000 {
+		for i := int64(0); i < 2 && len(res) < 3; i++ {
+			if ts >= start {
+				res = append(res, proto.Trade{ID: strconv.FormatInt(ts+i, 10), Symbol: symbol, Price: 1, Amount: 1, Side: proto.TradeSideBuy, TS: ts})
+			}
+		}
+	}
+	next := end
+	if len(res) == 3 {
+		next = res[2].TS
+	}
+	return res, next, nil
+}
+
+func TestDownloadResume(t *testing.T) {
+	dir, err := ioutil.TempDir("", "history")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store := NewStore(dir)
+	source := &fakeSource{}
+	d := NewDownloader(store, "fake", source)
+	d.RateLimit = 0
+
+	// 跨越UTC日期
+	start := time.Date(2018, 6, 1, 23, 55, 0, 0, time.UTC)
+	n, err := d.DownloadCandles("btcusdt", time.Minute, start, start.Add(5*time.Minute))
+	if err != nil || n != 5 {
+		t.Fatalf("downloaded %d, %v", n, err)
+	}
+	n, err = d.DownloadCandles("btcusdt", time.Minute, start, start.Add(10*time.Minute))
+	if err != nil || n != 5 {
+		t.Fatalf("resumed %d, %v", n, err)
+	}
+	candles, err := store.ReadCandles("fake", "btcusdt", time.Minute, millis(start), 0)
+	if err != nil || len(candles) != 10 {
+		t.Fatalf("read %d, %v", len(candles), err)
+	}
+	for i, c := range candles {
+		if c.Start != millis(start.Add(time.Duration(i)*time.Minute)) || c.QuoteVolume != 0 || c.Count != 3 {
+			t.Fatalf("candle %d %+v", i, c)
+		}
+	}
+
+	n, err = d.DownloadTrades("btcusdt", start, start.Add(2*time.Minute))
+	if err != nil || n != 8 {
+		t.Fatalf("trades %d, %v", n, err)
+	}
+	n, err = d.DownloadTrades("btcusdt", start, start.Add(3*time.Minute))
+	if err != nil || n != 4 {
+		t.Fatalf("resumed trades %d, %v", n, err)
+	}
+	trades, err := store.ReadTrades("fake", "btcusdt", 0, 0)
+	if err != nil || len(trades) != 12 {
+		t.Fatalf("read trades %d, %v", len(trades), err)
+	}
+	if last, _ := store.LastTrade("fake", "btcusdt"); last.ID != strconv.FormatInt(millis(start)+150001, 10) {
+		t.Fatalf("last trade %+v", last)
+	}
+}
+
+func TestDownloadGap(t *testing.T) {
+	dir, err := ioutil.TempDir("", "history")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store := NewStore(dir)
+	start := time.Date(2018, 6, 1, 0, 0, 0, 0, time.UTC)
+	candle := func(i int) proto.Candle {
+		ts := millis(start.Add(time.Duration(i) * time.Minute))
+		return proto.Candle{Start: ts, End: ts + 60000, Close: float64(i), Count: 1, Closed: true}
+	}
+	if err := store.WriteCandles("fake", "btcusdt", time.Minute, []proto.Candle{candle(0), candle(1), candle(5)}); err != nil {
+		t.Fatal(err)
+	}
+
+	d := NewDownloader(store, "fake", &fakeSource{})
+	d.RateLimit = 0
+	n, err := d.DownloadCandles("btcusdt", time.Minute, start, start.Add(8*time.Minute))
+	if err != nil || n != 5 {
+		t.Fatalf("downloaded %d, %v", n, err)
+	}
+	candles, err := store.ReadCandles("fake", "btcusdt", time.Minute, 0, 0)
+	if err != nil || len(candles) != 8 {
+		t.Fatalf("read %d, %v", len(candles), err)
+	}
+	for i, c := range candles {
+		if c.Start != candle(i).Start {
+			t.Fatalf("candle %d %+v", i, c)
+		}
+	}
+	if candles[5].Close != 5 || candles[5].Count != 1 {
+		t.Fatalf("stored candle replaced %+v", candles[5])
+	}
+}
+
+func TestPartialRow(t *testing.T) {
+	dir, err := ioutil.TempDir("", "history")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store := NewStore(dir)
+	start := time.Date(2018, 6, 1, 0, 0, 0, 0, time.UTC)
+	candle := func(i int) proto.Candle {
+		ts := millis(start.Add(time.Duration(i) * time.Minute))
+		return proto.Candle{Start: ts, End: ts + 60000, Close: float64(i), Count: 12, Closed: true}
+	}
+	if err := store.WriteCandles("fake", "btcusdt", time.Minute, []proto.Candle{candle(0), candle(1)}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 写入时中断, 留下没有换行的半行
+	path := filepath.Join(dir, "fake", "btcusdt", "1m", "2018-06-01.csv")
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(strconv.FormatInt(candle(2).Start, 10) + ",1527811380000,0,0,0,2,0,0,1")
+	f.Close()
+
+	last, err := store.LastCandle("fake", "btcusdt", time.Minute)
+	if err != nil || last.Start != candle(1).Start {
+		t.Fatalf("last %+v, %v", last, err)
+	}
+	if err := store.WriteCandles("fake", "btcusdt", time.Minute, []proto.Candle{candle(2)}); err != nil {
+		t.Fatal(err)
+	}
+	candles, err := store.ReadCandles("fake", "btcusdt", time.Minute, 0, 0)
+	if err != nil || len(candles) != 3 || candles[2].Count != 12 {
+		t.Fatalf("read %+v, %v", candles, err)
+	}
+}
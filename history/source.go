@@ -0,0 +1,141 @@
+package history
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gpmn/sheep/binance"
+	"github.com/gpmn/sheep/huobi"
+	"github.com/gpmn/sheep/proto"
+)
+
+var huobiPeriods = map[time.Duration]string{
+	time.Minute:        "1min",
+	5 * time.Minute:    "5min",
+	15 * time.Minute:   "15min",
+	30 * time.Minute:   "30min",
+	time.Hour:          "60min",
+	24 * time.Hour:     "1day",
+	7 * 24 * time.Hour: "1week",
+}
+
+var binanceIntervals = map[time.Duration]string{
+	time.Minute:        "1m",
+	3 * time.Minute:    "3m",
+	5 * time.Minute:    "5m",
+	15 * time.Minute:   "15m",
+	30 * time.Minute:   "30m",
+	time.Hour:          "1h",
+	2 * time.Hour:      "2h",
+	4 * time.Hour:      "4h",
+	6 * time.Hour:      "6h",
+	8 * time.Hour:      "8h",
+	12 * time.Hour:     "12h",
+	24 * time.Hour:     "1d",
+	3 * 24 * time.Hour: "3d",
+	7 * 24 * time.Hour: "1w",
+}
+
+// huobiSource 火币只能获取最近2000根K线, 不支持历史成交
+type huobiSource struct {
+	h *huobi.Huobi
+}
+
+// HuobiSource 火币数据源, /market/history/kline 不支持按时间查询, 只能取到最近2000根
+func HuobiSource(h *huobi.Huobi) Source {
+	return &huobiSource{h: h}
+}
+
+func (s *huobiSource) Candles(symbol string, interval time.Duration, start, end int64) ([]proto.Candle, int64, error) {
+	period, ok := huobiPeriods[interval]
+	if !ok {
+		return nil, 0, fmt.Errorf("huobi has no kline period %v", interval)
+	}
+	kl, err := s.h.GetKLines(symbol, period, 2000)
+	if err != nil {
+		return nil, 0, err
+	}
+	if kl.Status != "ok" {
+		return nil, 0, fmt.Errorf("huobi GetKLines status %s", kl.Status)
+	}
+
+	// 返回数据从新到旧
+	var res []proto.Candle
+	for i := len(kl.KLines) - 1; i >= 0; i-- {
+		c := huobi.TransKLine(symbol, interval, kl.KLines[i])
+		if c.Start >= start && c.Start < end {
+			res = append(res, c)
+		}
+	}
+	return res, end, nil
+}
+
+func (s *huobiSource) Trades(symbol string, start, end int64) ([]proto.Trade, int64, error) {
+	return nil, 0, ErrNotSupported
+}
+
+type binanceSource struct {
+	b *binance.Binance
+}
+
+// BinanceSource 币安数据源, K线每页1000根, 归集成交每页1000条且时间跨度不超过1小时
+func BinanceSource(b *binance.Binance) Source {
+	return &binanceSource{b: b}
+}
+
+func (s *binanceSource) Candles(symbol string, interval time.Duration, start, end int64) ([]proto.Candle, int64, error) {
+	name, ok := binanceIntervals[interval]
+	if !ok {
+		return nil, 0, fmt.Errorf("binance has no kline interval %v", interval)
+	}
+	const limit = 1000
+	klines, err := s.b.GetKlines(binance.KlineQuery{
+		Symbol:    strings.ToUpper(symbol),
+		Interval:  name,
+		Limit:     limit,
+		StartTime: start,
+		EndTime:   end - 1,
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var res []proto.Candle
+	for _, k := range klines {
+		res = append(res, binance.TransKline(symbol, k))
+	}
+	next := end
+	if len(klines) == limit {
+		next = klines[len(klines)-1].OpenTime + int64(interval/time.Millisecond)
+	}
+	return res, next, nil
+}
+
+func (s *binanceSource) Trades(symbol string, start, end int64) ([]proto.Trade, int64, error) {
+	const limit = 1000
+	windowEnd := start + 3600*1000
+	if windowEnd > end {
+		windowEnd = end
+	}
+	trades, err := s.b.GetAggTradesRange(binance.AggTradesQuery{
+		Symbol:    strings.ToUpper(symbol),
+		StartTime: start,
+		EndTime:   windowEnd - 1,
+		Limit:     limit,
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var res []proto.Trade
+	for _, t := range trades {
+		res = append(res, binance.TransRestAggTrade(symbol, t))
+	}
+	next := windowEnd
+	if len(trades) == limit {
+		// 本窗口未取完, 从最后一笔的时间继续, 由下载器去重
+		next = trades[len(trades)-1].Timestamp
+	}
+	return res, next, nil
+}
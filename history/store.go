@@ -0,0 +1,412 @@
+package history
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gpmn/sheep/proto"
+)
+
+// dayLayout 数据文件按UTC日期分割
+const dayLayout = "2006-01-02"
+
+// tradesDir 成交数据目录名, K线目录名为周期, 如 1m 1h 1d
+const tradesDir = "trades"
+
+// Store : 本地CSV存储, 目录结构为 <dir>/<exchange>/<symbol>/<周期或trades>/<日期>.csv
+type Store struct {
+	dir string
+}
+
+// NewStore 创建存储
+func NewStore(dir string) *Store {
+	return &Store{dir: dir}
+}
+
+// IntervalName 周期目录名, 如 1m 4h 1d
+func IntervalName(interval time.Duration) string {
+	switch {
+	case interval >= 24*time.Hour && interval%(24*time.Hour) == 0:
+		return fmt.Sprintf("%dd", interval/(24*time.Hour))
+	case interval >= time.Hour && interval%time.Hour == 0:
+		return fmt.Sprintf("%dh", interval/time.Hour)
+	case interval >= time.Minute && interval%time.Minute == 0:
+		return fmt.Sprintf("%dm", interval/time.Minute)
+	default:
+		return fmt.Sprintf("%ds", interval/time.Second)
+	}
+}
+
+func (s *Store) path(exchange, symbol, kind string) string {
+	return filepath.Join(s.dir, exchange, symbol, kind)
+}
+
+func day(ts int64) string {
+	return time.Unix(0, ts*int64(time.Millisecond)).UTC().Format(dayLayout)
+}
+
+func ftoa(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// WriteCandles 写入K线, 和已存储的K线按开始时间合并, 开始时间相同时以新数据为准
+func (s *Store) WriteCandles(exchange, symbol string, interval time.Duration, candles []proto.Candle) error {
+	rows := make([][]string, 0, len(candles))
+	for _, c := range candles {
+		rows = append(rows, []string{
+			strconv.FormatInt(c.Start, 10), strconv.FormatInt(c.End, 10),
+			ftoa(c.Open), ftoa(c.High), ftoa(c.Low), ftoa(c.Close),
+			ftoa(c.Volume), ftoa(c.QuoteVolume), strconv.FormatInt(c.Count, 10),
+		})
+	}
+	return s.merge(s.path(exchange, symbol, IntervalName(interval)), rows)
+}
+
+// WriteTrades 追加成交, trades 需按时间从早到晚且晚于已存储的数据
+func (s *Store) WriteTrades(exchange, symbol string, trades []proto.Trade) error {
+	rows := make([][]string, 0, len(trades))
+	for _, t := range trades {
+		rows = append(rows, []string{
+			strconv.FormatInt(t.TS, 10), t.ID, ftoa(t.Price), ftoa(t.Amount), t.Side,
+		})
+	}
+	return s.append(s.path(exchange, symbol, tradesDir), rows)
+}
+
+// append 按第一列的时间戳写入对应日期的文件
+func (s *Store) append(dir string, rows [][]string) error {
+	if len(rows) == 0 {
+		return nil
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	var f *os.File
+	var w *csv.Writer
+	var current string
+	closeFile := func() error {
+		if f == nil {
+			return nil
+		}
+		w.Flush()
+		err := w.Error()
+		if e := f.Close(); err == nil {
+			err = e
+		}
+		f = nil
+		return err
+	}
+	for _, row := range rows {
+		ts, _ := strconv.ParseInt(row[0], 10, 64)
+		if d := day(ts); d != current || f == nil {
+			if err := closeFile(); err != nil {
+				return err
+			}
+			path := filepath.Join(dir, d+".csv")
+			if err := trimPartial(path); err != nil {
+				return err
+			}
+			var err error
+			f, err = os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+			if err != nil {
+				return err
+			}
+			w = csv.NewWriter(f)
+			current = d
+		}
+		if err := w.Write(row); err != nil {
+			closeFile()
+			return err
+		}
+	}
+	return closeFile()
+}
+
+// trimPartial 截掉文件末尾没有换行的不完整行, 如写入时进程退出留下的半行
+func trimPartial(path string) error {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil || info.Size() == 0 {
+		f.Close()
+		return err
+	}
+	last := make([]byte, 1)
+	_, err = f.ReadAt(last, info.Size()-1)
+	f.Close()
+	if err != nil || last[0] == '\n' {
+		return err
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return os.Truncate(path, int64(bytes.LastIndexByte(data, '\n')+1))
+}
+
+func rowTS(row []string) int64 {
+	ts, _ := strconv.ParseInt(row[0], 10, 64)
+	return ts
+}
+
+// merge 按第一列的时间戳写入对应日期的文件. 新数据都晚于文件中已有的数据时追加,
+// 否则和已有数据合并, 时间戳相同的行以新数据为准, 按时间排序后重写文件
+func (s *Store) merge(dir string, rows [][]string) error {
+	var list []string
+	byDay := make(map[string][][]string)
+	for _, row := range rows {
+		d := day(rowTS(row))
+		if _, ok := byDay[d]; !ok {
+			list = append(list, d)
+		}
+		byDay[d] = append(byDay[d], row)
+	}
+	for _, d := range list {
+		path := filepath.Join(dir, d+".csv")
+		var stored [][]string
+		err := readFile(path, func(row []string) error {
+			stored = append(stored, row)
+			return nil
+		})
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		added := byDay[d]
+		sorted := sort.SliceIsSorted(added, func(i, j int) bool { return rowTS(added[i]) < rowTS(added[j]) })
+		if sorted && (len(stored) == 0 || rowTS(stored[len(stored)-1]) < rowTS(added[0])) {
+			if err = s.append(dir, added); err != nil {
+				return err
+			}
+			continue
+		}
+
+		merged := make(map[int64][]string)
+		for _, row := range append(stored, added...) {
+			merged[rowTS(row)] = row
+		}
+		all := make([][]string, 0, len(merged))
+		for _, row := range merged {
+			all = append(all, row)
+		}
+		sort.Slice(all, func(i, j int) bool { return rowTS(all[i]) < rowTS(all[j]) })
+		if err = rewrite(path, all); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rewrite 写入临时文件后替换, 避免中途退出损坏已有数据
+func rewrite(path string, rows [][]string) error {
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	err = csv.NewWriter(f).WriteAll(rows)
+	if e := f.Close(); err == nil {
+		err = e
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// days 目录下的日期文件, 从早到晚
+func days(dir string) ([]string, error) {
+	infos, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var res []string
+	for _, info := range infos {
+		if name := info.Name(); !info.IsDir() && strings.HasSuffix(name, ".csv") {
+			res = append(res, strings.TrimSuffix(name, ".csv"))
+		}
+	}
+	sort.Strings(res)
+	return res, nil
+}
+
+// read 读取[start, end)时间范围内的行, end 为0表示不限
+func read(dir string, start, end int64, fn func(ts int64, row []string) error) error {
+	list, err := days(dir)
+	if err != nil {
+		return err
+	}
+	for _, d := range list {
+		if d < day(start) || (end > 0 && d > day(end)) {
+			continue
+		}
+		if err = readFile(filepath.Join(dir, d+".csv"), func(row []string) error {
+			ts, err := strconv.ParseInt(row[0], 10, 64)
+			if err != nil {
+				return err
+			}
+			if ts < start || (end > 0 && ts >= end) {
+				return nil
+			}
+			return fn(ts, row)
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readFile 逐行读取, 末尾没有换行的不完整行忽略
+func readFile(path string, fn func(row []string) error) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	data = data[:bytes.LastIndexByte(data, '\n')+1]
+
+	r := csv.NewReader(bytes.NewReader(data))
+	r.FieldsPerRecord = -1
+	for {
+		row, err := r.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+		if err = fn(row); err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+	}
+}
+
+// lastRow 最后一个日期文件的最后一行
+func lastRow(dir string) ([]string, error) {
+	list, err := days(dir)
+	if err != nil {
+		return nil, err
+	}
+	for i := len(list) - 1; i >= 0; i-- {
+		var last []string
+		if err = readFile(filepath.Join(dir, list[i]+".csv"), func(row []string) error {
+			last = row
+			return nil
+		}); err != nil {
+			return nil, err
+		}
+		if last != nil {
+			return last, nil
+		}
+	}
+	return nil, nil
+}
+
+func parseCandle(symbol string, row []string) (proto.Candle, error) {
+	if len(row) < 9 {
+		return proto.Candle{}, fmt.Errorf("invalid candle row %v", row)
+	}
+	c := proto.Candle{Symbol: symbol, Closed: true}
+	var errs [9]error
+	c.Start, errs[0] = strconv.ParseInt(row[0], 10, 64)
+	c.End, errs[1] = strconv.ParseInt(row[1], 10, 64)
+	c.Open, errs[2] = strconv.ParseFloat(row[2], 64)
+	c.High, errs[3] = strconv.ParseFloat(row[3], 64)
+	c.Low, errs[4] = strconv.ParseFloat(row[4], 64)
+	c.Close, errs[5] = strconv.ParseFloat(row[5], 64)
+	c.Volume, errs[6] = strconv.ParseFloat(row[6], 64)
+	c.QuoteVolume, errs[7] = strconv.ParseFloat(row[7], 64)
+	c.Count, errs[8] = strconv.ParseInt(row[8], 10, 64)
+	for _, err := range errs {
+		if err != nil {
+			return c, err
+		}
+	}
+	return c, nil
+}
+
+func parseTrade(symbol string, row []string) (proto.Trade, error) {
+	if len(row) < 5 {
+		return proto.Trade{}, fmt.Errorf("invalid trade row %v", row)
+	}
+	t := proto.Trade{Symbol: symbol, ID: row[1], Side: row[4]}
+	var errs [3]error
+	t.TS, errs[0] = strconv.ParseInt(row[0], 10, 64)
+	t.Price, errs[1] = strconv.ParseFloat(row[2], 64)
+	t.Amount, errs[2] = strconv.ParseFloat(row[3], 64)
+	for _, err := range errs {
+		if err != nil {
+			return t, err
+		}
+	}
+	return t, nil
+}
+
+// ReadCandles 读取开始时间在[start, end)内的K线, 毫秒, end 为0表示不限
+func (s *Store) ReadCandles(exchange, symbol string, interval time.Duration, start, end int64) ([]proto.Candle, error) {
+	var res []proto.Candle
+	err := read(s.path(exchange, symbol, IntervalName(interval)), start, end, func(ts int64, row []string) error {
+		c, err := parseCandle(symbol, row)
+		if err == nil {
+			res = append(res, c)
+		}
+		return err
+	})
+	return res, err
+}
+
+// ReadTrades 读取时间在[start, end)内的成交, 毫秒, end 为0表示不限
+func (s *Store) ReadTrades(exchange, symbol string, start, end int64) ([]proto.Trade, error) {
+	var res []proto.Trade
+	err := read(s.path(exchange, symbol, tradesDir), start, end, func(ts int64, row []string) error {
+		t, err := parseTrade(symbol, row)
+		if err == nil {
+			res = append(res, t)
+		}
+		return err
+	})
+	return res, err
+}
+
+// LastCandle 最后存储的K线
+func (s *Store) LastCandle(exchange, symbol string, interval time.Duration) (*proto.Candle, error) {
+	row, err := lastRow(s.path(exchange, symbol, IntervalName(interval)))
+	if err != nil || row == nil {
+		return nil, err
+	}
+	c, err := parseCandle(symbol, row)
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// LastTrade 最后存储的成交
+func (s *Store) LastTrade(exchange, symbol string) (*proto.Trade, error) {
+	row, err := lastRow(s.path(exchange, symbol, tradesDir))
+	if err != nil || row == nil {
+		return nil, err
+	}
+	t, err := parseTrade(symbol, row)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
@@ -0,0 +1,77 @@
+package huobi
+
+import (
+	"errors"
+	"fmt"
+)
+
+// 账户类型, 见 GetAccounts
+const (
+	AccountTypeSpot   = "spot"
+	AccountTypeMargin = "margin"
+	AccountTypeOTC    = "otc"
+	AccountTypePoint  = "point"
+)
+
+func (h *Huobi) keys() (string, string) {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	return h.accessKey, h.secretKey
+}
+
+// SetKeys 更换密钥, 之后的请求使用新密钥签名, 用于不重启轮换密钥
+func (h *Huobi) SetKeys(accessKey, secretKey string) {
+	h.mutex.Lock()
+	h.accessKey, h.secretKey = accessKey, secretKey
+	h.mutex.Unlock()
+}
+
+// Account 下单和查询余额使用的账户
+func (h *Huobi) Account() Account {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	return h.tradeAccount
+}
+
+// SetAccount 直接指定下单和查询余额使用的账户, 不做校验
+func (h *Huobi) SetAccount(account Account) {
+	h.mutex.Lock()
+	h.tradeAccount = account
+	h.mutex.Unlock()
+}
+
+// Accounts 当前密钥下的所有账户
+func (h *Huobi) Accounts() ([]Account, error) {
+	ret, err := h.GetAccounts()
+	if err != nil {
+		return nil, err
+	}
+	if ret.Status != "ok" {
+		return nil, errors.New(ret.ErrMsg)
+	}
+	accounts := make([]Account, 0, len(ret.Data))
+	for _, data := range ret.Data {
+		accounts = append(accounts, Account{ID: data.ID, Type: data.Type, State: data.State, UserID: data.UserID})
+	}
+	return accounts, nil
+}
+
+// UseAccount 从 Accounts 中选择下单和查询余额使用的账户.
+// accountID 不为0时按id选择, 并校验类型(accountType 为空时不校验); 否则选择第一个 accountType 类型的账户
+func (h *Huobi) UseAccount(accountType string, accountID int64) error {
+	accounts, err := h.Accounts()
+	if err != nil {
+		return err
+	}
+	for _, account := range accounts {
+		if accountID != 0 && account.ID != accountID {
+			continue
+		}
+		if accountType != "" && account.Type != accountType {
+			continue
+		}
+		h.SetAccount(account)
+		return nil
+	}
+	return fmt.Errorf("account %s %d not found", accountType, accountID)
+}
@@ -3,15 +3,17 @@ package huobi
 import (
 	"encoding/json"
 	"errors"
-	"log"
 	"strconv"
 	"strings"
+	"sync"
 
 	"fmt"
 
 	simplejson "github.com/bitly/go-simplejson"
 	"github.com/gpmn/sheep/consts"
+	"github.com/gpmn/sheep/logger"
 	"github.com/gpmn/sheep/proto"
+	"github.com/gpmn/sheep/util"
 )
 
 // LoanOrder :
@@ -47,7 +49,6 @@ type MarginBalanceItem struct {
 // FindMBItem :
 func (mb *MarginBalance) FindMBItem(currency, tp string) *MarginBalanceItem {
 	if mb.State != "working" {
-		log.Printf("MarginBalance.FindMBItem - mb.State %s invalid", mb.State)
 		return nil
 	}
 
@@ -118,16 +119,19 @@ type Huobi struct {
 	accessKey       string
 	secretKey       string
 	tradeAccount    Account
+	mutex           sync.RWMutex // 保护密钥和 tradeAccount, 支持运行时轮换
 	market          *Market
 	depthListener   DepthlListener
 	detailListener  DetailListener
 	klineUpListener KLineUpListener
 	orderListener   OrderListener
+	recorder        util.Recorder
+	logger          logger.Logger
 }
 
 func (h *Huobi) OpenWebsocket() error {
 	var err error
-	h.market, err = NewMarket()
+	h.market, err = dialMarket(h.recorder, h.logger)
 	if err != nil {
 		return err
 	}
@@ -136,6 +140,29 @@ func (h *Huobi) OpenWebsocket() error {
 	return nil
 }
 
+// SetRecorder 设置行情消息记录器, 在 OpenWebsocket 之前调用
+func (h *Huobi) SetRecorder(recorder util.Recorder) {
+	h.recorder = recorder
+}
+
+// SetLogger 设置日志, 为nil时不输出, 同时用于 websocket
+func (h *Huobi) SetLogger(l logger.Logger) {
+	h.logger = l
+	if h.market != nil {
+		h.market.Logger = l
+	}
+}
+
+func (h *Huobi) log() logger.Logger {
+	return logger.OrNop(h.logger)
+}
+
+// OpenReplay 使用离线Market代替websocket连接, 订阅和监听器照常使用, 消息通过返回的 Market.Replay 输入
+func (h *Huobi) OpenReplay() *Market {
+	h.market = NewOfflineMarket()
+	return h.market
+}
+
 func (h *Huobi) CloseWebsocket() error {
 	return h.market.Close()
 }
@@ -175,14 +202,14 @@ func (h *Huobi) GetKLines(symbol, period string, size int) (kl RespGetKLines, er
 	param["symbol"] = symbol
 	param["period"] = period
 	param["size"] = strconv.Itoa(size)
-	jsonReturn, err := apiKeyGet(param, strRequest, h.accessKey, h.secretKey)
+	jsonReturn, err := h.apiKeyGet(param, strRequest)
 	if nil != err {
-		log.Printf("Huobi.GetKLines - apiKeyGet failed : %v, content : %s", err, jsonReturn)
+		h.log().Error("Huobi.GetKLines - apiKeyGet failed", logger.Err(err), logger.F("response", jsonReturn))
 		return kl, err
 	}
 	err = json.Unmarshal([]byte(jsonReturn), &kl)
 	if nil != err {
-		log.Printf("Huobi.GetKLines - failed : %v, content : %s", err, jsonReturn)
+		h.log().Error("Huobi.GetKLines - failed", logger.Err(err), logger.F("response", jsonReturn))
 	}
 	return kl, err
 }
@@ -193,9 +220,9 @@ func (h *Huobi) GetAccounts() (AccountsReturn, error) {
 	accountsReturn := AccountsReturn{}
 
 	strRequest := "/v1/account/accounts"
-	buf, err := apiKeyGet(make(map[string]string), strRequest, h.accessKey, h.secretKey)
+	buf, err := h.apiKeyGet(make(map[string]string), strRequest)
 	if nil != err {
-		log.Printf("Huobi.GetAccounts - apiKeyGet failed : %v, content : %s", err, buf)
+		h.log().Error("Huobi.GetAccounts - apiKeyGet failed", logger.Err(err), logger.F("response", buf))
 		return accountsReturn, err
 	}
 	json.Unmarshal([]byte(buf), &accountsReturn)
@@ -206,18 +233,18 @@ func (h *Huobi) GetAccounts() (AccountsReturn, error) {
 // return: BalanceReturn对象
 func (h *Huobi) GetAccountBalance() ([]proto.AccountBalance, error) {
 	balanceReturn := BalanceReturn{}
-	strRequest := fmt.Sprintf("/v1/account/accounts/%d/balance", h.tradeAccount.ID)
-	jsonBanlanceReturn, err := apiKeyGet(make(map[string]string), strRequest, h.accessKey, h.secretKey)
+	strRequest := fmt.Sprintf("/v1/account/accounts/%d/balance", h.Account().ID)
+	jsonBanlanceReturn, err := h.apiKeyGet(make(map[string]string), strRequest)
 	if nil != err {
-		log.Printf("Huobi.GetAccountBalance - apiKeyGet failed : %v", err)
+		h.log().Error("Huobi.GetAccountBalance - apiKeyGet failed", logger.Err(err))
 		return nil, err
 	}
 	if err = json.Unmarshal([]byte(jsonBanlanceReturn), &balanceReturn); err != nil {
-		log.Printf("Huobi.GetAccountBalance - json.Unmarshal failed : %v", err)
+		h.log().Error("Huobi.GetAccountBalance - json.Unmarshal failed", logger.Err(err))
 		return nil, err
 	}
 	if balanceReturn.Status != "ok" {
-		log.Printf("Huobi.GetAccountBalance - status not ok, return : %+v", balanceReturn)
+		h.log().Error("Huobi.GetAccountBalance - status not ok", logger.F("response", balanceReturn))
 		return nil, fmt.Errorf(balanceReturn.ErrMsg)
 	}
 
@@ -242,18 +269,18 @@ func (h *Huobi) GetMarginBalances(baseSym string) ([]MarginBalance, error) {
 	if baseSym != "" {
 		args["symbol"] = baseSym
 	}
-	buf, err := apiKeyGet(args, strRequest, h.accessKey, h.secretKey)
+	buf, err := h.apiKeyGet(args, strRequest)
 	if nil != err {
-		log.Printf("Huobi.GetMarginBalances - apiKeyGet failed : %v", err)
+		h.log().Error("Huobi.GetMarginBalances - apiKeyGet failed", logger.Err(err))
 		return nil, err
 	}
 	var mbr MarginBalanceResp
 	if err = json.Unmarshal([]byte(buf), &mbr); nil != err {
-		log.Printf("Huobi.GetMarginBalances - json.Unmarshal failed %v", err)
+		h.log().Error("Huobi.GetMarginBalances - json.Unmarshal failed", logger.Err(err))
 		return nil, err
 	}
 	if mbr.Status != "ok" {
-		log.Printf("Huobi.GetMarginBalances - response status %s, invalid", mbr.Status)
+		h.log().Error("Huobi.GetMarginBalances - response status invalid", logger.F("status", mbr.Status))
 		return nil, fmt.Errorf("response status wrong : " + mbr.Status)
 	}
 
@@ -266,7 +293,7 @@ func (h *Huobi) GetMarginBalances(baseSym string) ([]MarginBalance, error) {
 func (h *Huobi) OrderPlace(params *proto.OrderPlaceParams) (*proto.OrderPlaceReturn, error) {
 	placeReturn := PlaceReturn{}
 	var placeRequestParams PlaceRequestParams
-	placeRequestParams.AccountID = strconv.FormatInt(h.tradeAccount.ID, 10)
+	placeRequestParams.AccountID = strconv.FormatInt(h.Account().ID, 10)
 	fmtStr := fmt.Sprintf("%%.%df", params.AmountPrecision)
 	placeRequestParams.Amount = fmt.Sprintf(fmtStr, params.Amount) //strconv.FormatFloat(params.Amount, 'f', -1, 64)
 	fmtStr = fmt.Sprintf("%%.%df", params.PricePrecision)
@@ -288,15 +315,15 @@ func (h *Huobi) OrderPlace(params *proto.OrderPlaceParams) (*proto.OrderPlaceRet
 	mapParams["type"] = placeRequestParams.Type
 
 	strRequest := "/v1/order/orders/place"
-	buf, err := apiKeyPost(mapParams, strRequest, h.accessKey, h.secretKey)
+	buf, err := h.apiKeyPost(mapParams, strRequest)
 	if nil != err {
-		log.Printf("Huobi.OrderPlace - apiKeyPost failed : %v", err)
+		h.log().Error("Huobi.OrderPlace - apiKeyPost failed", logger.Err(err))
 		return nil, err
 	}
 	json.Unmarshal([]byte(buf), &placeReturn)
 
 	if placeReturn.Status != "ok" {
-		log.Printf("Huobi.OrderPlace - status wrong : %v", placeReturn)
+		h.log().Error("Huobi.OrderPlace - status wrong", logger.F("response", placeReturn))
 		return nil, errors.New(placeReturn.ErrMsg)
 	}
 
@@ -314,9 +341,9 @@ func (h *Huobi) OrderCancel(params *proto.OrderCancelParams) error {
 	placeReturn := PlaceReturn{}
 
 	strRequest := fmt.Sprintf("/v1/order/orders/%s/submitcancel", params.OrderID)
-	buf, err := apiKeyPost(make(map[string]string), strRequest, h.accessKey, h.secretKey)
+	buf, err := h.apiKeyPost(make(map[string]string), strRequest)
 	if nil != err {
-		log.Printf("Huobi.OrderCancel - apiKeyPost failed : %v", err)
+		h.log().Error("Huobi.OrderCancel - apiKeyPost failed", logger.Err(err))
 		return err
 	}
 	json.Unmarshal([]byte(buf), &placeReturn)
@@ -335,9 +362,9 @@ func (h *Huobi) GetOrderInfo(params *proto.OrderInfoParams) (*proto.Order, error
 	orderReturn := OrderReturn{}
 
 	strRequest := fmt.Sprintf("/v1/order/orders/%s", params.OrderID)
-	jsonPlaceReturn, err := apiKeyGet(make(map[string]string), strRequest, h.accessKey, h.secretKey)
+	jsonPlaceReturn, err := h.apiKeyGet(make(map[string]string), strRequest)
 	if nil != err {
-		log.Printf("Huobi.GetOrderInfo - apiKeyGet failed : %v", err)
+		h.log().Error("Huobi.GetOrderInfo - apiKeyGet failed", logger.Err(err))
 		return nil, err
 	}
 	json.Unmarshal([]byte(jsonPlaceReturn), &orderReturn)
@@ -350,10 +377,12 @@ func (h *Huobi) GetOrderInfo(params *proto.OrderInfoParams) (*proto.Order, error
 	ret.Price, _ = strconv.ParseFloat(orderReturn.Data.Price, 64)
 	ret.ID = strconv.FormatInt(orderReturn.Data.ID, 10)
 	ret.Symbol = orderReturn.Data.Symbol
-	ret.State = orderReturn.Data.State
+	ret.State = TransOrderState(orderReturn.Data.State)
 	ret.FieldAmount, _ = strconv.ParseFloat(orderReturn.Data.FieldAmount, 64)
 	ret.Type = orderReturn.Data.Type
 	ret.Amount, _ = strconv.ParseFloat(orderReturn.Data.Amount, 64)
+	ret.CreatedSec = orderReturn.Data.CreatedSec
+	ret.FieldCashAmount, _ = strconv.ParseFloat(orderReturn.Data.FieldCashAmount, 64)
 
 	return &ret, nil
 
@@ -369,9 +398,9 @@ func (h *Huobi) GetOrders(params *proto.OrdersParams) ([]proto.Order, error) {
 	json.Unmarshal(jsonP, &paramMap)
 
 	strRequest := "/v1/order/orders"
-	jsonRet, err := apiKeyGet(paramMap, strRequest, h.accessKey, h.secretKey)
+	jsonRet, err := h.apiKeyGet(paramMap, strRequest)
 	if nil != err {
-		log.Printf("Huobi.GetOrders - apiKeyGet failed : %v", err)
+		h.log().Error("Huobi.GetOrders - apiKeyGet failed", logger.Err(err))
 		return nil, err
 	}
 
@@ -386,11 +415,12 @@ func (h *Huobi) GetOrders(params *proto.OrdersParams) ([]proto.Order, error) {
 		item.Price, _ = strconv.ParseFloat(cell.Price, 64)
 		item.ID = strconv.FormatInt(cell.ID, 10)
 		item.Symbol = cell.Symbol
-		item.State = cell.State
+		item.State = TransOrderState(cell.State)
 		item.FieldAmount, _ = strconv.ParseFloat(cell.FieldAmount, 64)
 		item.Type = cell.Type
 		item.Amount, _ = strconv.ParseFloat(cell.Amount, 64)
 		item.CreatedSec = cell.CreatedSec
+		item.FieldCashAmount, _ = strconv.ParseFloat(cell.FieldCashAmount, 64)
 
 		ret = append(ret, item)
 	}
@@ -408,9 +438,9 @@ func (h *Huobi) GetOpenOrders(params *proto.OrdersParams) ([]proto.Order, error)
 	json.Unmarshal(jsonP, &paramMap)
 
 	strRequest := "/v1/order/openOrders"
-	jsonRet, err := apiKeyGet(paramMap, strRequest, h.accessKey, h.secretKey)
+	jsonRet, err := h.apiKeyGet(paramMap, strRequest)
 	if nil != err {
-		log.Printf("Huobi.GetOrders - apiKeyGet failed : %v", err)
+		h.log().Error("Huobi.GetOrders - apiKeyGet failed", logger.Err(err))
 		return nil, err
 	}
 
@@ -426,11 +456,12 @@ func (h *Huobi) GetOpenOrders(params *proto.OrdersParams) ([]proto.Order, error)
 		item.Price = cell.Price
 		item.ID = fmt.Sprintf("%d", cell.ID)
 		item.Symbol = cell.Symbol
-		item.State = cell.State
+		item.State = TransOrderState(cell.State)
 		item.FieldAmount = cell.FilledAmount
 		item.Type = cell.Type
 		item.Amount = cell.Amount
 		item.CreatedSec = cell.CreatedSec
+		item.FieldCashAmount = cell.FilledCashAmount
 
 		ret = append(ret, item)
 	}
@@ -445,12 +476,12 @@ func (h *Huobi) GetPointOrders() (*proto.Order, error) {
 	//orderReturn := OrderReturn{}
 
 	strRequest := fmt.Sprintf("/v1/points/orders")
-	jsonPlaceReturn, err := apiKeyGet(make(map[string]string), strRequest, h.accessKey, h.secretKey)
+	jsonPlaceReturn, err := h.apiKeyGet(make(map[string]string), strRequest)
 	if nil != err {
-		log.Printf("Huobi.GetPointOrders - apiKeyGet failed : %v", err)
+		h.log().Error("Huobi.GetPointOrders - apiKeyGet failed", logger.Err(err))
 		return nil, err
 	}
-	log.Println(jsonPlaceReturn)
+	h.log().Debug("Huobi.GetPointOrders", logger.F("response", jsonPlaceReturn))
 	//json.Unmarshal([]byte(jsonPlaceReturn), &orderReturn)
 	//
 	//if orderReturn.Status != "ok" {
@@ -461,7 +492,7 @@ func (h *Huobi) GetPointOrders() (*proto.Order, error) {
 	//ret.Price, _ = strconv.ParseFloat(orderReturn.Data.Price, 64)
 	//ret.ID = orderReturn.Data.ID
 	//ret.Symbol = orderReturn.Data.Symbol
-	//ret.State = orderReturn.Data.State
+	//ret.State = TransOrderState(orderReturn.Data.State)
 	//ret.FieldAmount, _ = strconv.ParseFloat(orderReturn.Data.FieldAmount, 64)
 	//ret.Type = orderReturn.Data.Type
 	//ret.Amount, _ = strconv.ParseFloat(orderReturn.Data.Amount, 64)
@@ -496,7 +527,7 @@ func (h *Huobi) SubscribeDetail(symbols ...string) (err error) {
 			var mtd MarketTradeDetail
 			err := json.Unmarshal(js, &mtd)
 			if err != nil {
-				log.Printf("Huobi.SubscribeDetail - callback failed : %s", err.Error())
+				h.log().Error("Huobi.SubscribeDetail - callback failed", logger.Err(err))
 				return
 			}
 
@@ -506,7 +537,7 @@ func (h *Huobi) SubscribeDetail(symbols ...string) (err error) {
 			}
 		})
 		if nil != err {
-			log.Printf("Huobi.SubscribeDetail - Subscribe failed : %v", err)
+			h.log().Error("Huobi.SubscribeDetail - Subscribe failed", logger.Err(err))
 			return err
 		}
 	}
@@ -516,14 +547,15 @@ func (h *Huobi) SubscribeDetail(symbols ...string) (err error) {
 // Listener 订阅事件监听器
 type DepthlListener func(symbol string, depth *MarketDepth)
 
-func (h *Huobi) SubscribeDepth(symbols ...string) {
+// SubscribeDepth :
+func (h *Huobi) SubscribeDepth(symbols ...string) error {
 	for _, symbol := range symbols {
-		h.market.Subscribe("market."+symbol+".depth.step0", func(topic string, j *simplejson.Json) {
+		e := h.market.Subscribe("market."+symbol+".depth.step0", func(topic string, j *simplejson.Json) {
 			js, _ := j.MarshalJSON()
 			var md = MarketDepth{}
 			err := json.Unmarshal(js, &md)
 			if err != nil {
-				log.Printf("Huobi.SubscribeDepth - callback failed : %s", err.Error())
+				h.log().Error("Huobi.SubscribeDepth - callback failed", logger.Err(err))
 				return
 			}
 
@@ -532,7 +564,12 @@ func (h *Huobi) SubscribeDepth(symbols ...string) {
 				h.depthListener(ts[1], &md)
 			}
 		})
+		if e != nil {
+			h.log().Error("Huobi.SubscribeDepth - h.market.Subscribe failed", logger.Err(e))
+			return e
+		}
 	}
+	return nil
 }
 
 // KLineUpListener :
@@ -546,7 +583,7 @@ func (h *Huobi) SubscribeKLine(period string, symbols ...string) error {
 			var mku KLineUpdate
 			err := json.Unmarshal(js, &mku)
 			if err != nil {
-				log.Printf("Huobi.SubscribeKLine - callback failed : %s", err.Error())
+				h.log().Error("Huobi.SubscribeKLine - callback failed", logger.Err(err))
 				return
 			}
 
@@ -556,7 +593,7 @@ func (h *Huobi) SubscribeKLine(period string, symbols ...string) error {
 			}
 		})
 		if e != nil {
-			log.Printf("Huobi.SubscribeKLine - h.market.Subscribe failed : %v", e)
+			h.log().Error("Huobi.SubscribeKLine - h.market.Subscribe failed", logger.Err(e))
 			return e
 		}
 	}
@@ -587,7 +624,7 @@ type OrderUpdateData struct {
 type OrderUpdate struct {
 	OP    string          `json:"op"`
 	Topic string          `json:"topic"`
-	ts    int64           `json:"ts"`
+	TS    int64           `json:"ts"`
 	Order OrderUpdateData `json:"data"`
 }
 
@@ -605,7 +642,7 @@ func (h *Huobi) SubscribeOrder(symbols ...string) (err error) {
 				var order OrderUpdate
 				err := json.Unmarshal(js, &order)
 				if err != nil {
-					log.Printf("Huobi.SubscribeDetail - callback failed : %s", err.Error())
+					h.log().Error("Huobi.SubscribeDetail - callback failed", logger.Err(err))
 					return
 				}
 
@@ -615,7 +652,7 @@ func (h *Huobi) SubscribeOrder(symbols ...string) (err error) {
 				}
 			})
 		if nil != err {
-			log.Printf("Huobi.SubscribeOrder - Subscribe failed : %s", err.Error())
+			h.log().Error("Huobi.SubscribeOrder - Subscribe failed", logger.Err(err))
 			return err
 		}
 	}
@@ -634,20 +671,20 @@ func (h *Huobi) SubscribeOrder(symbols ...string) (err error) {
 // 只需要"symbol":"xxxxxx"和 "states":"accrual"， 其他应该都不需要
 func (h *Huobi) GetMarginLoanOrders(params map[string]string) ([]LoanOrder, error) {
 	strReqURL := "/v1/margin/loan-orders"
-	buf, err := apiKeyGet(params, strReqURL, h.accessKey, h.secretKey)
+	buf, err := h.apiKeyGet(params, strReqURL)
 	if nil != err {
-		log.Printf("Huobi.GetMarginLoanOrders - apiKeyGet failed : %v", err)
+		h.log().Error("Huobi.GetMarginLoanOrders - apiKeyGet failed", logger.Err(err))
 		return nil, err
 	}
 	var resp LoanOrderResp
 	err = json.Unmarshal([]byte(buf), &resp)
 	if nil != err {
-		log.Printf("Huobi.GetMarginLoanOrders - json.Unmarshal '%s' failed : %v", buf, err)
+		h.log().Error("Huobi.GetMarginLoanOrders - json.Unmarshal failed", logger.F("response", buf), logger.Err(err))
 		return nil, err
 	}
 
 	if resp.Status != "ok" {
-		log.Printf("Huobi.GetMarginLoanOrders - resp.Status %s, invalid", resp.Status)
+		h.log().Error("Huobi.GetMarginLoanOrders - resp.Status invalid", logger.F("status", resp.Status))
 		return nil, fmt.Errorf("status %s invalid", resp.Status)
 	}
 
@@ -668,19 +705,19 @@ func (h *Huobi) MarginIO(symbol, currency string, dirIn bool, amount float64) er
 		"amount":   fmt.Sprintf("%.8f", amount),
 	}
 
-	buf, err := apiKeyPost(params, strReqURL, h.accessKey, h.secretKey)
+	buf, err := h.apiKeyPost(params, strReqURL)
 	if nil != err {
-		log.Printf("Huobi.MarginIO - apiKeyPost failed : %v", err)
+		h.log().Error("Huobi.MarginIO - apiKeyPost failed", logger.Err(err))
 		return err
 	}
 	resMap := make(map[string]interface{})
 	err = json.Unmarshal([]byte(buf), &resMap)
 	if nil != err {
-		log.Printf("Huobi.MarginIO - json.Unmarshal '%s' failed : %v", buf, err)
+		h.log().Error("Huobi.MarginIO - json.Unmarshal failed", logger.F("response", buf), logger.Err(err))
 		return err
 	}
 	if resMap["status"] != "ok" {
-		log.Printf("Huobi.MarginIO - status invalid, response : %s", buf)
+		h.log().Error("Huobi.MarginIO - status invalid", logger.F("response", buf))
 		return fmt.Errorf("status %s invalid", resMap["status"])
 	}
 	return nil
@@ -689,19 +726,19 @@ func (h *Huobi) MarginIO(symbol, currency string, dirIn bool, amount float64) er
 // RepayLoan :
 func (h *Huobi) RepayLoan(loanID int, amount string /*not float*/) (err error) {
 	strReqURL := fmt.Sprintf("/v1/margin/orders/%d/repay", loanID)
-	buf, err := apiKeyPost(map[string]string{"amount": amount}, strReqURL, h.accessKey, h.secretKey)
+	buf, err := h.apiKeyPost(map[string]string{"amount": amount}, strReqURL)
 	if nil != err {
-		log.Printf("Huobi.RepayLoan - apiKeyPost failed : %v", err)
+		h.log().Error("Huobi.RepayLoan - apiKeyPost failed", logger.Err(err))
 		return err
 	}
 	resMap := make(map[string]interface{})
 	err = json.Unmarshal([]byte(buf), &resMap)
 	if nil != err {
-		log.Printf("Huobi.RepayLoan - json.Unmarshal '%s' failed : %v", buf, err)
+		h.log().Error("Huobi.RepayLoan - json.Unmarshal failed", logger.F("response", buf), logger.Err(err))
 		return err
 	}
 	if resMap["status"] != "ok" {
-		log.Printf("Huobi.RepayLoan - status invalid, response : %s", buf)
+		h.log().Error("Huobi.RepayLoan - status invalid", logger.F("response", buf))
 		return fmt.Errorf("status %s invalid", resMap["status"])
 	}
 	return nil
@@ -710,24 +747,24 @@ func (h *Huobi) RepayLoan(loanID int, amount string /*not float*/) (err error) {
 // ApplyLoan :
 func (h *Huobi) ApplyLoan(symbol, currency string, amount float64) (err error) {
 	strReqURL := "/v1/margin/orders"
-	buf, err := apiKeyPost(map[string]string{
+	buf, err := h.apiKeyPost(map[string]string{
 		"symbol":   symbol,
 		"currency": currency,
 		"amount":   fmt.Sprintf("%.8f", amount)},
-		strReqURL, h.accessKey, h.secretKey)
+		strReqURL)
 
 	if nil != err {
-		log.Printf("Huobi.ApplyLoan - apiKeyPost failed : %v", err)
+		h.log().Error("Huobi.ApplyLoan - apiKeyPost failed", logger.Err(err))
 		return err
 	}
 	resMap := make(map[string]interface{})
 	err = json.Unmarshal([]byte(buf), &resMap)
 	if nil != err {
-		log.Printf("Huobi.ApplyLoan - json.Unmarshal '%s' failed : %v", buf, err)
+		h.log().Error("Huobi.ApplyLoan - json.Unmarshal failed", logger.F("response", buf), logger.Err(err))
 		return err
 	}
 	if resMap["status"] != "ok" {
-		log.Printf("Huobi.ApplyLoan - status invalid, response : %s", buf)
+		h.log().Error("Huobi.ApplyLoan - status invalid", logger.F("response", buf))
 		return fmt.Errorf("status %s invalid", resMap["status"])
 	}
 	return nil
@@ -750,19 +787,19 @@ type getSymbolResp struct {
 
 // GetSymbols :
 func (h *Huobi) GetSymbols() (descs []SymbolDesc, err error) {
-	buf, err := apiKeyGet(map[string]string{}, "/v1/common/symbols", h.accessKey, h.secretKey)
+	buf, err := h.apiKeyGet(map[string]string{}, "/v1/common/symbols")
 	if nil != err {
-		log.Printf("Huobi.GetSymbols - apiKeyGet failed : %v", err)
+		h.log().Error("Huobi.GetSymbols - apiKeyGet failed", logger.Err(err))
 		return nil, err
 	}
 
 	var resp getSymbolResp
 	if err = json.Unmarshal([]byte(buf), &resp); nil != err {
-		log.Printf("Huobi.GetSymbols - json.Unmarshal '%s' failed : %v", buf, err)
+		h.log().Error("Huobi.GetSymbols - json.Unmarshal failed", logger.F("response", buf), logger.Err(err))
 		return nil, err
 	}
 	if resp.Status != "ok" {
-		log.Printf("Huobi.GetSymbols - status invalid, response : %s", buf)
+		h.log().Error("Huobi.GetSymbols - status invalid", logger.F("response", buf))
 		return nil, fmt.Errorf("status %s invalid", resp.Status)
 	}
 	return resp.Data, nil
@@ -780,25 +817,38 @@ type getTickersResp struct {
 		Count  int     `json:"count"`
 		Vol    float64 `json:"vol"`
 		Symbol string  `json:"symbol"`
+		// 最优买卖价
+		Bid     float64 `json:"bid"`
+		BidSize float64 `json:"bidSize"`
+		Ask     float64 `json:"ask"`
+		AskSize float64 `json:"askSize"`
 	} `json:"data"`
 }
 
-// GetTickers :
-func (h *Huobi) GetTickers() (tickerMap map[string]*TickData, err error) {
-	buf, err := apiKeyGet(map[string]string{}, "/market/tickers", h.accessKey, h.secretKey)
+func (h *Huobi) getTickers(caller string) (*getTickersResp, error) {
+	buf, err := h.apiKeyGet(map[string]string{}, "/market/tickers")
 	if nil != err {
-		log.Printf("Huobi.GetTickers - apiKeyGet failed : %v", err)
+		h.log().Error(caller+" - apiKeyGet failed", logger.Err(err))
 		return nil, err
 	}
 	var resp getTickersResp
 	if err = json.Unmarshal([]byte(buf), &resp); nil != err {
-		log.Printf("Huobi.GetTickers - json.Unmarshal '%s' failed : %v", buf, err)
+		h.log().Error(caller+" - json.Unmarshal failed", logger.F("response", buf), logger.Err(err))
 		return nil, err
 	}
 	if resp.Status != "ok" {
-		log.Printf("Huobi.GetTickers - status invalid, response : %s", buf)
+		h.log().Error(caller+" - status invalid", logger.F("response", buf))
 		return nil, fmt.Errorf("status %s invalid", resp.Status)
 	}
+	return &resp, nil
+}
+
+// GetTickers :
+func (h *Huobi) GetTickers() (tickerMap map[string]*TickData, err error) {
+	resp, err := h.getTickers("Huobi.GetTickers")
+	if nil != err {
+		return nil, err
+	}
 	tickerMap = make(map[string]*TickData)
 	for idx := range resp.Data {
 		data := &resp.Data[idx]
@@ -812,7 +862,36 @@ func (h *Huobi) GetTickers() (tickerMap map[string]*TickData, err error) {
 	return tickerMap, nil
 }
 
-// NewHuobi :
+// BookTicker : 最优买卖价
+type BookTicker struct {
+	Bid     float64 `json:"bid"`
+	BidSize float64 `json:"bid_size"`
+	Ask     float64 `json:"ask"`
+	AskSize float64 `json:"ask_size"`
+	TS      int64   `json:"ts"`
+}
+
+// GetBookTickers : 全部交易对的最优买卖价, 和 GetTickers 同一个接口
+func (h *Huobi) GetBookTickers() (map[string]*BookTicker, error) {
+	resp, err := h.getTickers("Huobi.GetBookTickers")
+	if nil != err {
+		return nil, err
+	}
+	res := make(map[string]*BookTicker)
+	for idx := range resp.Data {
+		data := &resp.Data[idx]
+		res[data.Symbol] = &BookTicker{
+			Bid:     data.Bid,
+			BidSize: data.BidSize,
+			Ask:     data.Ask,
+			AskSize: data.AskSize,
+			TS:      resp.Ts,
+		}
+	}
+	return res, nil
+}
+
+// NewHuobi : 默认使用第一个现货账户, 其他账户用 UseAccount 或 SetAccount 指定
 func NewHuobi(accesskey, secretkey string) (*Huobi, error) {
 	h := &Huobi{
 		accessKey: accesskey,
@@ -820,7 +899,6 @@ func NewHuobi(accesskey, secretkey string) (*Huobi, error) {
 	}
 
 	if accesskey != "" {
-		log.Println("init huobi.")
 		ret, err := h.GetAccounts()
 		if nil != err {
 			return nil, err
@@ -831,7 +909,6 @@ func NewHuobi(accesskey, secretkey string) (*Huobi, error) {
 
 		for _, account := range ret.Data {
 			if account.Type == "spot" {
-				log.Println("account id:", account.ID)
 				h.tradeAccount.ID = account.ID
 				h.tradeAccount.Type = account.Type
 				h.tradeAccount.State = account.State
@@ -842,7 +919,5 @@ func NewHuobi(accesskey, secretkey string) (*Huobi, error) {
 		}
 	}
 
-	log.Println("init huobi success.")
-
 	return h, nil
 }
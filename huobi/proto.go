@@ -61,6 +61,8 @@ type Order struct {
 	Price       string `json:"price"`
 	Type        string `json:"type"`
 	CreatedSec  int64  `json:"created-at"`
+
+	FieldCashAmount string `json:"field-cash-amount"`
 }
 type OrderReturn struct {
 	Status  string `json:"status"`
@@ -1,12 +1,23 @@
 package huobi
 
 import (
+	"net/url"
 	"time"
 
 	"github.com/gpmn/sheep/util"
 )
 
-const host = "https://api.huobi.pro"
+// Host REST API入口
+var Host = "https://api.huobi.pro"
+
+// hostName 参与签名的主机名, 取自 Host
+func hostName() string {
+	u, err := url.Parse(Host)
+	if err != nil {
+		return "api.huobi.pro"
+	}
+	return u.Host
+}
 
 // 进行签名后的HTTP GET请求, 参考官方Python Demo写的
 // mapParams: map类型的请求参数, key:value
@@ -21,10 +32,9 @@ func apiKeyGet(mapParams map[string]string, strRequestPath string, accessKey, se
 	mapParams["SignatureVersion"] = "2"
 	mapParams["Timestamp"] = timestamp
 
-	hostName := "api.huobi.pro"
-	mapParams["Signature"] = createSign(mapParams, strMethod, hostName, strRequestPath, secretKey)
+	mapParams["Signature"] = createSign(mapParams, strMethod, hostName(), strRequestPath, secretKey)
 
-	strURL := host + strRequestPath
+	strURL := Host + strRequestPath
 	return util.HttpGetRequest(strURL, util.MapValueEncodeURI(mapParams))
 }
 
@@ -42,10 +52,20 @@ func apiKeyPost(mapParams map[string]string, strRequestPath string, accessKey, s
 	mapParams2Sign["SignatureVersion"] = "2"
 	mapParams2Sign["Timestamp"] = timestamp
 
-	hostName := "api.huobi.pro"
-
-	mapParams2Sign["Signature"] = createSign(mapParams2Sign, strMethod, hostName, strRequestPath, secretKey)
-	strURL := host + strRequestPath + "?" + util.Map2UrlQuery(util.MapValueEncodeURI(mapParams2Sign))
+	mapParams2Sign["Signature"] = createSign(mapParams2Sign, strMethod, hostName(), strRequestPath, secretKey)
+	strURL := Host + strRequestPath + "?" + util.Map2UrlQuery(util.MapValueEncodeURI(mapParams2Sign))
 
 	return util.HttpPostRequest(strURL, mapParams, nil)
 }
+
+// apiKeyGet 使用当前密钥签名的GET请求
+func (h *Huobi) apiKeyGet(mapParams map[string]string, strRequestPath string) (string, error) {
+	accessKey, secretKey := h.keys()
+	return apiKeyGet(mapParams, strRequestPath, accessKey, secretKey)
+}
+
+// apiKeyPost 使用当前密钥签名的POST请求
+func (h *Huobi) apiKeyPost(mapParams map[string]string, strRequestPath string) (string, error) {
+	accessKey, secretKey := h.keys()
+	return apiKeyPost(mapParams, strRequestPath, accessKey, secretKey)
+}
@@ -4,6 +4,7 @@ import (
 	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/base64"
+	"net/url"
 	"sort"
 
 	"github.com/gpmn/sheep/util"
@@ -26,6 +27,24 @@ func createSign(mapParams map[string]string, strMethod, strHostUrl, strRequestPa
 	return computeHmac256(strPayload, strSecretKey)
 }
 
+// VerifySign 校验请求签名, 用签名时的参数重新计算并比较
+// strMethod: 请求的方法 GET, POST......
+// strHostUrl: 请求的主机
+// strRequestPath: 请求的路由路径
+// query: 收到的url参数, 包含 Signature
+// strSecretKey: 进行签名的密钥
+// return: 签名是否正确
+func VerifySign(strMethod, strHostUrl, strRequestPath string, query url.Values, strSecretKey string) bool {
+	mapParams := make(map[string]string)
+	for key := range query {
+		if key != "Signature" {
+			mapParams[key] = query.Get(key)
+		}
+	}
+	strSign := createSign(mapParams, strMethod, strHostUrl, strRequestPath, strSecretKey)
+	return hmac.Equal([]byte(strSign), []byte(query.Get("Signature")))
+}
+
 // HMAC SHA256加密
 // strMessage: 需要加密的信息
 // strSecret: 密钥
@@ -0,0 +1,73 @@
+package huobi
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/gpmn/sheep/util"
+)
+
+func TestCreateSign(t *testing.T) {
+	cases := []struct {
+		method, host, path, secret string
+		params                     map[string]string
+		sign                       string
+	}{
+		{
+			method: "GET", host: "api.huobi.pro", path: "/v1/order/orders", secret: "b0xxxxxx-c6xxxxxx-94xxxxxx-dxxxx",
+			params: map[string]string{
+				"AccessKeyId":      "e2xxxxxx-99xxxxxx-84xxxxxx-7xxxx",
+				"SignatureMethod":  "HmacSHA256",
+				"SignatureVersion": "2",
+				"Timestamp":        "2017-05-11T15:19:30",
+				"order-id":         "1234567890",
+			},
+			sign: "Nmd8AU8uAe0mkFpxNbiava0aeZzBEtYjCdie1ZYZjoM=",
+		},
+		{
+			method: "POST", host: "api.huobi.pro", path: "/v1/order/orders/place", secret: "sk",
+			params: map[string]string{
+				"AccessKeyId":      "ak",
+				"SignatureMethod":  "HmacSHA256",
+				"SignatureVersion": "2",
+				"Timestamp":        "2018-01-02T03:04:05",
+			},
+			sign: "Z8RrW2/5jVzqgno3uYA9NTpF31mppQSIuZ2R5C484A4=",
+		},
+		{
+			// 参数值需要URI编码, 主机带端口
+			method: "GET", host: "127.0.0.1:8080", path: "/v1/order/orders", secret: "sk",
+			params: map[string]string{
+				"AccessKeyId":      "ak",
+				"SignatureMethod":  "HmacSHA256",
+				"SignatureVersion": "2",
+				"Timestamp":        "2018-01-02T03:04:05",
+				"symbol":           "btcusdt",
+				"states":           "filled,canceled",
+				"start-date":       "2018-01-01",
+			},
+			sign: "nL5PBpD+DI3Z61lRDO7RRcdY19MNQX9hPGNFlsG4w2E=",
+		},
+	}
+
+	for _, c := range cases {
+		sign := createSign(c.params, c.method, c.host, c.path, c.secret)
+		if sign != c.sign {
+			t.Errorf("%s %s : sign %s, want %s", c.method, c.path, sign, c.sign)
+		}
+
+		// 按 apiKeyGet 的方式编码后作为url参数发出, 服务器端解码后校验
+		c.params["Signature"] = sign
+		query, err := url.ParseQuery(util.Map2UrlQuery(util.MapValueEncodeURI(c.params)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !VerifySign(c.method, c.host, c.path, query, c.secret) {
+			t.Errorf("%s %s : VerifySign failed", c.method, c.path)
+		}
+		query.Set("Timestamp", "2018-01-02T03:04:06")
+		if VerifySign(c.method, c.host, c.path, query, c.secret) {
+			t.Errorf("%s %s : VerifySign accepted modified params", c.method, c.path)
+		}
+	}
+}
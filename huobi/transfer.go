@@ -0,0 +1,149 @@
+package huobi
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gpmn/sheep/proto"
+)
+
+// TransTrade 成交推送转换为 proto.Trade
+func TransTrade(symbol string, t TickData) proto.Trade {
+	side := proto.TradeSideSell
+	if t.Direction == "buy" {
+		side = proto.TradeSideBuy
+	}
+	return proto.Trade{
+		Symbol: symbol,
+		Price:  t.Price,
+		Amount: t.Amount,
+		Side:   side,
+		TS:     t.TS,
+	}
+}
+
+// TransKLine K线转换为 proto.Candle, interval 为K线周期
+func TransKLine(symbol string, interval time.Duration, k KLine) proto.Candle {
+	start := int64(k.ID) * 1000
+	end := start + int64(interval/time.Millisecond)
+	return proto.Candle{
+		Symbol:      symbol,
+		Start:       start,
+		End:         end,
+		Open:        k.Open,
+		High:        k.High,
+		Low:         k.Low,
+		Close:       k.Close,
+		Volume:      k.Amount,
+		QuoteVolume: k.Vol,
+		Count:       int64(k.Count),
+		Closed:      end <= time.Now().UnixNano()/int64(time.Millisecond),
+	}
+}
+
+// TransDepth 深度推送转换为 proto.Depth
+func TransDepth(symbol string, d *MarketDepth) proto.Depth {
+	res := proto.Depth{Symbol: symbol, TS: d.Tick.TS}
+	for _, l := range d.Tick.Bids {
+		if len(l) >= 2 {
+			res.Bids = append(res.Bids, proto.DepthLevel{Price: l[0], Amount: l[1]})
+		}
+	}
+	for _, l := range d.Tick.Asks {
+		if len(l) >= 2 {
+			res.Asks = append(res.Asks, proto.DepthLevel{Price: l[0], Amount: l[1]})
+		}
+	}
+	return res
+}
+
+// TransOrderState 火币订单状态转换为 proto 状态
+// 已创建未提交的订单视为已提交, 部分成交后撤销视为已撤销, 其余原样返回
+func TransOrderState(state string) string {
+	switch state {
+	case "created", "pre-submitted", "submitting":
+		return proto.OrderStateSubmitted
+	case "partial-canceled":
+		return proto.OrderStateCanceled
+	}
+	return state
+}
+
+// TransOrderUpdate 订单推送转换为 proto.Order
+func TransOrderUpdate(od *OrderUpdate) proto.Order {
+	d := od.Order
+	amount, _ := strconv.ParseFloat(d.OrderAmount, 64)
+	unfilled, _ := strconv.ParseFloat(d.UnfilledAmount, 64)
+	price, _ := strconv.ParseFloat(d.OrderPrice, 64)
+	return proto.Order{
+		ID:          strconv.Itoa(d.OrderID),
+		Symbol:      d.Symbol,
+		State:       TransOrderState(d.OrderState),
+		Amount:      amount,
+		FieldAmount: amount - unfilled,
+		Price:       price,
+		Type:        d.OrderType,
+		CreatedSec:  int64(d.CreatedAt) / 1000,
+	}
+}
+
+// TransMarginBalances 逐仓杠杆账户余额转换为 proto.AccountBalance, 只保留 trade 和 frozen,
+// 同一币种在多个交易对账户中的余额相加, loan 和 interest 等负债不计入
+func TransMarginBalances(mbs []MarginBalance) []proto.AccountBalance {
+	var res []proto.AccountBalance
+	index := make(map[string]int)
+	for _, mb := range mbs {
+		for _, item := range mb.List {
+			if item.Type != proto.AccountBalanceTypeTrade && item.Type != proto.AccountBalanceTypeFrozen {
+				continue
+			}
+			key := item.Currency + "/" + item.Type
+			if i, ok := index[key]; ok {
+				res[i].Balance += item.Balance
+				continue
+			}
+			index[key] = len(res)
+			res = append(res, proto.AccountBalance{Currency: item.Currency, Balance: item.Balance, Type: item.Type})
+		}
+	}
+	return res
+}
+
+// TransTickers GetTickers 的结果转换为交易对到最新价的映射
+func TransTickers(tickers map[string]*TickData) map[string]float64 {
+	res := make(map[string]float64, len(tickers))
+	for symbol, t := range tickers {
+		res[symbol] = t.Price
+	}
+	return res
+}
+
+// TransOrderFill 订单推送中的本次成交转换为 proto.Fill, 推送不含成交时返回 false.
+// price, filled-amount, filled-fees 为本次撮合的数值; 买入手续费为基础币 base, 卖出为计价币 quote,
+// 推送中只有交易对, 由调用方拆分
+func TransOrderFill(od *OrderUpdate, base, quote string) (proto.Fill, bool) {
+	d := od.Order
+	amount, _ := strconv.ParseFloat(d.FilledAmount, 64)
+	if amount <= 0 {
+		return proto.Fill{}, false
+	}
+	price, _ := strconv.ParseFloat(d.Price, 64)
+	fee, _ := strconv.ParseFloat(d.FilledFees, 64)
+	fill := proto.Fill{
+		OrderID: strconv.Itoa(d.OrderID),
+		Symbol:  d.Symbol,
+		Side:    proto.TradeSideSell,
+		Price:   price,
+		Amount:  amount,
+		Fee:     fee,
+		Maker:   d.Role == "maker",
+		TS:      od.TS,
+	}
+	fill.FeeCurrency = quote
+	if strings.HasPrefix(d.OrderType, "buy") {
+		fill.Side = proto.TradeSideBuy
+		fill.FeeCurrency = base
+	}
+	return fill, true
+}
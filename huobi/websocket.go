@@ -6,15 +6,18 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
-	"log"
 	"math/rand"
 	"time"
 
+	"github.com/gpmn/sheep/consts"
+	"github.com/gpmn/sheep/logger"
+	"github.com/gpmn/sheep/metrics"
 	"github.com/gpmn/sheep/util"
 
 	"math"
 
 	"sync"
+	"sync/atomic"
 
 	"github.com/bitly/go-simplejson"
 )
@@ -61,8 +64,11 @@ func getUinxMillisecond() int64 {
 	return time.Now().UnixNano() / int64(time.Millisecond)
 }
 
-// unGzipData 解压gzip的数据
+// unGzipData 解压gzip的数据, 未压缩的文本消息原样返回
 func unGzipData(buf []byte) ([]byte, error) {
+	if len(buf) > 0 && buf[0] == '{' {
+		return buf, nil
+	}
 	r, err := gzip.NewReader(bytes.NewBuffer(buf))
 	if err != nil {
 		return nil, err
@@ -76,6 +82,9 @@ type wsOperation struct {
 }
 
 type Market struct {
+	// 上次接收到的ping时间戳, 原子访问, 放在开头保证64位对齐
+	lastPing int64
+
 	ws *util.SafeWebSocket
 
 	listeners         map[string]Listener
@@ -83,17 +92,24 @@ type Market struct {
 	subscribeResultCb map[string]jsonChan
 	requestResultCb   map[string]jsonChan
 
-	// 掉线后是否自动重连，如果用户主动执行Close()则不自动重连
+	// 掉线后是否自动重连，如果用户主动执行Close()则不自动重连, 由 mutex 保护
 	autoReconnect bool
 
-	// 上次接收到的ping时间戳
-	lastPing int64
-
 	// 主动发送心跳的时间间隔，默认5秒
 	HeartbeatInterval time.Duration
 	// 接收消息超时时间，默认10秒
 	ReceiveTimeout time.Duration
 
+	// 消息记录器, 为nil时不记录
+	Recorder util.Recorder
+
+	// 日志, 为nil时不输出
+	Logger logger.Logger
+
+	// 离线模式, 不连接服务器, 消息由 Replay 输入
+	offline bool
+
+	// 保护 ws, autoReconnect 和各个 map
 	mutex *sync.RWMutex
 }
 
@@ -102,7 +118,14 @@ type Listener func(topic string, json *simplejson.Json)
 
 // NewMarket 创建Market实例
 func NewMarket() (m *Market, err error) {
+	return dialMarket(nil, nil)
+}
+
+// dialMarket 创建Market实例, 记录器和日志在连接之前设置, 避免和消息处理并发读写
+func dialMarket(recorder util.Recorder, l logger.Logger) (m *Market, err error) {
 	m = &Market{
+		Recorder:          recorder,
+		Logger:            l,
 		HeartbeatInterval: 5 * time.Second,
 		ReceiveTimeout:    10 * time.Second,
 		ws:                nil,
@@ -121,168 +144,236 @@ func NewMarket() (m *Market, err error) {
 	return m, nil
 }
 
+// NewOfflineMarket 创建不连接服务器的Market实例, 用于回放记录的消息
+func NewOfflineMarket() *Market {
+	return &Market{
+		HeartbeatInterval: 5 * time.Second,
+		ReceiveTimeout:    10 * time.Second,
+		listeners:         make(map[string]Listener),
+		subscribeResultCb: make(map[string]jsonChan),
+		requestResultCb:   make(map[string]jsonChan),
+		subscribedTopic:   make(map[string]bool),
+		offline:           true,
+		mutex:             &sync.RWMutex{},
+	}
+}
+
+func (m *Market) log() logger.Logger {
+	return logger.OrNop(m.Logger)
+}
+
 // connect 连接
 func (m *Market) connect() error {
-	log.Println("connecting")
+	m.log().Debug("Market.connect - connecting", logger.F("endpoint", Endpoint))
 	ws, err := util.NewSafeWebSocket(Endpoint)
 	if err != nil {
 		return err
 	}
+	atomic.StoreInt64(&m.lastPing, getUinxMillisecond())
+	m.mutex.Lock()
 	m.ws = ws
-	m.lastPing = getUinxMillisecond()
-	log.Println("connected")
+	m.mutex.Unlock()
+	m.log().Debug("Market.connect - connected")
 
-	m.handleMessageLoop()
-	m.keepAlive()
+	m.handleMessageLoop(ws)
+	m.keepAlive(ws)
 
 	return nil
 }
 
 // reconnect 重新连接
 func (m *Market) reconnect() error {
-	log.Println("reconnecting after 1s")
+	m.log().Info("Market.reconnect - reconnecting after 1s")
+	metrics.IncReconnect(consts.ExchangeTypeHuobi)
 	time.Sleep(time.Second)
 
 	if err := m.connect(); err != nil {
-		log.Println("m.reconnect - m.connect failed : %v", err)
+		m.log().Error("Market.reconnect - m.connect failed", logger.Err(err))
 		return err
 	}
 
 	// 重新订阅
 	var listeners = make(map[string]Listener)
+	m.mutex.RLock()
 	for k, v := range m.listeners {
 		listeners[k] = v
 	}
-	log.Println("m.reconnect - begin subscribed Topic")
+	m.mutex.RUnlock()
+	m.log().Debug("Market.reconnect - begin subscribe topics")
 	for topic, listener := range listeners {
-		log.Printf("m.reconnect - subscribed Topic for %s", topic)
+		m.log().Debug("Market.reconnect - subscribe topic", logger.F("topic", topic))
 		err := m.Subscribe(topic, listener)
 		if nil != err {
-			log.Printf("m.reconnect - m.Subscribe for %s failed : %v",
-				topic, err)
-			m.ws.Destroy()
+			m.log().Error("Market.reconnect - m.Subscribe failed", logger.F("topic", topic), logger.Err(err))
+			m.conn().Destroy()
 			return err
 		}
 	}
-	log.Println("m.reconnect - subscribedTopic done")
+	m.log().Info("Market.reconnect - subscribe topics done")
 	return nil
 }
 
+// conn 当前连接, 重连时会更换
+func (m *Market) conn() *util.SafeWebSocket {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	return m.ws
+}
+
+// reconnectEnabled 掉线后是否自动重连
+func (m *Market) reconnectEnabled() bool {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	return m.autoReconnect
+}
+
+func (m *Market) setAutoReconnect(v bool) {
+	m.mutex.Lock()
+	m.autoReconnect = v
+	m.mutex.Unlock()
+}
+
 // sendMessage 发送消息
 func (m *Market) sendMessage(data interface{}) error {
+	ws := m.conn()
+	if ws == nil {
+		return ConnectionClosedError
+	}
 	b, err := json.Marshal(data)
 	if err != nil {
 		return nil
 	}
 	//log.Println("sendMessage", string(b))
-	m.ws.Send(b)
+	ws.Send(b)
 	return nil
 }
 
 // handleMessageLoop 处理消息循环
-func (m *Market) handleMessageLoop() {
-	m.ws.Listen(func(buf []byte) {
-		msg, err := unGzipData(buf)
-		//log.Println("readMessage", string(msg))
-		if err != nil {
-			log.Println(err)
-			return
-		}
-		json, err := simplejson.NewJson(msg)
-		if err != nil {
-			log.Println(err)
-			return
-		}
+func (m *Market) handleMessageLoop(ws *util.SafeWebSocket) {
+	ws.Listen(m.handleMessage)
+}
 
-		// 处理ping消息
-		if ping := json.Get("ping").MustInt64(); ping > 0 {
-			m.handlePing(pingData{Ping: ping})
-			return
-		}
+// resultChan 等待订阅或请求结果的通道
+func (m *Market) resultChan(cbs map[string]jsonChan, key string) (jsonChan, bool) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	c, ok := cbs[key]
+	return c, ok
+}
+
+// Replay 输入一条记录的消息, 与收到的消息经过相同的处理
+func (m *Market) Replay(buf []byte) {
+	m.handleMessage(buf)
+}
 
-		// 处理pong消息
-		if pong := json.Get("pong").MustInt64(); pong > 0 {
-			m.lastPing = pong
-			return
+// handleMessage 处理一条消息
+func (m *Market) handleMessage(buf []byte) {
+	msg, err := unGzipData(buf)
+	//log.Println("readMessage", string(msg))
+	if err != nil {
+		m.log().Error("Market.handleMessage - unGzipData failed", logger.Err(err))
+		return
+	}
+	if m.Recorder != nil {
+		m.Recorder.Record(consts.ExchangeTypeHuobi, buf, msg)
+	}
+	json, err := simplejson.NewJson(msg)
+	if err != nil {
+		m.log().Error("Market.handleMessage - simplejson.NewJson failed", logger.Err(err), logger.F("response", string(msg)))
+		return
+	}
+
+	// 处理ping消息
+	if ping := json.Get("ping").MustInt64(); ping > 0 {
+		m.handlePing(pingData{Ping: ping})
+		return
+	}
+
+	// 处理pong消息
+	if pong := json.Get("pong").MustInt64(); pong > 0 {
+		atomic.StoreInt64(&m.lastPing, pong)
+		metrics.ObservePing(consts.ExchangeTypeHuobi, time.Duration(getUinxMillisecond()-pong)*time.Millisecond)
+		return
+	}
+
+	// 处理订阅消息
+	if ch := json.Get("ch").MustString(); ch != "" {
+		metrics.IncMessage(consts.ExchangeTypeHuobi, ch)
+		m.mutex.RLock()
+		listener, ok := m.listeners[ch]
+		m.mutex.RUnlock()
+		if ok {
+			//log.Println("handleSubscribe", json)
+			listener(ch, json)
 		}
+		return
+	}
 
-		// 处理订阅消息
-		if ch := json.Get("ch").MustString(); ch != "" {
-			m.mutex.RLock()
-			listener, ok := m.listeners[ch]
-			if ok {
-				//log.Println("handleSubscribe", json)
-				listener(ch, json)
-			}
-			m.mutex.RUnlock()
-			return
+	// 处理订阅成功通知
+	if subbed := json.Get("subbed").MustString(); subbed != "" {
+		c, ok := m.resultChan(m.subscribeResultCb, subbed)
+		if ok {
+			c <- json
 		}
+		return
+	}
 
-		// 处理订阅成功通知
-		if subbed := json.Get("subbed").MustString(); subbed != "" {
-			c, ok := m.subscribeResultCb[subbed]
+	// 处理订阅成功通知
+	if op := json.Get("op").MustString(); op != "" {
+		if op == "notify" {
+			topic := json.Get("topic").MustString()
+			c, ok := m.resultChan(m.subscribeResultCb, topic)
 			if ok {
 				c <- json
 			}
-			return
-		}
-
-		// 处理订阅成功通知
-		if op := json.Get("op").MustString(); op != "" {
-			if op == "notify" {
+		} else if op == "sub" {
+			errcode := json.Get("err-code").MustInt64()
+			if errcode != 0 {
 				topic := json.Get("topic").MustString()
-				c, ok := m.subscribeResultCb[topic]
-				if ok {
-					c <- json
-				}
-			} else if op == "sub" {
-				errcode := json.Get("err-code").MustInt64()
-				if errcode != 0 {
-					topic := json.Get("topic").MustString()
-					log.Printf("WARNING :: subscribe %s failed", topic)
-				}
-			} else {
-				log.Printf("WARNING :: unknown op '%s' : %+v", op, msg)
+				m.log().Warn("Market.handleMessage - subscribe failed", logger.F("topic", topic))
 			}
-			return
+		} else {
+			m.log().Warn("Market.handleMessage - unknown op", logger.F("op", op), logger.F("response", string(msg)))
 		}
+		return
+	}
 
-		// 请求行情结果
-		if rep, id := json.Get("rep").MustString(), json.Get("id").MustString(); rep != "" && id != "" {
-			c, ok := m.requestResultCb[id]
-			if ok {
-				c <- json
-			}
-			return
+	// 请求行情结果
+	if rep, id := json.Get("rep").MustString(), json.Get("id").MustString(); rep != "" && id != "" {
+		c, ok := m.resultChan(m.requestResultCb, id)
+		if ok {
+			c <- json
 		}
+		return
+	}
 
-		// 处理错误消息
-		if status := json.Get("status").MustString(); status == "error" {
-			// 判断是否为订阅失败
-			id := json.Get("id").MustString()
-			c, ok := m.subscribeResultCb[id]
-			if ok {
-				c <- json
-			}
-			return
+	// 处理错误消息
+	if status := json.Get("status").MustString(); status == "error" {
+		// 判断是否为订阅失败
+		id := json.Get("id").MustString()
+		c, ok := m.resultChan(m.subscribeResultCb, id)
+		if ok {
+			c <- json
 		}
-	})
+		return
+	}
 }
 
 // keepAlive 保持活跃
-func (m *Market) keepAlive() {
-	m.ws.KeepAlive(m.HeartbeatInterval, func() {
+func (m *Market) keepAlive(ws *util.SafeWebSocket) {
+	ws.KeepAlive(m.HeartbeatInterval, func() {
 		var t = getUinxMillisecond()
 		m.sendMessage(pingData{Ping: t})
 
 		// 检查上次ping时间，如果超过20秒无响应，重新连接
-		tr := time.Duration(math.Abs(float64(t - m.lastPing)))
+		lastPing := atomic.LoadInt64(&m.lastPing)
+		tr := time.Duration(math.Abs(float64(t-lastPing))) * time.Millisecond
 		if tr >= m.HeartbeatInterval*2 {
-			log.Println("no ping max delay", tr, m.HeartbeatInterval*2, t, m.lastPing)
-			if m.autoReconnect {
+			m.log().Warn("Market.keepAlive - no ping max delay", logger.F("delay", tr), logger.F("max", m.HeartbeatInterval*2), logger.F("lastPing", lastPing))
+			if m.reconnectEnabled() {
 				err := m.reconnect()
 				if err != nil {
-					log.Println(err)
+					m.log().Error("Market.keepAlive - reconnect failed", logger.Err(err))
 				}
 			}
 		}
@@ -292,7 +383,7 @@ func (m *Market) keepAlive() {
 // handlePing 处理Ping
 func (m *Market) handlePing(ping pingData) (err error) {
 	//log.Println("handlePing", ping)
-	m.lastPing = ping.Ping
+	atomic.StoreInt64(&m.lastPing, ping.Ping)
 	var pong = pongData{Pong: ping.Ping}
 	err = m.sendMessage(pong)
 	if err != nil {
@@ -310,10 +401,22 @@ func (m *Market) Subscribe(topic string, listener Listener) error {
 func (m *Market) SubscribeEx(topic string, data interface{}, listener Listener) error {
 	//var isNew = false
 
+	// 离线模式只设置监听器
+	if m.offline {
+		m.mutex.Lock()
+		m.listeners[topic] = listener
+		m.subscribedTopic[topic] = true
+		m.mutex.Unlock()
+		return nil
+	}
+
 	// 如果未曾发送过订阅指令，则发送，并等待订阅操作结果，否则直接返回
+	m.mutex.Lock()
 	if _, ok := m.subscribedTopic[topic]; !ok {
 		m.subscribeResultCb[topic] = make(jsonChan)
 	}
+	result := m.subscribeResultCb[topic]
+	m.mutex.Unlock()
 	m.sendMessage(data)
 	//isNew = true
 	// } else {
@@ -326,12 +429,13 @@ func (m *Market) SubscribeEx(topic string, data interface{}, listener Listener)
 	m.mutex.Unlock()
 
 	//if isNew {
-	log.Printf("begin wait m.subscribeResultCb[%s]", topic)
-	var json = <-m.subscribeResultCb[topic]
-	log.Printf("end wait m.subscribeResultCb[%s]", topic)
+	m.log().Debug("Market.SubscribeEx - begin wait subscribe result", logger.F("topic", topic))
+	var json = <-result
+	m.log().Debug("Market.SubscribeEx - end wait subscribe result", logger.F("topic", topic))
 	// 判断订阅结果，如果出错则返回出错信息
 	if msg, err := json.Get("err-msg").String(); err == nil {
-		log.Printf("SubscribeEx - err-msg : %s", msg)
+		m.log().Error("Market.SubscribeEx - subscribe failed", logger.F("topic", topic), logger.F("response", msg))
+		metrics.IncSubscribeFailure(consts.ExchangeTypeHuobi, topic)
 		return fmt.Errorf(msg)
 	}
 	//}
@@ -340,22 +444,30 @@ func (m *Market) SubscribeEx(topic string, data interface{}, listener Listener)
 
 // Unsubscribe 取消订阅
 func (m *Market) Unsubscribe(topic string) {
-	log.Println("unSubscribe", topic)
+	m.log().Debug("Market.Unsubscribe", logger.F("topic", topic))
 	// 火币网没有提供取消订阅的接口，只能删除监听器
+	m.mutex.Lock()
 	delete(m.listeners, topic)
+	m.mutex.Unlock()
 }
 
 // Request 请求行情信息
 func (m *Market) Request(req string) (*simplejson.Json, error) {
 	var id = getRandomString(10)
-	m.requestResultCb[id] = make(jsonChan)
+	result := make(jsonChan)
+	m.mutex.Lock()
+	m.requestResultCb[id] = result
+	m.mutex.Unlock()
+	defer func() {
+		m.mutex.Lock()
+		delete(m.requestResultCb, id)
+		m.mutex.Unlock()
+	}()
 
 	if err := m.sendMessage(reqData{Req: req, ID: id}); err != nil {
 		return nil, err
 	}
-	var json = <-m.requestResultCb[id]
-
-	delete(m.requestResultCb, id)
+	var json = <-result
 
 	// 判断是否出错
 	if msg := json.Get("err-msg").MustString(); msg != "" {
@@ -366,28 +478,28 @@ func (m *Market) Request(req string) (*simplejson.Json, error) {
 
 // Loop 进入循环
 func (m *Market) Loop() {
-	log.Println("startLoop")
+	m.log().Debug("Market.Loop - start")
 	for {
-		err := m.ws.Loop()
+		err := m.conn().Loop()
 		if err != nil {
-			log.Println(err)
+			m.log().Warn("Market.Loop - connection lost", logger.Err(err))
 			if err == util.SafeWebSocketDestroyError {
 				break
-			} else if m.autoReconnect {
+			} else if m.reconnectEnabled() {
 				m.reconnect()
 			} else {
 				break
 			}
 		}
 	}
-	log.Println("endLoop")
+	m.log().Debug("Market.Loop - end")
 }
 
 // ReConnect 重新连接
 func (m *Market) ReConnect() (err error) {
-	log.Println("reconnect")
-	m.autoReconnect = true
-	if err = m.ws.Destroy(); err != nil {
+	m.log().Info("Market.ReConnect")
+	m.setAutoReconnect(true)
+	if err = m.conn().Destroy(); err != nil {
 		return err
 	}
 	return m.reconnect()
@@ -395,9 +507,13 @@ func (m *Market) ReConnect() (err error) {
 
 // Close 关闭连接
 func (m *Market) Close() error {
-	log.Println("close")
-	m.autoReconnect = false
-	if err := m.ws.Destroy(); err != nil {
+	m.log().Debug("Market.Close")
+	m.setAutoReconnect(false)
+	ws := m.conn()
+	if ws == nil {
+		return nil
+	}
+	if err := ws.Destroy(); err != nil {
 		return err
 	}
 	return nil
@@ -0,0 +1,191 @@
+package sheep
+
+import (
+	"sync"
+	"time"
+
+	"github.com/gpmn/sheep/logger"
+	"github.com/gpmn/sheep/proto"
+	"github.com/pkg/errors"
+)
+
+// 可拦截的 ExchageI 方法名
+const (
+	MethodGetAccountBalance = "GetAccountBalance"
+	MethodOrderPlace        = "OrderPlace"
+	MethodOrderCancel       = "OrderCancel"
+	MethodGetOrderInfo      = "GetOrderInfo"
+	MethodGetOrders         = "GetOrders"
+)
+
+// Handler 执行一次 ExchageI 调用, params 和返回值为对应方法的参数和返回值,
+// GetAccountBalance 的 params 为 nil, OrderCancel 的返回值为 nil
+type Handler func(method string, params interface{}) (interface{}, error)
+
+// Interceptor 拦截器, 可以修改参数和返回值, 或者不调用 next 直接返回
+type Interceptor func(method string, params interface{}, next Handler) (interface{}, error)
+
+// Intercept 用拦截器包装交易所, 第一个拦截器在最外层. GetExchangeType 不经过拦截器;
+// 交易所实现 MarketDataI 时返回值也实现 MarketDataI, 行情方法直接转发, 不经过拦截器
+func Intercept(ex ExchageI, interceptors ...Interceptor) ExchageI {
+	if len(interceptors) == 0 {
+		return ex
+	}
+	i := &intercepted{ex: ex}
+	handler := i.invoke
+	for idx := len(interceptors) - 1; idx >= 0; idx-- {
+		handler = chain(interceptors[idx], handler)
+	}
+	i.handler = handler
+	if md, ok := ex.(MarketDataI); ok {
+		return &interceptedMarket{intercepted: i, md: md}
+	}
+	return i
+}
+
+func chain(interceptor Interceptor, next Handler) Handler {
+	return func(method string, params interface{}) (interface{}, error) {
+		return interceptor(method, params, next)
+	}
+}
+
+// Unwrap 返回被拦截器包装的交易所, 用于访问 ExchageI 之外的方法
+func Unwrap(ex ExchageI) ExchageI {
+	for {
+		switch i := ex.(type) {
+		case *intercepted:
+			ex = i.ex
+		case *interceptedMarket:
+			ex = i.ex
+		default:
+			return ex
+		}
+	}
+}
+
+type intercepted struct {
+	ex      ExchageI
+	handler Handler
+}
+
+// invoke 最内层, 调用交易所
+func (i *intercepted) invoke(method string, params interface{}) (interface{}, error) {
+	switch method {
+	case MethodGetAccountBalance:
+		return i.ex.GetAccountBalance()
+	case MethodOrderPlace:
+		return i.ex.OrderPlace(params.(*proto.OrderPlaceParams))
+	case MethodOrderCancel:
+		return nil, i.ex.OrderCancel(params.(*proto.OrderCancelParams))
+	case MethodGetOrderInfo:
+		return i.ex.GetOrderInfo(params.(*proto.OrderInfoParams))
+	case MethodGetOrders:
+		return i.ex.GetOrders(params.(*proto.OrdersParams))
+	}
+	return nil, errors.Errorf("unknown method %s", method)
+}
+
+func (i *intercepted) GetExchangeType() string {
+	return i.ex.GetExchangeType()
+}
+
+func (i *intercepted) GetAccountBalance() ([]proto.AccountBalance, error) {
+	ret, err := i.handler(MethodGetAccountBalance, nil)
+	res, _ := ret.([]proto.AccountBalance)
+	return res, err
+}
+
+func (i *intercepted) OrderPlace(params *proto.OrderPlaceParams) (*proto.OrderPlaceReturn, error) {
+	ret, err := i.handler(MethodOrderPlace, params)
+	res, _ := ret.(*proto.OrderPlaceReturn)
+	return res, err
+}
+
+func (i *intercepted) OrderCancel(params *proto.OrderCancelParams) error {
+	_, err := i.handler(MethodOrderCancel, params)
+	return err
+}
+
+func (i *intercepted) GetOrderInfo(params *proto.OrderInfoParams) (*proto.Order, error) {
+	ret, err := i.handler(MethodGetOrderInfo, params)
+	res, _ := ret.(*proto.Order)
+	return res, err
+}
+
+func (i *intercepted) GetOrders(params *proto.OrdersParams) ([]proto.Order, error) {
+	ret, err := i.handler(MethodGetOrders, params)
+	res, _ := ret.([]proto.Order)
+	return res, err
+}
+
+// interceptedMarket : 实现了 MarketDataI 的交易所的包装
+type interceptedMarket struct {
+	*intercepted
+	md MarketDataI
+}
+
+func (i *interceptedMarket) SetDepthListener(listener func(symbol string, depth *proto.Depth)) {
+	i.md.SetDepthListener(listener)
+}
+
+func (i *interceptedMarket) SetTradeListener(listener func(symbol string, trade *proto.Trade)) {
+	i.md.SetTradeListener(listener)
+}
+
+func (i *interceptedMarket) SubscribeDepth(symbols ...string) error {
+	return i.md.SubscribeDepth(symbols...)
+}
+
+func (i *interceptedMarket) SubscribeTrade(symbols ...string) error {
+	return i.md.SubscribeTrade(symbols...)
+}
+
+// LogInterceptor 用 l 记录每次调用的参数, 耗时和错误, 成功的调用为 Debug 级别
+func LogInterceptor(l logger.Logger) Interceptor {
+	l = logger.OrNop(l)
+	return func(method string, params interface{}, next Handler) (interface{}, error) {
+		start := time.Now()
+		ret, err := next(method, params)
+		if err != nil {
+			l.Error("sheep."+method+" failed", logger.F("params", params), logger.F("elapsed", time.Since(start)), logger.Err(err))
+		} else {
+			l.Debug("sheep."+method+" done", logger.F("params", params), logger.F("elapsed", time.Since(start)))
+		}
+		return ret, err
+	}
+}
+
+// RetryInterceptor 查询失败后最多重试 attempts 次, 每次间隔 backoff 并加倍.
+// 下单和撤单不重试, 以免重复下单
+func RetryInterceptor(attempts int, backoff time.Duration) Interceptor {
+	return func(method string, params interface{}, next Handler) (interface{}, error) {
+		if method == MethodOrderPlace || method == MethodOrderCancel {
+			return next(method, params)
+		}
+		ret, err := next(method, params)
+		for i, wait := 0, backoff; err != nil && i < attempts; i, wait = i+1, wait*2 {
+			time.Sleep(wait)
+			ret, err = next(method, params)
+		}
+		return ret, err
+	}
+}
+
+// RateLimitInterceptor 限制调用频率, 两次调用至少间隔 interval, 超出时等待
+func RateLimitInterceptor(interval time.Duration) Interceptor {
+	var mutex sync.Mutex
+	var next time.Time
+	return func(method string, params interface{}, handler Handler) (interface{}, error) {
+		mutex.Lock()
+		now := time.Now()
+		wait := next.Sub(now)
+		if wait < 0 {
+			wait = 0
+		}
+		next = now.Add(wait + interval)
+		mutex.Unlock()
+
+		time.Sleep(wait)
+		return handler(method, params)
+	}
+}
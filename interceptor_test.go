@@ -0,0 +1,91 @@
+package sheep
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/gpmn/sheep/proto"
+	"github.com/gpmn/sheep/sim"
+)
+
+func TestIntercept(t *testing.T) {
+	ex := sim.NewExchange(sim.Config{}, map[string]float64{"usdt": 1000})
+	var calls []string
+	trace := func(name string) Interceptor {
+		return func(method string, params interface{}, next Handler) (interface{}, error) {
+			calls = append(calls, name+":"+method)
+			return next(method, params)
+		}
+	}
+	errBlocked := errors.New("blocked")
+	block := func(method string, params interface{}, next Handler) (interface{}, error) {
+		if method == MethodOrderCancel {
+			return nil, errBlocked
+		}
+		return next(method, params)
+	}
+
+	wrapped := Intercept(ex, trace("a"), trace("b"), block)
+	if Unwrap(wrapped) != ExchageI(ex) || wrapped.GetExchangeType() != sim.ExchangeTypeSim {
+		t.Fatal("unwrap")
+	}
+
+	// 行情方法直接转发
+	md, ok := wrapped.(MarketDataI)
+	if !ok {
+		t.Fatal("MarketDataI not forwarded")
+	}
+	var depths int
+	md.SetDepthListener(func(symbol string, depth *proto.Depth) { depths++ })
+	if err := md.SubscribeDepth("btcusdt"); err != nil {
+		t.Fatal(err)
+	}
+	ex.OnDepth("btcusdt", &proto.Depth{Bids: []proto.DepthLevel{{Price: 1, Amount: 1}}, Asks: []proto.DepthLevel{{Price: 2, Amount: 1}}})
+	if depths != 1 || len(calls) != 0 {
+		t.Fatalf("depths %d, calls %v", depths, calls)
+	}
+
+	ret, err := wrapped.OrderPlace(&proto.OrderPlaceParams{Price: 1, Amount: 1, BaseCurrencyID: "btc", QuoteCurrencyID: "usdt", Type: proto.OrderPlaceTypeBuyLimit})
+	if err != nil || ret.OrderID == "" {
+		t.Fatalf("ret %+v, err %v", ret, err)
+	}
+	if err := wrapped.OrderCancel(&proto.OrderCancelParams{OrderID: ret.OrderID}); err != errBlocked {
+		t.Fatalf("got %v", err)
+	}
+	if order, err := wrapped.GetOrderInfo(&proto.OrderInfoParams{OrderID: ret.OrderID}); err != nil || order.State != proto.OrderStateSubmitted {
+		t.Fatalf("order %+v, err %v", order, err)
+	}
+	if balances, err := wrapped.GetAccountBalance(); err != nil || len(balances) == 0 {
+		t.Fatalf("balances %+v, err %v", balances, err)
+	}
+
+	want := []string{"a:OrderPlace", "b:OrderPlace", "a:OrderCancel", "b:OrderCancel", "a:GetOrderInfo", "b:GetOrderInfo", "a:GetAccountBalance", "b:GetAccountBalance"}
+	if len(calls) != len(want) {
+		t.Fatalf("calls %v", calls)
+	}
+	for i := range want {
+		if calls[i] != want[i] {
+			t.Fatalf("calls %v", calls)
+		}
+	}
+}
+
+func TestRetryInterceptor(t *testing.T) {
+	failures := 2
+	flaky := func(method string, params interface{}, next Handler) (interface{}, error) {
+		if failures > 0 {
+			failures--
+			return nil, errors.New("timeout")
+		}
+		return next(method, params)
+	}
+	ex := Intercept(sim.NewExchange(sim.Config{}, nil), RetryInterceptor(2, 0), flaky)
+	if _, err := ex.GetOrders(&proto.OrdersParams{}); err != nil {
+		t.Fatal(err)
+	}
+
+	failures = 1
+	if _, err := ex.OrderPlace(&proto.OrderPlaceParams{}); err == nil {
+		t.Fatal("order place retried")
+	}
+}
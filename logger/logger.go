@@ -0,0 +1,213 @@
+package logger
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Level 日志级别
+type Level int
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+func (l Level) String() string {
+	switch l {
+	case LevelDebug:
+		return "DEBUG"
+	case LevelInfo:
+		return "INFO"
+	case LevelWarn:
+		return "WARN"
+	case LevelError:
+		return "ERROR"
+	}
+	return "LEVEL(" + strconv.Itoa(int(l)) + ")"
+}
+
+// Field 结构化字段
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+// F 创建字段
+func F(key string, value interface{}) Field {
+	return Field{Key: key, Value: value}
+}
+
+// Err 错误字段, 键为 err
+func Err(err error) Field {
+	return Field{Key: "err", Value: err}
+}
+
+// Logger 日志接口, 交易所客户端, websocket Market 以及 OMS 等组件通过 SetLogger 或 Logger 字段注入, 默认不输出
+type Logger interface {
+	Debug(msg string, fields ...Field)
+	Info(msg string, fields ...Field)
+	Warn(msg string, fields ...Field)
+	Error(msg string, fields ...Field)
+}
+
+// Func 把一个函数适配为 Logger, 用于接入其他日志库. 字段在传给函数之前已经脱敏
+type Func func(level Level, msg string, fields []Field)
+
+func (f Func) Debug(msg string, fields ...Field) { f(LevelDebug, msg, Redact(fields)) }
+func (f Func) Info(msg string, fields ...Field)  { f(LevelInfo, msg, Redact(fields)) }
+func (f Func) Warn(msg string, fields ...Field)  { f(LevelWarn, msg, Redact(fields)) }
+func (f Func) Error(msg string, fields ...Field) { f(LevelError, msg, Redact(fields)) }
+
+type nop struct{}
+
+func (nop) Debug(msg string, fields ...Field) {}
+func (nop) Info(msg string, fields ...Field)  {}
+func (nop) Warn(msg string, fields ...Field)  {}
+func (nop) Error(msg string, fields ...Field) {}
+
+// Nop 丢弃所有日志
+var Nop Logger = nop{}
+
+// OrNop l 为nil时返回 Nop
+func OrNop(l Logger) Logger {
+	if l == nil {
+		return Nop
+	}
+	return l
+}
+
+// With 返回一个每条日志都附带 fields 的 Logger
+func With(l Logger, fields ...Field) Logger {
+	return &with{l: OrNop(l), fields: fields}
+}
+
+type with struct {
+	l      Logger
+	fields []Field
+}
+
+func (w *with) join(fields []Field) []Field {
+	return append(append([]Field(nil), w.fields...), fields...)
+}
+
+func (w *with) Debug(msg string, fields ...Field) { w.l.Debug(msg, w.join(fields)...) }
+func (w *with) Info(msg string, fields ...Field)  { w.l.Info(msg, w.join(fields)...) }
+func (w *with) Warn(msg string, fields ...Field)  { w.l.Warn(msg, w.join(fields)...) }
+func (w *with) Error(msg string, fields ...Field) { w.l.Error(msg, w.join(fields)...) }
+
+// NewText 以 "时间 级别 消息 key=value ..." 的格式写入 w, 低于 level 的日志丢弃
+func NewText(w io.Writer, level Level) Logger {
+	var mutex sync.Mutex
+	return Func(func(l Level, msg string, fields []Field) {
+		if l < level {
+			return
+		}
+		var b strings.Builder
+		b.WriteString(time.Now().Format("2006-01-02T15:04:05.000Z07:00"))
+		b.WriteString(" ")
+		b.WriteString(l.String())
+		b.WriteString(" ")
+		b.WriteString(msg)
+		for _, f := range fields {
+			b.WriteString(" ")
+			b.WriteString(f.Key)
+			b.WriteString("=")
+			b.WriteString(formatValue(f.Value))
+		}
+		b.WriteString("\n")
+
+		mutex.Lock()
+		io.WriteString(w, b.String())
+		mutex.Unlock()
+	})
+}
+
+// NewStd 使用标准库 log 输出, 低于 level 的日志丢弃
+func NewStd(level Level) Logger {
+	return Func(func(l Level, msg string, fields []Field) {
+		if l < level {
+			return
+		}
+		var b strings.Builder
+		b.WriteString(l.String())
+		b.WriteString(" ")
+		b.WriteString(msg)
+		for _, f := range fields {
+			b.WriteString(" ")
+			b.WriteString(f.Key)
+			b.WriteString("=")
+			b.WriteString(formatValue(f.Value))
+		}
+		log.Output(2, b.String())
+	})
+}
+
+func formatValue(v interface{}) string {
+	s := fmt.Sprintf("%+v", v)
+	if s == "" || strings.ContainsAny(s, " =\"\t\n") {
+		return strconv.Quote(s)
+	}
+	return s
+}
+
+// sensitiveKey 需要脱敏的字段名或参数名
+var sensitiveKey = regexp.MustCompile(`(?i)secret|key|sign|password|passphrase|token`)
+
+var sensitivePatterns = []*regexp.Regexp{
+	// url 参数和表单: Signature=xxx&AccessKeyId=xxx
+	regexp.MustCompile(`(?i)((?:[\w-]*secret[\w-]*|[\w-]*key(?:id)?|sign(?:ature)?|password|passphrase|token)=)[^&\s"']+`),
+	// JSON: "apikey":"xxx"
+	regexp.MustCompile(`(?i)("(?:[\w-]*secret[\w-]*|[\w-]*key(?:id)?|sign(?:ature)?|password|passphrase|token)"\s*:\s*)"[^"]*"`),
+	// %+v 格式的结构体和 map: {ApiKey:xxx Sign:xxx}
+	regexp.MustCompile(`(?i)\b((?:\w*secret\w*|\w*key(?:id)?|sign(?:ature)?|password|passphrase|token):)[^\s{}\[\]]+`),
+}
+
+// RedactString 替换字符串中 url 参数, JSON 和 %+v 格式的结构体里的密钥, 签名等敏感值
+func RedactString(s string) string {
+	s = sensitivePatterns[0].ReplaceAllString(s, "$1***")
+	s = sensitivePatterns[1].ReplaceAllString(s, `$1"***"`)
+	return sensitivePatterns[2].ReplaceAllString(s, "$1***")
+}
+
+// Redact 字段脱敏: 键名包含 secret, key, sign 等的字段值替换为 ***,
+// 字符串, []byte 和 error 中的敏感参数用 RedactString 替换;
+// 其他类型(如结构体)按 %+v 格式化后脱敏, 包含敏感值时替换为脱敏后的字符串
+func Redact(fields []Field) []Field {
+	if len(fields) == 0 {
+		return fields
+	}
+	res := make([]Field, len(fields))
+	for i, f := range fields {
+		res[i] = f
+		if sensitiveKey.MatchString(f.Key) {
+			res[i].Value = "***"
+			continue
+		}
+		switch v := f.Value.(type) {
+		case string:
+			res[i].Value = RedactString(v)
+		case []byte:
+			res[i].Value = RedactString(string(v))
+		case error:
+			if v != nil {
+				res[i].Value = RedactString(v.Error())
+			}
+		case nil, bool, int, int32, int64, uint, uint32, uint64, float32, float64, time.Duration, time.Time:
+		default:
+			formatted := fmt.Sprintf("%+v", v)
+			if redacted := RedactString(formatted); redacted != formatted {
+				res[i].Value = redacted
+			}
+		}
+	}
+	return res
+}
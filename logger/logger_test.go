@@ -0,0 +1,63 @@
+package logger
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestRedactString(t *testing.T) {
+	for in, want := range map[string]string{
+		"AccessKeyId=abc&SignatureMethod=HmacSHA256&Signature=xyz%3D": "AccessKeyId=***&SignatureMethod=HmacSHA256&Signature=***",
+		"symbol=btcusdt&timestamp=1&signature=f00":                    "symbol=btcusdt&timestamp=1&signature=***",
+		`{"apikey":"abc","sign":"xyz","pair":"BTC_USDT"}`:             `{"apikey":"***","sign":"***","pair":"BTC_USDT"}`,
+		"status ok": "status ok",
+		"{ApiKey:abc Secret:xyz Sign:s1 Pair:BTC_USDT}": "{ApiKey:*** Secret:*** Sign:*** Pair:BTC_USDT}",
+	} {
+		if got := RedactString(in); got != want {
+			t.Errorf("%s : got %s", in, got)
+		}
+	}
+}
+
+func TestText(t *testing.T) {
+	var buf bytes.Buffer
+	l := With(NewText(&buf, LevelInfo), F("exchange", "huobi"))
+	l.Debug("dropped")
+	l.Info("Market.reconnect", F("topic", "market.btcusdt.depth.step0"))
+	l.Error("Huobi.OrderPlace - apiKeyPost failed", Err(errors.New("get https://api.huobi.pro/v1/order?Signature=abc failed")), F("secretKey", "s"))
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %q", buf.String())
+	}
+	if !strings.HasSuffix(lines[0], " INFO Market.reconnect exchange=huobi topic=market.btcusdt.depth.step0") {
+		t.Errorf("got %s", lines[0])
+	}
+	if !strings.HasSuffix(lines[1], ` ERROR Huobi.OrderPlace - apiKeyPost failed exchange=huobi err="get https://api.huobi.pro/v1/order?Signature=*** failed" secretKey=***`) {
+		t.Errorf("got %s", lines[1])
+	}
+}
+
+func TestRedactStruct(t *testing.T) {
+	type cmd struct {
+		Cmd    string
+		APIKey string
+		Secret string
+		Sign   string
+	}
+	var buf bytes.Buffer
+	l := NewText(&buf, LevelDebug)
+	l.Debug("Bibox.OrderPlace",
+		F("request", cmd{Cmd: "orderpending/trade", APIKey: "ak", Secret: "sk", Sign: "f00"}),
+		F("response", &cmd{Cmd: "orderpending/trade", APIKey: "ak"}),
+		F("params", map[string]string{"apikey": "ak", "pair": "BTC_USDT"}),
+		F("count", 3))
+
+	want := ` DEBUG Bibox.OrderPlace request="{Cmd:orderpending/trade APIKey:*** Secret:*** Sign:***}"` +
+		` response="&{Cmd:orderpending/trade APIKey:*** Secret: Sign:}" params="map[apikey:*** pair:BTC_USDT]" count=3`
+	if got := strings.TrimSpace(buf.String()); !strings.HasSuffix(got, want) {
+		t.Errorf("got %s", got)
+	}
+}
@@ -0,0 +1,170 @@
+package metrics
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gpmn/sheep/consts"
+)
+
+// 请求结果, 用于 ObserveRequest 的 outcome
+const (
+	OutcomeOK          = "ok"
+	OutcomeError       = "error"
+	OutcomeRateLimited = "rate_limited"
+)
+
+// Collector 指标收集接口, 实现必须并发安全
+type Collector interface {
+	// ObserveRequest REST请求的结果和耗时, endpoint 为规范化后的路径
+	ObserveRequest(exchange, endpoint, outcome string, latency time.Duration)
+	// IncRateLimited 被交易所限频拒绝的请求
+	IncRateLimited(exchange, endpoint string)
+	// IncReconnect websocket重连
+	IncReconnect(exchange string)
+	// IncMessage websocket收到的订阅推送
+	IncMessage(exchange, topic string)
+	// ObservePing websocket心跳往返时间
+	ObservePing(exchange string, rtt time.Duration)
+	// IncSubscribeFailure websocket订阅失败
+	IncSubscribeFailure(exchange, topic string)
+}
+
+type nop struct{}
+
+func (nop) ObserveRequest(exchange, endpoint, outcome string, latency time.Duration) {}
+func (nop) IncRateLimited(exchange, endpoint string)                                 {}
+func (nop) IncReconnect(exchange string)                                             {}
+func (nop) IncMessage(exchange, topic string)                                        {}
+func (nop) ObservePing(exchange string, rtt time.Duration)                           {}
+func (nop) IncSubscribeFailure(exchange, topic string)                               {}
+
+var (
+	collector Collector = nop{}
+	mutex     sync.RWMutex
+)
+
+// Set 设置全局的指标收集器, 为nil时不收集
+func Set(c Collector) {
+	if c == nil {
+		c = nop{}
+	}
+	mutex.Lock()
+	collector = c
+	mutex.Unlock()
+}
+
+// Get 当前的指标收集器
+func Get() Collector {
+	mutex.RLock()
+	defer mutex.RUnlock()
+	return collector
+}
+
+// ObserveRequest 见 Collector.ObserveRequest
+func ObserveRequest(exchange, endpoint, outcome string, latency time.Duration) {
+	Get().ObserveRequest(exchange, endpoint, outcome, latency)
+}
+
+// IncRateLimited 见 Collector.IncRateLimited
+func IncRateLimited(exchange, endpoint string) {
+	Get().IncRateLimited(exchange, endpoint)
+}
+
+// IncReconnect 见 Collector.IncReconnect
+func IncReconnect(exchange string) {
+	Get().IncReconnect(exchange)
+}
+
+// IncMessage 见 Collector.IncMessage
+func IncMessage(exchange, topic string) {
+	Get().IncMessage(exchange, topic)
+}
+
+// ObservePing 见 Collector.ObservePing
+func ObservePing(exchange string, rtt time.Duration) {
+	Get().ObservePing(exchange, rtt)
+}
+
+// IncSubscribeFailure 见 Collector.IncSubscribeFailure
+func IncSubscribeFailure(exchange, topic string) {
+	Get().IncSubscribeFailure(exchange, topic)
+}
+
+// ObserveHTTP 记录一次HTTP请求, exchange 为空时按主机名判断, status 为0表示没有收到响应
+func ObserveHTTP(exchange string, u *url.URL, status int, err error, latency time.Duration) {
+	if exchange == "" {
+		exchange = ExchangeOf(u.Host)
+	}
+	endpoint := Endpoint(u.Path)
+	outcome := Outcome(status, err)
+	if outcome == OutcomeRateLimited {
+		IncRateLimited(exchange, endpoint)
+	}
+	ObserveRequest(exchange, endpoint, outcome, latency)
+}
+
+// Outcome 按HTTP状态码和错误判断请求结果, 429 和 418(币安封禁) 为限频
+func Outcome(status int, err error) string {
+	switch {
+	case status == 429 || status == 418:
+		return OutcomeRateLimited
+	case err != nil || status >= 400:
+		return OutcomeError
+	default:
+		return OutcomeOK
+	}
+}
+
+var idSegment = regexp.MustCompile(`/[0-9]+(/|$)`)
+
+// Endpoint 规范化请求路径, 数字的路径段(账户id, 订单id)替换为 :id, 避免标签过多
+func Endpoint(path string) string {
+	for {
+		replaced := idSegment.ReplaceAllString(path, "/:id$1")
+		if replaced == path {
+			return path
+		}
+		path = replaced
+	}
+}
+
+// hosts 域名后缀到交易所的映射
+var hosts = map[string]string{
+	"huobi.pro":   consts.ExchangeTypeHuobi,
+	"okex.com":    consts.ExchangeTypeOKEX,
+	"fcoin.com":   consts.ExchangeTypeFCoin,
+	"binance.com": consts.ExchangeTypeBinance,
+	"bibox.com":   consts.ExchangeTypeBibox,
+	"coinpark.cc": consts.ExchangeTypeCoinPark,
+}
+
+// RegisterHost 登记主机(或域名后缀)对应的交易所, 用于自定义的 Host 或测试服务器
+func RegisterHost(host, exchange string) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	hosts[strings.ToLower(host)] = exchange
+}
+
+// ExchangeOf 按主机名判断交易所, 未登记的主机返回主机名本身
+func ExchangeOf(host string) string {
+	host = strings.ToLower(host)
+	mutex.RLock()
+	defer mutex.RUnlock()
+	if exchange, ok := hosts[host]; ok {
+		return exchange
+	}
+	name := host
+	if i := strings.LastIndex(name, ":"); i >= 0 {
+		name = name[:i]
+	}
+	for suffix, exchange := range hosts {
+		if name == suffix || strings.HasSuffix(name, "."+suffix) {
+			return exchange
+		}
+	}
+	return host
+}
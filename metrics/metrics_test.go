@@ -0,0 +1,78 @@
+package metrics
+
+import (
+	"bytes"
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEndpoint(t *testing.T) {
+	for path, want := range map[string]string{
+		"/v1/order/orders/123/submitcancel": "/v1/order/orders/:id/submitcancel",
+		"/v1/account/accounts/1/balance":    "/v1/account/accounts/:id/balance",
+		"/v1/a/1/2":                         "/v1/a/:id/:id",
+		"/api/v3/account":                   "/api/v3/account",
+	} {
+		if got := Endpoint(path); got != want {
+			t.Errorf("%s : got %s", path, got)
+		}
+	}
+}
+
+func TestExchangeOf(t *testing.T) {
+	RegisterHost("127.0.0.1:8080", "mock")
+	for host, want := range map[string]string{
+		"api.huobi.pro":            "huobi",
+		"www.okex.com":             "okex",
+		"api.binance.com:443":      "binance",
+		"127.0.0.1:8080":           "mock",
+		"example.com":              "example.com",
+		"notbinance.com.evil.test": "notbinance.com.evil.test",
+	} {
+		if got := ExchangeOf(host); got != want {
+			t.Errorf("%s : got %s", host, got)
+		}
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry("sheep", 0.1, 1)
+	Set(r)
+	defer Set(nil)
+
+	u, _ := url.Parse("https://api.huobi.pro/v1/order/orders/42")
+	ObserveHTTP("", u, 200, nil, 50*time.Millisecond)
+	ObserveHTTP("", u, 429, nil, 500*time.Millisecond)
+	ObserveHTTP("", u, 0, errors.New("timeout"), 2*time.Second)
+	IncMessage("okex", `ch"1`)
+	ObservePing("huobi", 20*time.Millisecond)
+
+	if v := r.Value("rate_limited_total", "huobi", "/v1/order/orders/:id"); v != 1 {
+		t.Fatalf("rate limited %v", v)
+	}
+
+	var buf bytes.Buffer
+	if err := r.WriteText(&buf); err != nil {
+		t.Fatal(err)
+	}
+	text := buf.String()
+	for _, line := range []string{
+		"# TYPE sheep_requests_total counter",
+		`sheep_requests_total{exchange="huobi",endpoint="/v1/order/orders/:id",outcome="ok"} 1`,
+		`sheep_requests_total{exchange="huobi",endpoint="/v1/order/orders/:id",outcome="error"} 1`,
+		`sheep_request_duration_seconds_bucket{exchange="huobi",endpoint="/v1/order/orders/:id",outcome="rate_limited",le="0.1"} 0`,
+		`sheep_request_duration_seconds_bucket{exchange="huobi",endpoint="/v1/order/orders/:id",outcome="rate_limited",le="1"} 1`,
+		`sheep_request_duration_seconds_bucket{exchange="huobi",endpoint="/v1/order/orders/:id",outcome="error",le="+Inf"} 1`,
+		`sheep_request_duration_seconds_sum{exchange="huobi",endpoint="/v1/order/orders/:id",outcome="error"} 2`,
+		`sheep_ws_messages_total{exchange="okex",topic="ch\"1"} 1`,
+		`sheep_ws_ping_rtt_seconds_count{exchange="huobi"} 1`,
+		"# TYPE sheep_ws_reconnects_total counter",
+	} {
+		if !strings.Contains(text, line+"\n") {
+			t.Errorf("missing %s", line)
+		}
+	}
+}
@@ -0,0 +1,215 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultBuckets 耗时直方图的默认分桶, 单位秒
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+const (
+	typeCounter   = "counter"
+	typeHistogram = "histogram"
+)
+
+type series struct {
+	values  []string
+	value   float64   // counter 的值, histogram 的样本数
+	sum     float64   // histogram 的样本和
+	buckets []float64 // histogram 各分桶的样本数, 不累计
+}
+
+type family struct {
+	name    string
+	help    string
+	typ     string
+	labels  []string
+	buckets []float64
+	series  map[string]*series
+}
+
+func (f *family) get(values []string) *series {
+	key := strings.Join(values, "\xff")
+	s, ok := f.series[key]
+	if !ok {
+		s = &series{values: values}
+		if f.typ == typeHistogram {
+			s.buckets = make([]float64, len(f.buckets))
+		}
+		f.series[key] = s
+	}
+	return s
+}
+
+// Registry : Collector 的 Prometheus 实现, 以 text 格式(0.0.4)导出,
+// 可以直接作为 http.Handler 挂载到 /metrics
+type Registry struct {
+	namespace string
+	families  map[string]*family
+	mutex     sync.Mutex
+}
+
+// NewRegistry 创建 Registry, 指标名以 namespace_ 开头, 为空时不加前缀
+func NewRegistry(namespace string, buckets ...float64) *Registry {
+	if len(buckets) == 0 {
+		buckets = DefaultBuckets
+	}
+	r := &Registry{namespace: namespace, families: make(map[string]*family)}
+	r.register("requests_total", "REST requests by exchange, endpoint and outcome.", typeCounter, nil, "exchange", "endpoint", "outcome")
+	r.register("request_duration_seconds", "REST request latency by exchange, endpoint and outcome.", typeHistogram, buckets, "exchange", "endpoint", "outcome")
+	r.register("rate_limited_total", "REST requests rejected by exchange rate limits.", typeCounter, nil, "exchange", "endpoint")
+	r.register("ws_reconnects_total", "Websocket reconnects.", typeCounter, nil, "exchange")
+	r.register("ws_messages_total", "Websocket messages received by topic.", typeCounter, nil, "exchange", "topic")
+	r.register("ws_ping_rtt_seconds", "Websocket heartbeat round trip time.", typeHistogram, buckets, "exchange")
+	r.register("ws_subscribe_failures_total", "Websocket subscription failures by topic.", typeCounter, nil, "exchange", "topic")
+	return r
+}
+
+func (r *Registry) register(name, help, typ string, buckets []float64, labels ...string) {
+	if r.namespace != "" {
+		name = r.namespace + "_" + name
+	}
+	r.families[name] = &family{name: name, help: help, typ: typ, labels: labels, buckets: buckets, series: make(map[string]*series)}
+}
+
+func (r *Registry) family(name string) *family {
+	if r.namespace != "" {
+		name = r.namespace + "_" + name
+	}
+	return r.families[name]
+}
+
+func (r *Registry) inc(name string, values ...string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.family(name).get(values).value++
+}
+
+func (r *Registry) observe(name string, v float64, values ...string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	f := r.family(name)
+	s := f.get(values)
+	s.value++
+	s.sum += v
+	for i, le := range f.buckets {
+		if v <= le {
+			s.buckets[i]++
+			break
+		}
+	}
+}
+
+func (r *Registry) ObserveRequest(exchange, endpoint, outcome string, latency time.Duration) {
+	r.inc("requests_total", exchange, endpoint, outcome)
+	r.observe("request_duration_seconds", latency.Seconds(), exchange, endpoint, outcome)
+}
+
+func (r *Registry) IncRateLimited(exchange, endpoint string) {
+	r.inc("rate_limited_total", exchange, endpoint)
+}
+
+func (r *Registry) IncReconnect(exchange string) {
+	r.inc("ws_reconnects_total", exchange)
+}
+
+func (r *Registry) IncMessage(exchange, topic string) {
+	r.inc("ws_messages_total", exchange, topic)
+}
+
+func (r *Registry) ObservePing(exchange string, rtt time.Duration) {
+	r.observe("ws_ping_rtt_seconds", rtt.Seconds(), exchange)
+}
+
+func (r *Registry) IncSubscribeFailure(exchange, topic string) {
+	r.inc("ws_subscribe_failures_total", exchange, topic)
+}
+
+// Value 计数器的值或直方图的样本数, 用于测试和调试, name 不含 namespace
+func (r *Registry) Value(name string, values ...string) float64 {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	f := r.family(name)
+	if f == nil {
+		return 0
+	}
+	if s, ok := f.series[strings.Join(values, "\xff")]; ok {
+		return s.value
+	}
+	return 0
+}
+
+// WriteText 以 Prometheus text 格式写出所有指标, 按指标名和标签排序
+func (r *Registry) WriteText(w io.Writer) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	var names []string
+	for name := range r.families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	bw := bufio.NewWriter(w)
+	for _, name := range names {
+		f := r.families[name]
+		fmt.Fprintf(bw, "# HELP %s %s\n# TYPE %s %s\n", f.name, f.help, f.name, f.typ)
+
+		var keys []string
+		for key := range f.series {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			s := f.series[key]
+			if f.typ == typeCounter {
+				fmt.Fprintf(bw, "%s%s %s\n", f.name, labels(f.labels, s.values, ""), formatFloat(s.value))
+				continue
+			}
+			var cumulative float64
+			for i, le := range f.buckets {
+				cumulative += s.buckets[i]
+				fmt.Fprintf(bw, "%s_bucket%s %s\n", f.name, labels(f.labels, s.values, formatFloat(le)), formatFloat(cumulative))
+			}
+			fmt.Fprintf(bw, "%s_bucket%s %s\n", f.name, labels(f.labels, s.values, "+Inf"), formatFloat(s.value))
+			fmt.Fprintf(bw, "%s_sum%s %s\n", f.name, labels(f.labels, s.values, ""), formatFloat(s.sum))
+			fmt.Fprintf(bw, "%s_count%s %s\n", f.name, labels(f.labels, s.values, ""), formatFloat(s.value))
+		}
+	}
+	return bw.Flush()
+}
+
+// ServeHTTP 导出指标
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	r.WriteText(w)
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// labels 格式化标签, le 不为空时追加直方图的 le 标签
+func labels(names, values []string, le string) string {
+	var pairs []string
+	for i, name := range names {
+		pairs = append(pairs, name+`="`+labelEscaper.Replace(values[i])+`"`)
+	}
+	if le != "" {
+		pairs = append(pairs, `le="`+le+`"`)
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,72 @@
+package mockserver
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gpmn/sheep/bibox"
+	"github.com/gpmn/sheep/consts"
+)
+
+// NewBibox 创建Bibox模拟服务器, 测试时设置 bibox.BiboxHost = s.URL
+// 私有接口共用路径, 响应函数可以通过 BiboxCmds 区分请求的cmd
+func NewBibox(accessKey, secretKey string) *Server {
+	return newServer(consts.ExchangeTypeBibox, accessKey, secretKey, biboxProtocol{})
+}
+
+// BiboxCmd : Bibox请求体中的一条指令
+type BiboxCmd struct {
+	Cmd  string            `json:"cmd"`
+	Body map[string]string `json:"body"`
+}
+
+// BiboxCmds 解析Bibox请求体中的cmds
+func BiboxCmds(r *Request) ([]BiboxCmd, error) {
+	var req map[string]string
+	if err := r.JSON(&req); err != nil {
+		return nil, err
+	}
+	var cmds []BiboxCmd
+	err := json.Unmarshal([]byte(req["cmds"]), &cmds)
+	return cmds, err
+}
+
+type biboxProtocol struct{}
+
+// verify 请求体为 {"cmds":...,"apikey":...,"sign":...}, 签名为cmds的HMAC-MD5
+func (biboxProtocol) verify(s *Server, r *Request) error {
+	if len(r.Body) == 0 {
+		return nil
+	}
+	var req map[string]string
+	if err := r.JSON(&req); err != nil || req["sign"] == "" {
+		return nil
+	}
+	if req["apikey"] != s.AccessKey {
+		return errors.New("invalid apikey")
+	}
+	if !bibox.VerifySign(s.SecretKey, req["cmds"], req["sign"]) {
+		return errors.New("signature mismatch")
+	}
+	return nil
+}
+
+// reject 3012 签名错误
+func (biboxProtocol) reject(w http.ResponseWriter, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error": map[string]string{"code": "3012", "msg": err.Error()},
+	})
+}
+
+// open Bibox没有websocket接口
+func (biboxProtocol) open(s *Server, c *Conn) bool {
+	return false
+}
+
+func (biboxProtocol) message(s *Server, c *Conn, msg []byte) {}
+
+func (biboxProtocol) push(c *Conn, topic string, data interface{}) {}
+
+func (biboxProtocol) ping(c *Conn) {}
@@ -0,0 +1,89 @@
+package mockserver
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/gpmn/sheep/binance"
+	"github.com/gpmn/sheep/consts"
+)
+
+// NewBinance 创建币安模拟服务器, 测试时设置 binance.BaseUrl = s.URL, binance.StreamEndpoint = s.WSURL("")
+// websocket支持组合流 /stream 和用户数据流 /ws/<listenKey>, 用户数据流以 listenKey 为topic推送
+func NewBinance(accessKey, secretKey string) *Server {
+	return newServer(consts.ExchangeTypeBinance, accessKey, secretKey, binanceProtocol{})
+}
+
+type binanceProtocol struct{}
+
+// verify 签名在url参数signature中, 使用 binance.VerifySignature 校验
+func (binanceProtocol) verify(s *Server, r *Request) error {
+	if key := r.Header.Get("X-MBX-APIKEY"); key != "" && key != s.AccessKey {
+		return errors.New("Invalid API-key, IP, or permissions for action.")
+	}
+	if r.Query.Get("signature") == "" {
+		return nil
+	}
+	if !binance.VerifySignature(r.Query, s.SecretKey) {
+		return errors.New("Signature for this request is not valid.")
+	}
+	return nil
+}
+
+// reject -1022 签名错误
+func (binanceProtocol) reject(w http.ResponseWriter, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusUnauthorized)
+	json.NewEncoder(w).Encode(map[string]interface{}{"code": -1022, "msg": err.Error()})
+}
+
+func (binanceProtocol) open(s *Server, c *Conn) bool {
+	if c.Path == "/stream" {
+		return true
+	}
+	if key := strings.TrimPrefix(c.Path, "/ws/"); key != c.Path && key != "" {
+		c.subscribe(key)
+		return true
+	}
+	return false
+}
+
+// message 客户端消息: {"method":"SUBSCRIBE|UNSUBSCRIBE","params":[...],"id":...}
+func (binanceProtocol) message(s *Server, c *Conn, msg []byte) {
+	var m struct {
+		Method string   `json:"method"`
+		Params []string `json:"params"`
+		ID     int64    `json:"id"`
+	}
+	if err := json.Unmarshal(msg, &m); err != nil {
+		return
+	}
+	switch m.Method {
+	case "SUBSCRIBE":
+		c.subscribe(m.Params...)
+	case "UNSUBSCRIBE":
+		c.unsubscribe(m.Params...)
+	default:
+		c.writeText(map[string]interface{}{"id": m.ID, "error": map[string]interface{}{"code": 2, "msg": "Invalid request"}})
+		return
+	}
+	c.writeText(map[string]interface{}{"id": m.ID, "result": nil})
+}
+
+// push 组合流消息为 {"stream":...,"data":...}, 单独的流直接推送data
+func (binanceProtocol) push(c *Conn, topic string, data interface{}) {
+	if c.Path == "/stream" {
+		c.writeText(map[string]interface{}{"stream": topic, "data": data})
+		return
+	}
+	c.writeText(data)
+}
+
+// ping 使用websocket控制帧, 客户端的pong由 Server 记录
+func (binanceProtocol) ping(c *Conn) {
+	c.ws.WriteControl(websocket.PingMessage, nil, time.Now().Add(time.Second))
+}
@@ -0,0 +1,117 @@
+package mockserver
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gpmn/sheep/consts"
+	"github.com/gpmn/sheep/fcoin"
+)
+
+// NewFCoin 创建FCoin模拟服务器, 测试时设置 fcoin.FCoinHost = s.URL + "/v2/", fcoin.Endpoint = s.WSURL("/v2/ws")
+// 签名包含 fcoin.FCoinHost, 服务器和客户端使用同一个值计算
+func NewFCoin(accessKey, secretKey string) *Server {
+	return newServer(consts.ExchangeTypeFCoin, accessKey, secretKey, fcoinProtocol{})
+}
+
+type fcoinProtocol struct{}
+
+// verify 签名在 FC-ACCESS-* Header中, 使用 fcoin.VerifySign 校验
+func (fcoinProtocol) verify(s *Server, r *Request) error {
+	signature := r.Header.Get("FC-ACCESS-SIGNATURE")
+	if signature == "" {
+		return nil
+	}
+	if r.Header.Get("FC-ACCESS-KEY") != s.AccessKey {
+		return errors.New("api key check fail")
+	}
+	ts, err := strconv.ParseInt(r.Header.Get("FC-ACCESS-TIMESTAMP"), 10, 64)
+	if err != nil {
+		return errors.New("invalid timestamp")
+	}
+
+	urlParams := make(map[string]string)
+	for k := range r.Query {
+		urlParams[k] = r.Query.Get(k)
+	}
+	var postParams map[string]string
+	if len(r.Body) > 0 {
+		if err := r.JSON(&postParams); err != nil {
+			return err
+		}
+	}
+	path := strings.TrimPrefix(r.Path, "/v2/")
+	if !fcoin.VerifySign(r.Method, path, s.SecretKey, urlParams, postParams, ts, signature) {
+		return errors.New("signature mismatch")
+	}
+	return nil
+}
+
+// reject 6005 签名错误
+func (fcoinProtocol) reject(w http.ResponseWriter, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusUnauthorized)
+	json.NewEncoder(w).Encode(map[string]interface{}{"status": 6005, "msg": err.Error()})
+}
+
+// open 连接建立后发送hello
+func (fcoinProtocol) open(s *Server, c *Conn) bool {
+	if c.Path != "/v2/ws" {
+		return false
+	}
+	c.writeText(map[string]interface{}{"type": "hello", "ts": milliseconds()})
+	return true
+}
+
+// message 客户端消息: {"cmd":"sub|req|ping","args":[...],"id":...}
+func (fcoinProtocol) message(s *Server, c *Conn, msg []byte) {
+	var m struct {
+		Cmd  string        `json:"cmd"`
+		Args []interface{} `json:"args"`
+		ID   string        `json:"id"`
+	}
+	if err := json.Unmarshal(msg, &m); err != nil {
+		return
+	}
+	switch m.Cmd {
+	case "ping":
+		s.heartbeat()
+		c.writeText(map[string]interface{}{"type": "ping", "ts": milliseconds(), "id": m.ID})
+	case "sub":
+		var topics []string
+		for _, arg := range m.Args {
+			if topic, ok := arg.(string); ok {
+				topics = append(topics, topic)
+			}
+		}
+		c.subscribe(topics...)
+		c.writeText(map[string]interface{}{"type": "topics", "topics": topics, "id": m.ID})
+	case "req":
+		topic := ""
+		if len(m.Args) > 0 {
+			topic, _ = m.Args[0].(string)
+		}
+		data, ok := s.reply(topic)
+		if !ok {
+			c.writeText(map[string]interface{}{"id": m.ID, "status": 40003, "msg": "invalid topic " + topic})
+			return
+		}
+		c.writeText(map[string]interface{}{"id": m.ID, "data": data})
+	}
+}
+
+// push 推送消息的type为topic, data的字段合并到消息中
+func (fcoinProtocol) push(c *Conn, topic string, data interface{}) {
+	msg := make(map[string]interface{})
+	if b, err := json.Marshal(data); err != nil || json.Unmarshal(b, &msg) != nil {
+		msg = map[string]interface{}{"data": data}
+	}
+	msg["type"] = topic
+	c.writeText(msg)
+}
+
+// ping FCoin由客户端发起心跳
+func (fcoinProtocol) ping(c *Conn) {}
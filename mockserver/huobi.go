@@ -0,0 +1,115 @@
+package mockserver
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/websocket"
+	"github.com/gpmn/sheep/consts"
+	"github.com/gpmn/sheep/huobi"
+)
+
+// HuobiAccountID NewHuobi 默认返回的现货账户id
+const HuobiAccountID = 1
+
+// NewHuobi 创建火币模拟服务器, 测试时设置 huobi.Host = s.URL, huobi.Endpoint = s.WSURL("/ws")
+// 默认响应 /v1/account/accounts, 以便 huobi.NewHuobi 可以初始化
+func NewHuobi(accessKey, secretKey string) *Server {
+	s := newServer(consts.ExchangeTypeHuobi, accessKey, secretKey, huobiProtocol{})
+	s.Handle("GET", "/v1/account/accounts", map[string]interface{}{
+		"status": "ok",
+		"data": []map[string]interface{}{
+			{"id": HuobiAccountID, "type": "spot", "state": "working", "user-id": 1},
+		},
+	})
+	return s
+}
+
+type huobiProtocol struct{}
+
+// verify 签名参数在url中, 使用 huobi.VerifySign 校验, 主机为请求的Host
+func (huobiProtocol) verify(s *Server, r *Request) error {
+	if r.Query.Get("Signature") == "" {
+		return nil
+	}
+	if r.Query.Get("AccessKeyId") != s.AccessKey {
+		return errors.New("api-signature-not-valid : invalid AccessKeyId")
+	}
+	if !huobi.VerifySign(r.Method, r.Host, r.Path, r.Query, s.SecretKey) {
+		return errors.New("api-signature-not-valid : signature mismatch")
+	}
+	return nil
+}
+
+func (huobiProtocol) reject(w http.ResponseWriter, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"status":   "error",
+		"err-code": "api-signature-not-valid",
+		"err-msg":  err.Error(),
+	})
+}
+
+func (huobiProtocol) open(s *Server, c *Conn) bool {
+	return c.Path == "/ws"
+}
+
+// message 客户端消息为未压缩的JSON: ping, pong, sub, unsub, req
+func (p huobiProtocol) message(s *Server, c *Conn, msg []byte) {
+	var m struct {
+		Ping  int64  `json:"ping"`
+		Pong  int64  `json:"pong"`
+		Sub   string `json:"sub"`
+		Unsub string `json:"unsub"`
+		Req   string `json:"req"`
+		ID    string `json:"id"`
+	}
+	if err := json.Unmarshal(msg, &m); err != nil {
+		return
+	}
+	switch {
+	case m.Ping > 0:
+		s.heartbeat()
+		p.send(c, map[string]int64{"pong": m.Ping})
+	case m.Pong > 0:
+		s.heartbeat()
+	case m.Sub != "":
+		c.subscribe(m.Sub)
+		p.send(c, map[string]interface{}{"id": m.ID, "status": "ok", "subbed": m.Sub, "ts": milliseconds()})
+	case m.Unsub != "":
+		c.unsubscribe(m.Unsub)
+		p.send(c, map[string]interface{}{"id": m.ID, "status": "ok", "unsubbed": m.Unsub, "ts": milliseconds()})
+	case m.Req != "":
+		data, ok := s.reply(m.Req)
+		if !ok {
+			p.send(c, map[string]interface{}{"id": m.ID, "status": "error",
+				"err-code": "bad-request", "err-msg": "invalid topic " + m.Req, "ts": milliseconds()})
+			return
+		}
+		p.send(c, map[string]interface{}{"id": m.ID, "status": "ok", "rep": m.Req, "data": data, "ts": milliseconds()})
+	}
+}
+
+func (p huobiProtocol) push(c *Conn, topic string, data interface{}) {
+	p.send(c, map[string]interface{}{"ch": topic, "ts": milliseconds(), "tick": data})
+}
+
+func (p huobiProtocol) ping(c *Conn) {
+	p.send(c, map[string]int64{"ping": milliseconds()})
+}
+
+// send 服务器消息经gzip压缩后以二进制帧发送
+func (huobiProtocol) send(c *Conn, v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	w.Write(b)
+	w.Close()
+	return c.write(websocket.BinaryMessage, buf.Bytes())
+}
@@ -0,0 +1,335 @@
+package mockserver
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gpmn/sheep/bibox"
+	"github.com/gpmn/sheep/binance"
+	"github.com/gpmn/sheep/consts"
+	"github.com/gpmn/sheep/fcoin"
+	"github.com/gpmn/sheep/huobi"
+	"github.com/gpmn/sheep/metrics"
+	"github.com/gpmn/sheep/okex"
+	"github.com/gpmn/sheep/proto"
+)
+
+const (
+	testKey    = "test-access-key"
+	testSecret = "test-secret-key"
+	timeout    = 3 * time.Second
+)
+
+func waitHeartbeat(t *testing.T, s *Server) {
+	deadline := time.Now().Add(timeout)
+	for s.Heartbeats() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("no heartbeat received")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestHuobiREST(t *testing.T) {
+	s := NewHuobi(testKey, testSecret)
+	defer s.Close()
+	defer func(host string) { huobi.Host = host }(huobi.Host)
+	huobi.Host = s.URL
+
+	s.Handle("POST", "/v1/order/orders/place", map[string]string{"status": "ok", "data": "42"})
+
+	h, err := huobi.NewHuobi(testKey, testSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ret, err := h.OrderPlace(&proto.OrderPlaceParams{
+		BaseCurrencyID:  "btc",
+		QuoteCurrencyID: "usdt",
+		Type:            proto.OrderPlaceTypeBuyLimit,
+		Price:           100,
+		Amount:          1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ret.OrderID != "42" {
+		t.Fatalf("order id %s", ret.OrderID)
+	}
+	r, ok := s.LastRequest("POST", "/v1/order/orders/place")
+	if !ok {
+		t.Fatal("order request not received")
+	}
+	var body map[string]string
+	if err := r.JSON(&body); err != nil || body["account-id"] != "1" || body["symbol"] != "btcusdt" {
+		t.Fatalf("order body %s", r.Body)
+	}
+
+	if _, err := huobi.NewHuobi(testKey, "wrong"); err == nil {
+		t.Fatal("wrong secret accepted")
+	}
+}
+
+func TestHuobiWebsocket(t *testing.T) {
+	s := NewHuobi(testKey, testSecret)
+	s.PingInterval = 20 * time.Millisecond
+	defer s.Close()
+	defer func(endpoint string) { huobi.Endpoint = endpoint }(huobi.Endpoint)
+	huobi.Endpoint = s.WSURL("/ws")
+
+	h, _ := huobi.NewHuobi("", "")
+	if err := h.OpenWebsocket(); err != nil {
+		t.Fatal(err)
+	}
+	defer h.CloseWebsocket()
+
+	c := make(chan *huobi.MarketTradeDetail, 1)
+	h.SetDetailListener(func(symbol string, detail *huobi.MarketTradeDetail) {
+		if symbol == "btcusdt" {
+			c <- detail
+		}
+	})
+	if err := h.SubscribeDetail("btcusdt"); err != nil {
+		t.Fatal(err)
+	}
+
+	s.Publish("market.btcusdt.trade.detail", map[string]interface{}{
+		"data": []map[string]interface{}{{"amount": 1.5, "direction": "buy", "price": 100, "ts": 1}},
+	})
+	select {
+	case detail := <-c:
+		if len(detail.Tick.Data) != 1 || detail.Tick.Data[0].Price != 100 {
+			t.Fatalf("detail %+v", detail)
+		}
+	case <-time.After(timeout):
+		t.Fatal("trade detail not received")
+	}
+	waitHeartbeat(t, s)
+}
+
+func TestOKEX(t *testing.T) {
+	s := NewOKEX(testKey, testSecret)
+	defer s.Close()
+	defer func(api, endpoint string) { okex.APIURL, okex.Endpoint = api, endpoint }(okex.APIURL, okex.Endpoint)
+	okex.APIURL = s.URL + "/api/"
+	okex.Endpoint = s.WSURL("/websocket")
+
+	s.Handle("POST", "/api/v1/userinfo.do", map[string]interface{}{
+		"result": true,
+		"info": map[string]interface{}{"funds": map[string]interface{}{
+			"free":    map[string]string{"btc": "1.5"},
+			"freezed": map[string]string{"btc": "0"},
+		}},
+	})
+	o, _ := okex.NewOKEX(testKey, testSecret)
+	balances, err := o.GetAccountBalance()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(balances) != 1 || balances[0].Currency != "btc" || balances[0].Balance != 1.5 {
+		t.Fatalf("balances %+v", balances)
+	}
+	bad, _ := okex.NewOKEX(testKey, "wrong")
+	if _, err := bad.GetAccountBalance(); err == nil {
+		t.Fatal("wrong secret accepted")
+	}
+
+	if err := o.OpenWebsocket(); err != nil {
+		t.Fatal(err)
+	}
+	defer o.CloseWebsocket()
+	c := make(chan *okex.MarketDepth, 1)
+	o.SetDepthListener(func(symbol string, depth *okex.MarketDepth) { c <- depth })
+	if err := o.SubscribeDepthN(5, "btc_usdt"); err != nil {
+		t.Fatal(err)
+	}
+	s.Publish("ok_sub_spot_btc_usdt_depth_5", map[string]interface{}{
+		"asks":      [][]string{{"101", "1"}},
+		"bids":      [][]string{{"100", "2"}},
+		"timestamp": 1,
+	})
+	select {
+	case depth := <-c:
+		if len(depth.Bids) != 1 || depth.Bids[0].Price != 100 || depth.Level != 5 {
+			t.Fatalf("depth %+v", depth)
+		}
+	case <-time.After(timeout):
+		t.Fatal("depth not received")
+	}
+}
+
+func TestFCoin(t *testing.T) {
+	s := NewFCoin(testKey, testSecret)
+	defer s.Close()
+	defer func(host, endpoint string) { fcoin.FCoinHost, fcoin.Endpoint = host, endpoint }(fcoin.FCoinHost, fcoin.Endpoint)
+	fcoin.FCoinHost = s.URL + "/v2/"
+	fcoin.Endpoint = s.WSURL("/v2/ws")
+
+	s.Handle("GET", "/v2/accounts/balance", map[string]interface{}{
+		"status": 0,
+		"data":   []map[string]string{{"currency": "btc", "available": "1", "frozen": "0.5", "balance": "1.5"}},
+	})
+	s.Handle("POST", "/v2/orders", map[string]interface{}{"status": 0, "data": "abc"})
+
+	f, _ := fcoin.NewFCoin(testKey, testSecret)
+	balances, err := f.GetAccountBalance()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(balances) != 2 || balances[0].Balance != 1.5 || balances[1].Balance != 0.5 {
+		t.Fatalf("balances %+v", balances)
+	}
+	ret, err := f.OrderPlace(&proto.OrderPlaceParams{
+		BaseCurrencyID:  "btc",
+		QuoteCurrencyID: "usdt",
+		Type:            proto.OrderPlaceTypeSellLimit,
+		Price:           100,
+		Amount:          1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ret.OrderID != "abc" {
+		t.Fatalf("order id %s", ret.OrderID)
+	}
+	bad, _ := fcoin.NewFCoin(testKey, "wrong")
+	if _, err := bad.GetAccountBalance(); err == nil {
+		t.Fatal("wrong secret accepted")
+	}
+
+	if err := f.OpenWebsocket(); err != nil {
+		t.Fatal(err)
+	}
+	defer f.Market.Close()
+	c := make(chan *fcoin.TradeUpdate, 1)
+	f.SetTradeListener(func(symbol string, trade *fcoin.TradeUpdate) { c <- trade })
+	if err := f.SubscribeTrade("btcusdt"); err != nil {
+		t.Fatal(err)
+	}
+	s.Publish("trade.btcusdt", map[string]interface{}{"id": 1, "ts": 1, "side": "buy", "price": 100, "amount": 2})
+	select {
+	case trade := <-c:
+		if trade.Price != 100 || trade.Amount != 2 {
+			t.Fatalf("trade %+v", trade)
+		}
+	case <-time.After(timeout):
+		t.Fatal("trade not received")
+	}
+}
+
+func TestBinance(t *testing.T) {
+	s := NewBinance(testKey, testSecret)
+	s.PingInterval = 20 * time.Millisecond
+	defer s.Close()
+	defer func(base, endpoint string) { binance.BaseUrl, binance.StreamEndpoint = base, endpoint }(binance.BaseUrl, binance.StreamEndpoint)
+	binance.BaseUrl = s.URL
+	binance.StreamEndpoint = s.WSURL("")
+
+	s.Handle("GET", "/api/v3/account", map[string]interface{}{
+		"canTrade": true,
+		"balances": []map[string]string{{"asset": "BTC", "free": "1.5", "locked": "0"}},
+	})
+	b := binance.New(testKey, testSecret)
+	account, err := b.GetAccountInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !account.CanTrade || len(account.Balances) != 1 || account.Balances[0].Free != 1.5 {
+		t.Fatalf("account %+v", account)
+	}
+	if _, err := binance.New(testKey, "wrong").GetAccountInfo(); err == nil {
+		t.Fatal("wrong secret accepted")
+	}
+
+	if err := b.OpenWebsocket(); err != nil {
+		t.Fatal(err)
+	}
+	defer b.CloseWebsocket()
+	c := make(chan *binance.WsTradeEvent, 1)
+	b.SetTradeListener(func(symbol string, trade *binance.WsTradeEvent) { c <- trade })
+	if err := b.SubscribeTrade("BNBBTC"); err != nil {
+		t.Fatal(err)
+	}
+	s.Publish("bnbbtc@trade", map[string]interface{}{"e": "trade", "s": "BNBBTC", "t": 1, "p": "0.001", "q": "2"})
+	select {
+	case trade := <-c:
+		if trade.Price != 0.001 || trade.Quantity != 2 {
+			t.Fatalf("trade %+v", trade)
+		}
+	case <-time.After(timeout):
+		t.Fatal("trade not received")
+	}
+	waitHeartbeat(t, s)
+}
+
+func TestBibox(t *testing.T) {
+	s := NewBibox(testKey, testSecret)
+	defer s.Close()
+	defer func(host string) { bibox.BiboxHost = host }(bibox.BiboxHost)
+	bibox.BiboxHost = s.URL
+
+	s.HandleFunc("POST", "/v1/orderpending", func(r *Request) interface{} {
+		cmds, err := BiboxCmds(r)
+		if err != nil || len(cmds) != 1 || cmds[0].Cmd != "orderpending/trade" {
+			return map[string]interface{}{"error": map[string]string{"code": "2033", "msg": "bad cmds"}}
+		}
+		return map[string]interface{}{"result": []map[string]interface{}{{"result": 123, "cmd": cmds[0].Cmd}}}
+	})
+
+	b, _ := bibox.NewBibox(testKey, testSecret)
+	rsp, err := b.OrderPlace("BTC_USDT", "0", "2", "1", "100", "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rsp.Result) != 1 || rsp.Result[0].Result != 123 {
+		t.Fatalf("rsp %+v", rsp)
+	}
+
+	bad, _ := bibox.NewBibox(testKey, "wrong")
+	rsp, err = bad.OrderPlace("BTC_USDT", "0", "2", "1", "100", "1")
+	if err == nil && len(rsp.Result) != 0 {
+		t.Fatal("wrong secret accepted")
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	r := metrics.NewRegistry("sheep")
+	metrics.Set(r)
+	defer metrics.Set(nil)
+
+	s := NewHuobi(testKey, testSecret)
+	s.PingInterval = 20 * time.Millisecond
+	defer s.Close()
+	defer func(host, endpoint string) { huobi.Host, huobi.Endpoint = host, endpoint }(huobi.Host, huobi.Endpoint)
+	huobi.Host = s.URL
+	huobi.Endpoint = s.WSURL("/ws")
+	metrics.RegisterHost(s.Listener.Addr().String(), consts.ExchangeTypeHuobi)
+
+	h, err := huobi.NewHuobi(testKey, testSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := r.Value("requests_total", consts.ExchangeTypeHuobi, "/v1/account/accounts", metrics.OutcomeOK); v != 1 {
+		t.Fatalf("requests %v", v)
+	}
+
+	if err := h.OpenWebsocket(); err != nil {
+		t.Fatal(err)
+	}
+	defer h.CloseWebsocket()
+	c := make(chan struct{}, 1)
+	h.SetDetailListener(func(symbol string, detail *huobi.MarketTradeDetail) { c <- struct{}{} })
+	if err := h.SubscribeDetail("btcusdt"); err != nil {
+		t.Fatal(err)
+	}
+	s.Publish("market.btcusdt.trade.detail", map[string]interface{}{
+		"data": []map[string]interface{}{{"amount": 1, "direction": "buy", "price": 100, "ts": 1}},
+	})
+	select {
+	case <-c:
+	case <-time.After(timeout):
+		t.Fatal("trade detail not received")
+	}
+	if v := r.Value("ws_messages_total", consts.ExchangeTypeHuobi, "market.btcusdt.trade.detail"); v != 1 {
+		t.Fatalf("messages %v", v)
+	}
+}
@@ -0,0 +1,79 @@
+package mockserver
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gpmn/sheep/consts"
+	"github.com/gpmn/sheep/okex"
+)
+
+// NewOKEX 创建OKEX模拟服务器, 测试时设置 okex.APIURL = s.URL + "/api/", okex.Endpoint = s.WSURL("/websocket")
+func NewOKEX(accessKey, secretKey string) *Server {
+	return newServer(consts.ExchangeTypeOKEX, accessKey, secretKey, okexProtocol{})
+}
+
+type okexProtocol struct{}
+
+// verify 签名在表单参数sign中, 使用 okex.VerifySign 校验
+func (okexProtocol) verify(s *Server, r *Request) error {
+	form := r.Form()
+	if form.Get("sign") == "" {
+		return nil
+	}
+	if form.Get("api_key") != s.AccessKey {
+		return errors.New("invalid api_key")
+	}
+	if !okex.VerifySign(form, s.SecretKey) {
+		return errors.New("signature mismatch")
+	}
+	return nil
+}
+
+// reject 10007 签名不匹配
+func (okexProtocol) reject(w http.ResponseWriter, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"result": false, "error_code": 10007})
+}
+
+func (okexProtocol) open(s *Server, c *Conn) bool {
+	return c.Path == "/websocket"
+}
+
+// message 客户端消息: {"event":"ping"}, {"event":"addChannel","channel":...}, {"event":"removeChannel","channel":...}
+func (okexProtocol) message(s *Server, c *Conn, msg []byte) {
+	var m struct {
+		Event   string `json:"event"`
+		Channel string `json:"channel"`
+	}
+	if err := json.Unmarshal(msg, &m); err != nil {
+		return
+	}
+	switch m.Event {
+	case "ping":
+		s.heartbeat()
+		c.writeText(map[string]string{"event": "pong"})
+	case "addChannel":
+		c.subscribe(m.Channel)
+		c.writeText([]map[string]interface{}{{
+			"binary":  0,
+			"channel": "addChannel",
+			"data":    map[string]interface{}{"result": true, "channel": m.Channel},
+		}})
+	case "removeChannel":
+		c.unsubscribe(m.Channel)
+		c.writeText([]map[string]interface{}{{
+			"binary":  0,
+			"channel": "removeChannel",
+			"data":    map[string]interface{}{"result": true, "channel": m.Channel},
+		}})
+	}
+}
+
+func (okexProtocol) push(c *Conn, topic string, data interface{}) {
+	c.writeText([]map[string]interface{}{{"binary": 0, "channel": topic, "data": data}})
+}
+
+// ping OKEX由客户端发起心跳
+func (okexProtocol) ping(c *Conn) {}
@@ -0,0 +1,371 @@
+package mockserver
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Request : 服务器收到的REST请求
+type Request struct {
+	Method string
+	Host   string
+	Path   string
+	Query  url.Values
+	Header http.Header
+	Body   []byte
+}
+
+// Form 解析表单格式的请求体
+func (r *Request) Form() url.Values {
+	values, _ := url.ParseQuery(string(r.Body))
+	return values
+}
+
+// JSON 解析JSON格式的请求体
+func (r *Request) JSON(v interface{}) error {
+	return json.Unmarshal(r.Body, v)
+}
+
+// Handler 生成REST响应, 返回 string 或 []byte 时原样输出, 其他类型序列化为JSON
+type Handler func(r *Request) interface{}
+
+// protocol : 各交易所的签名校验和websocket协议
+type protocol interface {
+	// verify 校验请求签名, 未签名的公共请求返回nil
+	verify(s *Server, r *Request) error
+	// reject 输出签名错误, 格式同交易所的错误响应
+	reject(w http.ResponseWriter, err error)
+	// open websocket连接建立, path 不支持时返回false
+	open(s *Server, c *Conn) bool
+	// message 处理客户端消息
+	message(s *Server, c *Conn, msg []byte)
+	// push 向连接推送订阅的数据
+	push(c *Conn, topic string, data interface{})
+	// ping 服务器心跳
+	ping(c *Conn)
+}
+
+// Server : 模拟交易所服务器, REST请求校验签名后返回预设的响应, websocket按交易所协议应答订阅和心跳
+type Server struct {
+	*httptest.Server
+
+	// 交易所名称, 同 consts.ExchangeTypeXXX
+	Name      string
+	AccessKey string
+	SecretKey string
+
+	// 服务器主动发送心跳的间隔, 0 表示不发送, 在建立websocket连接之前设置
+	PingInterval time.Duration
+
+	protocol   protocol
+	routes     map[string]Handler
+	replies    map[string]interface{}
+	requests   []Request
+	conns      map[*Conn]bool
+	heartbeats int
+	mutex      sync.Mutex
+}
+
+var upgrader = websocket.Upgrader{
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+func newServer(name, accessKey, secretKey string, p protocol) *Server {
+	s := &Server{
+		Name:      name,
+		AccessKey: accessKey,
+		SecretKey: secretKey,
+		protocol:  p,
+		routes:    make(map[string]Handler),
+		replies:   make(map[string]interface{}),
+		conns:     make(map[*Conn]bool),
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// WSURL websocket地址, 路径为交易所的websocket入口
+func (s *Server) WSURL(path string) string {
+	return "ws" + strings.TrimPrefix(s.URL, "http") + path
+}
+
+// Handle 设置 method path 的固定响应
+func (s *Server) Handle(method, path string, response interface{}) {
+	s.HandleFunc(method, path, func(r *Request) interface{} { return response })
+}
+
+// HandleFunc 设置 method path 的响应函数
+func (s *Server) HandleFunc(method, path string, handler Handler) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.routes[method+" "+path] = handler
+}
+
+// HandleRequest 设置websocket行情请求 topic 的返回数据
+func (s *Server) HandleRequest(topic string, data interface{}) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.replies[topic] = data
+}
+
+// reply 取websocket行情请求的返回数据
+func (s *Server) reply(topic string) (interface{}, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	data, ok := s.replies[topic]
+	return data, ok
+}
+
+// Requests 收到的所有REST请求
+func (s *Server) Requests() []Request {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]Request(nil), s.requests...)
+}
+
+// LastRequest 最后一个 method path 的请求
+func (s *Server) LastRequest(method, path string) (*Request, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for i := len(s.requests) - 1; i >= 0; i-- {
+		if r := s.requests[i]; r.Method == method && r.Path == path {
+			return &r, true
+		}
+	}
+	return nil, false
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, hr *http.Request) {
+	if websocket.IsWebSocketUpgrade(hr) {
+		s.serveWebsocket(w, hr)
+		return
+	}
+
+	body, err := ioutil.ReadAll(hr.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	r := Request{
+		Method: hr.Method,
+		Host:   hr.Host,
+		Path:   hr.URL.Path,
+		Query:  hr.URL.Query(),
+		Header: hr.Header,
+		Body:   body,
+	}
+
+	s.mutex.Lock()
+	s.requests = append(s.requests, r)
+	handler, ok := s.routes[r.Method+" "+r.Path]
+	s.mutex.Unlock()
+
+	if err := s.protocol.verify(s, &r); err != nil {
+		s.protocol.reject(w, err)
+		return
+	}
+	if !ok {
+		http.NotFound(w, hr)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	switch v := handler(&r).(type) {
+	case string:
+		w.Write([]byte(v))
+	case []byte:
+		w.Write(v)
+	default:
+		json.NewEncoder(w).Encode(v)
+	}
+}
+
+func (s *Server) serveWebsocket(w http.ResponseWriter, hr *http.Request) {
+	ws, err := upgrader.Upgrade(w, hr, nil)
+	if err != nil {
+		return
+	}
+	c := &Conn{ws: ws, Path: hr.URL.Path, topics: make(map[string]bool), closed: make(chan struct{})}
+	ws.SetPongHandler(func(string) error {
+		s.heartbeat()
+		return nil
+	})
+	if !s.protocol.open(s, c) {
+		ws.Close()
+		return
+	}
+
+	s.mutex.Lock()
+	s.conns[c] = true
+	interval := s.PingInterval
+	s.mutex.Unlock()
+
+	if interval > 0 {
+		go func() {
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ticker.C:
+					s.protocol.ping(c)
+				case <-c.closed:
+					return
+				}
+			}
+		}()
+	}
+
+	defer func() {
+		s.mutex.Lock()
+		delete(s.conns, c)
+		s.mutex.Unlock()
+		c.Close()
+	}()
+	for {
+		_, msg, err := ws.ReadMessage()
+		if err != nil {
+			return
+		}
+		s.protocol.message(s, c, msg)
+	}
+}
+
+// heartbeat 记录一次客户端心跳
+func (s *Server) heartbeat() {
+	s.mutex.Lock()
+	s.heartbeats++
+	s.mutex.Unlock()
+}
+
+// Heartbeats 收到的客户端心跳数, 包括客户端的ping和对服务器ping的回复
+func (s *Server) Heartbeats() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.heartbeats
+}
+
+// connections 当前的websocket连接
+func (s *Server) connections() []*Conn {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	var conns []*Conn
+	for c := range s.conns {
+		conns = append(conns, c)
+	}
+	return conns
+}
+
+// Publish 向订阅了 topic 的连接推送数据, 返回推送的连接数
+func (s *Server) Publish(topic string, data interface{}) int {
+	n := 0
+	for _, c := range s.connections() {
+		if c.Subscribed(topic) {
+			s.protocol.push(c, topic, data)
+			n++
+		}
+	}
+	return n
+}
+
+// Subscribed 是否有连接订阅了 topic
+func (s *Server) Subscribed(topic string) bool {
+	for _, c := range s.connections() {
+		if c.Subscribed(topic) {
+			return true
+		}
+	}
+	return false
+}
+
+// WaitSubscribed 等待客户端订阅 topic, 超时返回false
+func (s *Server) WaitSubscribed(topic string, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for !s.Subscribed(topic) {
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return true
+}
+
+// Close 断开所有websocket连接并关闭服务器
+func (s *Server) Close() {
+	for _, c := range s.connections() {
+		c.Close()
+	}
+	s.Server.Close()
+}
+
+// Conn : 服务器端的websocket连接
+type Conn struct {
+	// 连接的路径
+	Path string
+
+	ws     *websocket.Conn
+	topics map[string]bool
+	closed chan struct{}
+	once   sync.Once
+	mutex  sync.Mutex
+}
+
+// Subscribed 连接是否订阅了 topic
+func (c *Conn) Subscribed(topic string) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.topics[topic]
+}
+
+func (c *Conn) subscribe(topics ...string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for _, topic := range topics {
+		c.topics[topic] = true
+	}
+}
+
+func (c *Conn) unsubscribe(topics ...string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for _, topic := range topics {
+		delete(c.topics, topic)
+	}
+}
+
+// write 发送消息, v 为 []byte 时原样发送, 其他类型序列化为JSON
+func (c *Conn) write(messageType int, v interface{}) error {
+	b, ok := v.([]byte)
+	if !ok {
+		var err error
+		if b, err = json.Marshal(v); err != nil {
+			return err
+		}
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.ws.WriteMessage(messageType, b)
+}
+
+// writeText 发送文本消息
+func (c *Conn) writeText(v interface{}) error {
+	return c.write(websocket.TextMessage, v)
+}
+
+// Close 断开连接
+func (c *Conn) Close() error {
+	c.once.Do(func() { close(c.closed) })
+	return c.ws.Close()
+}
+
+// milliseconds 当前毫秒时间戳
+func milliseconds() int64 {
+	return time.Now().UnixNano() / int64(time.Millisecond)
+}
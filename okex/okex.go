@@ -1,24 +1,84 @@
 package okex
 
 import (
+	"encoding/json"
 	"net/url"
 
 	"strings"
 
 	"strconv"
+	"sync"
 
-	"log"
-
+	simplejson "github.com/bitly/go-simplejson"
 	"github.com/gpmn/sheep/consts"
+	"github.com/gpmn/sheep/logger"
 	"github.com/gpmn/sheep/proto"
-	"github.com/leizongmin/huobiapi"
+	"github.com/gpmn/sheep/util"
 	"github.com/pkg/errors"
 )
 
 type OKEX struct {
-	accessKey string
-	secretKey string
-	market    *Market
+	accessKey      string
+	secretKey      string
+	market         *Market
+	depthListener  DepthListener
+	tickerListener TickerListener
+	dealsListener  DealsListener
+	klineListener  KLineListener
+	recorder       util.Recorder
+	logger         logger.Logger
+	mutex          sync.RWMutex // 保护密钥, 支持运行时轮换
+}
+
+func (o *OKEX) OpenWebsocket() error {
+	var err error
+	o.market, err = dialMarket(o.recorder, o.logger)
+	if err != nil {
+		return err
+	}
+
+	go o.market.Loop()
+	return nil
+}
+
+// SetRecorder 设置行情消息记录器, 在 OpenWebsocket 之前调用
+func (o *OKEX) SetRecorder(recorder util.Recorder) {
+	o.recorder = recorder
+}
+
+// SetLogger 设置日志, 为nil时不输出, 同时用于 websocket
+func (o *OKEX) SetLogger(l logger.Logger) {
+	o.logger = l
+	if o.market != nil {
+		o.market.Logger = l
+	}
+}
+
+func (o *OKEX) log() logger.Logger {
+	return logger.OrNop(o.logger)
+}
+
+func (o *OKEX) keys() (string, string) {
+	o.mutex.RLock()
+	defer o.mutex.RUnlock()
+	return o.accessKey, o.secretKey
+}
+
+// SetKeys 更换密钥, 之后的请求使用新密钥签名, 用于不重启轮换密钥
+func (o *OKEX) SetKeys(accessKey, secretKey string) {
+	o.mutex.Lock()
+	o.accessKey, o.secretKey = accessKey, secretKey
+	o.mutex.Unlock()
+}
+
+// OpenReplay 使用离线Market代替websocket连接, 订阅和监听器照常使用, 消息通过返回的 Market.Replay 输入
+func (o *OKEX) OpenReplay() *Market {
+	o.market = NewOfflineMarket()
+	return o.market
+}
+
+func (o *OKEX) CloseWebsocket() error {
+	return o.market.Close()
 }
 
 func (o *OKEX) GetExchangeType() string {
@@ -42,7 +102,7 @@ func (o *OKEX) GetAccountBalance() ([]proto.AccountBalance, error) {
 		if v != "0" {
 			var item proto.AccountBalance
 			item.Currency = k
-			item.Balance = v
+			item.Balance, _ = strconv.ParseFloat(v, 64)
 			item.Type = proto.AccountBalanceTypeTrade
 
 			res = append(res, item)
@@ -53,7 +113,7 @@ func (o *OKEX) GetAccountBalance() ([]proto.AccountBalance, error) {
 		if v != "0" {
 			var item proto.AccountBalance
 			item.Currency = k
-			item.Balance = v
+			item.Balance, _ = strconv.ParseFloat(v, 64)
 			item.Type = proto.AccountBalanceTypeFrozen
 
 			res = append(res, item)
@@ -140,6 +200,7 @@ func (o *OKEX) GetOrderInfo(params *proto.OrderInfoParams) (*proto.Order, error)
 	ret.Amount = okOrder.Amount
 	ret.FieldAmount = okOrder.DealAmount
 	ret.Price = okOrder.Price
+	ret.FieldCashAmount = TransCashAmount(okOrder)
 	ret.Type = TransOrderType(okOrder.Type)
 
 	return &ret, nil
@@ -174,6 +235,7 @@ func (o *OKEX) GetOrders(params *proto.OrdersParams) ([]proto.Order, error) {
 		item.Amount = okOrder.Amount
 		item.FieldAmount = okOrder.DealAmount
 		item.Price = okOrder.Price
+		item.FieldCashAmount = TransCashAmount(okOrder)
 		item.Type = TransOrderType(okOrder.Type)
 
 		ret = append(ret, item)
@@ -183,15 +245,124 @@ func (o *OKEX) GetOrders(params *proto.OrdersParams) ([]proto.Order, error) {
 
 }
 
-func (h *OKEX) SubscribeDetail(symbols ...string) {
-	for _, symbol := range symbols {
-		h.market.Subscribe("ok_sub_spot_"+symbol+"_depth", func(topic string, j *huobiapi.JSON) {
-			js, _ := j.MarshalJSON()
-			log.Println(js)
+func (o *OKEX) SetDepthListener(listener DepthListener) {
+	o.depthListener = listener
+}
 
-		})
+func (o *OKEX) SetTickerListener(listener TickerListener) {
+	o.tickerListener = listener
+}
+
+func (o *OKEX) SetDealsListener(listener DealsListener) {
+	o.dealsListener = listener
+}
+
+func (o *OKEX) SetKLineListener(listener KLineListener) {
+	o.klineListener = listener
+}
+
+// DepthListener 深度监听器, symbol 格式为 bch_btc
+type DepthListener func(symbol string, depth *MarketDepth)
+
+// SubscribeDepth 订阅增量深度 ok_sub_spot_X_depth
+func (o *OKEX) SubscribeDepth(symbols ...string) error {
+	return o.subscribeDepth("_depth", 0, symbols)
+}
+
+// SubscribeDepthN 订阅前N档全量深度 ok_sub_spot_X_depth_N, N取值 5, 10, 20
+func (o *OKEX) SubscribeDepthN(n int, symbols ...string) error {
+	if n != 5 && n != 10 && n != 20 {
+		return errors.New("depth level must be 5, 10 or 20")
 	}
+	return o.subscribeDepth("_depth_"+strconv.Itoa(n), n, symbols)
+}
+
+func (o *OKEX) subscribeDepth(suffix string, level int, symbols []string) error {
+	return o.subscribe(suffix, symbols, func(symbol string, buf []byte) {
+		var md MarketDepth
+		if err := json.Unmarshal(buf, &md); err != nil {
+			o.log().Error("OKEX.SubscribeDepth - callback failed", logger.Err(err))
+			return
+		}
+		md.Level = level
+		if o.depthListener != nil {
+			o.depthListener(symbol, &md)
+		}
+	})
+}
+
+// TickerListener 行情监听器
+type TickerListener func(symbol string, ticker *Ticker)
+
+// SubscribeTicker 订阅行情 ok_sub_spot_X_ticker
+func (o *OKEX) SubscribeTicker(symbols ...string) error {
+	return o.subscribe("_ticker", symbols, func(symbol string, buf []byte) {
+		var ticker Ticker
+		if err := json.Unmarshal(buf, &ticker); err != nil {
+			o.log().Error("OKEX.SubscribeTicker - callback failed", logger.Err(err))
+			return
+		}
+		if o.tickerListener != nil {
+			o.tickerListener(symbol, &ticker)
+		}
+	})
+}
 
+// DealsListener 成交记录监听器
+type DealsListener func(symbol string, deals []Deal)
+
+// SubscribeDeals 订阅成交记录 ok_sub_spot_X_deals
+func (o *OKEX) SubscribeDeals(symbols ...string) error {
+	return o.subscribe("_deals", symbols, func(symbol string, buf []byte) {
+		var deals []Deal
+		if err := json.Unmarshal(buf, &deals); err != nil {
+			o.log().Error("OKEX.SubscribeDeals - callback failed", logger.Err(err))
+			return
+		}
+		if o.dealsListener != nil {
+			o.dealsListener(symbol, deals)
+		}
+	})
+}
+
+// KLineListener K线监听器
+type KLineListener func(symbol, period string, klines []KLine)
+
+// SubscribeKLine 订阅K线 ok_sub_spot_X_kline_Y, period 取值见 KLinePeriod*
+func (o *OKEX) SubscribeKLine(period string, symbols ...string) error {
+	return o.subscribe("_kline_"+period, symbols, func(symbol string, buf []byte) {
+		var klines []KLine
+		if err := json.Unmarshal(buf, &klines); err != nil {
+			o.log().Error("OKEX.SubscribeKLine - callback failed", logger.Err(err))
+			return
+		}
+		if o.klineListener != nil {
+			o.klineListener(symbol, period, klines)
+		}
+	})
+}
+
+// subscribe 订阅 ok_sub_spot_<symbol><suffix>, 回调收到该channel的data字段
+func (o *OKEX) subscribe(suffix string, symbols []string, handler func(symbol string, buf []byte)) error {
+	if o.market == nil {
+		return errors.New("websocket not opened")
+	}
+	for _, symbol := range symbols {
+		symbol := strings.ToLower(symbol)
+		err := o.market.Subscribe("ok_sub_spot_"+symbol+suffix, func(topic string, j *simplejson.Json) {
+			buf, err := j.MarshalJSON()
+			if err != nil {
+				o.log().Error("OKEX.subscribe - MarshalJSON failed", logger.F("topic", topic), logger.Err(err))
+				return
+			}
+			handler(symbol, buf)
+		})
+		if err != nil {
+			o.log().Error("OKEX.subscribe - Subscribe failed", logger.Err(err))
+			return err
+		}
+	}
+	return nil
 }
 
 func NewOKEX(apiKey, secretKey string) (*OKEX, error) {
@@ -200,13 +371,5 @@ func NewOKEX(apiKey, secretKey string) (*OKEX, error) {
 		secretKey: secretKey,
 	}
 
-	var err error
-	o.market, err = NewMarket()
-	if err != nil {
-		return nil, err
-	}
-
-	go o.market.Loop()
-
 	return o, nil
 }
@@ -1,5 +1,11 @@
 package okex
 
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
 const (
 	OrderPlaceTypeBuy        = "buy"         //限价买
 	OrderPlaceTypeSell       = "sell"        //限价卖
@@ -56,3 +62,148 @@ type OrderInfoReturn struct {
 	ErrorCode int                        `json:"error_code"`
 	Orders    []OrderInfoReturnOrderItem `json:"orders"`
 }
+
+// K线周期
+const (
+	KLinePeriod1Min   = "1min"
+	KLinePeriod3Min   = "3min"
+	KLinePeriod5Min   = "5min"
+	KLinePeriod15Min  = "15min"
+	KLinePeriod30Min  = "30min"
+	KLinePeriod1Hour  = "1hour"
+	KLinePeriod2Hour  = "2hour"
+	KLinePeriod4Hour  = "4hour"
+	KLinePeriod6Hour  = "6hour"
+	KLinePeriod12Hour = "12hour"
+	KLinePeriodDay    = "day"
+	KLinePeriod3Day   = "3day"
+	KLinePeriodWeek   = "week"
+)
+
+// DepthItem 深度档位, 推送格式为 [price, amount]
+type DepthItem struct {
+	Price  float64
+	Amount float64
+}
+
+func (d *DepthItem) UnmarshalJSON(b []byte) error {
+	var s []interface{}
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	if len(s) < 2 {
+		return fmt.Errorf("invalid depth item %s", b)
+	}
+	d.Price = toFloat(s[0])
+	d.Amount = toFloat(s[1])
+	return nil
+}
+
+// MarketDepth : ok_sub_spot_X_depth 增量推送, ok_sub_spot_X_depth_N 全量推送
+type MarketDepth struct {
+	Asks      []DepthItem `json:"asks"`
+	Bids      []DepthItem `json:"bids"`
+	Timestamp int64       `json:"timestamp"`
+	Level     int         `json:"-"` // 0: 增量数据, 数量为0表示删除该档位; N: 前N档全量数据
+}
+
+// Ticker : ok_sub_spot_X_ticker
+type Ticker struct {
+	High      float64
+	Low       float64
+	Open      float64
+	Close     float64
+	Last      float64
+	Buy       float64
+	Sell      float64
+	Vol       float64
+	Change    float64
+	DayHigh   float64
+	DayLow    float64
+	Timestamp int64
+}
+
+func (t *Ticker) UnmarshalJSON(b []byte) error {
+	var m map[string]interface{}
+	if err := json.Unmarshal(b, &m); err != nil {
+		return err
+	}
+	t.High = toFloat(m["high"])
+	t.Low = toFloat(m["low"])
+	t.Open = toFloat(m["open"])
+	t.Close = toFloat(m["close"])
+	t.Last = toFloat(m["last"])
+	t.Buy = toFloat(m["buy"])
+	t.Sell = toFloat(m["sell"])
+	t.Vol = toFloat(m["vol"])
+	t.Change = toFloat(m["change"])
+	t.DayHigh = toFloat(m["dayHigh"])
+	t.DayLow = toFloat(m["dayLow"])
+	t.Timestamp = int64(toFloat(m["timestamp"]))
+	return nil
+}
+
+// Deal 成交记录, 推送格式为 [tid, price, amount, time, type]
+type Deal struct {
+	TID    string
+	Price  float64
+	Amount float64
+	Time   string // HH:mm:ss
+	Type   string // ask: 卖, bid: 买
+}
+
+func (d *Deal) UnmarshalJSON(b []byte) error {
+	var s []interface{}
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	if len(s) < 5 {
+		return fmt.Errorf("invalid deal %s", b)
+	}
+	d.TID = fmt.Sprint(s[0])
+	d.Price = toFloat(s[1])
+	d.Amount = toFloat(s[2])
+	d.Time = fmt.Sprint(s[3])
+	d.Type = fmt.Sprint(s[4])
+	return nil
+}
+
+// KLine K线, 推送格式为 [timestamp, open, high, low, close, vol]
+type KLine struct {
+	Timestamp int64
+	Open      float64
+	High      float64
+	Low       float64
+	Close     float64
+	Vol       float64
+}
+
+func (k *KLine) UnmarshalJSON(b []byte) error {
+	var s []interface{}
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	if len(s) < 6 {
+		return fmt.Errorf("invalid kline %s", b)
+	}
+	k.Timestamp = int64(toFloat(s[0]))
+	k.Open = toFloat(s[1])
+	k.High = toFloat(s[2])
+	k.Low = toFloat(s[3])
+	k.Close = toFloat(s[4])
+	k.Vol = toFloat(s[5])
+	return nil
+}
+
+// toFloat 推送数据中的数值可能是字符串也可能是数字
+func toFloat(v interface{}) float64 {
+	switch val := v.(type) {
+	case float64:
+		return val
+	case string:
+		f, _ := strconv.ParseFloat(val, 64)
+		return f
+	default:
+		return 0
+	}
+}
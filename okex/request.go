@@ -7,16 +7,17 @@ import (
 	"net/url"
 	"sort"
 	"strings"
+	"time"
 
-	"log"
-
-	"github.com/gpmn/sheep/util"
+	"github.com/gpmn/sheep/consts"
+	"github.com/gpmn/sheep/logger"
+	"github.com/gpmn/sheep/metrics"
 )
 
-const (
-	apiURL     = "https://www.okex.com/api/"
-	apiVersion = "v1/"
-)
+// APIURL REST API入口
+var APIURL = "https://www.okex.com/api/"
+
+const apiVersion = "v1/"
 
 // 将map格式的请求参数转换为字符串格式的
 // mapParams: map格式的参数键值对
@@ -59,7 +60,9 @@ func httpGetRequest(strUrl string, mapParams map[string]string) string {
 	request.Header.Add("User-Agent", "Mozilla/5.0 (Windows NT 6.1; WOW64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/39.0.2171.71 Safari/537.36")
 
 	// 发出请求
+	start := time.Now()
 	response, err := httpClient.Do(request)
+	observe(request, response, err, start)
 	if nil != err {
 		return err.Error()
 	}
@@ -79,8 +82,7 @@ func httpPostRequest(strUrl string, values url.Values, accessKey, secretKey stri
 
 	values.Set("api_key", accessKey)
 
-	hasher := util.MD5([]byte(values.Encode() + "&secret_key=" + secretKey))
-	values.Set("sign", strings.ToUpper(util.HexEncodeToString(hasher)))
+	values.Set("sign", createSign(values, secretKey))
 
 	request, err := http.NewRequest("POST", strUrl, strings.NewReader(values.Encode()))
 	if nil != err {
@@ -90,7 +92,9 @@ func httpPostRequest(strUrl string, values url.Values, accessKey, secretKey stri
 	request.Header.Add("Content-Type", "application/x-www-form-urlencoded")
 	request.Header.Add("Accept-Language", "zh-cn")
 
+	start := time.Now()
 	response, err := httpClient.Do(request)
+	observe(request, response, err, start)
 	if nil != err {
 		return err.Error()
 	}
@@ -104,9 +108,19 @@ func httpPostRequest(strUrl string, values url.Values, accessKey, secretKey stri
 	return string(body)
 }
 
+// observe 记录请求指标
+func observe(request *http.Request, response *http.Response, err error, start time.Time) {
+	status := 0
+	if response != nil {
+		status = response.StatusCode
+	}
+	metrics.ObserveHTTP(consts.ExchangeTypeOKEX, request.URL, status, err, time.Since(start))
+}
+
 func (o *OKEX) apiKeyPost(values url.Values, strRequestPath string, dst interface{}) error {
-	strUrl := apiURL + apiVersion + strRequestPath
-	resp := httpPostRequest(strUrl, values, o.accessKey, o.secretKey)
-	log.Println(resp)
+	strUrl := APIURL + apiVersion + strRequestPath
+	accessKey, secretKey := o.keys()
+	resp := httpPostRequest(strUrl, values, accessKey, secretKey)
+	o.log().Debug("OKEX.apiKeyPost", logger.F("path", strRequestPath), logger.F("response", resp))
 	return json.Unmarshal([]byte(resp), dst)
 }
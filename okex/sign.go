@@ -0,0 +1,29 @@
+package okex
+
+import (
+	"crypto/hmac"
+	"net/url"
+	"strings"
+
+	"github.com/gpmn/sheep/util"
+)
+
+// 构造签名
+// values: 请求参数, 按参数名排序编码后加上 &secret_key=
+// secretKey: 进行签名的密钥
+// return: MD5的大写十六进制
+func createSign(values url.Values, secretKey string) string {
+	hasher := util.MD5([]byte(values.Encode() + "&secret_key=" + secretKey))
+	return strings.ToUpper(util.HexEncodeToString(hasher))
+}
+
+// VerifySign 校验请求签名, values 为收到的表单参数, 包含 sign
+func VerifySign(values url.Values, secretKey string) bool {
+	params := url.Values{}
+	for key, value := range values {
+		if key != "sign" {
+			params[key] = value
+		}
+	}
+	return hmac.Equal([]byte(createSign(params, secretKey)), []byte(values.Get("sign")))
+}
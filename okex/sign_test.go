@@ -0,0 +1,45 @@
+package okex
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestCreateSign(t *testing.T) {
+	cases := []struct {
+		values url.Values
+		sign   string
+	}{
+		{
+			values: url.Values{"api_key": {"ak"}},
+			sign:   "E8EEDA6AD30DCA288DC22DC7A1003983",
+		},
+		{
+			// 参数按名称排序
+			values: url.Values{
+				"api_key": {"c821db84-6fbd-11e4-a9e3-c86000d26d7c"},
+				"symbol":  {"btc_usd"},
+				"type":    {"buy"},
+				"price":   {"680"},
+				"amount":  {"1.0"},
+			},
+			sign: "6B1F15ABE5AE9FD3924A0992A6792E38",
+		},
+	}
+
+	for _, c := range cases {
+		sign := createSign(c.values, "sk")
+		if sign != c.sign {
+			t.Errorf("%s : sign %s, want %s", c.values.Encode(), sign, c.sign)
+		}
+
+		c.values.Set("sign", sign)
+		if !VerifySign(c.values, "sk") {
+			t.Errorf("%s : VerifySign failed", c.values.Encode())
+		}
+		c.values.Set("api_key", "other")
+		if VerifySign(c.values, "sk") {
+			t.Errorf("%s : VerifySign accepted modified values", c.values.Encode())
+		}
+	}
+}
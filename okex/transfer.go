@@ -1,6 +1,11 @@
 package okex
 
-import "github.com/gpmn/sheep/proto"
+import (
+	"strconv"
+	"time"
+
+	"github.com/gpmn/sheep/proto"
+)
 
 func TransOrderType(t string) string {
 	switch t {
@@ -41,3 +46,53 @@ func TransOrderStateFromStatus(s int) string {
 
 	}
 }
+
+// TransCashAmount 订单的已成交金额, 成交均价乘已成交数量
+func TransCashAmount(o OrderInfoReturnOrderItem) float64 {
+	avg, _ := strconv.ParseFloat(o.AvgPrice, 64)
+	return avg * o.DealAmount
+}
+
+// okexLocation 成交推送中的时间为北京时间
+var okexLocation = time.FixedZone("CST", 8*3600)
+
+// TransDeal 成交推送转换为 proto.Trade, 推送只有时分秒, 按最近一次出现该时刻计算日期
+func TransDeal(symbol string, d Deal) proto.Trade {
+	side := proto.TradeSideSell
+	if d.Type == "bid" {
+		side = proto.TradeSideBuy
+	}
+	t := proto.Trade{
+		ID:     d.TID,
+		Symbol: symbol,
+		Price:  d.Price,
+		Amount: d.Amount,
+		Side:   side,
+	}
+	now := time.Now().In(okexLocation)
+	if hms, err := time.ParseInLocation("15:04:05", d.Time, okexLocation); err == nil {
+		ts := time.Date(now.Year(), now.Month(), now.Day(), hms.Hour(), hms.Minute(), hms.Second(), 0, okexLocation)
+		if ts.After(now.Add(time.Minute)) {
+			ts = ts.AddDate(0, 0, -1)
+		}
+		now = ts
+	}
+	t.TS = now.UnixNano() / int64(time.Millisecond)
+	return t
+}
+
+// TransKLine K线转换为 proto.Candle, interval 为K线周期
+func TransKLine(symbol string, interval time.Duration, k KLine) proto.Candle {
+	end := k.Timestamp + int64(interval/time.Millisecond)
+	return proto.Candle{
+		Symbol: symbol,
+		Start:  k.Timestamp,
+		End:    end,
+		Open:   k.Open,
+		High:   k.High,
+		Low:    k.Low,
+		Close:  k.Close,
+		Volume: k.Vol,
+		Closed: end <= time.Now().UnixNano()/int64(time.Millisecond),
+	}
+}
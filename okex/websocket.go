@@ -2,18 +2,21 @@ package okex
 
 import (
 	"bytes"
-	"compress/gzip"
+	"compress/flate"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
-	"math/rand"
 	"time"
 
+	"github.com/gpmn/sheep/consts"
+	"github.com/gpmn/sheep/logger"
+	"github.com/gpmn/sheep/metrics"
 	"github.com/gpmn/sheep/util"
 
 	"math"
 
 	"sync"
+	"sync/atomic"
 
 	"github.com/bitly/go-simplejson"
 )
@@ -25,8 +28,9 @@ var Endpoint = "wss://real.okex.com:10441/websocket"
 var ConnectionClosedError = fmt.Errorf("websocket connection closed")
 
 const (
-	WSAddChannel   = "addChannel"
-	WSChannelValue = "channelValue"
+	WSAddChannel    = "addChannel"
+	WSRemoveChannel = "removeChannel"
+	WSChannelValue  = "channelValue"
 )
 
 type pingPongData struct {
@@ -38,35 +42,20 @@ type subData struct {
 	Channel string `json:"channel"`
 }
 
-type reqData struct {
-	Req string `json:"req"`
-	ID  string `json:"id"`
-}
-
 type jsonChan = chan *simplejson.Json
 
-var letterRunes = []rune("1234567890abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ")
-
-// getRandomString 返回随机字符串
-func getRandomString(n uint) string {
-	b := make([]rune, n)
-	for i := range b {
-		b[i] = letterRunes[rand.Intn(len(letterRunes))]
-	}
-	return string(b)
-}
-
 // getUinxMillisecond 取毫秒时间戳
 func getUinxMillisecond() int64 {
 	return time.Now().UnixNano() / int64(time.Millisecond)
 }
 
-// unGzipData 解压gzip的数据
-func unGzipData(buf []byte) ([]byte, error) {
-	r, err := gzip.NewReader(bytes.NewBuffer(buf))
-	if err != nil {
-		return nil, err
+// inflateData 解压deflate的数据, 未压缩的文本消息原样返回
+func inflateData(buf []byte) ([]byte, error) {
+	if len(buf) > 0 && (buf[0] == '[' || buf[0] == '{') {
+		return buf, nil
 	}
+	r := flate.NewReader(bytes.NewBuffer(buf))
+	defer r.Close()
 	return ioutil.ReadAll(r)
 }
 
@@ -76,24 +65,35 @@ type wsOperation struct {
 }
 
 type Market struct {
+	// 上次接收到的ping时间戳, 原子访问, 放在开头保证64位对齐
+	lastPing int64
+	// 上次发送ping的时间戳, 用于计算心跳往返时间, 原子访问
+	lastPingSent int64
+
 	ws *util.SafeWebSocket
 
 	listeners         map[string]Listener
 	subscribedTopic   map[string]bool
 	subscribeResultCb map[string]jsonChan
-	requestResultCb   map[string]jsonChan
 
-	// 掉线后是否自动重连，如果用户主动执行Close()则不自动重连
+	// 掉线后是否自动重连，如果用户主动执行Close()则不自动重连, 由 mutex 保护
 	autoReconnect bool
 
-	// 上次接收到的ping时间戳
-	lastPing int64
-
 	// 主动发送心跳的时间间隔，默认5秒
 	HeartbeatInterval time.Duration
 	// 接收消息超时时间，默认10秒
 	ReceiveTimeout time.Duration
 
+	// 消息记录器, 为nil时不记录
+	Recorder util.Recorder
+
+	// 日志, 为nil时不输出
+	Logger logger.Logger
+
+	// 离线模式, 不连接服务器, 消息由 Replay 输入
+	offline bool
+
+	// 保护 ws, autoReconnect 和各个 map
 	mutex *sync.RWMutex
 }
 
@@ -102,14 +102,20 @@ type Listener = func(topic string, json *simplejson.Json)
 
 // NewMarket 创建Market实例
 func NewMarket() (m *Market, err error) {
+	return dialMarket(nil, nil)
+}
+
+// dialMarket 创建Market实例, 记录器和日志在连接之前设置, 避免和消息处理并发读写
+func dialMarket(recorder util.Recorder, l logger.Logger) (m *Market, err error) {
 	m = &Market{
+		Recorder:          recorder,
+		Logger:            l,
 		HeartbeatInterval: 5 * time.Second,
 		ReceiveTimeout:    10 * time.Second,
 		ws:                nil,
 		autoReconnect:     true,
 		listeners:         make(map[string]Listener),
 		subscribeResultCb: make(map[string]jsonChan),
-		requestResultCb:   make(map[string]jsonChan),
 		subscribedTopic:   make(map[string]bool),
 		mutex:             &sync.RWMutex{},
 	}
@@ -121,240 +127,305 @@ func NewMarket() (m *Market, err error) {
 	return m, nil
 }
 
+// NewOfflineMarket 创建不连接服务器的Market实例, 用于回放记录的消息
+func NewOfflineMarket() *Market {
+	return &Market{
+		HeartbeatInterval: 5 * time.Second,
+		ReceiveTimeout:    10 * time.Second,
+		listeners:         make(map[string]Listener),
+		subscribeResultCb: make(map[string]jsonChan),
+		subscribedTopic:   make(map[string]bool),
+		offline:           true,
+		mutex:             &sync.RWMutex{},
+	}
+}
+
+func (m *Market) log() logger.Logger {
+	return logger.OrNop(m.Logger)
+}
+
 // connect 连接
 func (m *Market) connect() error {
-	fmt.Println("connecting")
-	fmt.Println(Endpoint)
+	m.log().Debug("Market.connect - connecting", logger.F("endpoint", Endpoint))
 	ws, err := util.NewSafeWebSocket(Endpoint)
 	if err != nil {
 		return err
 	}
+	atomic.StoreInt64(&m.lastPing, getUinxMillisecond())
+	m.mutex.Lock()
 	m.ws = ws
-	m.lastPing = getUinxMillisecond()
-	fmt.Println("connected")
+	m.mutex.Unlock()
+	m.log().Debug("Market.connect - connected")
 
-	m.handleMessageLoop()
-	m.keepAlive()
+	m.handleMessageLoop(ws)
+	m.keepAlive(ws)
 
 	return nil
 }
 
 // reconnect 重新连接
 func (m *Market) reconnect() error {
-	fmt.Println("reconnecting after 1s")
+	m.log().Info("Market.reconnect - reconnecting after 1s")
+	metrics.IncReconnect(consts.ExchangeTypeOKEX)
 	time.Sleep(time.Second)
 
 	if err := m.connect(); err != nil {
-		fmt.Println(err)
+		m.log().Error("Market.reconnect - m.connect failed", logger.Err(err))
 		return err
 	}
 
 	// 重新订阅
 	var listeners = make(map[string]Listener)
+	m.mutex.Lock()
 	for k, v := range m.listeners {
 		listeners[k] = v
+		delete(m.subscribedTopic, k)
 	}
+	m.mutex.Unlock()
 	for topic, listener := range listeners {
-		delete(m.subscribedTopic, topic)
-		m.Subscribe(topic, listener)
+		if err := m.Subscribe(topic, listener); err != nil {
+			m.log().Error("Market.reconnect - m.Subscribe failed", logger.F("topic", topic), logger.Err(err))
+		}
 	}
 	return nil
 }
 
+// conn 当前连接, 重连时会更换
+func (m *Market) conn() *util.SafeWebSocket {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	return m.ws
+}
+
+// reconnectEnabled 掉线后是否自动重连
+func (m *Market) reconnectEnabled() bool {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	return m.autoReconnect
+}
+
+func (m *Market) setAutoReconnect(v bool) {
+	m.mutex.Lock()
+	m.autoReconnect = v
+	m.mutex.Unlock()
+}
+
 // sendMessage 发送消息
 func (m *Market) sendMessage(data interface{}) error {
+	ws := m.conn()
+	if ws == nil {
+		return ConnectionClosedError
+	}
 	b, err := json.Marshal(data)
 	if err != nil {
 		return nil
 	}
-	fmt.Println("sendMessage", string(b))
-	m.ws.Send(b)
+	m.log().Debug("Market.sendMessage", logger.F("message", string(b)))
+	ws.Send(b)
 	return nil
 }
 
 // handleMessageLoop 处理消息循环
-func (m *Market) handleMessageLoop() {
-	m.ws.Listen(func(buf []byte) {
-		msg, err := unGzipData(buf)
-		fmt.Println("readMessage", string(msg))
-		if err != nil {
-			fmt.Println(err)
-			return
-		}
-		json, err := simplejson.NewJson(msg)
-		if err != nil {
-			fmt.Println(err)
-			return
-		}
-
-		// 处理ping消息
-		if ping := json.Get("ping").MustInt64(); ping > 0 {
-			m.handlePing(pingPongData{Event: "ping"})
-			return
-		}
+func (m *Market) handleMessageLoop(ws *util.SafeWebSocket) {
+	ws.Listen(m.handleMessage)
+}
 
-		// 处理pong消息
-		if event := json.Get("event").MustString(); event == "pong" {
-			m.lastPing = time.Now().Unix()
-			return
-		}
+// Replay 输入一条记录的消息, 与收到的消息经过相同的处理
+func (m *Market) Replay(buf []byte) {
+	m.handleMessage(buf)
+}
 
-		// 处理订阅消息
-		if ch := json.Get("ch").MustString(); ch != "" {
-			m.mutex.RLock()
-			defer m.mutex.RUnlock()
-			listener, ok := m.listeners[ch]
-			if ok {
-				fmt.Println("handleSubscribe", json)
-				listener(ch, json)
-			}
+// handleMessage 处理一条消息
+func (m *Market) handleMessage(buf []byte) {
+	msg, err := inflateData(buf)
+	if err != nil {
+		m.log().Error("Market.handleMessage - inflateData failed", logger.Err(err))
+		return
+	}
+	if m.Recorder != nil {
+		m.Recorder.Record(consts.ExchangeTypeOKEX, buf, msg)
+	}
+	json, err := simplejson.NewJson(msg)
+	if err != nil {
+		m.log().Error("Market.handleMessage - simplejson.NewJson failed", logger.Err(err), logger.F("response", string(msg)))
+		return
+	}
 
-			return
+	// 处理pong消息
+	if event := json.Get("event").MustString(); event == "pong" {
+		now := getUinxMillisecond()
+		atomic.StoreInt64(&m.lastPing, now)
+		if sent := atomic.LoadInt64(&m.lastPingSent); sent > 0 {
+			metrics.ObservePing(consts.ExchangeTypeOKEX, time.Duration(now-sent)*time.Millisecond)
 		}
+		return
+	}
 
-		// 处理订阅成功通知
-		if subbed := json.Get("subbed").MustString(); subbed != "" {
-			c, ok := m.subscribeResultCb[subbed]
-			if ok {
-				c <- json
-			}
-			return
-		}
+	// 推送消息为数组, 每个元素对应一个channel
+	items, err := json.Array()
+	if err != nil {
+		m.log().Warn("Market.handleMessage - unknown message", logger.F("response", string(msg)))
+		return
+	}
+	for idx := range items {
+		m.handleChannelMessage(json.GetIndex(idx))
+	}
+}
 
-		// 请求行情结果
-		if rep, id := json.Get("rep").MustString(), json.Get("id").MustString(); rep != "" && id != "" {
-			c, ok := m.requestResultCb[id]
-			if ok {
-				c <- json
-			}
-			return
+// handleChannelMessage 处理单个channel的消息
+func (m *Market) handleChannelMessage(json *simplejson.Json) {
+	channel := json.Get("channel").MustString()
+
+	// 处理订阅结果通知
+	if channel == WSAddChannel {
+		data := json.Get("data")
+		topic := data.Get("channel").MustString()
+		m.mutex.RLock()
+		c, ok := m.subscribeResultCb[topic]
+		m.mutex.RUnlock()
+		if ok {
+			c <- data
 		}
+		return
+	}
 
-		// 处理错误消息
-		if status := json.Get("status").MustString(); status == "error" {
-			// 判断是否为订阅失败
-			id := json.Get("id").MustString()
-			c, ok := m.subscribeResultCb[id]
-			if ok {
-				c <- json
-			}
-			return
+	// 订阅出错时以channel本身返回错误码
+	if code := json.Get("errorcode").MustString(); code != "" {
+		m.mutex.RLock()
+		c, ok := m.subscribeResultCb[channel]
+		m.mutex.RUnlock()
+		if ok {
+			c <- json
 		}
-	})
+		return
+	}
+
+	// 处理订阅消息
+	metrics.IncMessage(consts.ExchangeTypeOKEX, channel)
+	m.mutex.RLock()
+	listener, ok := m.listeners[channel]
+	m.mutex.RUnlock()
+	if ok {
+		listener(channel, json.Get("data"))
+	}
 }
 
 // keepAlive 保持活跃
-func (m *Market) keepAlive() {
-	m.ws.KeepAlive(m.HeartbeatInterval, func() {
+func (m *Market) keepAlive(ws *util.SafeWebSocket) {
+	ws.KeepAlive(m.HeartbeatInterval, func() {
 		var t = getUinxMillisecond()
+		atomic.StoreInt64(&m.lastPingSent, t)
 		m.sendMessage(pingPongData{Event: "ping"})
 
-		// 检查上次ping时间，如果超过20秒无响应，重新连接
-		tr := time.Duration(math.Abs(float64(t - m.lastPing)))
+		// 检查上次pong时间，如果超过两个心跳周期无响应，重新连接
+		lastPing := atomic.LoadInt64(&m.lastPing)
+		tr := time.Duration(math.Abs(float64(t-lastPing))) * time.Millisecond
 		if tr >= m.HeartbeatInterval*2 {
-			fmt.Println("no ping max delay", tr, m.HeartbeatInterval*2, t, m.lastPing)
-			if m.autoReconnect {
+			m.log().Warn("Market.keepAlive - no ping max delay", logger.F("delay", tr), logger.F("max", m.HeartbeatInterval*2), logger.F("lastPing", lastPing))
+			if m.reconnectEnabled() {
 				err := m.reconnect()
 				if err != nil {
-					fmt.Println(err)
+					m.log().Error("Market.keepAlive - reconnect failed", logger.Err(err))
 				}
 			}
 		}
 	})
 }
 
-// handlePing 处理Ping
-func (m *Market) handlePing(ping pingPongData) (err error) {
-	fmt.Println("handlePing", ping)
-	m.lastPing = time.Now().Unix()
-	var pong = pingPongData{Event: "pong"}
-	err = m.sendMessage(pong)
-	if err != nil {
-		return err
-	}
-	return nil
-}
-
 // Subscribe 订阅
 func (m *Market) Subscribe(topic string, listener Listener) error {
+	m.log().Debug("Market.Subscribe", logger.F("topic", topic))
+
 	m.mutex.Lock()
-	defer m.mutex.Unlock()
-	fmt.Println("subscribe", topic)
-
-	var isNew = false
-
-	// 如果未曾发送过订阅指令，则发送，并等待订阅操作结果，否则直接返回
-	if _, ok := m.subscribedTopic[topic]; !ok {
-		m.subscribeResultCb[topic] = make(jsonChan)
-		m.sendMessage(subData{Event: WSAddChannel, Channel: topic})
-		isNew = true
-	} else {
-		fmt.Println("send subscribe before, reset listener only")
+	_, subscribed := m.subscribedTopic[topic]
+	m.listeners[topic] = listener
+	if m.offline {
+		m.subscribedTopic[topic] = true
+		m.mutex.Unlock()
+		return nil
+	}
+	if subscribed {
+		m.mutex.Unlock()
+		m.log().Debug("Market.Subscribe - send subscribe before, reset listener only", logger.F("topic", topic))
+		return nil
 	}
+	c := make(jsonChan, 1)
+	m.subscribeResultCb[topic] = c
+	m.mutex.Unlock()
 
-	m.listeners[topic] = listener
-	m.subscribedTopic[topic] = true
+	// 如果未曾发送过订阅指令，则发送，并等待订阅操作结果
+	m.sendMessage(subData{Event: WSAddChannel, Channel: topic})
 
-	if isNew {
-		var json = <-m.subscribeResultCb[topic]
-		// 判断订阅结果，如果出错则返回出错信息
-		if msg, err := json.Get("err-msg").String(); err == nil {
-			return fmt.Errorf(msg)
-		}
+	var json *simplejson.Json
+	select {
+	case json = <-c:
+	case <-time.After(m.ReceiveTimeout):
 	}
-	return nil
+
+	m.mutex.Lock()
+	delete(m.subscribeResultCb, topic)
+	m.mutex.Unlock()
+
+	// 判断订阅结果，如果出错则返回出错信息
+	if json == nil {
+		m.Unsubscribe(topic)
+		metrics.IncSubscribeFailure(consts.ExchangeTypeOKEX, topic)
+		return fmt.Errorf("subscribe %s timeout", topic)
+	}
+	if json.Get("result").MustBool() {
+		m.mutex.Lock()
+		m.subscribedTopic[topic] = true
+		m.mutex.Unlock()
+		return nil
+	}
+	m.Unsubscribe(topic)
+	metrics.IncSubscribeFailure(consts.ExchangeTypeOKEX, topic)
+	if msg := json.Get("error_msg").MustString(); msg != "" {
+		return fmt.Errorf("subscribe %s failed : %s", topic, msg)
+	}
+	return fmt.Errorf("subscribe %s failed : error code %v", topic,
+		json.Get("error_code").Interface())
 }
 
 // Unsubscribe 取消订阅
 func (m *Market) Unsubscribe(topic string) {
-	fmt.Println("unSubscribe", topic)
+	m.log().Debug("Market.Unsubscribe", logger.F("topic", topic))
 
+	m.mutex.Lock()
 	delete(m.listeners, topic)
-}
-
-// Request 请求行情信息
-func (m *Market) Request(req string) (*simplejson.Json, error) {
-	var id = getRandomString(10)
-	m.requestResultCb[id] = make(jsonChan)
+	_, subscribed := m.subscribedTopic[topic]
+	delete(m.subscribedTopic, topic)
+	m.mutex.Unlock()
 
-	if err := m.sendMessage(reqData{Req: req, ID: id}); err != nil {
-		return nil, err
-	}
-	var json = <-m.requestResultCb[id]
-
-	delete(m.requestResultCb, id)
-
-	// 判断是否出错
-	if msg := json.Get("err-msg").MustString(); msg != "" {
-		return json, fmt.Errorf(msg)
+	if subscribed {
+		m.sendMessage(subData{Event: WSRemoveChannel, Channel: topic})
 	}
-	return json, nil
 }
 
 // Loop 进入循环
 func (m *Market) Loop() {
-	fmt.Println("startLoop")
+	m.log().Debug("Market.Loop - start")
 	for {
-		err := m.ws.Loop()
+		err := m.conn().Loop()
 		if err != nil {
-			fmt.Println(err)
+			m.log().Warn("Market.Loop - connection lost", logger.Err(err))
 			if err == util.SafeWebSocketDestroyError {
 				break
-			} else if m.autoReconnect {
+			} else if m.reconnectEnabled() {
 				m.reconnect()
 			} else {
 				break
 			}
 		}
 	}
-	fmt.Println("endLoop")
+	m.log().Debug("Market.Loop - end")
 }
 
 // ReConnect 重新连接
 func (m *Market) ReConnect() (err error) {
-	fmt.Println("reconnect")
-	m.autoReconnect = true
-	if err = m.ws.Destroy(); err != nil {
+	m.log().Info("Market.ReConnect")
+	m.setAutoReconnect(true)
+	if err = m.conn().Destroy(); err != nil {
 		return err
 	}
 	return m.reconnect()
@@ -362,9 +433,13 @@ func (m *Market) ReConnect() (err error) {
 
 // Close 关闭连接
 func (m *Market) Close() error {
-	fmt.Println("close")
-	m.autoReconnect = false
-	if err := m.ws.Destroy(); err != nil {
+	m.log().Debug("Market.Close")
+	m.setAutoReconnect(false)
+	ws := m.conn()
+	if ws == nil {
+		return nil
+	}
+	if err := ws.Destroy(); err != nil {
 		return err
 	}
 	return nil
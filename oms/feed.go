@@ -0,0 +1,25 @@
+package oms
+
+import (
+	"github.com/gpmn/sheep/binance"
+	"github.com/gpmn/sheep/huobi"
+	"github.com/gpmn/sheep/logger"
+)
+
+// HuobiOrderListener 火币订单推送监听器, 用于 huobi.Huobi.SetOrderListener
+func (o *OMS) HuobiOrderListener() huobi.OrderListener {
+	return func(symbol string, od *huobi.OrderUpdate) {
+		if err := o.Apply(huobi.TransOrderUpdate(od)); err != nil {
+			o.log().Warn("OMS.HuobiOrderListener - Apply failed", logger.Err(err))
+		}
+	}
+}
+
+// BinanceExecutionListener 币安执行报告监听器, 用于 binance.Binance.SetExecutionReportListener
+func (o *OMS) BinanceExecutionListener() binance.ExecutionReportListener {
+	return func(report *binance.WsExecutionReport) {
+		if err := o.Apply(binance.TransExecutionReport(report)); err != nil {
+			o.log().Warn("OMS.BinanceExecutionListener - Apply failed", logger.Err(err))
+		}
+	}
+}
@@ -0,0 +1,311 @@
+package oms
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gpmn/sheep"
+	"github.com/gpmn/sheep/logger"
+	"github.com/gpmn/sheep/proto"
+)
+
+// ErrUnknownOrder 订单不在OMS中
+var ErrUnknownOrder = errors.New("unknown order")
+
+// TransitionError : 不允许的状态变化, 更新被丢弃
+type TransitionError struct {
+	OrderID string
+	From    string
+	To      string
+}
+
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("order %s : invalid state transition %s -> %s", e.OrderID, e.From, e.To)
+}
+
+// stateRank 状态的先后顺序, 只允许向后变化, filled 和 canceled 为终态
+var stateRank = map[string]int{
+	proto.OrderStateSubmitted:     0,
+	proto.OrderStatePartialFilled: 1,
+	proto.OrderStateFilled:        2,
+	proto.OrderStateCanceled:      2,
+}
+
+// Closed 订单是否已完成或已撤销
+func Closed(state string) bool {
+	return state == proto.OrderStateFilled || state == proto.OrderStateCanceled
+}
+
+// Order : OMS 跟踪的订单
+type Order struct {
+	proto.Order
+	Params          proto.OrderPlaceParams // 下单参数, 从推送中发现的订单为空
+	CancelRequested bool                   // 已申请撤单, 等待交易所确认
+	UpdatedAt       time.Time              // 最后一次状态或成交数量变化的时间
+}
+
+// Listener 订单变化回调, prevState 为变化前的状态, 新订单为空
+type Listener func(order Order, prevState string)
+
+// OMS : 订单管理, 包装 sheep.ExchageI, 记录经过它下的每一个订单,
+// 合并 REST 查询(GetOrderInfo/Poll)和推送(Apply)的订单状态
+type OMS struct {
+	sheep.ExchageI
+
+	orders   map[string]*Order
+	ids      []string // 按下单或发现的顺序
+	listener Listener
+	stop     chan struct{}
+	mutex    sync.RWMutex
+
+	// 日志, 为nil时不输出, 在 Start 之前设置
+	Logger logger.Logger
+}
+
+// New 创建OMS
+func New(ex sheep.ExchageI) *OMS {
+	return &OMS{
+		ExchageI: ex,
+		orders:   make(map[string]*Order),
+	}
+}
+
+func (o *OMS) log() logger.Logger {
+	return logger.OrNop(o.Logger)
+}
+
+// SetListener 设置订单变化监听器
+func (o *OMS) SetListener(listener Listener) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	o.listener = listener
+}
+
+// notify 在锁外调用监听器
+func (o *OMS) notify(order Order, prevState string) {
+	o.mutex.RLock()
+	listener := o.listener
+	o.mutex.RUnlock()
+	if listener != nil {
+		listener(order, prevState)
+	}
+}
+
+// OrderPlace 下单, 成功后记录为 submitted
+func (o *OMS) OrderPlace(params *proto.OrderPlaceParams) (*proto.OrderPlaceReturn, error) {
+	ret, err := o.ExchageI.OrderPlace(params)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	order := &Order{
+		Order: proto.Order{
+			ID:         ret.OrderID,
+			Symbol:     strings.ToLower(params.BaseCurrencyID + params.QuoteCurrencyID),
+			State:      proto.OrderStateSubmitted,
+			Amount:     params.Amount,
+			Price:      params.Price,
+			Type:       params.Type,
+			CreatedSec: now.Unix(),
+		},
+		Params:    *params,
+		UpdatedAt: now,
+	}
+
+	o.mutex.Lock()
+	// 推送可能先于下单返回, 此时保留推送的状态
+	if existing, ok := o.orders[ret.OrderID]; ok {
+		existing.Params = *params
+		o.mutex.Unlock()
+		return ret, nil
+	}
+	o.orders[ret.OrderID] = order
+	o.ids = append(o.ids, ret.OrderID)
+	copied := *order
+	o.mutex.Unlock()
+
+	o.notify(copied, "")
+	return ret, nil
+}
+
+// OrderCancel 撤单, 状态在交易所确认后才变为 canceled
+func (o *OMS) OrderCancel(params *proto.OrderCancelParams) error {
+	if err := o.ExchageI.OrderCancel(params); err != nil {
+		return err
+	}
+	o.mutex.Lock()
+	if order, ok := o.orders[params.OrderID]; ok {
+		order.CancelRequested = true
+	}
+	o.mutex.Unlock()
+	return nil
+}
+
+// GetOrderInfo 查询订单详情, 结果合并到OMS
+func (o *OMS) GetOrderInfo(params *proto.OrderInfoParams) (*proto.Order, error) {
+	ret, err := o.ExchageI.GetOrderInfo(params)
+	if err != nil {
+		return nil, err
+	}
+	if ret.ID == "" {
+		ret.ID = params.OrderID
+	}
+	if err := o.Apply(*ret); err != nil {
+		o.log().Warn("OMS.GetOrderInfo - Apply failed", logger.Err(err))
+	}
+	return ret, nil
+}
+
+// Apply 合并一次订单状态, 来源为推送或查询. 未知订单直接记录;
+// 成交数量只增不减, 落后的状态被忽略, 终态之后变为其他状态或无效的状态返回 *TransitionError
+func (o *OMS) Apply(update proto.Order) error {
+	if _, ok := stateRank[update.State]; !ok {
+		return &TransitionError{OrderID: update.ID, To: update.State}
+	}
+
+	o.mutex.Lock()
+	order, ok := o.orders[update.ID]
+	if !ok {
+		order = &Order{Order: update, UpdatedAt: time.Now()}
+		o.orders[update.ID] = order
+		o.ids = append(o.ids, update.ID)
+		copied := *order
+		o.mutex.Unlock()
+		o.notify(copied, "")
+		return nil
+	}
+
+	prev := order.State
+	if stateRank[update.State] < stateRank[prev] {
+		// 查询结果可能比推送旧, 落后的状态直接丢弃
+		o.mutex.Unlock()
+		return nil
+	}
+	if Closed(prev) && update.State != prev {
+		o.mutex.Unlock()
+		return &TransitionError{OrderID: update.ID, From: prev, To: update.State}
+	}
+
+	changed := update.State != prev || update.FieldAmount > order.FieldAmount
+	order.State = update.State
+	if update.FieldAmount > order.FieldAmount {
+		order.FieldAmount = update.FieldAmount
+	}
+	if order.Symbol == "" {
+		order.Symbol = update.Symbol
+	}
+	if order.Type == "" {
+		order.Type = update.Type
+	}
+	if order.Amount == 0 {
+		order.Amount = update.Amount
+	}
+	if order.Price == 0 {
+		order.Price = update.Price
+	}
+	if !changed {
+		o.mutex.Unlock()
+		return nil
+	}
+	order.UpdatedAt = time.Now()
+	copied := *order
+	o.mutex.Unlock()
+
+	o.notify(copied, prev)
+	return nil
+}
+
+// Poll 查询所有未完成订单的状态
+func (o *OMS) Poll() error {
+	var lastErr error
+	for _, order := range o.OpenOrders("") {
+		_, err := o.GetOrderInfo(&proto.OrderInfoParams{
+			OrderID:         order.ID,
+			BaseCurrencyID:  order.Params.BaseCurrencyID,
+			QuoteCurrencyID: order.Params.QuoteCurrencyID,
+		})
+		if err != nil {
+			o.log().Warn("OMS.Poll - GetOrderInfo failed", logger.F("order", order.ID), logger.Err(err))
+			lastErr = err
+		}
+	}
+	return lastErr
+}
+
+// Start 每隔 interval 轮询一次未完成订单, 直到 Stop
+func (o *OMS) Start(interval time.Duration) {
+	o.mutex.Lock()
+	if o.stop != nil {
+		o.mutex.Unlock()
+		return
+	}
+	stop := make(chan struct{})
+	o.stop = stop
+	o.mutex.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				o.Poll()
+			}
+		}
+	}()
+}
+
+// Stop 停止轮询
+func (o *OMS) Stop() {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	if o.stop != nil {
+		close(o.stop)
+		o.stop = nil
+	}
+}
+
+// Order 查询订单
+func (o *OMS) Order(id string) (Order, error) {
+	o.mutex.RLock()
+	defer o.mutex.RUnlock()
+	order, ok := o.orders[id]
+	if !ok {
+		return Order{}, ErrUnknownOrder
+	}
+	return *order, nil
+}
+
+// Orders 所有订单, symbol 为空时不过滤, 按下单顺序
+func (o *OMS) Orders(symbol string) []Order {
+	return o.filter(symbol, false)
+}
+
+// OpenOrders 未完成的订单, symbol 为空时不过滤, 按下单顺序
+func (o *OMS) OpenOrders(symbol string) []Order {
+	return o.filter(symbol, true)
+}
+
+func (o *OMS) filter(symbol string, open bool) []Order {
+	symbol = strings.ToLower(symbol)
+	o.mutex.RLock()
+	defer o.mutex.RUnlock()
+	var orders []Order
+	for _, id := range o.ids {
+		order := o.orders[id]
+		if symbol != "" && order.Symbol != symbol {
+			continue
+		}
+		if open && Closed(order.State) {
+			continue
+		}
+		orders = append(orders, *order)
+	}
+	return orders
+}
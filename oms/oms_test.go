@@ -0,0 +1,160 @@
+package oms
+
+import (
+	"testing"
+
+	"github.com/gpmn/sheep/binance"
+	"github.com/gpmn/sheep/huobi"
+	"github.com/gpmn/sheep/mockserver"
+	"github.com/gpmn/sheep/proto"
+	"github.com/gpmn/sheep/sim"
+)
+
+func depth(bid, ask float64) *proto.Depth {
+	return &proto.Depth{
+		Bids: []proto.DepthLevel{{Price: bid, Amount: 10}},
+		Asks: []proto.DepthLevel{{Price: ask, Amount: 10}},
+	}
+}
+
+func TestPlaceAndPoll(t *testing.T) {
+	ex := sim.NewExchange(sim.Config{}, map[string]float64{"usdt": 1000})
+	ex.OnDepth("btcusdt", depth(99, 101))
+	o := New(ex)
+
+	var changes []string
+	o.SetListener(func(order Order, prev string) { changes = append(changes, prev+">"+order.State) })
+
+	ret, err := o.OrderPlace(&proto.OrderPlaceParams{Price: 100, Amount: 2, BaseCurrencyID: "btc", QuoteCurrencyID: "usdt", Type: proto.OrderPlaceTypeBuyLimit})
+	if err != nil {
+		t.Fatal(err)
+	}
+	order, err := o.Order(ret.OrderID)
+	if err != nil || order.State != proto.OrderStateSubmitted || order.Symbol != "btcusdt" {
+		t.Fatalf("order %+v, err %v", order, err)
+	}
+
+	ex.OnTrade("btcusdt", &proto.Trade{Price: 100, Amount: 0.5, Side: proto.TradeSideSell})
+	if err := o.Poll(); err != nil {
+		t.Fatal(err)
+	}
+	order, _ = o.Order(ret.OrderID)
+	if order.State != proto.OrderStatePartialFilled || order.FieldAmount != 0.5 {
+		t.Fatalf("order %+v", order)
+	}
+
+	if err := o.OrderCancel(&proto.OrderCancelParams{OrderID: ret.OrderID, BaseCurrencyID: "btc", QuoteCurrencyID: "usdt"}); err != nil {
+		t.Fatal(err)
+	}
+	order, _ = o.Order(ret.OrderID)
+	if !order.CancelRequested || order.State != proto.OrderStatePartialFilled {
+		t.Fatalf("order %+v", order)
+	}
+	o.Poll()
+	if len(o.OpenOrders("btcusdt")) != 0 || len(o.Orders("BTCUSDT")) != 1 {
+		t.Fatalf("orders %+v", o.Orders(""))
+	}
+
+	want := []string{">submitted", "submitted>partial-filled", "partial-filled>canceled"}
+	if len(changes) != len(want) {
+		t.Fatalf("changes %v", changes)
+	}
+	for i := range want {
+		if changes[i] != want[i] {
+			t.Fatalf("changes %v", changes)
+		}
+	}
+}
+
+func TestApplyTransitions(t *testing.T) {
+	o := New(sim.NewExchange(sim.Config{}, nil))
+
+	if err := o.Apply(proto.Order{ID: "1", Symbol: "btcusdt", State: proto.OrderStatePartialFilled, FieldAmount: 1}); err != nil {
+		t.Fatal(err)
+	}
+	// 落后的查询结果被忽略
+	if err := o.Apply(proto.Order{ID: "1", State: proto.OrderStateSubmitted}); err != nil {
+		t.Fatal(err)
+	}
+	if order, _ := o.Order("1"); order.State != proto.OrderStatePartialFilled || order.FieldAmount != 1 {
+		t.Fatalf("order %+v", order)
+	}
+	if err := o.Apply(proto.Order{ID: "1", State: proto.OrderStateFilled, FieldAmount: 2}); err != nil {
+		t.Fatal(err)
+	}
+	err := o.Apply(proto.Order{ID: "1", State: proto.OrderStateCanceled})
+	if _, ok := err.(*TransitionError); !ok {
+		t.Fatalf("got %v, want *TransitionError", err)
+	}
+	if order, _ := o.Order("1"); order.State != proto.OrderStateFilled || order.FieldAmount != 2 {
+		t.Fatalf("order %+v", order)
+	}
+	if err := o.Apply(proto.Order{ID: "2", State: "unknown"}); err == nil {
+		t.Fatal("invalid state accepted")
+	}
+	if _, err := o.Order("2"); err != ErrUnknownOrder {
+		t.Fatalf("got %v, want ErrUnknownOrder", err)
+	}
+}
+
+func TestFeeds(t *testing.T) {
+	o := New(sim.NewExchange(sim.Config{}, nil))
+
+	o.HuobiOrderListener()("ethusdt", &huobi.OrderUpdate{Order: huobi.OrderUpdateData{
+		OrderID:        7,
+		Symbol:         "ethusdt",
+		OrderState:     "partial-canceled",
+		OrderType:      "buy-limit",
+		OrderAmount:    "3",
+		UnfilledAmount: "1",
+		OrderPrice:     "10",
+	}})
+	if order, _ := o.Order("7"); order.State != proto.OrderStateCanceled || order.FieldAmount != 2 {
+		t.Fatalf("order %+v", order)
+	}
+
+	listener := o.BinanceExecutionListener()
+	listener(&binance.WsExecutionReport{OrderId: 8, Symbol: "BNBBTC", Side: "SELL", Type: "LIMIT", Status: "NEW", Quantity: 5})
+	listener(&binance.WsExecutionReport{OrderId: 8, Symbol: "BNBBTC", Side: "SELL", Type: "LIMIT", Status: "PARTIALLY_FILLED", Quantity: 5, CumulativeFilledQty: 1})
+	order, _ := o.Order("8")
+	if order.State != proto.OrderStatePartialFilled || order.FieldAmount != 1 || order.Symbol != "bnbbtc" || order.Type != "sell-limit" {
+		t.Fatalf("order %+v", order)
+	}
+}
+
+func TestHuobiRESTStates(t *testing.T) {
+	s := mockserver.NewHuobi("ak", "sk")
+	defer s.Close()
+	defer func(host string) { huobi.Host = host }(huobi.Host)
+	huobi.Host = s.URL
+
+	s.Handle("POST", "/v1/order/orders/place", map[string]string{"status": "ok", "data": "42"})
+	h, err := huobi.NewHuobi("ak", "sk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	o := New(h)
+	if _, err := o.OrderPlace(&proto.OrderPlaceParams{Price: 100, Amount: 2, BaseCurrencyID: "btc", QuoteCurrencyID: "usdt", Type: proto.OrderPlaceTypeBuyLimit}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, step := range []struct{ state, want string }{
+		{"pre-submitted", proto.OrderStateSubmitted},
+		{"submitting", proto.OrderStateSubmitted},
+		{"partial-filled", proto.OrderStatePartialFilled},
+		{"partial-canceled", proto.OrderStateCanceled},
+	} {
+		s.Handle("GET", "/v1/order/orders/42", map[string]interface{}{"status": "ok", "data": map[string]interface{}{
+			"id": 42, "symbol": "btcusdt", "state": step.state, "amount": "2", "field-amount": "1", "price": "100", "type": "buy-limit",
+		}})
+		if err := o.Poll(); err != nil {
+			t.Fatalf("%s : %v", step.state, err)
+		}
+		if order, _ := o.Order("42"); order.State != step.want {
+			t.Fatalf("%s : order %+v", step.state, order)
+		}
+	}
+	if len(o.OpenOrders("")) != 0 {
+		t.Fatalf("open orders %+v", o.OpenOrders(""))
+	}
+}
@@ -0,0 +1,280 @@
+package orderbook
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gpmn/sheep/proto"
+)
+
+// ErrGap 增量数据序号不连续, 需要重新同步
+var ErrGap = errors.New("orderbook sequence gap")
+
+// ErrNotSynced 尚未收到全量数据
+var ErrNotSynced = errors.New("orderbook not synced")
+
+// Update : 订单簿更新
+// Snapshot 为 true 时是全量数据, 否则为增量数据, 增量数据中数量为0表示删除该档位.
+// FirstSeq/LastSeq 为本次更新覆盖的序号范围, 都为0时不做序号检查.
+type Update struct {
+	Snapshot bool
+	FirstSeq int64
+	LastSeq  int64
+	Bids     []proto.DepthLevel
+	Asks     []proto.DepthLevel
+	TS       int64 // 毫秒
+}
+
+// ChangeListener 订单簿变化监听器
+type ChangeListener func(b *Book)
+
+// Book : 本地订单簿, 并发安全
+type Book struct {
+	symbol  string
+	bids    []proto.DepthLevel // 价格从高到低
+	asks    []proto.DepthLevel // 价格从低到高
+	seq     int64
+	ts      int64
+	synced  bool
+	updated time.Time // 本地最后更新时间
+
+	listener ChangeListener
+	mutex    sync.RWMutex
+}
+
+// NewBook 创建订单簿
+func NewBook(symbol string) *Book {
+	return &Book{symbol: symbol}
+}
+
+// Symbol 交易对
+func (b *Book) Symbol() string {
+	return b.symbol
+}
+
+// SetChangeListener 设置变化监听器, 每次成功应用更新后回调
+func (b *Book) SetChangeListener(listener ChangeListener) {
+	b.mutex.Lock()
+	b.listener = listener
+	b.mutex.Unlock()
+}
+
+// Apply 应用一次更新
+// 过期的增量数据被忽略; 序号不连续时返回 ErrGap, 订单簿保持不变, 需要重新加载全量数据
+func (b *Book) Apply(u *Update) error {
+	b.mutex.Lock()
+	applied, err := b.apply(u)
+	listener := b.listener
+	b.mutex.Unlock()
+
+	if applied && listener != nil {
+		listener(b)
+	}
+	return err
+}
+
+func (b *Book) apply(u *Update) (applied bool, err error) {
+	defer func() {
+		if applied {
+			b.updated = time.Now()
+		}
+	}()
+
+	if u.Snapshot {
+		if b.synced && u.LastSeq != 0 && u.LastSeq < b.seq {
+			return false, nil
+		}
+		b.bids = sortLevels(u.Bids, true)
+		b.asks = sortLevels(u.Asks, false)
+		b.seq = u.LastSeq
+		b.ts = u.TS
+		b.synced = true
+		return true, nil
+	}
+
+	if !b.synced {
+		return false, ErrNotSynced
+	}
+	if u.LastSeq != 0 {
+		if u.LastSeq <= b.seq {
+			return false, nil
+		}
+		if u.FirstSeq > b.seq+1 {
+			return false, ErrGap
+		}
+		b.seq = u.LastSeq
+	}
+	for _, l := range u.Bids {
+		b.bids = setLevel(b.bids, l, true)
+	}
+	for _, l := range u.Asks {
+		b.asks = setLevel(b.asks, l, false)
+	}
+	if u.TS != 0 {
+		b.ts = u.TS
+	}
+	return true, nil
+}
+
+// Reset 清空订单簿, 等待下一次全量数据
+func (b *Book) Reset() {
+	b.mutex.Lock()
+	b.bids = nil
+	b.asks = nil
+	b.seq = 0
+	b.synced = false
+	b.mutex.Unlock()
+}
+
+// Synced 是否已同步全量数据
+func (b *Book) Synced() bool {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+	return b.synced
+}
+
+// Seq 最后应用的序号
+func (b *Book) Seq() int64 {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+	return b.seq
+}
+
+// TS 最后更新时间, 毫秒
+func (b *Book) TS() int64 {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+	return b.ts
+}
+
+// UpdatedAt 本地最后一次应用更新的时间
+func (b *Book) UpdatedAt() time.Time {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+	return b.updated
+}
+
+// BestBid 买一
+func (b *Book) BestBid() (proto.DepthLevel, bool) {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+	if len(b.bids) == 0 {
+		return proto.DepthLevel{}, false
+	}
+	return b.bids[0], true
+}
+
+// BestAsk 卖一
+func (b *Book) BestAsk() (proto.DepthLevel, bool) {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+	if len(b.asks) == 0 {
+		return proto.DepthLevel{}, false
+	}
+	return b.asks[0], true
+}
+
+// Mid 中间价
+func (b *Book) Mid() (float64, bool) {
+	bid, ok1 := b.BestBid()
+	ask, ok2 := b.BestAsk()
+	if !ok1 || !ok2 {
+		return 0, false
+	}
+	return (bid.Price + ask.Price) / 2, true
+}
+
+// Depth 返回前n档的拷贝, n<=0 返回全部
+func (b *Book) Depth(n int) *proto.Depth {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+	return &proto.Depth{
+		Symbol: b.symbol,
+		Bids:   copyLevels(b.bids, n),
+		Asks:   copyLevels(b.asks, n),
+		TS:     b.ts,
+	}
+}
+
+// VWAPBuy 买入size数量时吃掉卖盘的成交均价, filled 小于 size 表示深度不足
+func (b *Book) VWAPBuy(size float64) (price, filled float64) {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+	return vwap(b.asks, size)
+}
+
+// VWAPSell 卖出size数量时吃掉买盘的成交均价, filled 小于 size 表示深度不足
+func (b *Book) VWAPSell(size float64) (price, filled float64) {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+	return vwap(b.bids, size)
+}
+
+func vwap(levels []proto.DepthLevel, size float64) (price, filled float64) {
+	var cost float64
+	for _, l := range levels {
+		if filled >= size {
+			break
+		}
+		amount := l.Amount
+		if filled+amount > size {
+			amount = size - filled
+		}
+		cost += amount * l.Price
+		filled += amount
+	}
+	if filled == 0 {
+		return 0, 0
+	}
+	return cost / filled, filled
+}
+
+// before 价格a是否应排在b之前
+func before(a, b float64, desc bool) bool {
+	if desc {
+		return a > b
+	}
+	return a < b
+}
+
+func sortLevels(levels []proto.DepthLevel, desc bool) []proto.DepthLevel {
+	res := make([]proto.DepthLevel, 0, len(levels))
+	for _, l := range levels {
+		if l.Amount > 0 {
+			res = append(res, l)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool { return before(res[i].Price, res[j].Price, desc) })
+	return res
+}
+
+func setLevel(levels []proto.DepthLevel, l proto.DepthLevel, desc bool) []proto.DepthLevel {
+	idx := sort.Search(len(levels), func(i int) bool { return !before(levels[i].Price, l.Price, desc) })
+	found := idx < len(levels) && levels[idx].Price == l.Price
+
+	switch {
+	case l.Amount <= 0 && found:
+		return append(levels[:idx], levels[idx+1:]...)
+	case l.Amount <= 0:
+		return levels
+	case found:
+		levels[idx].Amount = l.Amount
+		return levels
+	default:
+		levels = append(levels, proto.DepthLevel{})
+		copy(levels[idx+1:], levels[idx:])
+		levels[idx] = l
+		return levels
+	}
+}
+
+func copyLevels(levels []proto.DepthLevel, n int) []proto.DepthLevel {
+	if n <= 0 || n > len(levels) {
+		n = len(levels)
+	}
+	res := make([]proto.DepthLevel, n)
+	copy(res, levels[:n])
+	return res
+}
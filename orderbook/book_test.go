@@ -0,0 +1,186 @@
+package orderbook
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gpmn/sheep/proto"
+)
+
+func levels(pa ...float64) []proto.DepthLevel {
+	var res []proto.DepthLevel
+	for i := 0; i+1 < len(pa); i += 2 {
+		res = append(res, proto.DepthLevel{Price: pa[i], Amount: pa[i+1]})
+	}
+	return res
+}
+
+func TestBookApply(t *testing.T) {
+	b := NewBook("btcusdt")
+
+	if err := b.Apply(&Update{FirstSeq: 1, LastSeq: 1, Bids: levels(1, 1)}); err != ErrNotSynced {
+		t.Fatalf("diff before snapshot: got %v, want ErrNotSynced", err)
+	}
+
+	b.Apply(&Update{Snapshot: true, LastSeq: 10, Bids: levels(99, 1, 100, 2, 98, 0), Asks: levels(102, 1, 101, 3)})
+	if bid, _ := b.BestBid(); bid.Price != 100 {
+		t.Fatalf("best bid %v, want 100", bid.Price)
+	}
+	if ask, _ := b.BestAsk(); ask.Price != 101 {
+		t.Fatalf("best ask %v, want 101", ask.Price)
+	}
+	if d := b.Depth(0); len(d.Bids) != 2 {
+		t.Fatalf("zero amount level kept in snapshot: %+v", d.Bids)
+	}
+
+	// 过期数据被忽略
+	if err := b.Apply(&Update{FirstSeq: 5, LastSeq: 10, Bids: levels(100.5, 1)}); err != nil {
+		t.Fatal(err)
+	}
+	if bid, _ := b.BestBid(); bid.Price != 100 {
+		t.Fatalf("stale diff applied")
+	}
+
+	// 跨越快照序号的首条增量
+	if err := b.Apply(&Update{FirstSeq: 9, LastSeq: 12, Bids: levels(100.5, 1, 100, 0), Asks: levels(101, 0)}); err != nil {
+		t.Fatal(err)
+	}
+	d := b.Depth(0)
+	if len(d.Bids) != 2 || d.Bids[0].Price != 100.5 || d.Bids[1].Price != 99 {
+		t.Fatalf("bids %+v", d.Bids)
+	}
+	if len(d.Asks) != 1 || d.Asks[0].Price != 102 {
+		t.Fatalf("asks %+v", d.Asks)
+	}
+
+	if err := b.Apply(&Update{FirstSeq: 14, LastSeq: 15, Asks: levels(101.5, 1)}); err != ErrGap {
+		t.Fatalf("got %v, want ErrGap", err)
+	}
+	if b.Seq() != 12 {
+		t.Fatalf("seq %d changed by rejected diff", b.Seq())
+	}
+}
+
+func TestBookVWAP(t *testing.T) {
+	b := NewBook("btcusdt")
+	b.Apply(&Update{Snapshot: true, Bids: levels(100, 1, 99, 1), Asks: levels(101, 1, 103, 2)})
+
+	price, filled := b.VWAPBuy(2)
+	if filled != 2 || price != 102 {
+		t.Fatalf("VWAPBuy(2) = %v, %v", price, filled)
+	}
+	price, filled = b.VWAPSell(5)
+	if filled != 2 || price != 99.5 {
+		t.Fatalf("VWAPSell(5) = %v, %v", price, filled)
+	}
+}
+
+func TestSyncerResync(t *testing.T) {
+	var calls int
+	var mutex sync.Mutex
+	snapshotSeq := int64(20)
+	snapshot := func() (*Update, error) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		calls++
+		if calls == 1 {
+			return nil, errors.New("unavailable")
+		}
+		return &Update{LastSeq: snapshotSeq, Bids: levels(100, 1), Asks: levels(101, 1)}, nil
+	}
+
+	b := NewBook("btcusdt")
+	changed := make(chan struct{}, 10)
+	b.SetChangeListener(func(*Book) {
+		select {
+		case changed <- struct{}{}:
+		default:
+		}
+	})
+
+	s := NewSyncer(b, snapshot)
+	s.RetryInterval = time.Millisecond
+	s.Start()
+	s.Feed(&Update{FirstSeq: 15, LastSeq: 18, Bids: levels(90, 1)})
+	s.Feed(&Update{FirstSeq: 19, LastSeq: 21, Bids: levels(100.5, 2)})
+
+	deadline := time.After(time.Second)
+	for b.Seq() != 21 {
+		select {
+		case <-changed:
+		case <-deadline:
+			t.Fatalf("not synced, seq %d", b.Seq())
+		}
+	}
+	if bid, _ := b.BestBid(); bid.Price != 100.5 {
+		t.Fatalf("best bid %v", bid.Price)
+	}
+
+	// 序号断档触发重新同步, 快照追上缓存的增量数据后完成
+	mutex.Lock()
+	snapshotSeq = 32
+	mutex.Unlock()
+	s.Feed(&Update{FirstSeq: 30, LastSeq: 31, Bids: levels(100.7, 1)})
+	s.Feed(&Update{FirstSeq: 32, LastSeq: 33, Bids: levels(100.9, 1)})
+	for b.Seq() != 33 {
+		select {
+		case <-changed:
+		case <-deadline:
+			t.Fatalf("not resynced, seq %d", b.Seq())
+		}
+	}
+	if bid, _ := b.BestBid(); bid.Price != 100.9 {
+		t.Fatalf("best bid %v", bid.Price)
+	}
+}
+
+func TestSyncerGapWithoutSnapshot(t *testing.T) {
+	b := NewBook("btcusdt")
+	s := NewSyncer(b, nil)
+	if err := s.Feed(&Update{FirstSeq: 1, LastSeq: 10, Bids: levels(100, 1)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Feed(&Update{FirstSeq: 12, LastSeq: 13, Bids: levels(99, 1)}); err != ErrGap {
+		t.Fatalf("gap err %v", err)
+	}
+	if b.Synced() {
+		t.Fatal("book still synced after gap")
+	}
+
+	// 断档后下一条推送重新作为全量数据
+	if err := s.Feed(&Update{FirstSeq: 20, LastSeq: 21, Bids: levels(98, 1)}); err != nil {
+		t.Fatal(err)
+	}
+	if bid, _ := b.BestBid(); bid.Price != 98 || b.Seq() != 21 {
+		t.Fatalf("best bid %v seq %d", bid.Price, b.Seq())
+	}
+}
+
+func TestSyncerStop(t *testing.T) {
+	var mutex sync.Mutex
+	var calls int
+	snapshot := func() (*Update, error) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		calls++
+		return nil, errors.New("unavailable")
+	}
+	s := NewSyncer(NewBook("btcusdt"), snapshot)
+	s.RetryInterval = time.Millisecond
+	s.Start()
+	time.Sleep(10 * time.Millisecond)
+	s.Stop()
+	time.Sleep(10 * time.Millisecond)
+
+	mutex.Lock()
+	n := calls
+	mutex.Unlock()
+	time.Sleep(20 * time.Millisecond)
+	mutex.Lock()
+	defer mutex.Unlock()
+	if n == 0 || calls != n {
+		t.Fatalf("snapshot calls %d then %d", n, calls)
+	}
+}
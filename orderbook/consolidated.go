@@ -0,0 +1,194 @@
+package orderbook
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Venue : 参与合并的交易所订单簿
+type Venue struct {
+	Exchange string        // 交易所标识, 如 consts.ExchangeTypeHuobi
+	Book     *Book         // 该交易所同一交易对的本地订单簿
+	TakerFee float64       // 吃单手续费率, 0.002 表示 0.2%
+	MaxAge   time.Duration // 超过该时间未更新视为过期, 不参与合并; 0 表示不检查
+}
+
+// VenueLevel : 带来源交易所的档位
+type VenueLevel struct {
+	Exchange string  `json:"exchange"`
+	Price    float64 `json:"price"`     // 含手续费价格, 买入为 RawPrice*(1+fee), 卖出为 RawPrice*(1-fee)
+	RawPrice float64 `json:"raw_price"` // 交易所挂单价格
+	Amount   float64 `json:"amount"`
+}
+
+// VenueStatus : 交易所订单簿状态
+type VenueStatus struct {
+	Exchange string        `json:"exchange"`
+	Synced   bool          `json:"synced"`
+	Age      time.Duration `json:"age"` // 距离最后一次更新的时间
+	Stale    bool          `json:"stale"`
+}
+
+// Execution : 按合并订单簿吃单的结果
+type Execution struct {
+	Size     float64      `json:"size"`      // 请求数量
+	Filled   float64      `json:"filled"`    // 可成交数量, 小于 Size 表示深度不足
+	AvgPrice float64      `json:"avg_price"` // 含手续费成交均价
+	Fills    []VenueLevel `json:"fills"`     // 各交易所各档位的成交明细
+}
+
+// Consolidated : 多个交易所同一交易对的合并订单簿
+type Consolidated struct {
+	venues map[string]*Venue
+	mutex  sync.RWMutex
+}
+
+// NewConsolidated 创建合并订单簿
+func NewConsolidated(venues ...Venue) *Consolidated {
+	c := &Consolidated{venues: make(map[string]*Venue)}
+	for _, v := range venues {
+		c.AddVenue(v)
+	}
+	return c
+}
+
+// AddVenue 添加或替换交易所
+func (c *Consolidated) AddVenue(v Venue) {
+	c.mutex.Lock()
+	c.venues[v.Exchange] = &v
+	c.mutex.Unlock()
+}
+
+// RemoveVenue 移除交易所
+func (c *Consolidated) RemoveVenue(exchange string) {
+	c.mutex.Lock()
+	delete(c.venues, exchange)
+	c.mutex.Unlock()
+}
+
+// Status 各交易所订单簿状态
+func (c *Consolidated) Status() []VenueStatus {
+	now := time.Now()
+	var res []VenueStatus
+	for _, v := range c.list() {
+		res = append(res, status(v, now))
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Exchange < res[j].Exchange })
+	return res
+}
+
+func status(v *Venue, now time.Time) VenueStatus {
+	s := VenueStatus{Exchange: v.Exchange, Synced: v.Book.Synced()}
+	if updated := v.Book.UpdatedAt(); !updated.IsZero() {
+		s.Age = now.Sub(updated)
+	}
+	s.Stale = !s.Synced || (v.MaxAge > 0 && s.Age > v.MaxAge)
+	return s
+}
+
+func (c *Consolidated) list() []*Venue {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	res := make([]*Venue, 0, len(c.venues))
+	for _, v := range c.venues {
+		res = append(res, v)
+	}
+	return res
+}
+
+// Bids 合并后的买盘, 按含手续费价格从高到低, n<=0 返回全部
+func (c *Consolidated) Bids(n int) []VenueLevel {
+	return truncate(c.merge(false), n)
+}
+
+// Asks 合并后的卖盘, 按含手续费价格从低到高, n<=0 返回全部
+func (c *Consolidated) Asks(n int) []VenueLevel {
+	return truncate(c.merge(true), n)
+}
+
+// BestBid 含手续费的最优卖出价
+func (c *Consolidated) BestBid() (VenueLevel, bool) {
+	levels := c.Bids(1)
+	if len(levels) == 0 {
+		return VenueLevel{}, false
+	}
+	return levels[0], true
+}
+
+// BestAsk 含手续费的最优买入价
+func (c *Consolidated) BestAsk() (VenueLevel, bool) {
+	levels := c.Asks(1)
+	if len(levels) == 0 {
+		return VenueLevel{}, false
+	}
+	return levels[0], true
+}
+
+// ExecutableBuy 在所有未过期交易所买入size数量的最优执行结果
+func (c *Consolidated) ExecutableBuy(size float64) *Execution {
+	return execute(c.merge(true), size)
+}
+
+// ExecutableSell 在所有未过期交易所卖出size数量的最优执行结果
+func (c *Consolidated) ExecutableSell(size float64) *Execution {
+	return execute(c.merge(false), size)
+}
+
+// merge 合并所有未过期交易所的档位, buy 为 true 时合并卖盘(买入方向)
+func (c *Consolidated) merge(buy bool) []VenueLevel {
+	now := time.Now()
+	var res []VenueLevel
+	for _, v := range c.list() {
+		if status(v, now).Stale {
+			continue
+		}
+		depth := v.Book.Depth(0)
+		levels, factor := depth.Bids, 1-v.TakerFee
+		if buy {
+			levels, factor = depth.Asks, 1+v.TakerFee
+		}
+		for _, l := range levels {
+			res = append(res, VenueLevel{
+				Exchange: v.Exchange,
+				Price:    l.Price * factor,
+				RawPrice: l.Price,
+				Amount:   l.Amount,
+			})
+		}
+	}
+	sort.SliceStable(res, func(i, j int) bool {
+		if res[i].Price == res[j].Price {
+			return res[i].Exchange < res[j].Exchange
+		}
+		return before(res[i].Price, res[j].Price, !buy)
+	})
+	return res
+}
+
+func execute(levels []VenueLevel, size float64) *Execution {
+	e := &Execution{Size: size}
+	var cost float64
+	for _, l := range levels {
+		if e.Filled >= size {
+			break
+		}
+		if e.Filled+l.Amount > size {
+			l.Amount = size - e.Filled
+		}
+		cost += l.Amount * l.Price
+		e.Filled += l.Amount
+		e.Fills = append(e.Fills, l)
+	}
+	if e.Filled > 0 {
+		e.AvgPrice = cost / e.Filled
+	}
+	return e
+}
+
+func truncate(levels []VenueLevel, n int) []VenueLevel {
+	if n > 0 && n < len(levels) {
+		return levels[:n]
+	}
+	return levels
+}
@@ -0,0 +1,47 @@
+package orderbook
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestConsolidatedExecutable(t *testing.T) {
+	huobi := NewBook("btcusdt")
+	huobi.Apply(&Update{Snapshot: true, Bids: levels(100, 1), Asks: levels(101, 1, 102, 5)})
+	okex := NewBook("btc_usdt")
+	okex.Apply(&Update{Snapshot: true, Bids: levels(100.1, 2), Asks: levels(101.05, 1)})
+	stale := NewBook("btcusdt")
+	stale.Apply(&Update{Snapshot: true, Bids: levels(200, 1), Asks: levels(1, 1)})
+
+	c := NewConsolidated(
+		Venue{Exchange: "huobi", Book: huobi, TakerFee: 0.002},
+		Venue{Exchange: "okex", Book: okex, TakerFee: 0.0},
+		Venue{Exchange: "stale", Book: stale, MaxAge: time.Nanosecond},
+	)
+	time.Sleep(time.Millisecond)
+
+	ask, ok := c.BestAsk()
+	if !ok || ask.Exchange != "okex" {
+		t.Fatalf("best ask %+v", ask)
+	}
+	bid, _ := c.BestBid()
+	if bid.Exchange != "okex" || bid.Price != 100.1 {
+		t.Fatalf("best bid %+v", bid)
+	}
+
+	e := c.ExecutableBuy(3)
+	if e.Filled != 3 || len(e.Fills) != 3 {
+		t.Fatalf("execution %+v", e)
+	}
+	want := (101.05 + 101*1.002 + 102*1.002) / 3
+	if math.Abs(e.AvgPrice-want) > 1e-9 {
+		t.Fatalf("avg price %v, want %v", e.AvgPrice, want)
+	}
+
+	for _, s := range c.Status() {
+		if s.Stale != (s.Exchange == "stale") {
+			t.Fatalf("status %+v", s)
+		}
+	}
+}
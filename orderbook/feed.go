@@ -0,0 +1,133 @@
+package orderbook
+
+import (
+	"strings"
+
+	"github.com/gpmn/sheep/binance"
+	"github.com/gpmn/sheep/fcoin"
+	"github.com/gpmn/sheep/huobi"
+	"github.com/gpmn/sheep/okex"
+	"github.com/gpmn/sheep/proto"
+)
+
+// 各交易所的深度推送转换为 Update, 以及可直接设置给各交易所的深度监听器.
+// 监听器参数 syncers 以推送中的 symbol 为 key, 设置监听器后不应再修改.
+
+// HuobiUpdate : market.<symbol>.depth.step0 每次推送都是全量数据
+func HuobiUpdate(md *huobi.MarketDepth) *Update {
+	u := &Update{Snapshot: true, TS: md.Tick.TS}
+	for _, l := range md.Tick.Bids {
+		if len(l) >= 2 {
+			u.Bids = append(u.Bids, proto.DepthLevel{Price: l[0], Amount: l[1]})
+		}
+	}
+	for _, l := range md.Tick.Asks {
+		if len(l) >= 2 {
+			u.Asks = append(u.Asks, proto.DepthLevel{Price: l[0], Amount: l[1]})
+		}
+	}
+	return u
+}
+
+// HuobiListener 返回用于 Huobi.SetDepthlListener 的监听器
+func HuobiListener(syncers map[string]*Syncer) huobi.DepthlListener {
+	return func(symbol string, depth *huobi.MarketDepth) {
+		if s, ok := syncers[symbol]; ok {
+			s.Feed(HuobiUpdate(depth))
+		}
+	}
+}
+
+// BinanceUpdate : <symbol>@depth 增量推送, 序号范围为 [U, u]
+func BinanceUpdate(ev *binance.WsDepthEvent) *Update {
+	return &Update{
+		FirstSeq: ev.FirstUpdateId,
+		LastSeq:  ev.FinalUpdateId,
+		Bids:     binanceLevels(ev.Bids),
+		Asks:     binanceLevels(ev.Asks),
+		TS:       ev.EventTime,
+	}
+}
+
+// BinanceSnapshot 通过 GET /api/v1/depth 获取全量数据, 序号为 lastUpdateId
+func BinanceSnapshot(b *binance.Binance, symbol string, limit int64) SnapshotFunc {
+	return func() (*Update, error) {
+		ob, err := b.GetOrderBook(binance.OrderBookQuery{Symbol: strings.ToUpper(symbol), Limit: limit})
+		if err != nil {
+			return nil, err
+		}
+		return &Update{
+			Snapshot: true,
+			LastSeq:  ob.LastUpdatedId,
+			Bids:     binanceLevels(ob.Bids),
+			Asks:     binanceLevels(ob.Asks),
+		}, nil
+	}
+}
+
+// BinanceListener 返回用于 Binance.SetDepthListener 的监听器
+func BinanceListener(syncers map[string]*Syncer) binance.DepthListener {
+	return func(symbol string, depth *binance.WsDepthEvent) {
+		if s, ok := syncers[symbol]; ok {
+			s.Feed(BinanceUpdate(depth))
+		}
+	}
+}
+
+func binanceLevels(orders []binance.Order) []proto.DepthLevel {
+	levels := make([]proto.DepthLevel, 0, len(orders))
+	for _, o := range orders {
+		levels = append(levels, proto.DepthLevel{Price: o.Price, Amount: o.Quantity})
+	}
+	return levels
+}
+
+// OKEXUpdate : ok_sub_spot_X_depth 为增量推送(首条为全量), ok_sub_spot_X_depth_N 为全量推送, 均无序号
+func OKEXUpdate(md *okex.MarketDepth) *Update {
+	u := &Update{Snapshot: md.Level > 0, TS: md.Timestamp}
+	for _, l := range md.Bids {
+		u.Bids = append(u.Bids, proto.DepthLevel{Price: l.Price, Amount: l.Amount})
+	}
+	for _, l := range md.Asks {
+		u.Asks = append(u.Asks, proto.DepthLevel{Price: l.Price, Amount: l.Amount})
+	}
+	return u
+}
+
+// OKEXListener 返回用于 OKEX.SetDepthListener 的监听器
+func OKEXListener(syncers map[string]*Syncer) okex.DepthListener {
+	return func(symbol string, depth *okex.MarketDepth) {
+		if s, ok := syncers[symbol]; ok {
+			s.Feed(OKEXUpdate(depth))
+		}
+	}
+}
+
+// FCoinUpdate : depth.<level>.<symbol> 每次推送都是全量数据, 带递增序号
+func FCoinUpdate(du *fcoin.DepthUpdate) *Update {
+	return &Update{
+		Snapshot: true,
+		LastSeq:  du.Seq,
+		Bids:     fcoinLevels(du.Bids),
+		Asks:     fcoinLevels(du.Asks),
+		TS:       du.TS,
+	}
+}
+
+// FCoinListener 返回用于 FCoin.SetDepthListener 的监听器
+func FCoinListener(syncers map[string]*Syncer) fcoin.DepthListener {
+	return func(symbol string, depth *fcoin.DepthUpdate) {
+		if s, ok := syncers[symbol]; ok {
+			s.Feed(FCoinUpdate(depth))
+		}
+	}
+}
+
+// fcoinLevels 转换 [price, amount, price, amount ...] 格式
+func fcoinLevels(flat []float64) []proto.DepthLevel {
+	levels := make([]proto.DepthLevel, 0, len(flat)/2)
+	for i := 0; i+1 < len(flat); i += 2 {
+		levels = append(levels, proto.DepthLevel{Price: flat[i], Amount: flat[i+1]})
+	}
+	return levels
+}
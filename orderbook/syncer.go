@@ -0,0 +1,183 @@
+package orderbook
+
+import (
+	"sync"
+	"time"
+
+	"github.com/gpmn/sheep/logger"
+)
+
+// SnapshotFunc 通过REST接口获取全量数据
+type SnapshotFunc func() (*Update, error)
+
+// Syncer : 维护订单簿与推送数据同步
+// 推送数据通过 Feed 送入; 发现序号不连续时自动重新加载全量数据,
+// 期间收到的增量数据先缓存, 加载完成后按序应用. 不再使用时调用 Stop 结束重新加载.
+type Syncer struct {
+	book     *Book
+	snapshot SnapshotFunc
+
+	buffer    []*Update
+	resyncing bool
+	stopped   bool
+	stop      chan struct{}
+
+	// 加载全量数据失败后的重试间隔, 默认1秒
+	RetryInterval time.Duration
+	// 缓存的增量数据上限, 超过后丢弃最早的数据, 默认1000
+	MaxBuffer int
+	// 日志, 为nil时不输出
+	Logger logger.Logger
+
+	mutex sync.Mutex
+}
+
+// NewSyncer 创建Syncer
+// snapshot 为 nil 时用于全量推送 (如 huobi, fcoin), 或首条推送即为全量数据的增量推送 (如 okex)
+func NewSyncer(book *Book, snapshot SnapshotFunc) *Syncer {
+	return &Syncer{
+		book:          book,
+		snapshot:      snapshot,
+		RetryInterval: time.Second,
+		MaxBuffer:     1000,
+		stop:          make(chan struct{}),
+	}
+}
+
+func (s *Syncer) log() logger.Logger {
+	return logger.OrNop(s.Logger)
+}
+
+// Book 订单簿
+func (s *Syncer) Book() *Book {
+	return s.book
+}
+
+// Start 加载全量数据; 应在订阅推送之后调用, 这样两者之间的增量数据不会丢失
+func (s *Syncer) Start() {
+	if s.snapshot == nil {
+		return
+	}
+	s.mutex.Lock()
+	if s.resyncing || s.stopped {
+		s.mutex.Unlock()
+		return
+	}
+	s.resyncing = true
+	s.mutex.Unlock()
+
+	go s.resync()
+}
+
+// Stop 结束正在进行的重新加载, 之后序号断档不再重新加载全量数据
+func (s *Syncer) Stop() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if !s.stopped {
+		s.stopped = true
+		close(s.stop)
+	}
+}
+
+// Feed 送入一条推送数据, 返回应用时的错误.
+// 序号断档时返回 ErrGap: 有 snapshot 时已在后台重新加载全量数据;
+// 没有时清空订单簿, 下一条推送作为全量数据重新开始, 调用者可以据此重新订阅
+func (s *Syncer) Feed(u *Update) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.resyncing {
+		s.push(u)
+		return nil
+	}
+
+	// 没有REST全量数据时, 首条推送即为全量数据
+	if s.snapshot == nil && !u.Snapshot && !s.book.Synced() {
+		snapshot := *u
+		snapshot.Snapshot = true
+		u = &snapshot
+	}
+
+	err := s.book.Apply(u)
+	if err == nil {
+		return nil
+	}
+	s.book.Reset()
+	if s.snapshot == nil || s.stopped {
+		s.log().Warn("Syncer.Feed - apply failed, reset", logger.F("symbol", s.book.Symbol()), logger.Err(err))
+		return err
+	}
+
+	s.log().Warn("Syncer.Feed - apply failed, resync", logger.F("symbol", s.book.Symbol()), logger.Err(err))
+	s.buffer = s.buffer[:0]
+	s.push(u)
+	s.resyncing = true
+	go s.resync()
+	return err
+}
+
+func (s *Syncer) push(u *Update) {
+	if len(s.buffer) >= s.MaxBuffer {
+		s.buffer = s.buffer[1:]
+	}
+	s.buffer = append(s.buffer, u)
+}
+
+// wait 等待重试间隔, Stop 后结束重新加载并返回 false
+func (s *Syncer) wait(interval time.Duration) bool {
+	select {
+	case <-s.stop:
+	case <-time.After(interval):
+		select {
+		case <-s.stop:
+		default:
+			return true
+		}
+	}
+	s.mutex.Lock()
+	s.resyncing = false
+	s.buffer = s.buffer[:0]
+	s.mutex.Unlock()
+	return false
+}
+
+// resync 加载全量数据并应用缓存的增量数据, 直到成功或 Stop
+func (s *Syncer) resync() {
+	for {
+		if !s.wait(0) {
+			return
+		}
+		snapshot, err := s.snapshot()
+		if err != nil {
+			s.log().Warn("Syncer.resync - snapshot failed", logger.F("symbol", s.book.Symbol()), logger.Err(err))
+			if !s.wait(s.RetryInterval) {
+				return
+			}
+			continue
+		}
+		snapshot.Snapshot = true
+
+		s.mutex.Lock()
+		s.book.Reset()
+		err = s.book.Apply(snapshot)
+		for _, u := range s.buffer {
+			if err != nil {
+				break
+			}
+			err = s.book.Apply(u)
+		}
+		if err == nil {
+			s.buffer = s.buffer[:0]
+			s.resyncing = false
+			s.mutex.Unlock()
+			return
+		}
+		s.mutex.Unlock()
+
+		// 全量数据早于缓存的增量数据, 重新加载
+		s.log().Warn("Syncer.resync - apply failed, retry", logger.F("symbol", s.book.Symbol()), logger.Err(err))
+		if !s.wait(s.RetryInterval) {
+			return
+		}
+	}
+}
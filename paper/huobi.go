@@ -0,0 +1,162 @@
+package paper
+
+import (
+	"sync"
+
+	"github.com/gpmn/sheep/huobi"
+	"github.com/gpmn/sheep/sim"
+)
+
+// huobiFeed : 火币公开行情连接, 所有火币模拟盘共用一个连接, 按交易对分发给订阅的模拟交易所.
+// 深度为 step0 快照, 成交为 trade.detail. 交易对的订阅保留到连接断开
+type huobiFeed struct {
+	h         *huobi.Huobi
+	exchanges map[string]map[*sim.Exchange]bool // 交易对 -> 订阅的模拟交易所
+	users     map[*sim.Exchange]bool            // 使用连接的模拟交易所, 全部退出时断开连接
+
+	// subscribe 串行化连接, 订阅和断开; mutex 只保护上面的字段,
+	// 订阅时不能持有 mutex, 否则等待订阅结果时推送的回调无法分发
+	subscribe sync.Mutex
+	mutex     sync.Mutex
+}
+
+// sharedHuobi 火币模拟盘共用的行情连接
+var sharedHuobi = &huobiFeed{}
+
+// targets 订阅了交易对的模拟交易所
+func (f *huobiFeed) targets(symbol string) []*sim.Exchange {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	var res []*sim.Exchange
+	for ex := range f.exchanges[symbol] {
+		res = append(res, ex)
+	}
+	return res
+}
+
+// open 第一次使用时建立连接
+func (f *huobiFeed) open() (*huobi.Huobi, error) {
+	f.mutex.Lock()
+	h := f.h
+	f.mutex.Unlock()
+	if h != nil {
+		return h, nil
+	}
+
+	h = new(huobi.Huobi)
+	h.SetDepthlListener(func(symbol string, depth *huobi.MarketDepth) {
+		d := huobi.TransDepth(symbol, depth)
+		for _, ex := range f.targets(symbol) {
+			ex.OnDepth(symbol, &d)
+		}
+	})
+	h.SetDetailListener(func(symbol string, detail *huobi.MarketTradeDetail) {
+		targets := f.targets(symbol)
+		for _, t := range detail.Tick.Data {
+			trade := huobi.TransTrade(symbol, t)
+			for _, ex := range targets {
+				ex.OnTrade(symbol, &trade)
+			}
+		}
+	})
+	if err := h.OpenWebsocket(); err != nil {
+		return nil, err
+	}
+
+	f.mutex.Lock()
+	f.h = h
+	f.exchanges = make(map[string]map[*sim.Exchange]bool)
+	f.users = make(map[*sim.Exchange]bool)
+	f.mutex.Unlock()
+	return h, nil
+}
+
+// join 模拟交易所订阅交易对, 只有连接上还没有订阅的交易对才发送订阅
+func (f *huobiFeed) join(ex *sim.Exchange, symbols ...string) error {
+	f.subscribe.Lock()
+	defer f.subscribe.Unlock()
+
+	h, err := f.open()
+	if err != nil {
+		return err
+	}
+
+	// 先登记再订阅, 以免错过订阅后的第一笔推送
+	var added []string
+	f.mutex.Lock()
+	f.users[ex] = true
+	for _, symbol := range symbols {
+		if f.exchanges[symbol] == nil {
+			f.exchanges[symbol] = make(map[*sim.Exchange]bool)
+			added = append(added, symbol)
+		}
+		f.exchanges[symbol][ex] = true
+	}
+	f.mutex.Unlock()
+
+	if len(added) == 0 {
+		return nil
+	}
+	err = h.SubscribeDepth(added...)
+	if err == nil {
+		err = h.SubscribeDetail(added...)
+	}
+	if err != nil {
+		// 下次重新订阅
+		f.mutex.Lock()
+		for _, symbol := range added {
+			delete(f.exchanges, symbol)
+		}
+		f.mutex.Unlock()
+	}
+	return err
+}
+
+// leave 模拟交易所退出, 最后一个退出时断开连接
+func (f *huobiFeed) leave(ex *sim.Exchange) error {
+	f.subscribe.Lock()
+	defer f.subscribe.Unlock()
+
+	f.mutex.Lock()
+	h := f.h
+	if h == nil || !f.users[ex] {
+		f.mutex.Unlock()
+		return nil
+	}
+	delete(f.users, ex)
+	for _, exchanges := range f.exchanges {
+		delete(exchanges, ex)
+	}
+	if len(f.users) > 0 {
+		f.mutex.Unlock()
+		return nil
+	}
+	f.h, f.exchanges, f.users = nil, nil, nil
+	f.mutex.Unlock()
+	return h.CloseWebsocket()
+}
+
+// huobiSource 火币行情源, 使用共用的连接
+type huobiSource struct {
+	feed  *huobiFeed
+	ex    *sim.Exchange // 订阅过的模拟交易所, Close 时退出
+	mutex sync.Mutex
+}
+
+func (s *huobiSource) Subscribe(ex *sim.Exchange, symbols ...string) error {
+	s.mutex.Lock()
+	s.ex = ex
+	s.mutex.Unlock()
+	return s.feed.join(ex, symbols...)
+}
+
+func (s *huobiSource) Close() error {
+	s.mutex.Lock()
+	ex := s.ex
+	s.ex = nil
+	s.mutex.Unlock()
+	if ex == nil {
+		return nil
+	}
+	return s.feed.leave(ex)
+}
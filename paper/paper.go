@@ -0,0 +1,191 @@
+package paper
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gpmn/sheep/consts"
+	"github.com/gpmn/sheep/proto"
+	"github.com/gpmn/sheep/sim"
+)
+
+// Prefix sheep.NewExchange 中模拟盘的类型前缀, 如 paper:huobi
+const Prefix = "paper:"
+
+// DefaultBalances 通过 sheep.NewExchange 创建时的初始余额
+var DefaultBalances = map[string]float64{"usdt": 10000}
+
+// DefaultConfig 通过 sheep.NewExchange 创建时的撮合参数
+var DefaultConfig = sim.Config{MakerFee: 0.002, TakerFee: 0.002, Slippage: 0.001}
+
+// ErrUnsupportedSource 不支持的行情源
+var ErrUnsupportedSource = errors.New("unsupported paper trading source")
+
+// ErrWarmupTimeout 交易对在 WarmupTimeout 内没有收到深度
+var ErrWarmupTimeout = errors.New("paper trading warmup timeout")
+
+// Source : 实时行情源, 把订阅的交易对的深度和成交输入模拟交易所
+type Source interface {
+	Subscribe(ex *sim.Exchange, symbols ...string) error
+	Close() error
+}
+
+// Exchange : 模拟盘, 使用实时行情在本地撮合, 实现 sheep.ExchageI 和 sheep.MarketDataI
+// 下单或订阅时自动订阅对应交易对的行情
+type Exchange struct {
+	*sim.Exchange
+
+	// 新订阅交易对后等待第一笔深度的时间
+	WarmupTimeout time.Duration
+
+	source     Source
+	subscribed map[string]chan struct{}
+	mutex      sync.Mutex
+}
+
+// New 创建模拟盘, name 为行情源名称, 如 consts.ExchangeTypeHuobi
+func New(name string, config sim.Config, balances map[string]float64) (*Exchange, error) {
+	open, ok := sources[name]
+	if !ok {
+		return nil, ErrUnsupportedSource
+	}
+	config.Name = Prefix + name
+	return NewWithSource(open(), config, balances), nil
+}
+
+// sources New 支持的行情源
+var sources = map[string]func() Source{
+	consts.ExchangeTypeHuobi: func() Source { return &huobiSource{feed: sharedHuobi} },
+}
+
+// accounts Account 创建的模拟盘, 键为行情源和账户
+var accounts = struct {
+	m     map[string]*Exchange
+	mutex sync.Mutex
+}{m: make(map[string]*Exchange)}
+
+// Account 指定账户的模拟盘, 用于 sheep.WithAccount. 第一次调用时按 DefaultConfig 和 DefaultBalances 创建,
+// 之后同一行情源和账户返回同一个实例, 余额和订单在多次调用之间共用; 不同账户的余额和订单互相独立
+func Account(name, accountType string, accountID int64) (*Exchange, error) {
+	if !Supported(name) {
+		return nil, ErrUnsupportedSource
+	}
+	key := fmt.Sprintf("%s/%s/%d", name, accountType, accountID)
+	accounts.mutex.Lock()
+	defer accounts.mutex.Unlock()
+	if p, ok := accounts.m[key]; ok {
+		return p, nil
+	}
+	p, err := New(name, DefaultConfig, DefaultBalances)
+	if err != nil {
+		return nil, err
+	}
+	accounts.m[key] = p
+	return p, nil
+}
+
+// Supported New 是否支持该行情源
+func Supported(name string) bool {
+	_, ok := sources[name]
+	return ok
+}
+
+// NewWithSource 使用自定义行情源创建模拟盘
+func NewWithSource(source Source, config sim.Config, balances map[string]float64) *Exchange {
+	p := &Exchange{
+		Exchange:      sim.NewExchange(config, balances),
+		WarmupTimeout: 5 * time.Second,
+		source:        source,
+		subscribed:    make(map[string]chan struct{}),
+	}
+	return p
+}
+
+// ensure 订阅交易对行情, 新订阅时等待第一笔深度, 以便市价单可以成交.
+// 超时或交易对仍没有价格时返回 ErrWarmupTimeout, 订阅保留, 之后收到深度即可使用
+func (p *Exchange) ensure(symbols ...string) error {
+	var waits []chan struct{}
+	var names []string
+	for _, symbol := range symbols {
+		symbol = strings.ToLower(symbol)
+		p.mutex.Lock()
+		ready, ok := p.subscribed[symbol]
+		if !ok {
+			ready = make(chan struct{})
+			p.subscribed[symbol] = ready
+		}
+		p.mutex.Unlock()
+
+		if !ok {
+			if err := p.source.Subscribe(p.Exchange, symbol); err != nil {
+				p.mutex.Lock()
+				delete(p.subscribed, symbol)
+				p.mutex.Unlock()
+				return err
+			}
+			go p.warmup(symbol, ready)
+		}
+		waits = append(waits, ready)
+		names = append(names, symbol)
+	}
+
+	timeout := time.After(p.WarmupTimeout)
+	for i, ready := range waits {
+		select {
+		case <-ready:
+		case <-timeout:
+			return ErrWarmupTimeout
+		}
+		if _, ok := p.LastPrice(names[i]); !ok {
+			return ErrWarmupTimeout
+		}
+	}
+	return nil
+}
+
+// warmup 等待交易对有价格后关闭ready
+func (p *Exchange) warmup(symbol string, ready chan struct{}) {
+	deadline := time.Now().Add(p.WarmupTimeout)
+	for time.Now().Before(deadline) {
+		if _, ok := p.LastPrice(symbol); ok {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	close(ready)
+}
+
+// OrderPlace 下单, 首次交易的交易对会先订阅行情
+func (p *Exchange) OrderPlace(params *proto.OrderPlaceParams) (*proto.OrderPlaceReturn, error) {
+	if err := p.ensure(params.BaseCurrencyID + params.QuoteCurrencyID); err != nil {
+		return nil, err
+	}
+	return p.Exchange.OrderPlace(params)
+}
+
+// SubscribeDepth 订阅深度
+func (p *Exchange) SubscribeDepth(symbols ...string) error {
+	if err := p.ensure(symbols...); err != nil {
+		return err
+	}
+	return p.Exchange.SubscribeDepth(symbols...)
+}
+
+// SubscribeTrade 订阅成交
+func (p *Exchange) SubscribeTrade(symbols ...string) error {
+	if err := p.ensure(symbols...); err != nil {
+		return err
+	}
+	return p.Exchange.SubscribeTrade(symbols...)
+}
+
+// Close 关闭行情连接, 之后下单或订阅时重新订阅行情
+func (p *Exchange) Close() error {
+	p.mutex.Lock()
+	p.subscribed = make(map[string]chan struct{})
+	p.mutex.Unlock()
+	return p.source.Close()
+}
@@ -0,0 +1,157 @@
+package paper
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gpmn/sheep/consts"
+	"github.com/gpmn/sheep/huobi"
+	"github.com/gpmn/sheep/mockserver"
+	"github.com/gpmn/sheep/proto"
+	"github.com/gpmn/sheep/sim"
+)
+
+type fakeSource struct {
+	subscribed []string
+}
+
+func (s *fakeSource) Subscribe(ex *sim.Exchange, symbols ...string) error {
+	for _, symbol := range symbols {
+		s.subscribed = append(s.subscribed, symbol)
+		ex.OnDepth(symbol, &proto.Depth{
+			Bids: []proto.DepthLevel{{Price: 99, Amount: 1}},
+			Asks: []proto.DepthLevel{{Price: 100, Amount: 1}},
+		})
+	}
+	return nil
+}
+
+func (s *fakeSource) Close() error {
+	return nil
+}
+
+func TestOrderSubscribes(t *testing.T) {
+	source := &fakeSource{}
+	p := NewWithSource(source, sim.Config{}, map[string]float64{"usdt": 100})
+
+	res, err := p.OrderPlace(&proto.OrderPlaceParams{Amount: 50, BaseCurrencyID: "btc", QuoteCurrencyID: "usdt", Type: proto.OrderPlaceTypeBuyMarket})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.SubscribeDepth("btcusdt")
+	if len(source.subscribed) != 1 || source.subscribed[0] != "btcusdt" {
+		t.Fatalf("subscribed %v", source.subscribed)
+	}
+	o, _ := p.GetOrderInfo(&proto.OrderInfoParams{OrderID: res.OrderID})
+	if o.State != proto.OrderStateFilled || o.FieldAmount != 0.5 {
+		t.Fatalf("order %+v", o)
+	}
+}
+
+func TestNewUnsupported(t *testing.T) {
+	if _, err := New("nope", sim.Config{}, nil); err != ErrUnsupportedSource {
+		t.Fatalf("got %v", err)
+	}
+}
+
+// silentSource 订阅后没有任何推送
+type silentSource struct{}
+
+func (silentSource) Subscribe(ex *sim.Exchange, symbols ...string) error { return nil }
+func (silentSource) Close() error                                        { return nil }
+
+func TestWarmupTimeout(t *testing.T) {
+	p := NewWithSource(silentSource{}, sim.Config{}, map[string]float64{"usdt": 100})
+	p.WarmupTimeout = 20 * time.Millisecond
+
+	params := &proto.OrderPlaceParams{Amount: 50, BaseCurrencyID: "btc", QuoteCurrencyID: "usdt", Type: proto.OrderPlaceTypeBuyMarket}
+	if _, err := p.OrderPlace(params); err != ErrWarmupTimeout {
+		t.Fatalf("got %v", err)
+	}
+	// 已订阅的交易对仍没有价格
+	if _, err := p.OrderPlace(params); err != ErrWarmupTimeout {
+		t.Fatalf("got %v", err)
+	}
+
+	p.Exchange.OnDepth("btcusdt", &proto.Depth{
+		Bids: []proto.DepthLevel{{Price: 99, Amount: 1}},
+		Asks: []proto.DepthLevel{{Price: 100, Amount: 1}},
+	})
+	if _, err := p.OrderPlace(params); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAccount(t *testing.T) {
+	spot, err := Account(consts.ExchangeTypeHuobi, "spot", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := Account(consts.ExchangeTypeHuobi, "spot", 1); again != spot {
+		t.Fatal("same account returned a new exchange")
+	}
+	if other, _ := Account(consts.ExchangeTypeHuobi, "spot", 2); other == spot {
+		t.Fatal("different accounts share an exchange")
+	}
+	if _, err := Account("nope", "spot", 1); err != ErrUnsupportedSource {
+		t.Fatalf("got %v", err)
+	}
+}
+
+func TestHuobiSharedConnection(t *testing.T) {
+	s := mockserver.NewHuobi("", "")
+	defer s.Close()
+	defer func(endpoint string) { huobi.Endpoint = endpoint }(huobi.Endpoint)
+	huobi.Endpoint = s.WSURL("/ws")
+
+	var exchanges []*Exchange
+	for i := 0; i < 2; i++ {
+		p, err := New(consts.ExchangeTypeHuobi, sim.Config{}, map[string]float64{"usdt": 100})
+		if err != nil {
+			t.Fatal(err)
+		}
+		exchanges = append(exchanges, p)
+	}
+
+	done := make(chan error, len(exchanges))
+	for _, p := range exchanges {
+		go func(p *Exchange) { done <- p.SubscribeDepth("btcusdt") }(p)
+	}
+	topic := "market.btcusdt.depth.step0"
+	if !s.WaitSubscribed(topic, 3*time.Second) {
+		t.Fatal("depth not subscribed")
+	}
+	tick := map[string]interface{}{"bids": [][]float64{{99, 1}}, "asks": [][]float64{{100, 1}}, "ts": 1}
+	for finished := 0; finished < len(exchanges); {
+		if n := s.Publish(topic, tick); n != 1 {
+			t.Fatalf("published to %d connections", n)
+		}
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Fatal(err)
+			}
+			finished++
+		case <-time.After(20 * time.Millisecond):
+		}
+	}
+	for _, p := range exchanges {
+		if price, ok := p.LastPrice("btcusdt"); !ok || price != 99.5 {
+			t.Fatalf("price %v", price)
+		}
+	}
+
+	// 最后一个模拟盘关闭时断开连接
+	exchanges[0].Close()
+	if n := s.Publish(topic, tick); n != 1 {
+		t.Fatalf("published to %d connections", n)
+	}
+	exchanges[1].Close()
+	deadline := time.Now().Add(3 * time.Second)
+	for s.Subscribed(topic) {
+		if time.Now().After(deadline) {
+			t.Fatal("connection not closed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,38 @@
+package portfolio
+
+import (
+	"github.com/gpmn/sheep/binance"
+	"github.com/gpmn/sheep/huobi"
+	"github.com/gpmn/sheep/logger"
+)
+
+// HuobiOrderListener 火币订单推送监听器, 推送中的成交记入 account 账户, 用于 huobi.Huobi.SetOrderListener
+func (l *Ledger) HuobiOrderListener(account string) huobi.OrderListener {
+	return func(symbol string, od *huobi.OrderUpdate) {
+		base, quote, ok := SplitSymbol(od.Order.Symbol)
+		if !ok {
+			l.log().Warn("Ledger.HuobiOrderListener - unknown symbol", logger.F("symbol", od.Order.Symbol))
+			return
+		}
+		fill, ok := huobi.TransOrderFill(od, base, quote)
+		if !ok {
+			return
+		}
+		if err := l.ApplyFill(account, &fill); err != nil {
+			l.log().Warn("Ledger.HuobiOrderListener - ApplyFill failed", logger.Err(err))
+		}
+	}
+}
+
+// BinanceExecutionListener 币安执行报告监听器, 成交记入 account 账户, 用于 binance.Binance.SetExecutionReportListener
+func (l *Ledger) BinanceExecutionListener(account string) binance.ExecutionReportListener {
+	return func(report *binance.WsExecutionReport) {
+		fill, ok := binance.TransExecutionFill(report)
+		if !ok {
+			return
+		}
+		if err := l.ApplyFill(account, &fill); err != nil {
+			l.log().Warn("Ledger.BinanceExecutionListener - ApplyFill failed", logger.Err(err))
+		}
+	}
+}
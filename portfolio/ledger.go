@@ -0,0 +1,364 @@
+package portfolio
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gpmn/sheep"
+	"github.com/gpmn/sheep/huobi"
+	"github.com/gpmn/sheep/logger"
+	"github.com/gpmn/sheep/proto"
+)
+
+// ErrUnknownSymbol 无法从交易对拆分出基础币和计价币
+var ErrUnknownSymbol = errors.New("unknown symbol")
+
+// QuoteCurrencies 拆分交易对时识别的计价币, 按顺序匹配后缀
+var QuoteCurrencies = []string{"usdt", "husd", "usdc", "busd", "btc", "eth", "bnb", "ht", "okb"}
+
+// SplitSymbol 拆分交易对, 支持 btcusdt 和 btc_usdt 两种格式
+func SplitSymbol(symbol string) (base, quote string, ok bool) {
+	symbol = strings.ToLower(symbol)
+	if i := strings.Index(symbol, "_"); i > 0 {
+		return symbol[:i], symbol[i+1:], true
+	}
+	for _, q := range QuoteCurrencies {
+		if len(symbol) > len(q) && strings.HasSuffix(symbol, q) {
+			return strings.TrimSuffix(symbol, q), q, true
+		}
+	}
+	return "", "", false
+}
+
+// Balance : 某账户某币种的余额
+type Balance struct {
+	Available float64 `json:"available"`
+	Frozen    float64 `json:"frozen"`
+}
+
+// Total 可用加冻结
+func (b Balance) Total() float64 {
+	return b.Available + b.Frozen
+}
+
+// Source 账户余额来源, 用于初始化和对账
+type Source func() ([]proto.AccountBalance, error)
+
+// HuobiMarginSource 火币逐仓杠杆账户余额
+func HuobiMarginSource(h *huobi.Huobi) Source {
+	return func() ([]proto.AccountBalance, error) {
+		mbs, err := h.GetMarginBalances("")
+		if err != nil {
+			return nil, err
+		}
+		return huobi.TransMarginBalances(mbs), nil
+	}
+}
+
+// Drift : 对账时本地余额与交易所余额的差异
+type Drift struct {
+	Account  string
+	Currency string
+	Local    Balance
+	Remote   Balance
+}
+
+// Diff 交易所总额减本地总额
+func (d Drift) Diff() float64 {
+	return d.Remote.Total() - d.Local.Total()
+}
+
+// DriftListener 对账差异回调
+type DriftListener func(drift Drift)
+
+// Ledger : 多账户余额账本, 从交易所余额初始化, 按推送的成交实时更新, 定期对账.
+// 账户名通常为交易所类型, 同一交易所的多个账户(如火币杠杆)使用不同的名字
+type Ledger struct {
+	accounts  map[string]map[string]*Balance
+	sources   map[string]Source
+	names     []string // 按添加顺序
+	tolerance float64
+	listener  DriftListener
+	stop      chan struct{}
+	mutex     sync.RWMutex
+
+	// 日志, 为nil时不输出, 在 Start 之前设置
+	Logger logger.Logger
+}
+
+// NewLedger 创建账本, 对账时总额差异超过 tolerance 视为偏差
+func NewLedger(tolerance float64) *Ledger {
+	return &Ledger{
+		accounts:  make(map[string]map[string]*Balance),
+		sources:   make(map[string]Source),
+		tolerance: tolerance,
+	}
+}
+
+func (l *Ledger) log() logger.Logger {
+	return logger.OrNop(l.Logger)
+}
+
+// AddSource 添加账户及其余额来源
+func (l *Ledger) AddSource(account string, source Source) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if _, ok := l.sources[account]; !ok {
+		l.names = append(l.names, account)
+	}
+	l.sources[account] = source
+}
+
+// AddExchange 添加交易所现货账户, 账户名为交易所类型
+func (l *Ledger) AddExchange(ex sheep.ExchageI) {
+	l.AddSource(ex.GetExchangeType(), ex.GetAccountBalance)
+}
+
+// SetDriftListener 设置对账差异监听器
+func (l *Ledger) SetDriftListener(listener DriftListener) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.listener = listener
+}
+
+// Seed 用交易所余额覆盖账户的本地余额
+func (l *Ledger) Seed(account string, balances []proto.AccountBalance) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.seed(account, balances)
+}
+
+func (l *Ledger) seed(account string, balances []proto.AccountBalance) {
+	l.accounts[account] = collect(balances)
+}
+
+// collect 按币种汇总 trade 和 frozen 余额
+func collect(balances []proto.AccountBalance) map[string]*Balance {
+	res := make(map[string]*Balance)
+	for _, item := range balances {
+		currency := strings.ToLower(item.Currency)
+		b, ok := res[currency]
+		if !ok {
+			b = new(Balance)
+			res[currency] = b
+		}
+		switch item.Type {
+		case proto.AccountBalanceTypeTrade:
+			b.Available += item.Balance
+		case proto.AccountBalanceTypeFrozen:
+			b.Frozen += item.Balance
+		}
+	}
+	return res
+}
+
+// ApplyFill 按成交更新余额, 支出先从冻结中扣除, 不足部分从可用中扣除; 手续费从可用中扣除
+func (l *Ledger) ApplyFill(account string, fill *proto.Fill) error {
+	base, quote, ok := SplitSymbol(fill.Symbol)
+	if !ok {
+		return ErrUnknownSymbol
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	balances, ok := l.accounts[account]
+	if !ok {
+		balances = make(map[string]*Balance)
+		l.accounts[account] = balances
+	}
+	get := func(currency string) *Balance {
+		b, ok := balances[currency]
+		if !ok {
+			b = new(Balance)
+			balances[currency] = b
+		}
+		return b
+	}
+
+	cost := fill.Price * fill.Amount
+	if fill.Side == proto.TradeSideBuy {
+		spend(get(quote), cost)
+		get(base).Available += fill.Amount
+	} else {
+		spend(get(base), fill.Amount)
+		get(quote).Available += cost
+	}
+	if fill.Fee != 0 && fill.FeeCurrency != "" {
+		get(strings.ToLower(fill.FeeCurrency)).Available -= fill.Fee
+	}
+	return nil
+}
+
+func spend(b *Balance, amount float64) {
+	frozen := math.Min(b.Frozen, amount)
+	b.Frozen -= frozen
+	b.Available -= amount - frozen
+}
+
+// Reconcile 从所有来源查询余额, 与本地余额比较后覆盖本地余额.
+// 首次查询只做初始化; 冻结余额会随下单变化, 只比较总额
+func (l *Ledger) Reconcile() ([]Drift, error) {
+	l.mutex.RLock()
+	names := append([]string(nil), l.names...)
+	l.mutex.RUnlock()
+
+	var drifts []Drift
+	var lastErr error
+	for _, account := range names {
+		l.mutex.RLock()
+		source := l.sources[account]
+		l.mutex.RUnlock()
+
+		balances, err := source()
+		if err != nil {
+			l.log().Warn("Ledger.Reconcile - source failed", logger.F("account", account), logger.Err(err))
+			lastErr = err
+			continue
+		}
+		drifts = append(drifts, l.reconcile(account, balances)...)
+	}
+
+	l.mutex.RLock()
+	listener := l.listener
+	l.mutex.RUnlock()
+	if listener != nil {
+		for _, d := range drifts {
+			listener(d)
+		}
+	}
+	return drifts, lastErr
+}
+
+func (l *Ledger) reconcile(account string, balances []proto.AccountBalance) []Drift {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	local, seeded := l.accounts[account]
+	remote := collect(balances)
+	l.seed(account, balances)
+	if !seeded {
+		return nil
+	}
+
+	var drifts []Drift
+	for _, currency := range currencies(local, remote) {
+		d := Drift{Account: account, Currency: currency}
+		if b, ok := local[currency]; ok {
+			d.Local = *b
+		}
+		if b, ok := remote[currency]; ok {
+			d.Remote = *b
+		}
+		if math.Abs(d.Diff()) > l.tolerance {
+			drifts = append(drifts, d)
+		}
+	}
+	return drifts
+}
+
+// currencies 两份余额中出现的所有币种, 按字母顺序
+func currencies(a, b map[string]*Balance) []string {
+	var res []string
+	for currency := range a {
+		res = append(res, currency)
+	}
+	for currency := range b {
+		if _, ok := a[currency]; !ok {
+			res = append(res, currency)
+		}
+	}
+	sort.Strings(res)
+	return res
+}
+
+// Start 立即对账一次, 之后每隔 interval 对账, 直到 Stop
+func (l *Ledger) Start(interval time.Duration) error {
+	l.mutex.Lock()
+	if l.stop != nil {
+		l.mutex.Unlock()
+		return nil
+	}
+	stop := make(chan struct{})
+	l.stop = stop
+	l.mutex.Unlock()
+
+	_, err := l.Reconcile()
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				l.Reconcile()
+			}
+		}
+	}()
+	return err
+}
+
+// Stop 停止对账
+func (l *Ledger) Stop() {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.stop != nil {
+		close(l.stop)
+		l.stop = nil
+	}
+}
+
+// Accounts 所有账户名, 按添加顺序, 只通过 Seed 或 ApplyFill 出现的账户排在最后
+func (l *Ledger) Accounts() []string {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+	res := append([]string(nil), l.names...)
+	var extra []string
+	for account := range l.accounts {
+		if _, ok := l.sources[account]; !ok {
+			extra = append(extra, account)
+		}
+	}
+	sort.Strings(extra)
+	return append(res, extra...)
+}
+
+// Balance 账户某币种的余额
+func (l *Ledger) Balance(account, currency string) Balance {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+	if b, ok := l.accounts[account][strings.ToLower(currency)]; ok {
+		return *b
+	}
+	return Balance{}
+}
+
+// Balances 账户所有币种的余额
+func (l *Ledger) Balances(account string) map[string]Balance {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+	res := make(map[string]Balance)
+	for currency, b := range l.accounts[account] {
+		res[currency] = *b
+	}
+	return res
+}
+
+// Total 所有账户某币种余额之和
+func (l *Ledger) Total(currency string) Balance {
+	currency = strings.ToLower(currency)
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+	var res Balance
+	for _, balances := range l.accounts {
+		if b, ok := balances[currency]; ok {
+			res.Available += b.Available
+			res.Frozen += b.Frozen
+		}
+	}
+	return res
+}
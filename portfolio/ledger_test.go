@@ -0,0 +1,130 @@
+package portfolio
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/gpmn/sheep/binance"
+	"github.com/gpmn/sheep/huobi"
+	"github.com/gpmn/sheep/proto"
+	"github.com/gpmn/sheep/sim"
+)
+
+func near(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestSplitSymbol(t *testing.T) {
+	for symbol, want := range map[string][2]string{
+		"btcusdt":  {"btc", "usdt"},
+		"BNBETH":   {"bnb", "eth"},
+		"eth_btc":  {"eth", "btc"},
+		"ethht":    {"eth", "ht"},
+		"unknown1": {"", ""},
+	} {
+		base, quote, _ := SplitSymbol(symbol)
+		if base != want[0] || quote != want[1] {
+			t.Errorf("%s : got %s %s", symbol, base, quote)
+		}
+	}
+}
+
+func TestLedgerReconcile(t *testing.T) {
+	ex := sim.NewExchange(sim.Config{TakerFee: 0.001}, map[string]float64{"usdt": 1000})
+	ex.OnDepth("btcusdt", &proto.Depth{
+		Bids: []proto.DepthLevel{{Price: 99, Amount: 10}},
+		Asks: []proto.DepthLevel{{Price: 100, Amount: 10}},
+	})
+
+	l := NewLedger(1e-6)
+	l.AddExchange(ex)
+	var drifts []Drift
+	l.SetDriftListener(func(d Drift) { drifts = append(drifts, d) })
+	if err := l.Start(time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	defer l.Stop()
+	if b := l.Balance(sim.ExchangeTypeSim, "USDT"); !near(b.Available, 1000) {
+		t.Fatalf("seed %+v", b)
+	}
+
+	ex.SetFillListener(func(f *proto.Fill) {
+		if err := l.ApplyFill(sim.ExchangeTypeSim, f); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if _, err := ex.OrderPlace(&proto.OrderPlaceParams{Price: 100, Amount: 2, BaseCurrencyID: "btc", QuoteCurrencyID: "usdt", Type: proto.OrderPlaceTypeBuyLimit}); err != nil {
+		t.Fatal(err)
+	}
+	if b := l.Balance(sim.ExchangeTypeSim, "btc"); !near(b.Available, 2*0.999) {
+		t.Fatalf("btc %+v", b)
+	}
+	if b := l.Total("usdt"); !near(b.Total(), 800) {
+		t.Fatalf("usdt %+v", b)
+	}
+	if _, err := l.Reconcile(); err != nil || len(drifts) != 0 {
+		t.Fatalf("drifts %+v, err %v", drifts, err)
+	}
+
+	// 漏掉一次成交
+	ex.OnDepth("btcusdt", &proto.Depth{
+		Bids: []proto.DepthLevel{{Price: 99, Amount: 10}},
+		Asks: []proto.DepthLevel{{Price: 100, Amount: 10}},
+	})
+	ex.SetFillListener(nil)
+	ex.OrderPlace(&proto.OrderPlaceParams{Price: 100, Amount: 1, BaseCurrencyID: "btc", QuoteCurrencyID: "usdt", Type: proto.OrderPlaceTypeBuyLimit})
+	res, _ := l.Reconcile()
+	if len(res) != 2 || len(drifts) != 2 || res[0].Currency != "btc" || !near(res[1].Diff(), -100) {
+		t.Fatalf("drifts %+v", res)
+	}
+	if b := l.Balance(sim.ExchangeTypeSim, "usdt"); !near(b.Total(), 700) {
+		t.Fatalf("usdt after reconcile %+v", b)
+	}
+}
+
+// 交易所文档中的推送样例
+const (
+	huobiOrderPush     = `{"op":"notify","topic":"orders.htusdt","ts":1522856623232,"data":{"seq-id":94984,"order-id":2039498445,"symbol":"htusdt","account-id":100077,"order-amount":"5.000000000000000000","order-price":"1.662100000000000000","created-at":1522858623622,"order-type":"buy-limit","order-source":"api","order-state":"filled","role":"taker","price":"1.662100000000000000","filled-amount":"5.000000000000000000","unfilled-amount":"0.000000000000000000","filled-cash-amount":"8.310500000000000000","filled-fees":"0.010000000000000000"}}`
+	binanceNewReport   = `{"e":"executionReport","E":1499405658658,"s":"ETHBTC","c":"mUvoqJxFIILMdfAW5iGSOW","S":"SELL","o":"LIMIT","f":"GTC","q":"1.00000000","p":"0.10264410","P":"0.00000000","F":"0.00000000","g":-1,"C":"","x":"NEW","X":"NEW","r":"NONE","i":4293153,"l":"0.00000000","z":"0.00000000","L":"0.00000000","n":"0","N":null,"T":1499405658657,"t":-1,"I":8641984,"w":true,"m":false,"M":false,"O":1499405658657,"Z":"0.00000000","Y":"0.00000000","Q":"0.00000000"}`
+	binanceTradeReport = `{"e":"executionReport","E":1499405658700,"s":"ETHBTC","c":"mUvoqJxFIILMdfAW5iGSOW","S":"SELL","o":"LIMIT","f":"GTC","q":"1.00000000","p":"0.10264410","P":"0.00000000","F":"0.00000000","g":-1,"C":"","x":"TRADE","X":"PARTIALLY_FILLED","r":"NONE","i":4293153,"l":"0.40000000","z":"0.40000000","L":"0.10264410","n":"0.00004106","N":"BTC","T":1499405658699,"t":1234,"I":8641990,"w":false,"m":true,"M":true,"O":1499405658657,"Z":"0.04105764","Y":"0.04105764","Q":"0.00000000"}`
+)
+
+func TestFeeds(t *testing.T) {
+	l := NewLedger(0)
+	l.Seed("huobi", []proto.AccountBalance{{Currency: "usdt", Balance: 100, Type: proto.AccountBalanceTypeTrade}})
+	l.Seed("binance", []proto.AccountBalance{{Currency: "eth", Balance: 1, Type: proto.AccountBalanceTypeFrozen}})
+
+	var od huobi.OrderUpdate
+	if err := json.Unmarshal([]byte(huobiOrderPush), &od); err != nil {
+		t.Fatal(err)
+	}
+	fill, ok := huobi.TransOrderFill(&od, "ht", "usdt")
+	if !ok || fill.OrderID != "2039498445" || fill.Side != proto.TradeSideBuy || fill.Price != 1.6621 ||
+		fill.Amount != 5 || fill.Fee != 0.01 || fill.FeeCurrency != "ht" || fill.Maker || fill.TS != 1522856623232 {
+		t.Fatalf("fill %+v", fill)
+	}
+	l.HuobiOrderListener("huobi")("htusdt", &od)
+	if b := l.Balance("huobi", "usdt"); !near(b.Available, 100-8.3105) {
+		t.Fatalf("usdt %+v", b)
+	}
+	if b := l.Balance("huobi", "ht"); !near(b.Available, 4.99) {
+		t.Fatalf("ht %+v", b)
+	}
+
+	listener := l.BinanceExecutionListener("binance")
+	for _, payload := range []string{binanceNewReport, binanceTradeReport} {
+		var report binance.WsExecutionReport
+		if err := json.Unmarshal([]byte(payload), &report); err != nil {
+			t.Fatal(err)
+		}
+		listener(&report)
+	}
+	if b := l.Balance("binance", "eth"); !near(b.Frozen, 0.6) || b.Available != 0 {
+		t.Fatalf("eth %+v", b)
+	}
+	if b := l.Balance("binance", "btc"); !near(b.Available, 0.04105764-0.00004106) {
+		t.Fatalf("btc %+v", b)
+	}
+}
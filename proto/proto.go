@@ -60,6 +60,9 @@ type Order struct {
 	Price       float64 `json:"price"`
 	Type        string  `json:"type"`
 	CreatedSec  int64   `json:"created-at"`
+
+	// 已成交金额(计价币), 除以 FieldAmount 为成交均价, 交易所不提供时为0
+	FieldCashAmount float64 `json:"field-cash-amount"`
 }
 
 type OrdersParams struct {
@@ -77,3 +80,60 @@ type MarketDepthParams struct {
 	Symbol string `json:"symbol"`
 	Level  string `json:"level"`
 }
+
+// DepthLevel : 深度档位
+type DepthLevel struct {
+	Price  float64 `json:"price"`
+	Amount float64 `json:"amount"`
+}
+
+// Depth : 深度, Bids 价格从高到低, Asks 价格从低到高
+type Depth struct {
+	Symbol string       `json:"symbol"`
+	Bids   []DepthLevel `json:"bids"`
+	Asks   []DepthLevel `json:"asks"`
+	TS     int64        `json:"ts"` // 毫秒
+}
+
+const (
+	TradeSideBuy  = "buy"  // 主动买
+	TradeSideSell = "sell" // 主动卖
+)
+
+// Trade : 成交
+type Trade struct {
+	ID     string  `json:"id"`
+	Symbol string  `json:"symbol"`
+	Price  float64 `json:"price"`
+	Amount float64 `json:"amount"`
+	Side   string  `json:"side"` // 吃单方向, TradeSideBuy/TradeSideSell
+	TS     int64   `json:"ts"`   // 毫秒
+}
+
+// Candle : K线
+type Candle struct {
+	Symbol      string  `json:"symbol"`
+	Start       int64   `json:"start"` // 开始时间, 毫秒
+	End         int64   `json:"end"`   // 结束时间, 毫秒, 时间K线为开始时间加周期, 其他K线为最后一笔成交时间
+	Open        float64 `json:"open"`
+	High        float64 `json:"high"`
+	Low         float64 `json:"low"`
+	Close       float64 `json:"close"`
+	Volume      float64 `json:"volume"`       // 成交量
+	QuoteVolume float64 `json:"quote_volume"` // 成交额
+	Count       int64   `json:"count"`        // 成交笔数
+	Closed      bool    `json:"closed"`       // 是否已收盘
+}
+
+// Fill : 成交回报
+type Fill struct {
+	OrderID     string  `json:"order_id"`
+	Symbol      string  `json:"symbol"`
+	Side        string  `json:"side"` // TradeSideBuy/TradeSideSell
+	Price       float64 `json:"price"`
+	Amount      float64 `json:"amount"`
+	Fee         float64 `json:"fee"`
+	FeeCurrency string  `json:"fee_currency"`
+	Maker       bool    `json:"maker"`
+	TS          int64   `json:"ts"` // 毫秒
+}
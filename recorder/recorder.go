@@ -0,0 +1,166 @@
+package recorder
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gpmn/sheep/logger"
+)
+
+// fileLayout 记录文件名中的时间格式
+const fileLayout = "20060102-150405.000"
+
+// Frame : 一条记录的消息
+type Frame struct {
+	TS     int64  `json:"ts"`            // 收到消息的本地时间, 毫秒
+	Source string `json:"source"`        // 交易所, 如 consts.ExchangeTypeHuobi
+	Raw    []byte `json:"raw,omitempty"` // 原始数据, base64编码
+	Msg    string `json:"msg"`           // 解压后的数据
+}
+
+// Recorder : 把websocket消息按行写入JSON文件, 超过大小或时长时切换到新文件
+// 实现 util.Recorder, 可设置给 huobi/okex/fcoin 的 SetRecorder
+type Recorder struct {
+	MaxSize   int64         // 单个文件的最大字节数, 0 不限
+	MaxAge    time.Duration // 单个文件的最长记录时间, 0 不限
+	OmitRaw   bool          // 不记录原始数据, 只记录解压后的数据
+	FlushEach bool          // 每条消息后刷新到磁盘
+	Logger    logger.Logger // 日志, 为nil时不输出
+
+	dir     string
+	prefix  string
+	file    *os.File
+	writer  *bufio.Writer
+	size    int64
+	opened  time.Time
+	lastErr error
+	mutex   sync.Mutex
+}
+
+// NewRecorder 创建记录器, 文件名为 <dir>/<prefix>-<时间>.jsonl
+func NewRecorder(dir, prefix string) (*Recorder, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &Recorder{
+		MaxSize: 100 << 20,
+		MaxAge:  time.Hour,
+		dir:     dir,
+		prefix:  prefix,
+	}, nil
+}
+
+// Record 记录一条消息
+func (r *Recorder) Record(source string, raw, msg []byte) {
+	now := time.Now()
+	f := Frame{
+		TS:     now.UnixNano() / int64(time.Millisecond),
+		Source: source,
+		Msg:    string(msg),
+	}
+	if !r.OmitRaw {
+		f.Raw = raw
+	}
+	b, err := json.Marshal(f)
+	if err != nil {
+		r.log().Error("Recorder.Record - json.Marshal failed", logger.Err(err))
+		return
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if err = r.rotate(now); err == nil {
+		var n int
+		n, err = r.writer.Write(append(b, '\n'))
+		r.size += int64(n)
+		if err == nil && r.FlushEach {
+			err = r.writer.Flush()
+		}
+	}
+	if err != nil && err != r.lastErr {
+		r.log().Error("Recorder.Record - write failed", logger.Err(err))
+	}
+	r.lastErr = err
+}
+
+func (r *Recorder) log() logger.Logger {
+	return logger.OrNop(r.Logger)
+}
+
+// rotate 需要时切换文件
+func (r *Recorder) rotate(now time.Time) error {
+	if r.file != nil &&
+		(r.MaxSize <= 0 || r.size < r.MaxSize) &&
+		(r.MaxAge <= 0 || now.Sub(r.opened) < r.MaxAge) {
+		return nil
+	}
+	if err := r.close(); err != nil {
+		return err
+	}
+
+	name := filepath.Join(r.dir, fmt.Sprintf("%s-%s.jsonl", r.prefix, now.UTC().Format(fileLayout)))
+	f, err := os.OpenFile(name, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	r.file = f
+	r.writer = bufio.NewWriter(f)
+	r.size = 0
+	r.opened = now
+	return nil
+}
+
+func (r *Recorder) close() error {
+	if r.file == nil {
+		return nil
+	}
+	err := r.writer.Flush()
+	if e := r.file.Close(); err == nil {
+		err = e
+	}
+	r.file = nil
+	r.writer = nil
+	return err
+}
+
+// Flush 刷新缓冲到磁盘
+func (r *Recorder) Flush() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.writer == nil {
+		return nil
+	}
+	return r.writer.Flush()
+}
+
+// Close 关闭当前文件, 之后的 Record 会打开新文件
+func (r *Recorder) Close() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.close()
+}
+
+// Files 返回目录中前缀为prefix的记录文件, 按时间从早到晚
+func Files(dir, prefix string) ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(dir, prefix+"-*.jsonl"))
+	if err != nil {
+		return nil, err
+	}
+	// 前缀本身可能包含'-', 只保留后缀为时间的文件
+	var res []string
+	for _, f := range files {
+		stamp := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(f), prefix+"-"), ".jsonl")
+		if _, err := time.Parse(fileLayout, stamp); err == nil {
+			res = append(res, f)
+		}
+	}
+	sort.Strings(res)
+	return res, nil
+}
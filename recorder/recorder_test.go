@@ -0,0 +1,71 @@
+package recorder
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	simplejson "github.com/bitly/go-simplejson"
+	"github.com/gpmn/sheep/consts"
+	"github.com/gpmn/sheep/huobi"
+)
+
+func gzipData(t *testing.T, s string) []byte {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	w.Write([]byte(s))
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestRecordReplay(t *testing.T) {
+	dir, err := ioutil.TempDir("", "recorder")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	r, err := NewRecorder(dir, "huobi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.MaxSize = 1 // 每条消息一个文件
+	msgs := []string{
+		`{"ch":"market.btcusdt.trade.detail","ts":1,"tick":{"data":[{"price":100,"amount":1,"direction":"buy","ts":1}]}}`,
+		`{"ch":"market.btcusdt.trade.detail","ts":2,"tick":{"data":[{"price":101,"amount":2,"direction":"sell","ts":2}]}}`,
+		`{"ch":"market.ethusdt.trade.detail","ts":3,"tick":{"data":[{"price":10,"amount":1,"direction":"sell","ts":3}]}}`,
+	}
+	for _, m := range msgs {
+		r.Record(consts.ExchangeTypeHuobi, gzipData(t, m), []byte(m))
+	}
+	r.Record(consts.ExchangeTypeOKEX, nil, []byte(`[]`))
+	if err = r.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := Files(dir, "huobi")
+	if err != nil || len(files) == 0 {
+		t.Fatalf("files %v, %v", files, err)
+	}
+
+	market := huobi.NewOfflineMarket()
+	var prices []float64
+	market.Subscribe("market.btcusdt.trade.detail", func(topic string, j *simplejson.Json) {
+		prices = append(prices, j.Get("tick").Get("data").GetIndex(0).Get("price").MustFloat64())
+	})
+
+	replayer := NewReplayer(files...)
+	replayer.Speed = 0
+	replayer.Handle(consts.ExchangeTypeHuobi, market.Replay)
+	n, err := replayer.Run()
+	if err != nil || n != 3 {
+		t.Fatalf("replayed %d, %v", n, err)
+	}
+	if len(prices) != 2 || prices[0] != 100 || prices[1] != 101 {
+		t.Fatalf("prices %v", prices)
+	}
+}
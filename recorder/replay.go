@@ -0,0 +1,129 @@
+package recorder
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Handler 回放消息处理函数, 如 huobi.Market.Replay
+type Handler func(buf []byte)
+
+// Replayer : 按记录的时间间隔回放消息
+type Replayer struct {
+	// 回放速度倍数, 1 为实时, 10 为10倍速, 0 为不等待尽快回放
+	Speed float64
+
+	files    []string
+	handlers map[string]Handler
+	stop     chan struct{}
+}
+
+// NewReplayer 创建回放器, files 按顺序回放
+func NewReplayer(files ...string) *Replayer {
+	return &Replayer{
+		Speed:    1,
+		files:    files,
+		handlers: make(map[string]Handler),
+		stop:     make(chan struct{}),
+	}
+}
+
+// Handle 设置source的消息处理函数, 没有处理函数的消息被跳过
+func (r *Replayer) Handle(source string, handler Handler) {
+	r.handlers[source] = handler
+}
+
+// Stop 停止回放
+func (r *Replayer) Stop() {
+	close(r.stop)
+}
+
+// Run 回放所有文件, 直到结束或 Stop, 返回回放的消息数量
+// 记录了原始数据时回放原始数据, 以经过和实时消息相同的解压和解析流程
+func (r *Replayer) Run() (int, error) {
+	var count int
+	var first int64
+	var start time.Time
+	for _, name := range r.files {
+		err := readFrames(name, func(f *Frame) error {
+			handler, ok := r.handlers[f.Source]
+			if !ok {
+				return nil
+			}
+			if first == 0 {
+				first, start = f.TS, time.Now()
+			}
+			if r.Speed > 0 {
+				due := start.Add(time.Duration(float64(f.TS-first)/r.Speed) * time.Millisecond)
+				if wait := time.Until(due); wait > 0 {
+					select {
+					case <-time.After(wait):
+					case <-r.stop:
+						return errStopped
+					}
+				}
+			}
+			select {
+			case <-r.stop:
+				return errStopped
+			default:
+			}
+
+			if len(f.Raw) > 0 {
+				handler(f.Raw)
+			} else {
+				handler([]byte(f.Msg))
+			}
+			count++
+			return nil
+		})
+		if err == errStopped {
+			return count, nil
+		}
+		if err != nil {
+			return count, err
+		}
+	}
+	return count, nil
+}
+
+var errStopped = fmt.Errorf("replay stopped")
+
+// ReadFrames 读取记录文件中的所有消息
+func ReadFrames(name string) ([]Frame, error) {
+	var frames []Frame
+	err := readFrames(name, func(f *Frame) error {
+		frames = append(frames, *f)
+		return nil
+	})
+	return frames, err
+}
+
+func readFrames(name string, fn func(f *Frame) error) error {
+	file, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 64<<20)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var f Frame
+		if err := json.Unmarshal(scanner.Bytes(), &f); err != nil {
+			return fmt.Errorf("%s:%d: %v", name, line, err)
+		}
+		if err := fn(&f); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,418 @@
+package risk
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gpmn/sheep"
+	"github.com/gpmn/sheep/logger"
+	"github.com/gpmn/sheep/oms"
+	"github.com/gpmn/sheep/portfolio"
+	"github.com/gpmn/sheep/proto"
+)
+
+// 风控规则, 用于 RejectError.Rule
+const (
+	RuleKillSwitch    = "kill-switch"
+	RuleOrderNotional = "order-notional"
+	RulePosition      = "position"
+	RuleOpenOrders    = "open-orders"
+	RulePriceBand     = "price-band"
+	RuleFatFinger     = "fat-finger"
+	RuleDailyLoss     = "daily-loss"
+)
+
+// RejectError : 订单被风控拒绝, 没有发送到交易所
+type RejectError struct {
+	Rule   string
+	Reason string
+}
+
+func (e *RejectError) Error() string {
+	return fmt.Sprintf("risk rejected (%s) : %s", e.Rule, e.Reason)
+}
+
+func reject(rule, format string, args ...interface{}) error {
+	return &RejectError{Rule: rule, Reason: fmt.Sprintf(format, args...)}
+}
+
+// Limits 风控参数, 为0的项不检查
+type Limits struct {
+	MaxOrderNotional float64            // 单笔订单最大金额, 计价币
+	MaxPosition      map[string]float64 // 各币种成交后的最大持仓(可用加冻结)
+	MaxOpenOrders    int                // 最多未完成订单数
+	PriceBand        float64            // 限价单价格偏离参考价的最大比例, 如 0.05
+	FatFinger        float64            // 单笔订单最多使用的余额比例, 买单为计价币, 卖单为基础币
+	MaxDailyLoss     float64            // 当日(UTC)最大亏损, 需要 SetPnLSource
+}
+
+// Guard : 下单前风控, 包装 sheep.ExchageI.
+// 参考价为 OnTrade 输入的最新成交价, 没有成交时为 OnDepth 的中间价, 没有参考价时不检查价格偏离,
+// 需要参考价计算金额或数量的市价单被拒绝.
+// 经过它的订单由内部的 oms.OMS 跟踪, 用于未完成订单数; 一键撤单还会撤销交易所中已知交易对的其他未完成订单
+type Guard struct {
+	*oms.OMS
+
+	limits   Limits
+	balances func() (map[string]float64, error)
+	pnl      func() float64
+	clock    func() time.Time
+	last     map[string]float64
+	mid      map[string]float64
+	killed   bool
+	pending  int // 已通过检查正在下单的订单数, 计入未完成订单数
+	day      string
+	dayStart float64
+	mutex    sync.Mutex
+}
+
+// New 创建风控
+func New(ex sheep.ExchageI, limits Limits) *Guard {
+	g := &Guard{
+		OMS:    oms.New(ex),
+		limits: limits,
+		clock:  time.Now,
+		last:   make(map[string]float64),
+		mid:    make(map[string]float64),
+	}
+	g.balances = g.exchangeBalances
+	return g
+}
+
+// log 和内嵌的 OMS 使用同一个 Logger
+func (g *Guard) log() logger.Logger {
+	return logger.OrNop(g.Logger)
+}
+
+// exchangeBalances 从交易所查询各币种总余额
+func (g *Guard) exchangeBalances() (map[string]float64, error) {
+	balances, err := g.GetAccountBalance()
+	if err != nil {
+		return nil, err
+	}
+	res := make(map[string]float64)
+	for _, b := range balances {
+		res[strings.ToLower(b.Currency)] += b.Balance
+	}
+	return res, nil
+}
+
+// SetLedger 使用账本中的余额检查持仓和余额比例, 代替每次下单查询交易所
+func (g *Guard) SetLedger(ledger *portfolio.Ledger, account string) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	g.balances = func() (map[string]float64, error) {
+		res := make(map[string]float64)
+		for currency, b := range ledger.Balances(account) {
+			res[currency] = b.Total()
+		}
+		return res, nil
+	}
+}
+
+// SetPnLSource 设置累计盈亏来源, 每个UTC日第一次检查时记录起点, 当日亏损为起点减当前值
+func (g *Guard) SetPnLSource(pnl func() float64) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	g.pnl = pnl
+}
+
+// SetClock 设置时钟, 用于划分交易日
+func (g *Guard) SetClock(clock func() time.Time) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	g.clock = clock
+}
+
+// SetLimits 修改风控参数
+func (g *Guard) SetLimits(limits Limits) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	g.limits = limits
+}
+
+// OnTrade 输入成交, 更新参考价
+func (g *Guard) OnTrade(symbol string, trade *proto.Trade) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	g.last[strings.ToLower(symbol)] = trade.Price
+}
+
+// OnDepth 输入深度, 更新中间价
+func (g *Guard) OnDepth(symbol string, depth *proto.Depth) {
+	if len(depth.Bids) == 0 || len(depth.Asks) == 0 {
+		return
+	}
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	g.mid[strings.ToLower(symbol)] = (depth.Bids[0].Price + depth.Asks[0].Price) / 2
+}
+
+func (g *Guard) reference(symbol string) (float64, bool) {
+	if p, ok := g.last[symbol]; ok && p > 0 {
+		return p, true
+	}
+	if p, ok := g.mid[symbol]; ok && p > 0 {
+		return p, true
+	}
+	return 0, false
+}
+
+// Kill 打开总开关, 之后的下单全部拒绝, 并撤销所有未完成订单, 返回最后一个查询或撤单错误.
+// 除了跟踪的订单, 还从交易所查询已知交易对(下过单或输入过行情)的未完成订单一并撤销
+func (g *Guard) Kill() error {
+	g.mutex.Lock()
+	g.killed = true
+	g.mutex.Unlock()
+
+	var lastErr error
+	canceled := make(map[string]bool)
+	cancel := func(id, base, quote string) {
+		canceled[id] = true
+		err := g.OrderCancel(&proto.OrderCancelParams{
+			OrderID:         id,
+			BaseCurrencyID:  base,
+			QuoteCurrencyID: quote,
+		})
+		if err != nil {
+			g.log().Error("Guard.Kill - OrderCancel failed", logger.F("order", id), logger.Err(err))
+			lastErr = err
+		}
+	}
+	for _, order := range g.OpenOrders("") {
+		cancel(order.ID, order.Params.BaseCurrencyID, order.Params.QuoteCurrencyID)
+	}
+
+	for _, pair := range g.symbols() {
+		orders, err := g.GetOrders(&proto.OrdersParams{
+			Symbol:          pair[0] + pair[1],
+			States:          "pre-submitted,submitted,partial-filled",
+			BaseCurrencyID:  pair[0],
+			QuoteCurrencyID: pair[1],
+			Status:          "0",
+		})
+		if err != nil {
+			g.log().Error("Guard.Kill - GetOrders failed", logger.F("symbol", pair[0]+pair[1]), logger.Err(err))
+			lastErr = err
+			continue
+		}
+		for _, order := range orders {
+			if canceled[order.ID] || (order.State != proto.OrderStateSubmitted && order.State != proto.OrderStatePartialFilled) {
+				continue
+			}
+			cancel(order.ID, pair[0], pair[1])
+		}
+	}
+	return lastErr
+}
+
+// symbols 已知交易对的基础币和计价币, 来自跟踪的订单和输入的行情, 无法拆分的交易对被忽略
+func (g *Guard) symbols() [][2]string {
+	seen := make(map[string]bool)
+	var res [][2]string
+	add := func(base, quote string) {
+		if base == "" || quote == "" || seen[base+quote] {
+			return
+		}
+		seen[base+quote] = true
+		res = append(res, [2]string{base, quote})
+	}
+	for _, order := range g.Orders("") {
+		add(strings.ToLower(order.Params.BaseCurrencyID), strings.ToLower(order.Params.QuoteCurrencyID))
+	}
+
+	g.mutex.Lock()
+	var names []string
+	for symbol := range g.last {
+		names = append(names, symbol)
+	}
+	for symbol := range g.mid {
+		names = append(names, symbol)
+	}
+	g.mutex.Unlock()
+	sort.Strings(names)
+	for _, symbol := range names {
+		base, quote, _ := portfolio.SplitSymbol(symbol)
+		add(base, quote)
+	}
+	return res
+}
+
+// Resume 关闭总开关
+func (g *Guard) Resume() {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	g.killed = false
+}
+
+// Killed 总开关是否打开
+func (g *Guard) Killed() bool {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	return g.killed
+}
+
+// OrderPlace 检查通过后下单, 拒绝时返回 *RejectError.
+// 下单期间占用一个未完成订单名额, 并发下单不会超过 MaxOpenOrders
+func (g *Guard) OrderPlace(params *proto.OrderPlaceParams) (*proto.OrderPlaceReturn, error) {
+	if err := g.Check(params); err != nil {
+		return nil, err
+	}
+	if err := g.reserve(); err != nil {
+		return nil, err
+	}
+	defer g.release()
+	return g.OMS.OrderPlace(params)
+}
+
+// reserve 再次检查总开关和未完成订单数, 通过后占用一个名额
+func (g *Guard) reserve() error {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	if g.killed {
+		return reject(RuleKillSwitch, "kill switch is on")
+	}
+	if g.limits.MaxOpenOrders > 0 {
+		if n := len(g.OpenOrders("")) + g.pending; n >= g.limits.MaxOpenOrders {
+			return reject(RuleOpenOrders, "%d open orders, max %d", n, g.limits.MaxOpenOrders)
+		}
+	}
+	g.pending++
+	return nil
+}
+
+func (g *Guard) release() {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	g.pending--
+}
+
+// Check 按风控参数检查订单, 不下单
+func (g *Guard) Check(params *proto.OrderPlaceParams) error {
+	base := strings.ToLower(params.BaseCurrencyID)
+	quote := strings.ToLower(params.QuoteCurrencyID)
+	symbol := base + quote
+	buy := strings.HasPrefix(params.Type, "buy")
+	market := strings.HasSuffix(params.Type, "market")
+
+	g.mutex.Lock()
+	limits := g.limits
+	killed := g.killed
+	ref, hasRef := g.reference(symbol)
+	balances := g.balances
+	g.mutex.Unlock()
+
+	if killed {
+		return reject(RuleKillSwitch, "kill switch is on")
+	}
+
+	if err := g.checkDailyLoss(limits); err != nil {
+		return err
+	}
+
+	if limits.MaxOpenOrders > 0 {
+		if n := len(g.OpenOrders("")); n >= limits.MaxOpenOrders {
+			return reject(RuleOpenOrders, "%d open orders, max %d", n, limits.MaxOpenOrders)
+		}
+	}
+
+	price := params.Price
+	if market {
+		price = ref
+	} else if limits.PriceBand > 0 && hasRef {
+		if deviation := math.Abs(price-ref) / ref; deviation > limits.PriceBand {
+			return reject(RulePriceBand, "price %v deviates %.4f from reference %v, max %v", price, deviation, ref, limits.PriceBand)
+		}
+	}
+
+	// 没有参考价时无法计算市价卖单的金额和市价买单的数量, 设置了对应限额时拒绝
+	if market && !hasRef {
+		if limits.MaxOrderNotional > 0 && !buy {
+			return reject(RuleOrderNotional, "no reference price for market order on %s", symbol)
+		}
+		if _, ok := limits.MaxPosition[base]; ok && buy {
+			return reject(RulePosition, "no reference price for market order on %s", symbol)
+		}
+		if _, ok := limits.MaxPosition[quote]; ok && !buy {
+			return reject(RulePosition, "no reference price for market order on %s", symbol)
+		}
+	}
+
+	// 市价买单的 Amount 为花费的计价币数量
+	amount, notional := params.Amount, params.Amount*price
+	if market && buy {
+		notional = params.Amount
+		amount = 0
+		if price > 0 {
+			amount = params.Amount / price
+		}
+	}
+	if limits.MaxOrderNotional > 0 && notional > limits.MaxOrderNotional {
+		return reject(RuleOrderNotional, "notional %v, max %v", notional, limits.MaxOrderNotional)
+	}
+
+	if len(limits.MaxPosition) == 0 && limits.FatFinger <= 0 {
+		return nil
+	}
+	current, err := balances()
+	if err != nil {
+		g.log().Warn("Guard.Check - balances failed", logger.Err(err))
+		return err
+	}
+	if max, ok := limits.MaxPosition[base]; ok && buy {
+		if after := current[base] + amount; after > max {
+			return reject(RulePosition, "%s position %v after fill, max %v", base, after, max)
+		}
+	}
+	if max, ok := limits.MaxPosition[quote]; ok && !buy {
+		if after := current[quote] + notional; after > max {
+			return reject(RulePosition, "%s position %v after fill, max %v", quote, after, max)
+		}
+	}
+	if limits.FatFinger > 0 {
+		currency, used := base, params.Amount
+		if buy {
+			currency, used = quote, notional
+		}
+		if balance := current[currency]; used > balance*limits.FatFinger {
+			return reject(RuleFatFinger, "order uses %v of %v %s, max ratio %v", used, balance, currency, limits.FatFinger)
+		}
+	}
+	return nil
+}
+
+// checkDailyLoss 检查当日亏损, 跨日时重新记录起点
+func (g *Guard) checkDailyLoss(limits Limits) error {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	if limits.MaxDailyLoss <= 0 || g.pnl == nil {
+		return nil
+	}
+	pnl := g.pnl()
+	day := g.clock().UTC().Format("2006-01-02")
+	if day != g.day {
+		g.day, g.dayStart = day, pnl
+	}
+	if loss := g.dayStart - pnl; loss > limits.MaxDailyLoss {
+		return reject(RuleDailyLoss, "daily loss %v, max %v", loss, limits.MaxDailyLoss)
+	}
+	return nil
+}
+
+// Interceptor 下单前检查的拦截器, 用于 sheep.WithInterceptors.
+// 拦截器只检查不跟踪订单, 未完成订单数只包括通过 Guard.OrderPlace 下的订单,
+// Kill 只能撤销已知交易对中经过拦截器下的订单
+func (g *Guard) Interceptor() sheep.Interceptor {
+	return func(method string, params interface{}, next sheep.Handler) (interface{}, error) {
+		if p, ok := params.(*proto.OrderPlaceParams); ok && method == sheep.MethodOrderPlace {
+			if err := g.Check(p); err != nil {
+				return nil, err
+			}
+		}
+		return next(method, params)
+	}
+}
@@ -0,0 +1,160 @@
+package risk
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gpmn/sheep"
+	"github.com/gpmn/sheep/proto"
+	"github.com/gpmn/sheep/sim"
+)
+
+var _ sheep.ExchageI = (*Guard)(nil)
+
+func newGuard(limits Limits) (*Guard, *sim.Exchange) {
+	ex := sim.NewExchange(sim.Config{}, map[string]float64{"usdt": 10000, "btc": 1})
+	ex.OnDepth("btcusdt", &proto.Depth{
+		Bids: []proto.DepthLevel{{Price: 99, Amount: 1}},
+		Asks: []proto.DepthLevel{{Price: 101, Amount: 1}},
+	})
+	g := New(ex, limits)
+	g.OnTrade("btcusdt", &proto.Trade{Price: 100, Amount: 1})
+	return g, ex
+}
+
+func buy(price, amount float64) *proto.OrderPlaceParams {
+	return &proto.OrderPlaceParams{Price: price, Amount: amount, BaseCurrencyID: "btc", QuoteCurrencyID: "usdt", Type: proto.OrderPlaceTypeBuyLimit}
+}
+
+func rule(err error) string {
+	if e, ok := err.(*RejectError); ok {
+		return e.Rule
+	}
+	return ""
+}
+
+func TestRules(t *testing.T) {
+	for _, c := range []struct {
+		name   string
+		limits Limits
+		params *proto.OrderPlaceParams
+		rule   string
+	}{
+		{"notional", Limits{MaxOrderNotional: 500}, buy(90, 6), RuleOrderNotional},
+		{"market notional", Limits{MaxOrderNotional: 500}, &proto.OrderPlaceParams{Amount: 600, BaseCurrencyID: "btc", QuoteCurrencyID: "usdt", Type: proto.OrderPlaceTypeBuyMarket}, RuleOrderNotional},
+		// ethusdt 没有参考价
+		{"market sell without reference", Limits{MaxOrderNotional: 500}, &proto.OrderPlaceParams{Amount: 100, BaseCurrencyID: "eth", QuoteCurrencyID: "usdt", Type: proto.OrderPlaceTypeSellMarket}, RuleOrderNotional},
+		{"market buy without reference", Limits{MaxPosition: map[string]float64{"eth": 3}}, &proto.OrderPlaceParams{Amount: 100, BaseCurrencyID: "eth", QuoteCurrencyID: "usdt", Type: proto.OrderPlaceTypeBuyMarket}, RulePosition},
+		{"band", Limits{PriceBand: 0.05}, buy(80, 1), RulePriceBand},
+		{"position", Limits{MaxPosition: map[string]float64{"btc": 3}}, buy(90, 2.5), RulePosition},
+		{"fat finger", Limits{FatFinger: 0.5}, &proto.OrderPlaceParams{Price: 110, Amount: 0.6, BaseCurrencyID: "btc", QuoteCurrencyID: "usdt", Type: proto.OrderPlaceTypeSellLimit}, RuleFatFinger},
+		{"ok", Limits{MaxOrderNotional: 500, PriceBand: 0.05, MaxPosition: map[string]float64{"btc": 3}, FatFinger: 0.5}, buy(96, 2), ""},
+	} {
+		g, _ := newGuard(c.limits)
+		_, err := g.OrderPlace(c.params)
+		if rule(err) != c.rule || (c.rule == "" && err != nil) {
+			t.Errorf("%s : got %v, want %s", c.name, err, c.rule)
+		}
+	}
+}
+
+func TestOpenOrdersAndKill(t *testing.T) {
+	g, ex := newGuard(Limits{MaxOpenOrders: 2})
+	for i := 0; i < 2; i++ {
+		if _, err := g.OrderPlace(buy(95, 1)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := g.OrderPlace(buy(95, 1)); rule(err) != RuleOpenOrders {
+		t.Fatalf("got %v", err)
+	}
+
+	// 不经过 Guard 下的订单, ethusdt 只输入过行情
+	g.OnTrade("ethusdt", &proto.Trade{Price: 10, Amount: 1})
+	outside := []*proto.OrderPlaceParams{
+		{Price: 120, Amount: 0.5, BaseCurrencyID: "btc", QuoteCurrencyID: "usdt", Type: proto.OrderPlaceTypeSellLimit},
+		{Price: 9, Amount: 2, BaseCurrencyID: "eth", QuoteCurrencyID: "usdt", Type: proto.OrderPlaceTypeBuyLimit},
+	}
+	for _, params := range outside {
+		if _, err := ex.OrderPlace(params); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := g.Kill(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := g.OrderPlace(buy(95, 1)); rule(err) != RuleKillSwitch {
+		t.Fatalf("got %v", err)
+	}
+	g.Poll()
+	if n := len(g.OpenOrders("")); n != 0 {
+		t.Fatalf("%d open orders after kill", n)
+	}
+	if orders, _ := ex.GetOrders(&proto.OrdersParams{States: "submitted,partial-filled"}); len(orders) != 0 {
+		t.Fatalf("exchange open orders %+v", orders)
+	}
+	if b := ex.Balances(); b["usdt"] != 10000 || b["btc"] != 1 {
+		t.Fatalf("balances %v", b)
+	}
+	g.Resume()
+	if _, err := g.OrderPlace(buy(95, 1)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// slowExchange 下单较慢的交易所, 用于检查并发下单
+type slowExchange struct {
+	*sim.Exchange
+}
+
+func (e slowExchange) OrderPlace(params *proto.OrderPlaceParams) (*proto.OrderPlaceReturn, error) {
+	time.Sleep(10 * time.Millisecond)
+	return e.Exchange.OrderPlace(params)
+}
+
+func TestOpenOrdersConcurrent(t *testing.T) {
+	_, ex := newGuard(Limits{})
+	g := New(slowExchange{ex}, Limits{MaxOpenOrders: 2})
+	var wg sync.WaitGroup
+	var mutex sync.Mutex
+	placed := 0
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := g.OrderPlace(buy(95, 0.1)); err == nil {
+				mutex.Lock()
+				placed++
+				mutex.Unlock()
+			} else if rule(err) != RuleOpenOrders {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := len(g.OpenOrders("")); placed != 2 || n != 2 {
+		t.Fatalf("placed %d, %d open orders", placed, n)
+	}
+}
+
+func TestDailyLoss(t *testing.T) {
+	g, _ := newGuard(Limits{MaxDailyLoss: 100})
+	now := time.Date(2020, 1, 1, 10, 0, 0, 0, time.UTC)
+	pnl := 1000.0
+	g.SetClock(func() time.Time { return now })
+	g.SetPnLSource(func() float64 { return pnl })
+
+	if err := g.Check(buy(95, 1)); err != nil {
+		t.Fatal(err)
+	}
+	pnl = 850
+	if err := g.Check(buy(95, 1)); rule(err) != RuleDailyLoss {
+		t.Fatalf("got %v", err)
+	}
+	now = now.Add(24 * time.Hour)
+	if err := g.Check(buy(95, 1)); err != nil {
+		t.Fatal(err)
+	}
+}
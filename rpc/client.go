@@ -0,0 +1,188 @@
+package rpc
+
+import (
+	"context"
+
+	"github.com/gpmn/sheep"
+	"github.com/gpmn/sheep/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// Client : sheep.v1.Exchange 的 Go 客户端, 其他语言用 sheep.proto 生成
+type Client struct {
+	cc    grpc.ClientConnInterface
+	token string
+}
+
+// NewClient 创建客户端, token 为 gateway.Client 的令牌
+func NewClient(cc grpc.ClientConnInterface, token string) *Client {
+	return &Client{cc: cc, token: token}
+}
+
+func (c *Client) context(ctx context.Context) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+c.token)
+}
+
+func (c *Client) invoke(ctx context.Context, method string, req, resp message) error {
+	return c.cc.Invoke(c.context(ctx), "/"+ServiceName+"/"+method, req, resp, grpc.ForceCodec(Codec{}))
+}
+
+// ListExchanges 可以访问的交易所实例
+func (c *Client) ListExchanges(ctx context.Context) ([]ExchangeInfo, error) {
+	var resp ExchangeList
+	if err := c.invoke(ctx, "ListExchanges", &Empty{}, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Exchanges, nil
+}
+
+// GetAccountBalance 余额
+func (c *Client) GetAccountBalance(ctx context.Context, exchange string) ([]proto.AccountBalance, error) {
+	var resp BalanceList
+	if err := c.invoke(ctx, "GetAccountBalance", &ExchangeRequest{Exchange: exchange}, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Balances, nil
+}
+
+// OrderPlace 下单
+func (c *Client) OrderPlace(ctx context.Context, exchange string, params *proto.OrderPlaceParams) (*proto.OrderPlaceReturn, error) {
+	var resp OrderPlaceReturn
+	if err := c.invoke(ctx, "OrderPlace", &OrderPlaceRequest{Exchange: exchange, Params: *params}, &resp); err != nil {
+		return nil, err
+	}
+	return (*proto.OrderPlaceReturn)(&resp), nil
+}
+
+// OrderCancel 撤单
+func (c *Client) OrderCancel(ctx context.Context, exchange string, params *proto.OrderCancelParams) error {
+	return c.invoke(ctx, "OrderCancel", &OrderCancelRequest{Exchange: exchange, Params: *params}, &Empty{})
+}
+
+// GetOrderInfo 订单详情
+func (c *Client) GetOrderInfo(ctx context.Context, exchange string, params *proto.OrderInfoParams) (*proto.Order, error) {
+	var resp Order
+	if err := c.invoke(ctx, "GetOrderInfo", &OrderInfoRequest{Exchange: exchange, Params: *params}, &resp); err != nil {
+		return nil, err
+	}
+	return (*proto.Order)(&resp), nil
+}
+
+// GetOrders 订单列表
+func (c *Client) GetOrders(ctx context.Context, exchange string, params *proto.OrdersParams) ([]proto.Order, error) {
+	var resp OrderList
+	if err := c.invoke(ctx, "GetOrders", &OrdersRequest{Exchange: exchange, Params: *params}, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Orders, nil
+}
+
+func (c *Client) stream(ctx context.Context, desc *grpc.StreamDesc, req message) (grpc.ClientStream, error) {
+	cs, err := c.cc.NewStream(c.context(ctx), desc, "/"+ServiceName+"/"+desc.StreamName, grpc.ForceCodec(Codec{}))
+	if err != nil {
+		return nil, err
+	}
+	if err := cs.SendMsg(req); err != nil {
+		return nil, err
+	}
+	if err := cs.CloseSend(); err != nil {
+		return nil, err
+	}
+	// 等待服务端订阅完成
+	if _, err := cs.Header(); err != nil {
+		return nil, err
+	}
+	return cs, nil
+}
+
+// MarketStream : 行情推送
+type MarketStream struct {
+	cs grpc.ClientStream
+}
+
+// Recv 读取下一条推送, 取消 ctx 结束
+func (s *MarketStream) Recv() (*MarketEvent, error) {
+	ev := new(MarketEvent)
+	if err := s.cs.RecvMsg(ev); err != nil {
+		return nil, err
+	}
+	return ev, nil
+}
+
+// StreamMarket 订阅行情推送, 返回时服务端已经订阅
+func (c *Client) StreamMarket(ctx context.Context, req *MarketRequest) (*MarketStream, error) {
+	cs, err := c.stream(ctx, &serviceDesc.Streams[0], req)
+	if err != nil {
+		return nil, err
+	}
+	return &MarketStream{cs: cs}, nil
+}
+
+// OrderStream : 订单推送
+type OrderStream struct {
+	cs grpc.ClientStream
+}
+
+// Recv 读取下一条推送, 取消 ctx 结束
+func (s *OrderStream) Recv() (*OrderEvent, error) {
+	ev := new(OrderEvent)
+	if err := s.cs.RecvMsg(ev); err != nil {
+		return nil, err
+	}
+	return ev, nil
+}
+
+// StreamOrders 订阅订单推送, 返回时服务端已经订阅
+func (c *Client) StreamOrders(ctx context.Context, req *OrderStreamRequest) (*OrderStream, error) {
+	cs, err := c.stream(ctx, &serviceDesc.Streams[1], req)
+	if err != nil {
+		return nil, err
+	}
+	return &OrderStream{cs: cs}, nil
+}
+
+// Exchange 远端交易所实例, 实现 sheep.ExchageI
+func (c *Client) Exchange(name string) sheep.ExchageI {
+	return &remote{c: c, name: name}
+}
+
+// remote : 通过 gRPC 调用的 sheep.ExchageI
+type remote struct {
+	c    *Client
+	name string
+}
+
+// GetExchangeType 查询失败时返回空
+func (r *remote) GetExchangeType() string {
+	list, err := r.c.ListExchanges(context.Background())
+	if err != nil {
+		return ""
+	}
+	for _, info := range list {
+		if info.Name == r.name {
+			return info.Type
+		}
+	}
+	return ""
+}
+
+func (r *remote) GetAccountBalance() ([]proto.AccountBalance, error) {
+	return r.c.GetAccountBalance(context.Background(), r.name)
+}
+
+func (r *remote) OrderPlace(params *proto.OrderPlaceParams) (*proto.OrderPlaceReturn, error) {
+	return r.c.OrderPlace(context.Background(), r.name, params)
+}
+
+func (r *remote) OrderCancel(params *proto.OrderCancelParams) error {
+	return r.c.OrderCancel(context.Background(), r.name, params)
+}
+
+func (r *remote) GetOrderInfo(params *proto.OrderInfoParams) (*proto.Order, error) {
+	return r.c.GetOrderInfo(context.Background(), r.name, params)
+}
+
+func (r *remote) GetOrders(params *proto.OrdersParams) ([]proto.Order, error) {
+	return r.c.GetOrders(context.Background(), r.name, params)
+}
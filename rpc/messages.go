@@ -0,0 +1,548 @@
+package rpc
+
+import (
+	"github.com/gpmn/sheep/proto"
+)
+
+// 和 proto 包同构的消息可以直接转换, 如 (*proto.Order)(o)
+
+// Empty : 空消息
+type Empty struct{}
+
+func (m *Empty) marshal(e *encoder)      {}
+func (m *Empty) unmarshal(f field) error { return nil }
+
+// ExchangeRequest : 指定交易所实例
+type ExchangeRequest struct {
+	Exchange string
+}
+
+func (m *ExchangeRequest) marshal(e *encoder) {
+	e.string(1, m.Exchange)
+}
+
+func (m *ExchangeRequest) unmarshal(f field) error {
+	if f.num == 1 {
+		m.Exchange = f.string()
+	}
+	return nil
+}
+
+// ExchangeInfo : 交易所实例
+type ExchangeInfo struct {
+	Name string
+	Type string
+}
+
+func (m *ExchangeInfo) marshal(e *encoder) {
+	e.string(1, m.Name)
+	e.string(2, m.Type)
+}
+
+func (m *ExchangeInfo) unmarshal(f field) error {
+	switch f.num {
+	case 1:
+		m.Name = f.string()
+	case 2:
+		m.Type = f.string()
+	}
+	return nil
+}
+
+// ExchangeList : 交易所实例列表
+type ExchangeList struct {
+	Exchanges []ExchangeInfo
+}
+
+func (m *ExchangeList) marshal(e *encoder) {
+	for i := range m.Exchanges {
+		e.message(1, &m.Exchanges[i])
+	}
+}
+
+func (m *ExchangeList) unmarshal(f field) error {
+	if f.num == 1 {
+		var info ExchangeInfo
+		if err := f.decode(&info); err != nil {
+			return err
+		}
+		m.Exchanges = append(m.Exchanges, info)
+	}
+	return nil
+}
+
+// AccountBalance : proto.AccountBalance
+type AccountBalance proto.AccountBalance
+
+func (m *AccountBalance) marshal(e *encoder) {
+	e.string(1, m.Currency)
+	e.double(2, m.Balance)
+	e.string(3, m.Type)
+}
+
+func (m *AccountBalance) unmarshal(f field) error {
+	switch f.num {
+	case 1:
+		m.Currency = f.string()
+	case 2:
+		m.Balance = f.double()
+	case 3:
+		m.Type = f.string()
+	}
+	return nil
+}
+
+// BalanceList : 余额列表
+type BalanceList struct {
+	Balances []proto.AccountBalance
+}
+
+func (m *BalanceList) marshal(e *encoder) {
+	for i := range m.Balances {
+		e.message(1, (*AccountBalance)(&m.Balances[i]))
+	}
+}
+
+func (m *BalanceList) unmarshal(f field) error {
+	if f.num == 1 {
+		var b AccountBalance
+		if err := f.decode(&b); err != nil {
+			return err
+		}
+		m.Balances = append(m.Balances, proto.AccountBalance(b))
+	}
+	return nil
+}
+
+// OrderPlaceParams : proto.OrderPlaceParams
+type OrderPlaceParams proto.OrderPlaceParams
+
+func (m *OrderPlaceParams) marshal(e *encoder) {
+	e.double(1, m.Price)
+	e.double(2, m.Amount)
+	e.string(3, m.BaseCurrencyID)
+	e.string(4, m.QuoteCurrencyID)
+	e.string(5, m.Type)
+	e.int64(6, int64(m.PricePrecision))
+	e.int64(7, int64(m.AmountPrecision))
+}
+
+func (m *OrderPlaceParams) unmarshal(f field) error {
+	switch f.num {
+	case 1:
+		m.Price = f.double()
+	case 2:
+		m.Amount = f.double()
+	case 3:
+		m.BaseCurrencyID = f.string()
+	case 4:
+		m.QuoteCurrencyID = f.string()
+	case 5:
+		m.Type = f.string()
+	case 6:
+		m.PricePrecision = f.int32()
+	case 7:
+		m.AmountPrecision = f.int32()
+	}
+	return nil
+}
+
+// OrderPlaceRequest : 下单
+type OrderPlaceRequest struct {
+	Exchange string
+	Params   proto.OrderPlaceParams
+}
+
+func (m *OrderPlaceRequest) marshal(e *encoder) {
+	e.string(1, m.Exchange)
+	e.message(2, (*OrderPlaceParams)(&m.Params))
+}
+
+func (m *OrderPlaceRequest) unmarshal(f field) error {
+	switch f.num {
+	case 1:
+		m.Exchange = f.string()
+	case 2:
+		return f.decode((*OrderPlaceParams)(&m.Params))
+	}
+	return nil
+}
+
+// OrderPlaceReturn : proto.OrderPlaceReturn
+type OrderPlaceReturn proto.OrderPlaceReturn
+
+func (m *OrderPlaceReturn) marshal(e *encoder) {
+	e.string(1, m.OrderID)
+}
+
+func (m *OrderPlaceReturn) unmarshal(f field) error {
+	if f.num == 1 {
+		m.OrderID = f.string()
+	}
+	return nil
+}
+
+// orderRef : proto.OrderCancelParams 和 proto.OrderInfoParams 的字段相同
+type orderRef struct {
+	OrderID         string
+	BaseCurrencyID  string
+	QuoteCurrencyID string
+}
+
+func (m *orderRef) marshal(e *encoder) {
+	e.string(1, m.OrderID)
+	e.string(2, m.BaseCurrencyID)
+	e.string(3, m.QuoteCurrencyID)
+}
+
+func (m *orderRef) unmarshal(f field) error {
+	switch f.num {
+	case 1:
+		m.OrderID = f.string()
+	case 2:
+		m.BaseCurrencyID = f.string()
+	case 3:
+		m.QuoteCurrencyID = f.string()
+	}
+	return nil
+}
+
+// OrderCancelRequest : 撤单
+type OrderCancelRequest struct {
+	Exchange string
+	Params   proto.OrderCancelParams
+}
+
+func (m *OrderCancelRequest) marshal(e *encoder) {
+	e.string(1, m.Exchange)
+	e.message(2, (*orderRef)(&m.Params))
+}
+
+func (m *OrderCancelRequest) unmarshal(f field) error {
+	switch f.num {
+	case 1:
+		m.Exchange = f.string()
+	case 2:
+		return f.decode((*orderRef)(&m.Params))
+	}
+	return nil
+}
+
+// OrderInfoRequest : 查询订单
+type OrderInfoRequest struct {
+	Exchange string
+	Params   proto.OrderInfoParams
+}
+
+func (m *OrderInfoRequest) marshal(e *encoder) {
+	e.string(1, m.Exchange)
+	e.message(2, (*orderRef)(&m.Params))
+}
+
+func (m *OrderInfoRequest) unmarshal(f field) error {
+	switch f.num {
+	case 1:
+		m.Exchange = f.string()
+	case 2:
+		return f.decode((*orderRef)(&m.Params))
+	}
+	return nil
+}
+
+// Order : proto.Order
+type Order proto.Order
+
+func (m *Order) marshal(e *encoder) {
+	e.string(1, m.ID)
+	e.string(2, m.Symbol)
+	e.string(3, m.State)
+	e.double(4, m.Amount)
+	e.double(5, m.FieldAmount)
+	e.double(6, m.Price)
+	e.string(7, m.Type)
+	e.int64(8, m.CreatedSec)
+	e.double(9, m.FieldCashAmount)
+}
+
+func (m *Order) unmarshal(f field) error {
+	switch f.num {
+	case 1:
+		m.ID = f.string()
+	case 2:
+		m.Symbol = f.string()
+	case 3:
+		m.State = f.string()
+	case 4:
+		m.Amount = f.double()
+	case 5:
+		m.FieldAmount = f.double()
+	case 6:
+		m.Price = f.double()
+	case 7:
+		m.Type = f.string()
+	case 8:
+		m.CreatedSec = f.int64()
+	case 9:
+		m.FieldCashAmount = f.double()
+	}
+	return nil
+}
+
+// OrdersParams : proto.OrdersParams
+type OrdersParams proto.OrdersParams
+
+func (m *OrdersParams) marshal(e *encoder) {
+	e.string(1, m.Symbol)
+	e.string(2, m.States)
+	e.string(3, m.BaseCurrencyID)
+	e.string(4, m.QuoteCurrencyID)
+	e.string(5, m.Status)
+	e.string(6, m.CurrentPage)
+	e.string(7, m.PageLength)
+}
+
+func (m *OrdersParams) unmarshal(f field) error {
+	switch f.num {
+	case 1:
+		m.Symbol = f.string()
+	case 2:
+		m.States = f.string()
+	case 3:
+		m.BaseCurrencyID = f.string()
+	case 4:
+		m.QuoteCurrencyID = f.string()
+	case 5:
+		m.Status = f.string()
+	case 6:
+		m.CurrentPage = f.string()
+	case 7:
+		m.PageLength = f.string()
+	}
+	return nil
+}
+
+// OrdersRequest : 查询订单列表
+type OrdersRequest struct {
+	Exchange string
+	Params   proto.OrdersParams
+}
+
+func (m *OrdersRequest) marshal(e *encoder) {
+	e.string(1, m.Exchange)
+	e.message(2, (*OrdersParams)(&m.Params))
+}
+
+func (m *OrdersRequest) unmarshal(f field) error {
+	switch f.num {
+	case 1:
+		m.Exchange = f.string()
+	case 2:
+		return f.decode((*OrdersParams)(&m.Params))
+	}
+	return nil
+}
+
+// OrderList : 订单列表
+type OrderList struct {
+	Orders []proto.Order
+}
+
+func (m *OrderList) marshal(e *encoder) {
+	for i := range m.Orders {
+		e.message(1, (*Order)(&m.Orders[i]))
+	}
+}
+
+func (m *OrderList) unmarshal(f field) error {
+	if f.num == 1 {
+		var o Order
+		if err := f.decode(&o); err != nil {
+			return err
+		}
+		m.Orders = append(m.Orders, proto.Order(o))
+	}
+	return nil
+}
+
+// DepthLevel : proto.DepthLevel
+type DepthLevel proto.DepthLevel
+
+func (m *DepthLevel) marshal(e *encoder) {
+	e.double(1, m.Price)
+	e.double(2, m.Amount)
+}
+
+func (m *DepthLevel) unmarshal(f field) error {
+	switch f.num {
+	case 1:
+		m.Price = f.double()
+	case 2:
+		m.Amount = f.double()
+	}
+	return nil
+}
+
+// Depth : proto.Depth
+type Depth proto.Depth
+
+func (m *Depth) marshal(e *encoder) {
+	e.string(1, m.Symbol)
+	for i := range m.Bids {
+		e.message(2, (*DepthLevel)(&m.Bids[i]))
+	}
+	for i := range m.Asks {
+		e.message(3, (*DepthLevel)(&m.Asks[i]))
+	}
+	e.int64(4, m.TS)
+}
+
+func (m *Depth) unmarshal(f field) error {
+	switch f.num {
+	case 1:
+		m.Symbol = f.string()
+	case 2, 3:
+		var l DepthLevel
+		if err := f.decode(&l); err != nil {
+			return err
+		}
+		if f.num == 2 {
+			m.Bids = append(m.Bids, proto.DepthLevel(l))
+		} else {
+			m.Asks = append(m.Asks, proto.DepthLevel(l))
+		}
+	case 4:
+		m.TS = f.int64()
+	}
+	return nil
+}
+
+// Trade : proto.Trade
+type Trade proto.Trade
+
+func (m *Trade) marshal(e *encoder) {
+	e.string(1, m.ID)
+	e.string(2, m.Symbol)
+	e.double(3, m.Price)
+	e.double(4, m.Amount)
+	e.string(5, m.Side)
+	e.int64(6, m.TS)
+}
+
+func (m *Trade) unmarshal(f field) error {
+	switch f.num {
+	case 1:
+		m.ID = f.string()
+	case 2:
+		m.Symbol = f.string()
+	case 3:
+		m.Price = f.double()
+	case 4:
+		m.Amount = f.double()
+	case 5:
+		m.Side = f.string()
+	case 6:
+		m.TS = f.int64()
+	}
+	return nil
+}
+
+// MarketRequest : 行情推送的条件, 为空的项不过滤
+type MarketRequest struct {
+	Kinds     []string // stream.KindDepth, stream.KindTrade
+	Exchanges []string // 交易所类型
+	Symbols   []string
+}
+
+func (m *MarketRequest) marshal(e *encoder) {
+	e.strings(1, m.Kinds)
+	e.strings(2, m.Exchanges)
+	e.strings(3, m.Symbols)
+}
+
+func (m *MarketRequest) unmarshal(f field) error {
+	switch f.num {
+	case 1:
+		m.Kinds = append(m.Kinds, f.string())
+	case 2:
+		m.Exchanges = append(m.Exchanges, f.string())
+	case 3:
+		m.Symbols = append(m.Symbols, f.string())
+	}
+	return nil
+}
+
+// MarketEvent : 一条行情推送, 按 Kind 只有 Depth 或 Trade
+type MarketEvent struct {
+	Kind     string
+	Exchange string
+	Symbol   string
+	Depth    *proto.Depth
+	Trade    *proto.Trade
+}
+
+func (m *MarketEvent) marshal(e *encoder) {
+	e.string(1, m.Kind)
+	e.string(2, m.Exchange)
+	e.string(3, m.Symbol)
+	if m.Depth != nil {
+		e.message(4, (*Depth)(m.Depth))
+	}
+	if m.Trade != nil {
+		e.message(5, (*Trade)(m.Trade))
+	}
+}
+
+func (m *MarketEvent) unmarshal(f field) error {
+	switch f.num {
+	case 1:
+		m.Kind = f.string()
+	case 2:
+		m.Exchange = f.string()
+	case 3:
+		m.Symbol = f.string()
+	case 4:
+		m.Depth = new(proto.Depth)
+		return f.decode((*Depth)(m.Depth))
+	case 5:
+		m.Trade = new(proto.Trade)
+		return f.decode((*Trade)(m.Trade))
+	}
+	return nil
+}
+
+// OrderStreamRequest : 订单推送的交易所实例, 为空时为全部有权限的实例
+type OrderStreamRequest struct {
+	Exchanges []string
+}
+
+func (m *OrderStreamRequest) marshal(e *encoder) {
+	e.strings(1, m.Exchanges)
+}
+
+func (m *OrderStreamRequest) unmarshal(f field) error {
+	if f.num == 1 {
+		m.Exchanges = append(m.Exchanges, f.string())
+	}
+	return nil
+}
+
+// OrderEvent : 一条订单推送
+type OrderEvent struct {
+	Exchange string
+	Order    proto.Order
+}
+
+func (m *OrderEvent) marshal(e *encoder) {
+	e.string(1, m.Exchange)
+	e.message(2, (*Order)(&m.Order))
+}
+
+func (m *OrderEvent) unmarshal(f field) error {
+	switch f.num {
+	case 1:
+		m.Exchange = f.string()
+	case 2:
+		return f.decode((*Order)(&m.Order))
+	}
+	return nil
+}
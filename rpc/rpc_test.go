@@ -0,0 +1,150 @@
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/gpmn/sheep"
+	"github.com/gpmn/sheep/gateway"
+	"github.com/gpmn/sheep/proto"
+	"github.com/gpmn/sheep/risk"
+	"github.com/gpmn/sheep/stream"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+type fakeExchange struct{}
+
+func (fakeExchange) GetExchangeType() string { return "fake" }
+
+func (fakeExchange) GetAccountBalance() ([]proto.AccountBalance, error) {
+	return []proto.AccountBalance{{Currency: "usdt", Balance: 1.5, Type: proto.AccountBalanceTypeTrade}}, nil
+}
+
+func (fakeExchange) OrderPlace(params *proto.OrderPlaceParams) (*proto.OrderPlaceReturn, error) {
+	return &proto.OrderPlaceReturn{OrderID: "42"}, nil
+}
+
+func (fakeExchange) OrderCancel(params *proto.OrderCancelParams) error { return nil }
+
+func (fakeExchange) GetOrderInfo(params *proto.OrderInfoParams) (*proto.Order, error) {
+	return &proto.Order{ID: params.OrderID, State: proto.OrderStateFilled, Amount: 1, FieldAmount: 1, CreatedSec: -1}, nil
+}
+
+func (fakeExchange) GetOrders(params *proto.OrdersParams) ([]proto.Order, error) {
+	return []proto.Order{{ID: "1", Symbol: params.Symbol}, {ID: "2", Symbol: params.Symbol}}, nil
+}
+
+func TestCodec(t *testing.T) {
+	buf, err := Codec{}.Marshal(&OrderPlaceReturn{OrderID: "42"})
+	if err != nil || !bytes.Equal(buf, []byte{0x0a, 0x02, '4', '2'}) {
+		t.Fatalf("%x %v", buf, err)
+	}
+	in := &OrderPlaceRequest{Exchange: "main", Params: proto.OrderPlaceParams{Price: 0.1, Amount: 2, Type: "buy-limit", PricePrecision: -1}}
+	buf, _ = Codec{}.Marshal(in)
+	var out OrderPlaceRequest
+	if err := (Codec{}).Unmarshal(buf, &out); err != nil || out != *in {
+		t.Fatalf("%+v %v", out, err)
+	}
+	if err := (Codec{}).Unmarshal([]byte{0x0a, 0x05}, &out); err == nil {
+		t.Fatal("truncated message accepted")
+	}
+}
+
+func TestServer(t *testing.T) {
+	hub := stream.NewHub(0)
+	s := New(map[string]sheep.ExchageI{
+		"main":  risk.New(fakeExchange{}, risk.Limits{MaxOrderNotional: 100}),
+		"other": fakeExchange{},
+	}, []gateway.Client{
+		{Name: "trader", Token: "t1", Exchanges: []string{"main"}, Trade: true},
+		{Name: "viewer", Token: "t2"},
+	}, hub)
+	srv := NewGRPCServer(s)
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go srv.Serve(lis)
+	defer srv.Stop()
+
+	cc, err := grpc.Dial(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cc.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	trader, viewer := NewClient(cc, "t1"), NewClient(cc, "t2")
+
+	if _, err := NewClient(cc, "bad").ListExchanges(ctx); status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("got %v", err)
+	}
+	if list, err := trader.ListExchanges(ctx); err != nil || len(list) != 1 || list[0].Type != "fake" {
+		t.Fatalf("%+v %v", list, err)
+	}
+	if _, err := trader.GetAccountBalance(ctx, "other"); status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("got %v", err)
+	}
+	if b, err := viewer.GetAccountBalance(ctx, "other"); err != nil || len(b) != 1 || b[0].Balance != 1.5 {
+		t.Fatalf("%+v %v", b, err)
+	}
+	params := &proto.OrderPlaceParams{Price: 10, Amount: 1, BaseCurrencyID: "btc", QuoteCurrencyID: "usdt", Type: "buy-limit"}
+	if _, err := viewer.OrderPlace(ctx, "main", params); status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("got %v", err)
+	}
+
+	orders, err := trader.StreamOrders(ctx, &OrderStreamRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	market, err := viewer.StreamMarket(ctx, &MarketRequest{Kinds: []string{stream.KindTrade}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ex := trader.Exchange("main")
+	ret, err := ex.OrderPlace(params)
+	if err != nil || ret.OrderID != "42" {
+		t.Fatalf("%+v %v", ret, err)
+	}
+	big := *params
+	big.Price = 1000
+	if _, err := ex.OrderPlace(&big); status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("got %v", err)
+	}
+	if o, err := ex.GetOrderInfo(&proto.OrderInfoParams{OrderID: "42"}); err != nil || o.State != proto.OrderStateFilled || o.CreatedSec != -1 {
+		t.Fatalf("%+v %v", o, err)
+	}
+	if list, err := ex.GetOrders(&proto.OrdersParams{Symbol: "btcusdt"}); err != nil || len(list) != 2 || list[1].Symbol != "btcusdt" {
+		t.Fatalf("%+v %v", list, err)
+	}
+	if err := ex.OrderCancel(&proto.OrderCancelParams{OrderID: "42"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 下单和查询各产生一条订单推送
+	for _, state := range []string{proto.OrderStateSubmitted, proto.OrderStateFilled} {
+		ev, err := orders.Recv()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ev.Exchange != "main" || ev.Order.ID != "42" || ev.Order.State != state {
+			t.Fatalf("order event %+v", ev)
+		}
+	}
+
+	hub.OnTrade("huobi", "btcusdt", &proto.Trade{Symbol: "btcusdt", Price: 100, Side: proto.TradeSideBuy})
+	ev, err := market.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ev.Kind != stream.KindTrade || ev.Trade == nil || ev.Trade.Price != 100 || ev.Depth != nil {
+		t.Fatalf("market event %+v", ev)
+	}
+}
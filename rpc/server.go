@@ -0,0 +1,337 @@
+package rpc
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/gpmn/sheep"
+	"github.com/gpmn/sheep/gateway"
+	"github.com/gpmn/sheep/risk"
+	"github.com/gpmn/sheep/stream"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// ServiceName sheep.proto 中的服务名
+const ServiceName = "sheep.v1.Exchange"
+
+// Server : sheep.v1.Exchange 服务, 和 gateway.Server 一样每个交易所实例都经过 risk.Guard,
+// 使用相同的令牌和权限, 两者共用 hub 和 Guard 时订单推送和风控状态一致
+type Server struct {
+	exchanges map[string]*risk.Guard
+	clients   []gateway.Client
+	hub       *stream.Hub
+}
+
+// New 创建服务, 参数同 gateway.New
+func New(exchanges map[string]sheep.ExchageI, clients []gateway.Client, hub *stream.Hub) *Server {
+	s := &Server{
+		exchanges: make(map[string]*risk.Guard),
+		clients:   clients,
+		hub:       hub,
+	}
+	for name, ex := range exchanges {
+		g, ok := ex.(*risk.Guard)
+		if !ok {
+			g = risk.New(ex, risk.Limits{})
+		}
+		g.SetListener(hub.OrderListener(name))
+		s.exchanges[name] = g
+	}
+	return s
+}
+
+// NewGRPCServer 创建 grpc.Server 并注册服务, 使用本包的 Codec
+func NewGRPCServer(s *Server, opts ...grpc.ServerOption) *grpc.Server {
+	opts = append([]grpc.ServerOption{grpc.ForceServerCodec(Codec{})}, opts...)
+	srv := grpc.NewServer(opts...)
+	Register(srv, s)
+	return srv
+}
+
+// Register 在 grpc.Server 上注册服务, grpc.Server 需要使用 ForceServerCodec(Codec{})
+func Register(srv *grpc.Server, s *Server) {
+	srv.RegisterService(&serviceDesc, s)
+}
+
+// authorize 按 metadata authorization: Bearer <token> 查找调用方
+func (s *Server) authorize(ctx context.Context) (*gateway.Client, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, v := range md.Get("authorization") {
+		if c := gateway.FindClient(s.clients, strings.TrimPrefix(v, "Bearer ")); c != nil {
+			return c, nil
+		}
+	}
+	return nil, status.Error(codes.Unauthenticated, "unauthorized")
+}
+
+// exchange 检查权限并返回交易所实例, trade 为是否修改订单
+func (s *Server) exchange(ctx context.Context, name string, trade bool) (sheep.ExchageI, error) {
+	c, err := s.authorize(ctx)
+	if err != nil {
+		return nil, err
+	}
+	g, ok := s.exchanges[name]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "exchange %q not found", name)
+	}
+	if !c.Allowed(name) || (trade && !c.Trade) {
+		return nil, status.Error(codes.PermissionDenied, "forbidden")
+	}
+	return g, nil
+}
+
+// exchangeError 风控拒绝为 FailedPrecondition, 其他为交易所返回的错误
+func exchangeError(err error) error {
+	if e, ok := err.(*risk.RejectError); ok {
+		return status.Error(codes.FailedPrecondition, e.Error())
+	}
+	return status.Error(codes.Unavailable, err.Error())
+}
+
+// ListExchanges 调用方可以访问的交易所实例
+func (s *Server) ListExchanges(ctx context.Context, req *Empty) (*ExchangeList, error) {
+	c, err := s.authorize(ctx)
+	if err != nil {
+		return nil, err
+	}
+	res := &ExchangeList{}
+	for name, g := range s.exchanges {
+		if c.Allowed(name) {
+			res.Exchanges = append(res.Exchanges, ExchangeInfo{Name: name, Type: g.GetExchangeType()})
+		}
+	}
+	sort.Slice(res.Exchanges, func(i, j int) bool { return res.Exchanges[i].Name < res.Exchanges[j].Name })
+	return res, nil
+}
+
+// GetAccountBalance 余额
+func (s *Server) GetAccountBalance(ctx context.Context, req *ExchangeRequest) (*BalanceList, error) {
+	ex, err := s.exchange(ctx, req.Exchange, false)
+	if err != nil {
+		return nil, err
+	}
+	balances, err := ex.GetAccountBalance()
+	if err != nil {
+		return nil, exchangeError(err)
+	}
+	return &BalanceList{Balances: balances}, nil
+}
+
+// OrderPlace 下单
+func (s *Server) OrderPlace(ctx context.Context, req *OrderPlaceRequest) (*OrderPlaceReturn, error) {
+	ex, err := s.exchange(ctx, req.Exchange, true)
+	if err != nil {
+		return nil, err
+	}
+	// proto3 不区分 0 和未设置, 精度为0时使用价格和数量的小数位数
+	gateway.FillPrecision(&req.Params)
+	ret, err := ex.OrderPlace(&req.Params)
+	if err != nil {
+		return nil, exchangeError(err)
+	}
+	if ret == nil {
+		return &OrderPlaceReturn{}, nil
+	}
+	return (*OrderPlaceReturn)(ret), nil
+}
+
+// OrderCancel 撤单
+func (s *Server) OrderCancel(ctx context.Context, req *OrderCancelRequest) (*Empty, error) {
+	ex, err := s.exchange(ctx, req.Exchange, true)
+	if err != nil {
+		return nil, err
+	}
+	if err := ex.OrderCancel(&req.Params); err != nil {
+		return nil, exchangeError(err)
+	}
+	return &Empty{}, nil
+}
+
+// GetOrderInfo 订单详情
+func (s *Server) GetOrderInfo(ctx context.Context, req *OrderInfoRequest) (*Order, error) {
+	ex, err := s.exchange(ctx, req.Exchange, false)
+	if err != nil {
+		return nil, err
+	}
+	order, err := ex.GetOrderInfo(&req.Params)
+	if err != nil {
+		return nil, exchangeError(err)
+	}
+	if order == nil {
+		return &Order{}, nil
+	}
+	return (*Order)(order), nil
+}
+
+// GetOrders 订单列表
+func (s *Server) GetOrders(ctx context.Context, req *OrdersRequest) (*OrderList, error) {
+	ex, err := s.exchange(ctx, req.Exchange, false)
+	if err != nil {
+		return nil, err
+	}
+	orders, err := ex.GetOrders(&req.Params)
+	if err != nil {
+		return nil, exchangeError(err)
+	}
+	return &OrderList{Orders: orders}, nil
+}
+
+// StreamMarket 行情推送, 直到客户端取消; 读得慢时丢弃推送, 见 stream.Hub
+func (s *Server) StreamMarket(req *MarketRequest, ss grpc.ServerStream) error {
+	if _, err := s.authorize(ss.Context()); err != nil {
+		return err
+	}
+	kinds := req.Kinds
+	if len(kinds) == 0 {
+		kinds = []string{stream.KindDepth, stream.KindTrade}
+	}
+	for _, kind := range kinds {
+		if kind != stream.KindDepth && kind != stream.KindTrade {
+			return status.Errorf(codes.InvalidArgument, "unknown kind %q", kind)
+		}
+	}
+	sub := s.hub.Subscribe(stream.Filter{Kinds: kinds, Exchanges: req.Exchanges, Symbols: req.Symbols})
+	defer s.hub.Unsubscribe(sub)
+	// 订阅之后再发送响应头, 客户端收到响应头后的推送不会遗漏
+	if err := ss.SendHeader(metadata.MD{}); err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case ev, ok := <-sub.C:
+			if !ok {
+				return nil
+			}
+			err := ss.SendMsg(&MarketEvent{Kind: ev.Kind, Exchange: ev.Exchange, Symbol: ev.Symbol, Depth: ev.Depth, Trade: ev.Trade})
+			if err != nil {
+				return err
+			}
+		case <-ss.Context().Done():
+			return nil
+		}
+	}
+}
+
+// StreamOrders 订单推送, 只包括调用方可以访问的交易所实例
+func (s *Server) StreamOrders(req *OrderStreamRequest, ss grpc.ServerStream) error {
+	c, err := s.authorize(ss.Context())
+	if err != nil {
+		return err
+	}
+	for _, name := range req.Exchanges {
+		if !c.Allowed(name) {
+			return status.Error(codes.PermissionDenied, "forbidden")
+		}
+	}
+	sub := s.hub.Subscribe(stream.Filter{Kinds: []string{stream.KindOrder}, Exchanges: req.Exchanges})
+	defer s.hub.Unsubscribe(sub)
+	// 订阅之后再发送响应头, 客户端收到响应头后的推送不会遗漏
+	if err := ss.SendHeader(metadata.MD{}); err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case ev, ok := <-sub.C:
+			if !ok {
+				return nil
+			}
+			if !c.Allowed(ev.Exchange) || ev.Order == nil {
+				continue
+			}
+			if err := ss.SendMsg(&OrderEvent{Exchange: ev.Exchange, Order: ev.Order.Order}); err != nil {
+				return err
+			}
+		case <-ss.Context().Done():
+			return nil
+		}
+	}
+}
+
+// exchangeService 用于 ServiceDesc.HandlerType 检查
+type exchangeService interface {
+	ListExchanges(context.Context, *Empty) (*ExchangeList, error)
+	GetAccountBalance(context.Context, *ExchangeRequest) (*BalanceList, error)
+	OrderPlace(context.Context, *OrderPlaceRequest) (*OrderPlaceReturn, error)
+	OrderCancel(context.Context, *OrderCancelRequest) (*Empty, error)
+	GetOrderInfo(context.Context, *OrderInfoRequest) (*Order, error)
+	GetOrders(context.Context, *OrdersRequest) (*OrderList, error)
+	StreamMarket(*MarketRequest, grpc.ServerStream) error
+	StreamOrders(*OrderStreamRequest, grpc.ServerStream) error
+}
+
+// unary 创建一元方法, call 把请求交给服务
+func unary(name string, newReq func() message, call func(s *Server, ctx context.Context, req message) (interface{}, error)) grpc.MethodDesc {
+	return grpc.MethodDesc{
+		MethodName: name,
+		Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+			req := newReq()
+			if err := dec(req); err != nil {
+				return nil, err
+			}
+			handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+				return call(srv.(*Server), ctx, req.(message))
+			}
+			if interceptor == nil {
+				return handler(ctx, req)
+			}
+			info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/" + ServiceName + "/" + name}
+			return interceptor(ctx, req, info, handler)
+		},
+	}
+}
+
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: ServiceName,
+	HandlerType: (*exchangeService)(nil),
+	Methods: []grpc.MethodDesc{
+		unary("ListExchanges", func() message { return new(Empty) }, func(s *Server, ctx context.Context, req message) (interface{}, error) {
+			return s.ListExchanges(ctx, req.(*Empty))
+		}),
+		unary("GetAccountBalance", func() message { return new(ExchangeRequest) }, func(s *Server, ctx context.Context, req message) (interface{}, error) {
+			return s.GetAccountBalance(ctx, req.(*ExchangeRequest))
+		}),
+		unary("OrderPlace", func() message { return new(OrderPlaceRequest) }, func(s *Server, ctx context.Context, req message) (interface{}, error) {
+			return s.OrderPlace(ctx, req.(*OrderPlaceRequest))
+		}),
+		unary("OrderCancel", func() message { return new(OrderCancelRequest) }, func(s *Server, ctx context.Context, req message) (interface{}, error) {
+			return s.OrderCancel(ctx, req.(*OrderCancelRequest))
+		}),
+		unary("GetOrderInfo", func() message { return new(OrderInfoRequest) }, func(s *Server, ctx context.Context, req message) (interface{}, error) {
+			return s.GetOrderInfo(ctx, req.(*OrderInfoRequest))
+		}),
+		unary("GetOrders", func() message { return new(OrdersRequest) }, func(s *Server, ctx context.Context, req message) (interface{}, error) {
+			return s.GetOrders(ctx, req.(*OrdersRequest))
+		}),
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamMarket",
+			ServerStreams: true,
+			Handler: func(srv interface{}, ss grpc.ServerStream) error {
+				req := new(MarketRequest)
+				if err := ss.RecvMsg(req); err != nil {
+					return err
+				}
+				return srv.(*Server).StreamMarket(req, ss)
+			},
+		},
+		{
+			StreamName:    "StreamOrders",
+			ServerStreams: true,
+			Handler: func(srv interface{}, ss grpc.ServerStream) error {
+				req := new(OrderStreamRequest)
+				if err := ss.RecvMsg(req); err != nil {
+					return err
+				}
+				return srv.(*Server).StreamOrders(req, ss)
+			},
+		},
+	},
+	Metadata: "sheep.proto",
+}
@@ -0,0 +1,181 @@
+package rpc
+
+import (
+	"fmt"
+	"math"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// message 手写 protobuf 编解码的消息, 字段编号见 sheep.proto
+type message interface {
+	marshal(e *encoder)
+	// unmarshal 处理一个字段, 未知字段忽略
+	unmarshal(f field) error
+}
+
+// encoder : proto3 编码, 默认值不输出
+type encoder struct {
+	b []byte
+}
+
+func (e *encoder) string(num protowire.Number, s string) {
+	if s == "" {
+		return
+	}
+	e.b = protowire.AppendTag(e.b, num, protowire.BytesType)
+	e.b = protowire.AppendString(e.b, s)
+}
+
+func (e *encoder) strings(num protowire.Number, list []string) {
+	for _, s := range list {
+		e.b = protowire.AppendTag(e.b, num, protowire.BytesType)
+		e.b = protowire.AppendString(e.b, s)
+	}
+}
+
+func (e *encoder) double(num protowire.Number, f float64) {
+	if f == 0 {
+		return
+	}
+	e.b = protowire.AppendTag(e.b, num, protowire.Fixed64Type)
+	e.b = protowire.AppendFixed64(e.b, math.Float64bits(f))
+}
+
+func (e *encoder) int64(num protowire.Number, v int64) {
+	if v == 0 {
+		return
+	}
+	e.b = protowire.AppendTag(e.b, num, protowire.VarintType)
+	e.b = protowire.AppendVarint(e.b, uint64(v))
+}
+
+// message 嵌套消息, nil 不输出; repeated 的每一项都输出
+func (e *encoder) message(num protowire.Number, m message) {
+	var sub encoder
+	m.marshal(&sub)
+	e.b = protowire.AppendTag(e.b, num, protowire.BytesType)
+	e.b = protowire.AppendBytes(e.b, sub.b)
+}
+
+// field : 解码出的一个字段
+type field struct {
+	num protowire.Number
+	typ protowire.Type
+	u   uint64 // varint, fixed32, fixed64
+	b   []byte // bytes
+	err *error // 第一个线路类型不符的错误, 由 decode 返回
+}
+
+// check 线路类型和字段定义不符时记录错误, 字段值按默认值处理
+func (f field) check(typ protowire.Type) bool {
+	if f.typ == typ {
+		return true
+	}
+	if *f.err == nil {
+		*f.err = fmt.Errorf("rpc : field %d has wire type %d, want %d", f.num, f.typ, typ)
+	}
+	return false
+}
+
+func (f field) string() string {
+	if !f.check(protowire.BytesType) {
+		return ""
+	}
+	return string(f.b)
+}
+
+func (f field) double() float64 {
+	if !f.check(protowire.Fixed64Type) {
+		return 0
+	}
+	return math.Float64frombits(f.u)
+}
+
+func (f field) int64() int64 {
+	if !f.check(protowire.VarintType) {
+		return 0
+	}
+	return int64(f.u)
+}
+
+func (f field) int32() int {
+	if !f.check(protowire.VarintType) {
+		return 0
+	}
+	return int(int32(f.u))
+}
+
+func (f field) decode(m message) error {
+	if !f.check(protowire.BytesType) {
+		return *f.err
+	}
+	return decode(f.b, m)
+}
+
+// decode 逐个字段交给 m.unmarshal, 已知字段的线路类型不符时返回错误
+func decode(b []byte, m message) error {
+	var typeErr error
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+		f := field{num: num, typ: typ, err: &typeErr}
+		switch typ {
+		case protowire.VarintType:
+			f.u, n = protowire.ConsumeVarint(b)
+		case protowire.Fixed64Type:
+			f.u, n = protowire.ConsumeFixed64(b)
+		case protowire.Fixed32Type:
+			var v uint32
+			v, n = protowire.ConsumeFixed32(b)
+			f.u = uint64(v)
+		case protowire.BytesType:
+			f.b, n = protowire.ConsumeBytes(b)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, b)
+		}
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+		if err := m.unmarshal(f); err != nil {
+			return err
+		}
+		if typeErr != nil {
+			return typeErr
+		}
+	}
+	return nil
+}
+
+// Codec : gRPC 编解码, 使用 protobuf 二进制格式, 和其他语言 protoc 生成的客户端兼容.
+// 只支持本包的消息类型, 服务端用 grpc.ForceServerCodec, 客户端用 grpc.ForceCodec
+type Codec struct{}
+
+// Marshal 编码
+func (Codec) Marshal(v interface{}) ([]byte, error) {
+	m, ok := v.(message)
+	if !ok {
+		return nil, fmt.Errorf("rpc : cannot marshal %T", v)
+	}
+	var e encoder
+	m.marshal(&e)
+	return e.b, nil
+}
+
+// Unmarshal 解码
+func (Codec) Unmarshal(data []byte, v interface{}) error {
+	m, ok := v.(message)
+	if !ok {
+		return fmt.Errorf("rpc : cannot unmarshal %T", v)
+	}
+	return decode(data, m)
+}
+
+// Name 和 protobuf 默认编解码同名, content-type 为 application/grpc+proto
+func (Codec) Name() string {
+	return "proto"
+}
@@ -0,0 +1,184 @@
+package rpc
+
+import (
+	"bufio"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gpmn/sheep/proto"
+	gproto "google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// loadDescriptor 从 sheep.proto 解析消息定义, 只支持本文件用到的语法
+func loadDescriptor(t *testing.T) protoreflect.FileDescriptor {
+	file, err := os.Open("sheep.proto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	fdp := &descriptorpb.FileDescriptorProto{
+		Name:    gproto.String("sheep.proto"),
+		Package: gproto.String("sheep.v1"),
+		Syntax:  gproto.String("proto3"),
+	}
+	scalars := map[string]descriptorpb.FieldDescriptorProto_Type{
+		"string": descriptorpb.FieldDescriptorProto_TYPE_STRING,
+		"double": descriptorpb.FieldDescriptorProto_TYPE_DOUBLE,
+		"int64":  descriptorpb.FieldDescriptorProto_TYPE_INT64,
+		"int32":  descriptorpb.FieldDescriptorProto_TYPE_INT32,
+	}
+	var msg *descriptorpb.DescriptorProto
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "//"); i >= 0 {
+			line = line[:i]
+		}
+		words := strings.Fields(strings.NewReplacer("=", " ", ";", " ").Replace(line))
+		switch {
+		case len(words) >= 2 && words[0] == "message":
+			msg = &descriptorpb.DescriptorProto{Name: gproto.String(words[1])}
+			fdp.MessageType = append(fdp.MessageType, msg)
+		case len(words) == 1 && words[0] == "}":
+			msg = nil
+		case msg != nil && len(words) >= 3:
+			f := &descriptorpb.FieldDescriptorProto{Label: descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum()}
+			if words[0] == "repeated" {
+				f.Label = descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum()
+				words = words[1:]
+			}
+			num, err := strconv.Atoi(words[2])
+			if err != nil {
+				t.Fatalf("bad field %q", line)
+			}
+			f.Name, f.Number = gproto.String(words[1]), gproto.Int32(int32(num))
+			if typ, ok := scalars[words[0]]; ok {
+				f.Type = typ.Enum()
+			} else {
+				f.Type = descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum()
+				f.TypeName = gproto.String(".sheep.v1." + words[0])
+			}
+			msg.Field = append(msg.Field, f)
+		}
+	}
+	fd, err := protodesc.NewFile(fdp, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fd
+}
+
+// checkKnown 确认所有字段都按定义解析, 没有因为编号或线路类型不符成为未知字段
+func checkKnown(t *testing.T, m protoreflect.Message) {
+	if len(m.GetUnknown()) > 0 {
+		t.Fatalf("%s has unknown fields", m.Descriptor().FullName())
+	}
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		if fd.Message() == nil {
+			return true
+		}
+		if fd.IsList() {
+			for i := 0; i < v.List().Len(); i++ {
+				checkKnown(t, v.List().Get(i).Message())
+			}
+		} else {
+			checkKnown(t, v.Message())
+		}
+		return true
+	})
+}
+
+func TestCodecCompatible(t *testing.T) {
+	fd := loadDescriptor(t)
+	messages := map[string]message{
+		"OrderPlaceRequest": &OrderPlaceRequest{Exchange: "huobi", Params: proto.OrderPlaceParams{
+			Price: 10.5, Amount: 0.01, BaseCurrencyID: "btc", QuoteCurrencyID: "usdt",
+			Type: proto.OrderPlaceTypeBuyLimit, PricePrecision: 2, AmountPrecision: -1,
+		}},
+		"OrderList": &OrderList{Orders: []proto.Order{
+			{ID: "1", Symbol: "btcusdt", State: proto.OrderStateFilled, Amount: 1, FieldAmount: 1, Price: 100, CreatedSec: -1, FieldCashAmount: 100},
+			{ID: "2"},
+		}},
+		"MarketEvent": &MarketEvent{Kind: "depth", Exchange: "huobi", Symbol: "btcusdt", Depth: &proto.Depth{
+			Symbol: "btcusdt", TS: 1,
+			Bids: []proto.DepthLevel{{Price: 100, Amount: 1}, {Price: 99, Amount: 2}},
+			Asks: []proto.DepthLevel{{Price: 101, Amount: 3}},
+		}},
+		"MarketRequest": &MarketRequest{Kinds: []string{"depth", "trade"}, Symbols: []string{"btcusdt"}},
+		"BalanceList":   &BalanceList{Balances: []proto.AccountBalance{{Currency: "usdt", Balance: 1.5, Type: "trade"}}},
+	}
+	var codec Codec
+	for name, m := range messages {
+		md := fd.Messages().ByName(protoreflect.Name(name))
+		if md == nil {
+			t.Fatalf("%s not in sheep.proto", name)
+		}
+
+		// Codec 编码, 按 .proto 定义解码
+		b, err := codec.Marshal(m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		dyn := dynamicpb.NewMessage(md)
+		if err := gproto.Unmarshal(b, dyn); err != nil {
+			t.Fatalf("%s : %v", name, err)
+		}
+		checkKnown(t, dyn)
+
+		// 按 .proto 定义编码, Codec 解码
+		b, err = gproto.Marshal(dyn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := reflect.New(reflect.TypeOf(m).Elem()).Interface().(message)
+		if err := codec.Unmarshal(b, got); err != nil {
+			t.Fatalf("%s : %v", name, err)
+		}
+		if !reflect.DeepEqual(got, m) {
+			t.Fatalf("%s : got %+v, want %+v", name, got, m)
+		}
+	}
+
+	// 按字段名设置的消息, 确认字段编号和类型一致
+	md := fd.Messages().ByName("OrderPlaceParams")
+	dyn := dynamicpb.NewMessage(md)
+	dyn.Set(md.Fields().ByName("price"), protoreflect.ValueOfFloat64(10.5))
+	dyn.Set(md.Fields().ByName("base_currency_id"), protoreflect.ValueOfString("btc"))
+	dyn.Set(md.Fields().ByName("amount_precision"), protoreflect.ValueOfInt32(4))
+	b, err := gproto.Marshal(dyn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var params OrderPlaceParams
+	if err := codec.Unmarshal(b, &params); err != nil {
+		t.Fatal(err)
+	}
+	if params.Price != 10.5 || params.BaseCurrencyID != "btc" || params.AmountPrecision != 4 {
+		t.Fatalf("params %+v", params)
+	}
+}
+
+func TestCodecWireType(t *testing.T) {
+	var codec Codec
+	// price = 1 是 double, 用 varint 编码
+	if err := codec.Unmarshal([]byte{1 << 3, 1}, new(OrderPlaceParams)); err == nil {
+		t.Fatal("varint accepted for double")
+	}
+	// params = 2 是消息, 用 fixed64 编码
+	b := []byte{2<<3 | 1, 0, 0, 0, 0, 0, 0, 0, 0}
+	if err := codec.Unmarshal(b, new(OrderPlaceRequest)); err == nil {
+		t.Fatal("fixed64 accepted for message")
+	}
+	// 未知字段的任何类型都忽略
+	if err := codec.Unmarshal([]byte{15 << 3, 1}, new(OrderPlaceParams)); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -1,9 +1,12 @@
 package sheep
 
 import (
+	"strings"
+
 	"github.com/gpmn/sheep/consts"
 	"github.com/gpmn/sheep/huobi"
 	"github.com/gpmn/sheep/okex"
+	"github.com/gpmn/sheep/paper"
 	"github.com/gpmn/sheep/proto"
 	"github.com/pkg/errors"
 )
@@ -22,13 +25,128 @@ type ExchageI interface {
 	GetOrders(params *proto.OrdersParams) ([]proto.Order, error)
 }
 
-func NewExchange(typ, accessKey, secretKey string) (ExchageI, error) {
+// MarketDataI 行情接口, 监听器收到统一的 proto 数据
+type MarketDataI interface {
+	SetDepthListener(listener func(symbol string, depth *proto.Depth))
+	SetTradeListener(listener func(symbol string, trade *proto.Trade))
+	SubscribeDepth(symbols ...string) error
+	SubscribeTrade(symbols ...string) error
+}
+
+// Option NewExchange 的选项
+type Option func(*options)
+
+type options struct {
+	interceptors []Interceptor
+	accountType  string
+	accountID    int64
+	passphrase   string
+}
+
+// ErrPassphraseUnsupported 交易所的API不使用 passphrase
+var ErrPassphraseUnsupported = errors.New("该交易所不支持 passphrase")
+
+// WithInterceptors 安装拦截器, 多次使用时按顺序追加, 第一个拦截器在最外层
+func WithInterceptors(interceptors ...Interceptor) Option {
+	return func(o *options) {
+		o.interceptors = append(o.interceptors, interceptors...)
+	}
+}
+
+// WithAccount 指定下单和查询余额使用的账户, 目前只有火币和模拟盘支持, 见 huobi.Huobi.UseAccount 和 paper.Account
+func WithAccount(accountType string, accountID int64) Option {
+	return func(o *options) {
+		o.accountType = accountType
+		o.accountID = accountID
+	}
+}
+
+// WithPassphrase 指定API密钥的 passphrase, 交易所不使用 passphrase 时 NewExchange 返回 ErrPassphraseUnsupported
+func WithPassphrase(passphrase string) Option {
+	return func(o *options) {
+		o.passphrase = passphrase
+	}
+}
+
+// NewExchange 创建交易所实例, typ 为 paper:<交易所> 时创建使用该交易所实时行情的模拟盘
+func NewExchange(typ, accessKey, secretKey string, opts ...Option) (ExchageI, error) {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	ex, err := newExchange(typ, accessKey, secretKey, &o)
+	if err != nil {
+		return nil, err
+	}
+	return Intercept(ex, o.interceptors...), nil
+}
+
+func newExchange(typ, accessKey, secretKey string, o *options) (ExchageI, error) {
+	account := o.accountType != "" || o.accountID != 0
+	if strings.HasPrefix(typ, paper.Prefix) {
+		name := strings.TrimPrefix(typ, paper.Prefix)
+		var p *paper.Exchange
+		var err error
+		if account {
+			p, err = paper.Account(name, o.accountType, o.accountID)
+		} else {
+			p, err = paper.New(name, paper.DefaultConfig, paper.DefaultBalances)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return p, nil
+	}
+
+	// 目前支持的交易所API都只使用 access key 和 secret key
+	if o.passphrase != "" && Supported(typ) {
+		return nil, ErrPassphraseUnsupported
+	}
 	switch typ {
 	case consts.ExchangeTypeHuobi:
-		return huobi.NewHuobi(accessKey, secretKey)
+		h, err := huobi.NewHuobi(accessKey, secretKey)
+		if err != nil {
+			return nil, err
+		}
+		if account {
+			if err := h.UseAccount(o.accountType, o.accountID); err != nil {
+				return nil, err
+			}
+		}
+		return h, nil
 	case consts.ExchangeTypeOKEX:
+		if account {
+			return nil, errors.New("该交易所不支持指定账户")
+		}
 		return okex.NewOKEX(accessKey, secretKey)
 	}
 
 	return nil, errors.New("不支持该交易所")
 }
+
+// Supported NewExchange 是否支持该类型
+func Supported(typ string) bool {
+	if strings.HasPrefix(typ, paper.Prefix) {
+		return paper.Supported(strings.TrimPrefix(typ, paper.Prefix))
+	}
+	switch typ {
+	case consts.ExchangeTypeHuobi, consts.ExchangeTypeOKEX:
+		return true
+	}
+	return false
+}
+
+// KeySetter 支持运行时更换密钥的交易所
+type KeySetter interface {
+	SetKeys(accessKey, secretKey string)
+}
+
+// SetKeys 更换交易所实例的密钥, 拦截器包装的实例会先 Unwrap
+func SetKeys(ex ExchageI, accessKey, secretKey string) error {
+	setter, ok := Unwrap(ex).(KeySetter)
+	if !ok {
+		return errors.Errorf("%s 不支持更换密钥", ex.GetExchangeType())
+	}
+	setter.SetKeys(accessKey, secretKey)
+	return nil
+}
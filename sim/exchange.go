@@ -0,0 +1,437 @@
+package sim
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gpmn/sheep/proto"
+)
+
+// ExchangeTypeSim 模拟交易所的默认类型名
+const ExchangeTypeSim = "sim"
+
+var (
+	// ErrInsufficientBalance 可用余额不足
+	ErrInsufficientBalance = errors.New("insufficient balance")
+	// ErrOrderNotFound 订单不存在
+	ErrOrderNotFound = errors.New("order not found")
+	// ErrOrderClosed 订单已完成或已撤销
+	ErrOrderClosed = errors.New("order already closed")
+)
+
+// Config : 撮合参数
+type Config struct {
+	Name     string        // GetExchangeType 返回值, 默认 ExchangeTypeSim
+	MakerFee float64       // 挂单手续费率
+	TakerFee float64       // 吃单手续费率
+	Latency  time.Duration // 下单和撤单到达交易所的延迟
+	Slippage float64       // 没有深度时市价单相对最新成交价的滑点比例
+}
+
+// DepthListener 深度监听器
+type DepthListener = func(symbol string, depth *proto.Depth)
+
+// TradeListener 成交监听器
+type TradeListener = func(symbol string, trade *proto.Trade)
+
+// FillListener 订单成交监听器
+type FillListener = func(fill *proto.Fill)
+
+type balance struct {
+	available float64
+	frozen    float64
+}
+
+type market struct {
+	depth *proto.Depth
+	taken map[float64]float64 // 当前深度各价位已被模拟订单吃掉的数量, 新深度到来时清空
+	last  float64             // 最新成交价
+	mid   float64             // 最新深度的中间价
+}
+
+// left 当前深度 price 价位还可以吃的数量
+func (m *market) left(price, amount float64) float64 {
+	if left := amount - m.taken[price]; left > 0 {
+		return left
+	}
+	return 0
+}
+
+// take 记录吃掉当前深度 price 价位的数量
+func (m *market) take(price, amount float64) {
+	if m.taken == nil {
+		m.taken = make(map[float64]float64)
+	}
+	m.taken[price] += amount
+}
+
+// Exchange : 模拟交易所, 实现 sheep.ExchageI 和 sheep.MarketDataI, 并发安全
+// 行情由 OnDepth/OnTrade 输入, 订单按行情撮合. 交易对为 base+quote 小写, 如 btcusdt.
+// 市价买单的 Amount 为花费的计价币数量, 与火币一致.
+// 模拟订单不会出现在输入的行情中: 同一份深度的挂单量被所有订单共同消耗, 下一份深度视为新的挂单量;
+// 成交推送先消耗同价位排在前面的挂单量, 所有模拟订单分到的成交量之和不超过推送的成交量.
+type Exchange struct {
+	config   Config
+	clock    func() time.Time
+	balances map[string]*balance
+	markets  map[string]*market
+	orders   map[string]*order
+	open     []*order // 未完成的订单, 按下单顺序
+	seq      int64
+
+	depthSubscribed map[string]bool
+	tradeSubscribed map[string]bool
+	depthListener   DepthListener
+	tradeListener   TradeListener
+	fillListener    FillListener
+
+	mutex sync.Mutex
+}
+
+// NewExchange 创建模拟交易所, balances 为各币种初始可用余额
+func NewExchange(config Config, balances map[string]float64) *Exchange {
+	if config.Name == "" {
+		config.Name = ExchangeTypeSim
+	}
+	e := &Exchange{
+		config:          config,
+		clock:           time.Now,
+		balances:        make(map[string]*balance),
+		markets:         make(map[string]*market),
+		orders:          make(map[string]*order),
+		depthSubscribed: make(map[string]bool),
+		tradeSubscribed: make(map[string]bool),
+	}
+	for currency, amount := range balances {
+		e.balances[strings.ToLower(currency)] = &balance{available: amount}
+	}
+	return e
+}
+
+// SetClock 设置时钟, 回测时使用行情时间
+func (e *Exchange) SetClock(clock func() time.Time) {
+	e.mutex.Lock()
+	e.clock = clock
+	e.mutex.Unlock()
+}
+
+// Now 当前时间
+func (e *Exchange) Now() time.Time {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return e.clock()
+}
+
+func (e *Exchange) GetExchangeType() string {
+	return e.config.Name
+}
+
+// SetDepthListener 设置深度监听器
+func (e *Exchange) SetDepthListener(listener func(symbol string, depth *proto.Depth)) {
+	e.mutex.Lock()
+	e.depthListener = listener
+	e.mutex.Unlock()
+}
+
+// SetTradeListener 设置成交监听器
+func (e *Exchange) SetTradeListener(listener func(symbol string, trade *proto.Trade)) {
+	e.mutex.Lock()
+	e.tradeListener = listener
+	e.mutex.Unlock()
+}
+
+// SetFillListener 设置订单成交监听器
+func (e *Exchange) SetFillListener(listener FillListener) {
+	e.mutex.Lock()
+	e.fillListener = listener
+	e.mutex.Unlock()
+}
+
+// SubscribeDepth 订阅深度, 只有订阅的交易对会回调监听器
+func (e *Exchange) SubscribeDepth(symbols ...string) error {
+	e.mutex.Lock()
+	for _, s := range symbols {
+		e.depthSubscribed[strings.ToLower(s)] = true
+	}
+	e.mutex.Unlock()
+	return nil
+}
+
+// SubscribeTrade 订阅成交, 只有订阅的交易对会回调监听器
+func (e *Exchange) SubscribeTrade(symbols ...string) error {
+	e.mutex.Lock()
+	for _, s := range symbols {
+		e.tradeSubscribed[strings.ToLower(s)] = true
+	}
+	e.mutex.Unlock()
+	return nil
+}
+
+// OnDepth 输入深度, 先撮合再回调监听器
+func (e *Exchange) OnDepth(symbol string, depth *proto.Depth) {
+	symbol = strings.ToLower(symbol)
+	e.mutex.Lock()
+	m := e.market(symbol)
+	m.depth = depth
+	m.taken = nil
+	if len(depth.Bids) > 0 && len(depth.Asks) > 0 {
+		m.mid = (depth.Bids[0].Price + depth.Asks[0].Price) / 2
+	}
+	fills := e.advance()
+	fills = append(fills, e.matchDepth(symbol)...)
+	listener := e.depthListener
+	if !e.depthSubscribed[symbol] {
+		listener = nil
+	}
+	fillListener := e.fillListener
+	e.mutex.Unlock()
+
+	notify(fillListener, fills)
+	if listener != nil {
+		listener(symbol, depth)
+	}
+}
+
+// OnTrade 输入成交, 先撮合再回调监听器
+func (e *Exchange) OnTrade(symbol string, trade *proto.Trade) {
+	symbol = strings.ToLower(symbol)
+	e.mutex.Lock()
+	e.market(symbol).last = trade.Price
+	fills := e.advance()
+	fills = append(fills, e.matchTrade(symbol, trade)...)
+	listener := e.tradeListener
+	if !e.tradeSubscribed[symbol] {
+		listener = nil
+	}
+	fillListener := e.fillListener
+	e.mutex.Unlock()
+
+	notify(fillListener, fills)
+	if listener != nil {
+		listener(symbol, trade)
+	}
+}
+
+// Advance 处理到达时间已过的下单和撤单, 没有行情时可定时调用
+func (e *Exchange) Advance() {
+	e.mutex.Lock()
+	fills := e.advance()
+	fillListener := e.fillListener
+	e.mutex.Unlock()
+
+	notify(fillListener, fills)
+}
+
+func notify(listener FillListener, fills []proto.Fill) {
+	if listener == nil {
+		return
+	}
+	for i := range fills {
+		listener(&fills[i])
+	}
+}
+
+func (e *Exchange) market(symbol string) *market {
+	m, ok := e.markets[symbol]
+	if !ok {
+		m = &market{}
+		e.markets[symbol] = m
+	}
+	return m
+}
+
+func (e *Exchange) balance(currency string) *balance {
+	b, ok := e.balances[currency]
+	if !ok {
+		b = &balance{}
+		e.balances[currency] = b
+	}
+	return b
+}
+
+// LastPrice 交易对的最新成交价, 没有成交时为深度中间价
+func (e *Exchange) LastPrice(symbol string) (float64, bool) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	m, ok := e.markets[strings.ToLower(symbol)]
+	switch {
+	case !ok:
+		return 0, false
+	case m.last != 0:
+		return m.last, true
+	case m.mid != 0:
+		return m.mid, true
+	default:
+		return 0, false
+	}
+}
+
+// Balances 各币种的总余额(可用加冻结)
+func (e *Exchange) Balances() map[string]float64 {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	res := make(map[string]float64)
+	for currency, b := range e.balances {
+		res[currency] = b.available + b.frozen
+	}
+	return res
+}
+
+func (e *Exchange) GetAccountBalance() ([]proto.AccountBalance, error) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	var currencies []string
+	for currency := range e.balances {
+		currencies = append(currencies, currency)
+	}
+	sort.Strings(currencies)
+
+	var res []proto.AccountBalance
+	for _, currency := range currencies {
+		b := e.balances[currency]
+		res = append(res, proto.AccountBalance{Currency: currency, Balance: b.available, Type: proto.AccountBalanceTypeTrade})
+		res = append(res, proto.AccountBalance{Currency: currency, Balance: b.frozen, Type: proto.AccountBalanceTypeFrozen})
+	}
+	return res, nil
+}
+
+func (e *Exchange) OrderPlace(params *proto.OrderPlaceParams) (*proto.OrderPlaceReturn, error) {
+	base, quote := strings.ToLower(params.BaseCurrencyID), strings.ToLower(params.QuoteCurrencyID)
+	if base == "" || quote == "" {
+		return nil, errors.New("base and quote currency required")
+	}
+	if params.Amount <= 0 {
+		return nil, fmt.Errorf("invalid amount %v", params.Amount)
+	}
+
+	o := &order{base: base, quote: quote}
+	switch params.Type {
+	case proto.OrderPlaceTypeBuyLimit:
+		o.buy = true
+	case proto.OrderPlaceTypeSellLimit:
+	case proto.OrderPlaceTypeBuyMarket:
+		o.buy, o.market = true, true
+	case proto.OrderPlaceTypeSellMarket:
+		o.market = true
+	default:
+		return nil, fmt.Errorf("unsupported order type %s", params.Type)
+	}
+	if !o.market && params.Price <= 0 {
+		return nil, fmt.Errorf("invalid price %v", params.Price)
+	}
+
+	e.mutex.Lock()
+
+	// 冻结资金
+	currency, frozen := base, params.Amount
+	if o.buy {
+		currency = quote
+		if !o.market {
+			frozen = params.Price * params.Amount
+		}
+	}
+	b := e.balance(currency)
+	if b.available < frozen {
+		e.mutex.Unlock()
+		return nil, ErrInsufficientBalance
+	}
+	b.available -= frozen
+	b.frozen += frozen
+	o.frozen = frozen
+	o.remaining = params.Amount
+
+	e.seq++
+	now := e.clock()
+	o.Order = proto.Order{
+		ID:         strconv.FormatInt(e.seq, 10),
+		Symbol:     base + quote,
+		State:      proto.OrderStateSubmitted,
+		Amount:     params.Amount,
+		Price:      params.Price,
+		Type:       params.Type,
+		CreatedSec: now.Unix(),
+	}
+	o.activeAt = now.Add(e.config.Latency)
+	e.orders[o.ID] = o
+	e.open = append(e.open, o)
+
+	// 没有延迟时立即撮合
+	var fills []proto.Fill
+	if e.config.Latency == 0 {
+		fills = e.advance()
+	}
+	fillListener := e.fillListener
+	e.mutex.Unlock()
+
+	notify(fillListener, fills)
+	return &proto.OrderPlaceReturn{OrderID: o.ID}, nil
+}
+
+func (e *Exchange) OrderCancel(params *proto.OrderCancelParams) error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	o, ok := e.orders[params.OrderID]
+	if !ok {
+		return ErrOrderNotFound
+	}
+	if o.closed() {
+		return ErrOrderClosed
+	}
+	if o.cancelAt.IsZero() {
+		o.cancelAt = e.clock().Add(e.config.Latency)
+	}
+	if e.config.Latency == 0 {
+		e.cancel(o)
+		e.prune()
+	}
+	return nil
+}
+
+func (e *Exchange) GetOrderInfo(params *proto.OrderInfoParams) (*proto.Order, error) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	o, ok := e.orders[params.OrderID]
+	if !ok {
+		return nil, ErrOrderNotFound
+	}
+	res := o.Order
+	return &res, nil
+}
+
+// GetOrders 查询订单, 按 Symbol 和 States(逗号分隔) 过滤, 为空表示不过滤
+func (e *Exchange) GetOrders(params *proto.OrdersParams) ([]proto.Order, error) {
+	states := make(map[string]bool)
+	for _, s := range strings.Split(params.States, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			states[s] = true
+		}
+	}
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	var res []proto.Order
+	for _, o := range e.orders {
+		if params.Symbol != "" && !strings.EqualFold(params.Symbol, o.Symbol) {
+			continue
+		}
+		if len(states) > 0 && !states[o.State] {
+			continue
+		}
+		res = append(res, o.Order)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		a, _ := strconv.ParseInt(res[i].ID, 10, 64)
+		b, _ := strconv.ParseInt(res[j].ID, 10, 64)
+		return a < b
+	})
+	return res, nil
+}
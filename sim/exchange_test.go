@@ -0,0 +1,180 @@
+package sim
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/gpmn/sheep/proto"
+)
+
+func depth(bids, asks []float64) *proto.Depth {
+	d := &proto.Depth{}
+	for i := 0; i+1 < len(bids); i += 2 {
+		d.Bids = append(d.Bids, proto.DepthLevel{Price: bids[i], Amount: bids[i+1]})
+	}
+	for i := 0; i+1 < len(asks); i += 2 {
+		d.Asks = append(d.Asks, proto.DepthLevel{Price: asks[i], Amount: asks[i+1]})
+	}
+	return d
+}
+
+func near(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestMarketOrderSlippage(t *testing.T) {
+	e := NewExchange(Config{TakerFee: 0.001}, map[string]float64{"usdt": 1000})
+	e.OnDepth("btcusdt", depth([]float64{99, 1}, []float64{100, 1, 101, 1}))
+
+	// 花费 150.5 usdt: 100*1 + 101*0.5
+	res, err := e.OrderPlace(&proto.OrderPlaceParams{Amount: 150.5, BaseCurrencyID: "btc", QuoteCurrencyID: "usdt", Type: proto.OrderPlaceTypeBuyMarket})
+	if err != nil {
+		t.Fatal(err)
+	}
+	o, _ := e.GetOrderInfo(&proto.OrderInfoParams{OrderID: res.OrderID})
+	if o.State != proto.OrderStateFilled || !near(o.FieldAmount, 1.5) {
+		t.Fatalf("order %+v", o)
+	}
+	b := e.Balances()
+	if !near(b["btc"], 1.5*0.999) || !near(b["usdt"], 849.5) {
+		t.Fatalf("balances %v", b)
+	}
+}
+
+func TestLimitQueue(t *testing.T) {
+	now := time.Unix(1000, 0)
+	e := NewExchange(Config{MakerFee: 0.002, Latency: time.Second}, map[string]float64{"btc": 2})
+	e.SetClock(func() time.Time { return now })
+	var fills []proto.Fill
+	e.SetFillListener(func(f *proto.Fill) { fills = append(fills, *f) })
+
+	e.OnDepth("btcusdt", depth([]float64{99, 1}, []float64{100, 3}))
+	res, err := e.OrderPlace(&proto.OrderPlaceParams{Price: 100, Amount: 2, BaseCurrencyID: "btc", QuoteCurrencyID: "usdt", Type: proto.OrderPlaceTypeSellLimit})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = e.OrderPlace(&proto.OrderPlaceParams{Price: 100, Amount: 1, BaseCurrencyID: "btc", QuoteCurrencyID: "usdt", Type: proto.OrderPlaceTypeSellLimit}); err != ErrInsufficientBalance {
+		t.Fatalf("got %v, want ErrInsufficientBalance", err)
+	}
+
+	// 延迟内的成交不影响订单
+	e.OnTrade("btcusdt", &proto.Trade{Price: 100, Amount: 5, Side: proto.TradeSideBuy})
+	if len(fills) != 0 {
+		t.Fatalf("filled before arrival %+v", fills)
+	}
+
+	// 到达后排在3个之后
+	now = now.Add(time.Second)
+	e.OnTrade("btcusdt", &proto.Trade{Price: 100, Amount: 2, Side: proto.TradeSideBuy})
+	e.OnTrade("btcusdt", &proto.Trade{Price: 100, Amount: 2, Side: proto.TradeSideBuy})
+	if len(fills) != 1 || !near(fills[0].Amount, 1) || !fills[0].Maker {
+		t.Fatalf("fills %+v", fills)
+	}
+	// 成交价穿过挂单价
+	e.OnTrade("btcusdt", &proto.Trade{Price: 100.5, Amount: 3, Side: proto.TradeSideBuy})
+	o, _ := e.GetOrderInfo(&proto.OrderInfoParams{OrderID: res.OrderID})
+	if o.State != proto.OrderStateFilled {
+		t.Fatalf("order %+v", o)
+	}
+	if b := e.Balances(); !near(b["usdt"], 200*0.998) || !near(b["btc"], 0) {
+		t.Fatalf("balances %v", b)
+	}
+}
+
+func TestCancelReleases(t *testing.T) {
+	e := NewExchange(Config{}, map[string]float64{"usdt": 100})
+	res, _ := e.OrderPlace(&proto.OrderPlaceParams{Price: 10, Amount: 5, BaseCurrencyID: "btc", QuoteCurrencyID: "usdt", Type: proto.OrderPlaceTypeBuyLimit})
+	if err := e.OrderCancel(&proto.OrderCancelParams{OrderID: res.OrderID}); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.OrderCancel(&proto.OrderCancelParams{OrderID: res.OrderID}); err != ErrOrderClosed {
+		t.Fatalf("got %v, want ErrOrderClosed", err)
+	}
+	balances, _ := e.GetAccountBalance()
+	for _, b := range balances {
+		if b.Currency == "usdt" && b.Type == proto.AccountBalanceTypeTrade && b.Balance != 100 {
+			t.Fatalf("balance %+v", b)
+		}
+	}
+}
+
+func TestSameLevelQueue(t *testing.T) {
+	e := NewExchange(Config{}, map[string]float64{"btc": 4})
+	var fills []proto.Fill
+	e.SetFillListener(func(f *proto.Fill) { fills = append(fills, *f) })
+
+	// 第一个订单排在1个之后, 第二个订单排在3个之后(包括第一个订单之后新挂的2个)
+	e.OnDepth("btcusdt", depth([]float64{99, 1}, []float64{100, 1}))
+	first, _ := e.OrderPlace(&proto.OrderPlaceParams{Price: 100, Amount: 2, BaseCurrencyID: "btc", QuoteCurrencyID: "usdt", Type: proto.OrderPlaceTypeSellLimit})
+	e.OnDepth("btcusdt", depth([]float64{99, 1}, []float64{100, 3}))
+	second, _ := e.OrderPlace(&proto.OrderPlaceParams{Price: 100, Amount: 2, BaseCurrencyID: "btc", QuoteCurrencyID: "usdt", Type: proto.OrderPlaceTypeSellLimit})
+
+	// 成交4个: 第一个订单前面的1个之后还有3个, 成交2个; 第二个订单前面的3个之后还有1个, 成交1个
+	e.OnTrade("btcusdt", &proto.Trade{Price: 100, Amount: 4, Side: proto.TradeSideBuy})
+	if len(fills) != 2 || fills[0].OrderID != first.OrderID || !near(fills[0].Amount, 2) ||
+		fills[1].OrderID != second.OrderID || !near(fills[1].Amount, 1) {
+		t.Fatalf("fills %+v", fills)
+	}
+	// 第二个订单前面已经没有挂单
+	e.OnTrade("btcusdt", &proto.Trade{Price: 100, Amount: 2, Side: proto.TradeSideBuy})
+	if len(fills) != 3 || fills[2].OrderID != second.OrderID || !near(fills[2].Amount, 1) {
+		t.Fatalf("fills %+v", fills)
+	}
+}
+
+func TestQueueAdvancesAfterVolumeUsed(t *testing.T) {
+	e := NewExchange(Config{}, map[string]float64{"btc": 4})
+	var fills []proto.Fill
+	e.SetFillListener(func(f *proto.Fill) { fills = append(fills, *f) })
+
+	// 第一个订单排在最前, 第二个订单排在3个之后
+	e.OnDepth("btcusdt", depth([]float64{99, 1}, []float64{101, 1}))
+	first, _ := e.OrderPlace(&proto.OrderPlaceParams{Price: 100, Amount: 3, BaseCurrencyID: "btc", QuoteCurrencyID: "usdt", Type: proto.OrderPlaceTypeSellLimit})
+	e.OnDepth("btcusdt", depth([]float64{99, 1}, []float64{100, 3}))
+	second, _ := e.OrderPlace(&proto.OrderPlaceParams{Price: 100, Amount: 1, BaseCurrencyID: "btc", QuoteCurrencyID: "usdt", Type: proto.OrderPlaceTypeSellLimit})
+
+	// 成交量全部分给第一个订单, 第二个订单前面的挂单也被吃掉
+	e.OnTrade("btcusdt", &proto.Trade{Price: 100, Amount: 3, Side: proto.TradeSideBuy})
+	if len(fills) != 1 || fills[0].OrderID != first.OrderID || !near(fills[0].Amount, 3) {
+		t.Fatalf("fills %+v", fills)
+	}
+	e.OnTrade("btcusdt", &proto.Trade{Price: 100, Amount: 1, Side: proto.TradeSideBuy})
+	if len(fills) != 2 || fills[1].OrderID != second.OrderID || !near(fills[1].Amount, 1) {
+		t.Fatalf("fills %+v", fills)
+	}
+}
+
+func TestDepthConsumed(t *testing.T) {
+	e := NewExchange(Config{}, map[string]float64{"usdt": 1000})
+	var fills []proto.Fill
+	e.SetFillListener(func(f *proto.Fill) { fills = append(fills, *f) })
+	buy := func() string {
+		res, err := e.OrderPlace(&proto.OrderPlaceParams{Price: 100, Amount: 1, BaseCurrencyID: "btc", QuoteCurrencyID: "usdt", Type: proto.OrderPlaceTypeBuyLimit})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res.OrderID
+	}
+
+	e.OnDepth("btcusdt", depth([]float64{99, 1}, []float64{101, 1}))
+	first, second := buy(), buy()
+
+	// 卖盘降到100, 1.5个按下单顺序分给两个订单
+	e.OnDepth("btcusdt", depth([]float64{99, 1}, []float64{100, 1.5}))
+	if len(fills) != 2 || fills[0].OrderID != first || !near(fills[0].Amount, 1) ||
+		fills[1].OrderID != second || !near(fills[1].Amount, 0.5) {
+		t.Fatalf("fills %+v", fills)
+	}
+	// 同一份深度已经吃完, 新订单只能挂单
+	third := buy()
+	if len(fills) != 2 {
+		t.Fatalf("fills %+v", fills)
+	}
+	// 新的深度
+	e.OnDepth("btcusdt", depth([]float64{99, 1}, []float64{100, 1}))
+	if len(fills) != 4 || fills[2].OrderID != second || !near(fills[2].Amount, 0.5) ||
+		fills[3].OrderID != third || !near(fills[3].Amount, 0.5) {
+		t.Fatalf("fills %+v", fills)
+	}
+}
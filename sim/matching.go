@@ -0,0 +1,289 @@
+package sim
+
+import (
+	"time"
+
+	"github.com/gpmn/sheep/proto"
+)
+
+// epsilon 浮点误差, 剩余数量小于该值视为完全成交
+const epsilon = 1e-9
+
+type order struct {
+	proto.Order
+	base, quote string
+	buy         bool
+	market      bool
+	remaining   float64 // 剩余数量, 市价买单为剩余的计价币
+	frozen      float64 // 剩余冻结资金
+	queue       float64 // 同价位排在前面的挂单量
+	activeAt    time.Time
+	cancelAt    time.Time
+	active      bool
+}
+
+func (o *order) closed() bool {
+	return o.State == proto.OrderStateFilled || o.State == proto.OrderStateCanceled
+}
+
+func (o *order) side() string {
+	if o.buy {
+		return proto.TradeSideBuy
+	}
+	return proto.TradeSideSell
+}
+
+// advance 激活到达交易所的订单, 执行到期的撤单
+func (e *Exchange) advance() []proto.Fill {
+	now := e.clock()
+	var fills []proto.Fill
+	for _, o := range e.open {
+		if o.closed() {
+			continue
+		}
+		if !o.active && !o.activeAt.After(now) {
+			o.active = true
+			fills = append(fills, e.activate(o)...)
+		}
+		if !o.closed() && !o.cancelAt.IsZero() && !o.cancelAt.After(now) {
+			e.cancel(o)
+		}
+	}
+	e.prune()
+	return fills
+}
+
+// prune 移除已完成的订单
+func (e *Exchange) prune() {
+	open := e.open[:0]
+	for _, o := range e.open {
+		if !o.closed() {
+			open = append(open, o)
+		}
+	}
+	for i := len(open); i < len(e.open); i++ {
+		e.open[i] = nil
+	}
+	e.open = open
+}
+
+// activate 订单到达交易所, 市价单和可成交的限价单立即吃单, 剩余限价单挂单排队
+func (e *Exchange) activate(o *order) []proto.Fill {
+	m := e.market(o.Symbol)
+	var levels []proto.DepthLevel
+	var same []proto.DepthLevel
+	if m.depth != nil {
+		levels, same = m.depth.Asks, m.depth.Bids
+		if !o.buy {
+			levels, same = m.depth.Bids, m.depth.Asks
+		}
+	}
+
+	var fills []proto.Fill
+	if o.market {
+		worst := m.last
+		if worst == 0 {
+			worst = m.mid
+		}
+		for _, l := range levels {
+			if o.remaining <= epsilon {
+				break
+			}
+			worst = l.Price
+			if left := m.left(l.Price, l.Amount); left > epsilon {
+				f := e.fill(o, l.Price, o.takeable(l.Price, left), false)
+				m.take(l.Price, f.Amount)
+				fills = append(fills, f)
+			}
+		}
+		// 深度不足或没有深度时按最差价位加滑点成交
+		if o.remaining > epsilon {
+			if worst == 0 {
+				e.cancel(o)
+				return fills
+			}
+			price := worst * (1 + e.config.Slippage)
+			if !o.buy {
+				price = worst * (1 - e.config.Slippage)
+			}
+			fills = append(fills, e.fill(o, price, o.takeable(price, -1), false))
+		}
+		return fills
+	}
+
+	for _, l := range levels {
+		if o.remaining <= epsilon || !o.crosses(l.Price) {
+			break
+		}
+		if left := m.left(l.Price, l.Amount); left > epsilon {
+			f := e.fill(o, l.Price, o.takeable(l.Price, left), false)
+			m.take(l.Price, f.Amount)
+			fills = append(fills, f)
+		}
+	}
+	if !o.closed() {
+		o.queue = levelAmount(same, o.Price)
+	}
+	return fills
+}
+
+// takeable 在price价位最多可成交的数量, available 小于0表示不限
+func (o *order) takeable(price, available float64) float64 {
+	amount := o.remaining
+	if o.market && o.buy {
+		amount = o.remaining / price
+	}
+	if available >= 0 && amount > available {
+		amount = available
+	}
+	return amount
+}
+
+// crosses 对手价price是否可以和限价单成交
+func (o *order) crosses(price float64) bool {
+	if o.buy {
+		return price <= o.Price
+	}
+	return price >= o.Price
+}
+
+func levelAmount(levels []proto.DepthLevel, price float64) float64 {
+	for _, l := range levels {
+		if l.Price == price {
+			return l.Amount
+		}
+	}
+	return 0
+}
+
+// matchDepth 深度更新后, 对手盘穿过挂单价时按挂单价成交, 同价位挂单减少时排队位置前移.
+// 对手盘的挂单量按下单顺序分给各订单
+func (e *Exchange) matchDepth(symbol string) []proto.Fill {
+	m := e.market(symbol)
+	depth := m.depth
+	var fills []proto.Fill
+	for _, o := range e.open {
+		if !o.active || o.closed() || o.market || o.Symbol != symbol {
+			continue
+		}
+		opposite, same := depth.Asks, depth.Bids
+		if !o.buy {
+			opposite, same = depth.Bids, depth.Asks
+		}
+		for _, l := range opposite {
+			if o.remaining <= epsilon || !o.crosses(l.Price) {
+				break
+			}
+			if left := m.left(l.Price, l.Amount); left > epsilon {
+				f := e.fill(o, o.Price, o.takeable(o.Price, left), true)
+				m.take(l.Price, f.Amount)
+				fills = append(fills, f)
+			}
+		}
+		if amount := levelAmount(same, o.Price); amount < o.queue {
+			o.queue = amount
+		}
+	}
+	e.prune()
+	return fills
+}
+
+// matchTrade 成交推送: 成交价优于挂单价时直接成交, 等于挂单价时成交量先消耗排在前面的挂单量, 超出的部分才能成交.
+// 同价位所有订单前面的挂单量都减少成交量; 分给前面订单的成交量不能再分给后面的订单, 同价位的订单按下单顺序成交
+func (e *Exchange) matchTrade(symbol string, t *proto.Trade) []proto.Fill {
+	available := t.Amount // 还可以分给模拟订单的成交量
+	var fills []proto.Fill
+	for _, o := range e.open {
+		if !o.active || o.closed() || o.market || o.Symbol != symbol || !o.crosses(t.Price) {
+			continue
+		}
+		// 主动买的成交只会和卖单成交, 反之亦然
+		if (o.buy && t.Side == proto.TradeSideBuy) || (!o.buy && t.Side == proto.TradeSideSell) {
+			continue
+		}
+		reach := available
+		if t.Price == o.Price {
+			if passed := t.Amount - o.queue; passed < reach {
+				reach = passed
+			}
+			if o.queue -= t.Amount; o.queue < 0 {
+				o.queue = 0
+			}
+		}
+		if reach <= epsilon {
+			continue
+		}
+		f := e.fill(o, o.Price, o.takeable(o.Price, reach), true)
+		available -= f.Amount
+		fills = append(fills, f)
+	}
+	e.prune()
+	return fills
+}
+
+// fill 记录一次成交, 更新订单和余额
+func (e *Exchange) fill(o *order, price, amount float64, maker bool) proto.Fill {
+	rate := e.config.TakerFee
+	if maker {
+		rate = e.config.MakerFee
+	}
+	f := proto.Fill{
+		OrderID: o.ID,
+		Symbol:  o.Symbol,
+		Side:    o.side(),
+		Price:   price,
+		Amount:  amount,
+		Maker:   maker,
+		TS:      e.clock().UnixNano() / int64(time.Millisecond),
+	}
+
+	base, quote := e.balance(o.base), e.balance(o.quote)
+	if o.buy {
+		cost := price * amount
+		o.frozen -= cost
+		quote.frozen -= cost
+		f.Fee, f.FeeCurrency = amount*rate, o.base
+		base.available += amount - f.Fee
+		if o.market {
+			o.remaining -= cost
+		} else {
+			o.remaining -= amount
+		}
+	} else {
+		o.frozen -= amount
+		base.frozen -= amount
+		f.Fee, f.FeeCurrency = price*amount*rate, o.quote
+		quote.available += price*amount - f.Fee
+		o.remaining -= amount
+	}
+
+	o.FieldAmount += amount
+	o.FieldCashAmount += price * amount
+	o.State = proto.OrderStatePartialFilled
+	if o.remaining <= epsilon {
+		o.State = proto.OrderStateFilled
+		e.release(o)
+	}
+	return f
+}
+
+// cancel 撤销订单, 解冻剩余资金
+func (e *Exchange) cancel(o *order) {
+	if o.closed() {
+		return
+	}
+	o.State = proto.OrderStateCanceled
+	e.release(o)
+}
+
+// release 解冻订单剩余的冻结资金
+func (e *Exchange) release(o *order) {
+	currency := o.base
+	if o.buy {
+		currency = o.quote
+	}
+	b := e.balance(currency)
+	b.frozen -= o.frozen
+	b.available += o.frozen
+	o.frozen = 0
+}
@@ -0,0 +1,180 @@
+package stream
+
+import (
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/gpmn/sheep/oms"
+	"github.com/gpmn/sheep/proto"
+)
+
+// 推送类型
+const (
+	KindDepth = "depth"
+	KindTrade = "trade"
+	KindOrder = "order"
+)
+
+// DefaultBuffer 每个订阅者的默认缓冲条数
+const DefaultBuffer = 256
+
+// Event : 一条推送, 按 Kind 只有 Depth, Trade, Order 之一
+type Event struct {
+	Kind     string       `json:"kind"`
+	Exchange string       `json:"exchange"` // 行情为交易所类型, 订单为交易所实例名称
+	Symbol   string       `json:"symbol"`
+	Depth    *proto.Depth `json:"depth,omitempty"`
+	Trade    *proto.Trade `json:"trade,omitempty"`
+	Order    *oms.Order   `json:"order,omitempty"`
+}
+
+// Filter 订阅条件, 为空的项不过滤
+type Filter struct {
+	Kinds     []string
+	Exchanges []string
+	Symbols   []string
+}
+
+func contains(list []string, s string) bool {
+	if len(list) == 0 {
+		return true
+	}
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// Match 推送是否符合条件
+func (f Filter) Match(ev *Event) bool {
+	return contains(f.Kinds, ev.Kind) && contains(f.Exchanges, ev.Exchange) && contains(f.Symbols, ev.Symbol)
+}
+
+// Subscription : 一个订阅者, 从 C 读取推送, Hub 关闭订阅时关闭 C
+type Subscription struct {
+	C <-chan Event
+
+	c       chan Event
+	filter  Filter
+	dropped int64
+}
+
+// Dropped 缓冲满时丢弃的推送数
+func (s *Subscription) Dropped() int64 {
+	return atomic.LoadInt64(&s.dropped)
+}
+
+// Hub : 把行情和订单推送分发给多个订阅者.
+// 推送不阻塞: 订阅者读得慢时丢弃新的推送并计数, 深度推送是快照, 丢弃后下一条仍然完整
+type Hub struct {
+	buffer int
+	subs   map[*Subscription]struct{}
+	depths map[string]*proto.Depth
+	trades map[string]*proto.Trade
+	mutex  sync.RWMutex
+}
+
+// NewHub 创建 Hub, buffer 为每个订阅者的缓冲条数, 为0时使用 DefaultBuffer
+func NewHub(buffer int) *Hub {
+	if buffer <= 0 {
+		buffer = DefaultBuffer
+	}
+	return &Hub{
+		buffer: buffer,
+		subs:   make(map[*Subscription]struct{}),
+		depths: make(map[string]*proto.Depth),
+		trades: make(map[string]*proto.Trade),
+	}
+}
+
+// Subscribe 按条件订阅, 用完后调用 Unsubscribe
+func (h *Hub) Subscribe(filter Filter) *Subscription {
+	c := make(chan Event, h.buffer)
+	s := &Subscription{C: c, c: c, filter: filter}
+	h.mutex.Lock()
+	h.subs[s] = struct{}{}
+	h.mutex.Unlock()
+	return s
+}
+
+// Unsubscribe 取消订阅并关闭 s.C
+func (h *Hub) Unsubscribe(s *Subscription) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if _, ok := h.subs[s]; ok {
+		delete(h.subs, s)
+		close(s.c)
+	}
+}
+
+// Close 取消所有订阅
+func (h *Hub) Close() {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	for s := range h.subs {
+		delete(h.subs, s)
+		close(s.c)
+	}
+}
+
+func key(exchange, symbol string) string {
+	return exchange + "/" + strings.ToLower(symbol)
+}
+
+// Publish 分发一条推送, 同时记录最新的深度和成交
+func (h *Hub) Publish(ev Event) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	switch ev.Kind {
+	case KindDepth:
+		h.depths[key(ev.Exchange, ev.Symbol)] = ev.Depth
+	case KindTrade:
+		h.trades[key(ev.Exchange, ev.Symbol)] = ev.Trade
+	}
+	for s := range h.subs {
+		if !s.filter.Match(&ev) {
+			continue
+		}
+		select {
+		case s.c <- ev:
+		default:
+			atomic.AddInt64(&s.dropped, 1)
+		}
+	}
+}
+
+// OnDepth 深度监听器, 参数和 config.Runtime.OpenFeeds 一致
+func (h *Hub) OnDepth(exchange, symbol string, depth *proto.Depth) {
+	h.Publish(Event{Kind: KindDepth, Exchange: exchange, Symbol: symbol, Depth: depth})
+}
+
+// OnTrade 成交监听器, 参数和 config.Runtime.OpenFeeds 一致
+func (h *Hub) OnTrade(exchange, symbol string, trade *proto.Trade) {
+	h.Publish(Event{Kind: KindTrade, Exchange: exchange, Symbol: symbol, Trade: trade})
+}
+
+// OrderListener 返回 oms.Listener, 把交易所实例 exchange 的订单变化作为推送
+func (h *Hub) OrderListener(exchange string) oms.Listener {
+	return func(order oms.Order, prevState string) {
+		h.Publish(Event{Kind: KindOrder, Exchange: exchange, Symbol: order.Symbol, Order: &order})
+	}
+}
+
+// Depth 最新的深度快照
+func (h *Hub) Depth(exchange, symbol string) (*proto.Depth, bool) {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	d, ok := h.depths[key(exchange, symbol)]
+	return d, ok
+}
+
+// Trade 最新的成交
+func (h *Hub) Trade(exchange, symbol string) (*proto.Trade, bool) {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	t, ok := h.trades[key(exchange, symbol)]
+	return t, ok
+}
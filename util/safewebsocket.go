@@ -2,6 +2,7 @@ package util
 
 import (
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/gorilla/websocket"
@@ -12,15 +13,19 @@ var SafeWebSocketDestroyError = fmt.Errorf("connection destroy by user")
 // SafeWebSocket 安全的WebSocket封装
 // 保证读取和发送操作是并发安全的，支持自定义保持alive函数
 type SafeWebSocket struct {
-	ws               *websocket.Conn
-	listener         SafeWebSocketMessageListener
-	aliveHandler     SafeWebSocketAliveHandler
-	aliveInterval    time.Duration
-	sendQueue        chan []byte
-	lastError        error
-	runningTaskSend  bool
-	runningTaskRead  bool
-	runningTaskAlive bool
+	ws            *websocket.Conn
+	listener      SafeWebSocketMessageListener
+	aliveHandler  SafeWebSocketAliveHandler
+	aliveInterval time.Duration
+	sendQueue     chan []byte
+	lastError     error
+	// done 连接出错或销毁时关闭, 各任务随之退出
+	done chan struct{}
+	// alive KeepAlive 设置后立即执行一次alive函数
+	alive     chan struct{}
+	closeOnce sync.Once
+	destroyed bool
+	mutex     sync.RWMutex
 }
 
 type SafeWebSocketMessageListener = func(b []byte)
@@ -32,83 +37,130 @@ func NewSafeWebSocket(endpoint string) (*SafeWebSocket, error) {
 	if err != nil {
 		return nil, err
 	}
-	s := &SafeWebSocket{ws: ws, sendQueue: make(chan []byte, 1000), aliveInterval: time.Second * 60}
+	s := &SafeWebSocket{
+		ws:            ws,
+		sendQueue:     make(chan []byte, 1000),
+		aliveInterval: time.Second * 60,
+		done:          make(chan struct{}),
+		alive:         make(chan struct{}, 1),
+	}
 
 	go func() {
-		s.runningTaskSend = true
-		for s.lastError == nil {
-			b := <-s.sendQueue
-			if err := s.ws.WriteMessage(websocket.TextMessage, b); err != nil {
-				s.lastError = err
-				break
+		for {
+			select {
+			case <-s.done:
+				return
+			case b := <-s.sendQueue:
+				if err := s.ws.WriteMessage(websocket.TextMessage, b); err != nil {
+					s.fail(err)
+					return
+				}
 			}
 		}
-		s.runningTaskSend = false
 	}()
 
 	go func() {
-		s.runningTaskRead = true
-		for s.lastError == nil {
+		for {
 			_, b, err := s.ws.ReadMessage()
 			if err != nil {
-				s.lastError = err
-				break
+				s.fail(err)
+				return
+			}
+			s.mutex.RLock()
+			listener := s.listener
+			s.mutex.RUnlock()
+			// Listen 之前收到的消息丢弃
+			if listener != nil {
+				listener(b)
 			}
-			s.listener(b)
 		}
-		s.runningTaskRead = false
 	}()
 
 	go func() {
-		s.runningTaskAlive = true
-		for s.lastError == nil {
-			if s.aliveHandler != nil {
-				s.aliveHandler()
+		for {
+			s.mutex.RLock()
+			handler, interval := s.aliveHandler, s.aliveInterval
+			s.mutex.RUnlock()
+			if handler != nil {
+				handler()
+			}
+			select {
+			case <-s.done:
+				return
+			case <-s.alive:
+			case <-time.After(interval):
 			}
-			time.Sleep(s.aliveInterval)
 		}
-		s.runningTaskAlive = false
 	}()
 
 	return s, nil
 }
 
+// fail 记录第一个错误并通知各任务退出
+func (s *SafeWebSocket) fail(err error) {
+	s.mutex.Lock()
+	if s.lastError == nil {
+		s.lastError = err
+	}
+	s.mutex.Unlock()
+	s.closeOnce.Do(func() { close(s.done) })
+}
+
+// Err 连接出错或销毁的原因, 正常时为nil
+func (s *SafeWebSocket) Err() error {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.lastError
+}
+
 // Listen 监听消息
 func (s *SafeWebSocket) Listen(h SafeWebSocketMessageListener) {
+	s.mutex.Lock()
 	s.listener = h
+	s.mutex.Unlock()
 }
 
-// Send 发送消息
+// Send 发送消息, 连接关闭后丢弃
 func (s *SafeWebSocket) Send(b []byte) {
-	s.sendQueue <- b
+	select {
+	case s.sendQueue <- b:
+	case <-s.done:
+	}
 }
 
 // KeepAlive 设置alive周期及函数
 func (s *SafeWebSocket) KeepAlive(v time.Duration, h SafeWebSocketAliveHandler) {
+	s.mutex.Lock()
 	s.aliveInterval = v
 	s.aliveHandler = h
+	s.mutex.Unlock()
+	select {
+	case s.alive <- struct{}{}:
+	default:
+	}
 }
 
-// Destroy 销毁
+// Destroy 销毁, 多次调用只关闭一次连接
 func (s *SafeWebSocket) Destroy() (err error) {
-	s.lastError = SafeWebSocketDestroyError
-	for !s.runningTaskRead && !s.runningTaskSend && !s.runningTaskAlive {
-		time.Sleep(time.Millisecond * 100)
-	}
-	if s.ws != nil {
-		err = s.ws.Close()
-		s.ws = nil
+	s.fail(SafeWebSocketDestroyError)
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.destroyed {
+		return nil
 	}
+	s.destroyed = true
 	s.listener = nil
 	s.aliveHandler = nil
-	s.sendQueue = nil
-	return err
+	return s.ws.Close()
 }
 
 // Loop 进入事件循环，直到连接关闭才退出
 func (s *SafeWebSocket) Loop() error {
-	for s.lastError == nil {
-		time.Sleep(time.Millisecond * 100)
-	}
-	return s.lastError
+	<-s.done
+	return s.Err()
+}
+
+// Recorder 消息记录器, raw 为收到的原始数据, msg 为解压后的数据
+type Recorder interface {
+	Record(source string, raw, msg []byte)
 }
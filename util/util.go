@@ -13,6 +13,8 @@ import (
 	"sort"
 	"strings"
 	"time"
+
+	"github.com/gpmn/sheep/metrics"
 )
 
 func MD5(input []byte) []byte {
@@ -90,6 +92,11 @@ func ComputeHmacMd5(strMessage, strSecret string) string {
 // strParams: string类型的请求参数, user=lxz&pwd=lxz
 // return: 请求结果
 func HttpGetRequest(strUrl string, mapParams map[string]string) (string, error) {
+	return HttpGetRequestWithHeader(strUrl, mapParams, nil)
+}
+
+// HttpGetRequestWithHeader : 同 HttpGetRequest, headerParams 添加到请求的Http Header中
+func HttpGetRequestWithHeader(strUrl string, mapParams, headerParams map[string]string) (string, error) {
 	httpClient := &http.Client{}
 
 	var strRequestUrl string
@@ -106,9 +113,14 @@ func HttpGetRequest(strUrl string, mapParams map[string]string) (string, error)
 		return "", err
 	}
 	request.Header.Add("User-Agent", "Mozilla/5.0 (Windows NT 6.1; WOW64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/39.0.2171.71 Safari/537.36")
+	for k, v := range headerParams {
+		request.Header.Add(k, v)
+	}
 
 	// 发出请求
+	start := time.Now()
 	response, err := httpClient.Do(request)
+	observe(request, response, err, start)
 	if nil != err {
 		return "", err
 	}
@@ -147,7 +159,9 @@ func HttpPostRequest(strUrl string, mapParams, headerParams map[string]string) (
 		request.Header.Add(k, v)
 	}
 
+	start := time.Now()
 	response, err := httpClient.Do(request)
+	observe(request, response, err, start)
 	if nil != err {
 		return "", err
 	}
@@ -161,6 +175,15 @@ func HttpPostRequest(strUrl string, mapParams, headerParams map[string]string) (
 	return string(body), nil
 }
 
+// observe 记录请求指标, 交易所按主机名判断
+func observe(request *http.Request, response *http.Response, err error, start time.Time) {
+	status := 0
+	if response != nil {
+		status = response.StatusCode
+	}
+	metrics.ObserveHTTP("", request.URL, status, err, time.Since(start))
+}
+
 // 对Map的值进行URI编码
 // mapParams: 需要进行URI编码的map
 // return: 编码后的map